@@ -0,0 +1,2024 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/smx509"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/sm2-cosign/backend/internal/config"
+	"github.com/sm2-cosign/backend/internal/crypto"
+	"github.com/sm2-cosign/backend/internal/keystore"
+	"github.com/sm2-cosign/backend/internal/model"
+	"github.com/sm2-cosign/backend/internal/repository"
+	"github.com/sm2-cosign/backend/internal/service"
+	"github.com/sm2-cosign/backend/pkg/client"
+	"github.com/sm2-cosign/backend/pkg/response"
+)
+
+// testBatchMaxSize 测试服务的批量签名条目上限
+const testBatchMaxSize = 64
+
+var (
+	// baseURL 测试服务地址，由 TestMain 启动
+	baseURL string
+	// userSeq 保证重复运行 (-count) 时用户名不冲突
+	userSeq atomic.Int64
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(runTestServer(m))
+}
+
+// runTestServer 基于内存 SQLite 启动完整服务并运行测试
+func runTestServer(m *testing.M) int {
+	config.AppConfig = &config.Config{
+		Database: config.DatabaseConfig{
+			Driver:      repository.DriverSQLite,
+			Path:        repository.MemoryPath,
+			AutoMigrate: true,
+			BusyTimeout: 5 * time.Second,
+			JournalMode: "MEMORY",
+			Synchronous: "OFF",
+		},
+		Auth: config.AuthConfig{TokenExpire: time.Hour},
+		Cosign: config.CosignConfig{
+			NonceMode:    string(crypto.DefaultNonceMode),
+			BatchMaxSize: testBatchMaxSize,
+			BatchWorkers: 4,
+		},
+		Cache: config.CacheConfig{
+			SessionSize: 100,
+			SessionTTL:  time.Minute,
+			KeySize:     100,
+			KeyTTL:      time.Minute,
+		},
+	}
+	if err := initDatabase(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer repository.CloseDB()
+	if err := service.InitAdminUser(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	app := newApp()
+	go app.Listener(ln)
+	defer app.Shutdown()
+
+	baseURL = "http://" + ln.Addr().String()
+	return m.Run()
+}
+
+// testUser 已注册并登录的测试用户
+type testUser struct {
+	*client.Client
+	username string
+	ks       *client.KeyShare
+}
+
+func newTestUser(t *testing.T) *testUser {
+	t.Helper()
+	ctx := context.Background()
+	username := fmt.Sprintf("user-%d", userSeq.Add(1))
+	password := "password-123"
+
+	c := client.New(baseURL)
+	_, ks, err := c.Register(ctx, username, password)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := c.Login(ctx, username, password); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	return &testUser{Client: c, username: username, ks: ks}
+}
+
+// loginAdmin 以默认管理员登录，用于调用须管理员认证的管理接口
+func loginAdmin(t *testing.T) *testUser {
+	t.Helper()
+	c := client.New(baseURL)
+	if err := c.Login(context.Background(), service.DefaultAdminUsername, service.DefaultAdminPassword); err != nil {
+		t.Fatalf("admin login: %v", err)
+	}
+	return &testUser{Client: c, username: service.DefaultAdminUsername}
+}
+
+// call 直接调用接口，返回业务错误码与数据，用于构造篡改后的请求
+func (u *testUser) call(t *testing.T, method, path string, body interface{}, out interface{}) response.Code {
+	t.Helper()
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token := u.Token(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Code response.Code   `json:"code"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	if result.Code == response.CodeSuccess && out != nil {
+		if err := json.Unmarshal(result.Data, out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return result.Code
+}
+
+// serverPublicKey 读取服务端保存的协同公钥 Pa
+func (u *testUser) serverPublicKey(t *testing.T) *ecdsa.PublicKey {
+	t.Helper()
+	var info struct {
+		PublicKey string `json:"publicKey"`
+	}
+	if code := u.call(t, http.MethodGet, "/api/user/info", nil, &info); code != response.CodeSuccess {
+		t.Fatalf("user info: code %d", code)
+	}
+	pa, err := base64.StdEncoding.DecodeString(info.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return toPublicKey(t, pa)
+}
+
+type signResponse struct {
+	R  string `json:"r"`
+	S2 string `json:"s2"`
+	S3 string `json:"s3"`
+}
+
+func (r *signResponse) decode(t *testing.T) (rb, s2, s3 []byte) {
+	t.Helper()
+	var err error
+	if rb, err = base64.StdEncoding.DecodeString(r.R); err != nil {
+		t.Fatal(err)
+	}
+	if s2, err = base64.StdEncoding.DecodeString(r.S2); err != nil {
+		t.Fatal(err)
+	}
+	if s3, err = base64.StdEncoding.DecodeString(r.S3); err != nil {
+		t.Fatal(err)
+	}
+	return rb, s2, s3
+}
+
+func encode(b []byte) string {
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// signRequest 构造带 Q1 知识证明的签名请求
+func (u *testUser) signRequest(t *testing.T, session *client.SignSession) map[string]string {
+	t.Helper()
+	proof, err := session.ProveQ1(u.UserID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]string{
+		"q1":      encode(session.Q1()),
+		"e":       encode(session.E()),
+		"q1Proof": encode(proof),
+	}
+}
+
+func TestCooperativeProtocol(t *testing.T) {
+	ctx := context.Background()
+	u := newTestUser(t)
+	msg := []byte("end-to-end cooperative signature")
+
+	t.Run("sign", func(t *testing.T) {
+		pub := u.serverPublicKey(t)
+		e, err := u.ks.Digest(msg, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		session, err := u.ks.NewSignSession(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resp signResponse
+		if code := u.call(t, http.MethodPost, "/api/sign", u.signRequest(t, session), &resp); code != response.CodeSuccess {
+			t.Fatalf("sign: code %d", code)
+		}
+		r, s, err := session.Finish(resp.decode(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sm2.Verify(pub, e, r, s) {
+			t.Fatal("signature does not verify against Pa")
+		}
+	})
+
+	t.Run("key-init", func(t *testing.T) {
+		old := u.ks
+		ks, err := u.KeyInit(ctx)
+		if err != nil {
+			t.Fatalf("key init: %v", err)
+		}
+		u.ks = ks
+		if !u.ks.PublicKey().Equal(u.serverPublicKey(t)) {
+			t.Fatal("server Pa does not match client key")
+		}
+
+		// 旧密钥分量与新的服务端分量无法合成有效签名
+		e, _ := old.Digest(msg, nil)
+		session, _ := old.NewSignSession(e)
+		var resp signResponse
+		if code := u.call(t, http.MethodPost, "/api/sign", u.signRequest(t, session), &resp); code != response.CodeSuccess {
+			t.Fatalf("sign: code %d", code)
+		}
+		if _, _, err := session.Finish(resp.decode(t)); !errors.Is(err, client.ErrInvalidSignature) {
+			t.Errorf("stale key share: got %v, want %v", err, client.ErrInvalidSignature)
+		}
+	})
+
+	t.Run("sign-client", func(t *testing.T) {
+		for i := 0; i < 8; i++ {
+			sig, err := u.Sign(ctx, u.ks, msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sm2.VerifyASN1WithSM2(u.serverPublicKey(t), nil, msg, sig) {
+				t.Fatal("signature does not verify against Pa")
+			}
+			if sm2.VerifyASN1WithSM2(u.serverPublicKey(t), nil, []byte("other message"), sig) {
+				t.Fatal("signature verifies for a different message")
+			}
+		}
+	})
+
+	t.Run("decrypt", func(t *testing.T) {
+		pub := u.serverPublicKey(t)
+		plaintext := []byte("end-to-end cooperative decryption")
+
+		plain, err := sm2.Encrypt(rand.Reader, pub, plaintext, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		asn1, err := sm2.EncryptASN1(rand.Reader, pub, plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c1c2c3, err := sm2.Encrypt(rand.Reader, pub, plaintext, sm2.NewPlainEncrypterOpts(sm2.MarshalUncompressed, sm2.C1C2C3))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, ciphertext := range map[string][]byte{"c1c3c2": plain, "asn1": asn1} {
+			got, err := u.Decrypt(ctx, u.ks, ciphertext)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Fatalf("%s: plaintext = %q, want %q", name, got, plaintext)
+			}
+		}
+		for format, ciphertext := range map[client.CiphertextFormat][]byte{
+			client.CiphertextAuto:   plain,
+			client.CiphertextC1C3C2: plain,
+			client.CiphertextC1C2C3: c1c2c3,
+			client.CiphertextASN1:   asn1,
+		} {
+			got, err := u.DecryptCiphertext(ctx, u.ks, ciphertext, format)
+			if err != nil {
+				t.Fatalf("ciphertext %q: %v", format, err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Fatalf("ciphertext %q: plaintext = %q, want %q", format, got, plaintext)
+			}
+		}
+	})
+}
+
+func TestSignRejectsTamperedInput(t *testing.T) {
+	u := newTestUser(t)
+	e, err := u.ks.Digest([]byte("message"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := u.ks.NewSignSession(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := u.signRequest(t, session)
+
+	offCurve := append([]byte(nil), session.Q1()...)
+	offCurve[len(offCurve)-1] ^= 1
+	otherE := crypto.SM3Hash([]byte("other message"))
+
+	tests := []struct {
+		name  string
+		field string
+		value string
+		want  response.Code
+	}{
+		{"q1-off-curve", "q1", encode(offCurve), response.CodeInvalidParam},
+		{"q1-infinity", "q1", encode(make([]byte, crypto.PointSize)), response.CodeInvalidParam},
+		{"q1-short", "q1", encode(session.Q1()[1:]), response.CodeInvalidParam},
+		{"e-short", "e", encode(e[1:]), response.CodeInvalidParam},
+		{"e-mismatch", "e", encode(otherE), response.CodeInvalidProof},
+		{"q1-proof", "q1Proof", encode(make([]byte, crypto.ProofSize)), response.CodeInvalidProof},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := make(map[string]string, len(valid))
+			for k, v := range valid {
+				req[k] = v
+			}
+			req[tt.field] = tt.value
+			if code := u.call(t, http.MethodPost, "/api/sign", req, nil); code != tt.want {
+				t.Errorf("code = %d, want %d", code, tt.want)
+			}
+		})
+	}
+
+	t.Run("tampered-response", func(t *testing.T) {
+		var resp signResponse
+		if code := u.call(t, http.MethodPost, "/api/sign", valid, &resp); code != response.CodeSuccess {
+			t.Fatalf("sign: code %d", code)
+		}
+		r, s2, s3 := resp.decode(t)
+		s2[len(s2)-1] ^= 1
+		if _, _, err := session.Finish(r, s2, s3); !errors.Is(err, client.ErrInvalidSignature) {
+			t.Errorf("got %v, want %v", err, client.ErrInvalidSignature)
+		}
+	})
+
+	t.Run("tampered-signature", func(t *testing.T) {
+		var resp signResponse
+		if code := u.call(t, http.MethodPost, "/api/sign", valid, &resp); code != response.CodeSuccess {
+			t.Fatalf("sign: code %d", code)
+		}
+		r, s, err := session.Finish(resp.decode(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub := u.serverPublicKey(t)
+		if sm2.Verify(pub, otherE, r, s) {
+			t.Error("signature verifies for a different digest")
+		}
+		s.Add(s, big.NewInt(1))
+		if sm2.Verify(pub, e, r, s) {
+			t.Error("tampered signature verifies")
+		}
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		anon := &testUser{Client: client.New(baseURL)}
+		if code := anon.call(t, http.MethodPost, "/api/sign", valid, nil); code != response.CodeUnauthorized {
+			t.Errorf("code = %d, want %d", code, response.CodeUnauthorized)
+		}
+		anon.SetToken("invalid-token", u.UserID())
+		if code := anon.call(t, http.MethodPost, "/api/sign", valid, nil); code != response.CodeTokenInvalid {
+			t.Errorf("code = %d, want %d", code, response.CodeTokenInvalid)
+		}
+	})
+}
+
+func TestDecryptRejectsTamperedInput(t *testing.T) {
+	ctx := context.Background()
+	u := newTestUser(t)
+	pub := u.serverPublicKey(t)
+	plaintext := []byte("tamper detection")
+
+	ciphertext, err := sm2.Encrypt(rand.Reader, pub, plaintext, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("c3", func(t *testing.T) {
+		tampered := append([]byte(nil), ciphertext...)
+		tampered[1+crypto.PointSize] ^= 1
+		if _, err := u.Decrypt(ctx, u.ks, tampered); !errors.Is(err, client.ErrDecryption) {
+			t.Errorf("got %v, want %v", err, client.ErrDecryption)
+		}
+	})
+
+	t.Run("c2", func(t *testing.T) {
+		tampered := append([]byte(nil), ciphertext...)
+		tampered[len(tampered)-1] ^= 1
+		if _, err := u.Decrypt(ctx, u.ks, tampered); !errors.Is(err, client.ErrDecryption) {
+			t.Errorf("got %v, want %v", err, client.ErrDecryption)
+		}
+	})
+
+	t.Run("other-key", func(t *testing.T) {
+		other, err := sm2.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		foreign, err := sm2.Encrypt(rand.Reader, &other.PublicKey, plaintext, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := u.Decrypt(ctx, u.ks, foreign); !errors.Is(err, client.ErrDecryption) {
+			t.Errorf("got %v, want %v", err, client.ErrDecryption)
+		}
+	})
+
+	t.Run("ciphertext", func(t *testing.T) {
+		session, err := u.ks.NewDecryptSession(ciphertext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		offCurve := append([]byte(nil), ciphertext...)
+		offCurve[crypto.PointSize] ^= 1
+		tests := []struct {
+			name string
+			req  map[string]string
+		}{
+			{"empty", map[string]string{}},
+			{"both", map[string]string{"t1": encode(session.T1()), "ciphertext": encode(ciphertext)}},
+			{"off-curve", map[string]string{"ciphertext": encode(offCurve)}},
+			{"truncated", map[string]string{"ciphertext": encode(ciphertext[:1+crypto.PointSize+32])}},
+			{"wrong-format", map[string]string{"ciphertext": encode(ciphertext), "format": "asn1"}},
+			{"unknown-format", map[string]string{"ciphertext": encode(ciphertext), "format": "c2c1c3"}},
+		}
+		for _, tt := range tests {
+			if code := u.call(t, http.MethodPost, "/api/decrypt", tt.req, nil); code != response.CodeInvalidParam {
+				t.Errorf("%s: code = %d, want %d", tt.name, code, response.CodeInvalidParam)
+			}
+		}
+
+		// 篡改 C2 不影响服务端运算，由客户端 C3 校验发现
+		tampered := append([]byte(nil), ciphertext...)
+		tampered[len(tampered)-1] ^= 1
+		if _, err := u.DecryptCiphertext(ctx, u.ks, tampered, client.CiphertextC1C3C2); !errors.Is(err, client.ErrDecryption) {
+			t.Errorf("got %v, want %v", err, client.ErrDecryption)
+		}
+	})
+
+	t.Run("t1", func(t *testing.T) {
+		session, err := u.ks.NewDecryptSession(ciphertext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		offCurve := append([]byte(nil), session.T1()...)
+		offCurve[len(offCurve)-1] ^= 1
+		for name, t1 := range map[string][]byte{
+			"off-curve": offCurve,
+			"infinity":  make([]byte, crypto.PointSize),
+			"short":     session.T1()[1:],
+		} {
+			if code := u.call(t, http.MethodPost, "/api/decrypt", map[string]string{"t1": encode(t1)}, nil); code != response.CodeInvalidParam {
+				t.Errorf("%s: code = %d, want %d", name, code, response.CodeInvalidParam)
+			}
+		}
+	})
+}
+
+func TestCooperativeKeyExchange(t *testing.T) {
+	const keyLen = 32
+	ctx := context.Background()
+	u := newTestUser(t)
+	uid, peerUID := []byte("alice"), []byte("bob")
+	peerKey, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer, err := sm2.NewKeyExchange(peerKey, u.serverPublicKey(t), peerUID, uid, keyLen, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := u.ks.NewKeyExchangeSession(uid, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rA, err := u.KeyExchangeInit(ctx, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peerR, sB, err := peer.RepondKeyExchange(rand.Reader, toPublicKey(t, rA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rB := make([]byte, crypto.PointSize)
+	peerR.X.FillBytes(rB[:crypto.ScalarSize])
+	peerR.Y.FillBytes(rB[crypto.ScalarSize:])
+	if err := u.KeyExchange(ctx, session, &peerKey.PublicKey, peerUID, rB); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := session.VerifyConfirmation(sB); err != nil {
+		t.Fatal(err)
+	}
+	sA, _ := session.Confirmation()
+	peerShared, err := peer.ConfirmInitiator(sA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := session.Key(keyLen)
+	if !bytes.Equal(key, peerShared) {
+		t.Fatalf("shared key mismatch: %x != %x", key, peerShared)
+	}
+	// 两步均记录审计日志：第一步的 d2Inv*K1 与解密运算相同
+	if got := auditCount(t, model.ActionKeyExchange, u.UserID()); got != 2 {
+		t.Errorf("key exchange audit entries: got %d, want 2", got)
+	}
+
+	offCurve := append([]byte(nil), rA...)
+	offCurve[len(offCurve)-1] ^= 1
+	for _, path := range []string{"/api/keyexchange/init", "/api/keyexchange/compute"} {
+		for name, p := range map[string][]byte{
+			"off-curve": offCurve,
+			"infinity":  make([]byte, crypto.PointSize),
+			"short":     rA[1:],
+		} {
+			req := map[string]string{"k1": encode(p), "w": encode(p)}
+			if code := u.call(t, http.MethodPost, path, req, nil); code != response.CodeInvalidParam {
+				t.Errorf("%s %s: code = %d, want %d", path, name, code, response.CodeInvalidParam)
+			}
+		}
+	}
+}
+
+func toPublicKey(t *testing.T, p []byte) *ecdsa.PublicKey {
+	t.Helper()
+	pub, err := sm2.NewPublicKey(append([]byte{0x04}, p...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub
+}
+
+// signAuditCount 查询用户的签名审计日志条数
+func signAuditCount(t *testing.T, userID string) int64 {
+	t.Helper()
+	return auditCount(t, model.ActionSign, userID)
+}
+
+// TestAuditFailure 审计日志写入失败时签名、解密与密钥交换均不返回结果
+func TestAuditFailure(t *testing.T) {
+	u := newTestUser(t)
+	point, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := make([]byte, crypto.PointSize)
+	point.X.FillBytes(p[:crypto.ScalarSize])
+	point.Y.FillBytes(p[crypto.ScalarSize:])
+	requests := []struct {
+		name string
+		call func() response.Code
+	}{
+		{"sign", func() response.Code { return u.signCode(t) }},
+		{"decrypt", func() response.Code {
+			return u.call(t, http.MethodPost, "/api/decrypt", map[string]string{"t1": encode(p)}, nil)
+		}},
+		{"key exchange init", func() response.Code {
+			return u.call(t, http.MethodPost, "/api/keyexchange/init", map[string]string{"k1": encode(p)}, nil)
+		}},
+		{"key exchange", func() response.Code {
+			return u.call(t, http.MethodPost, "/api/keyexchange/compute", map[string]string{"w": encode(p)}, nil)
+		}},
+	}
+	for _, req := range requests {
+		if code := req.call(); code != response.CodeSuccess {
+			t.Fatalf("%s: code %d", req.name, code)
+		}
+	}
+
+	db := repository.GetDB()
+	if _, err := db.Exec("ALTER TABLE audit_logs RENAME TO audit_logs_unavailable"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := db.Exec("ALTER TABLE audit_logs_unavailable RENAME TO audit_logs"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	for _, req := range requests {
+		if code := req.call(); code != response.CodeDBError {
+			t.Errorf("%s without audit log: got code %d, want %d", req.name, code, response.CodeDBError)
+		}
+	}
+}
+
+func TestSignBatch(t *testing.T) {
+	ctx := context.Background()
+	u := newTestUser(t)
+	pub := u.serverPublicKey(t)
+
+	digests := make([][]byte, testBatchMaxSize)
+	for i := range digests {
+		e, err := u.ks.Digest([]byte(fmt.Sprintf("document %d", i)), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		digests[i] = e
+	}
+
+	before := signAuditCount(t, u.UserID())
+	results, err := u.SignDigests(ctx, u.ks, digests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, result := range results {
+		if result.Err != nil {
+			t.Fatalf("item %d: %v", i, result.Err)
+		}
+		if !sm2.VerifyASN1(pub, digests[i], result.Signature) {
+			t.Fatalf("item %d: signature does not verify against Pa", i)
+		}
+	}
+	if got := signAuditCount(t, u.UserID()) - before; got != int64(len(digests)) {
+		t.Errorf("audit rows = %d, want %d", got, len(digests))
+	}
+
+	t.Run("per-item-errors", func(t *testing.T) {
+		session, err := u.ks.NewSignSession(digests[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		valid := u.signRequest(t, session)
+		items := []map[string]string{
+			valid,
+			{"q1": encode(make([]byte, crypto.PointSize)), "e": valid["e"]},
+			{"q1": valid["q1"], "e": encode(digests[0][1:])},
+			{"q1": valid["q1"], "e": valid["e"], "q1Proof": encode(make([]byte, crypto.ProofSize))},
+		}
+		want := []response.Code{response.CodeSuccess, response.CodeInvalidParam, response.CodeInvalidParam, response.CodeInvalidProof}
+
+		var resp struct {
+			Items []struct {
+				Code response.Code `json:"code"`
+				R    string        `json:"r"`
+				S2   string        `json:"s2"`
+				S3   string        `json:"s3"`
+			} `json:"items"`
+			Succeeded int `json:"succeeded"`
+		}
+		before := signAuditCount(t, u.UserID())
+		if code := u.call(t, http.MethodPost, "/api/sign/batch", map[string]interface{}{"items": items}, &resp); code != response.CodeSuccess {
+			t.Fatalf("sign batch: code %d", code)
+		}
+		if len(resp.Items) != len(want) || resp.Succeeded != 1 {
+			t.Fatalf("got %d items, %d succeeded", len(resp.Items), resp.Succeeded)
+		}
+		for i, item := range resp.Items {
+			if item.Code != want[i] {
+				t.Errorf("item %d: code = %d, want %d", i, item.Code, want[i])
+			}
+		}
+		item := resp.Items[0]
+		sig := signResponse{R: item.R, S2: item.S2, S3: item.S3}
+		r, s, err := session.Finish(sig.decode(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sm2.Verify(pub, digests[0], r, s) {
+			t.Error("signature does not verify against Pa")
+		}
+		if got := signAuditCount(t, u.UserID()) - before; got != 1 {
+			t.Errorf("audit rows = %d, want 1", got)
+		}
+	})
+
+	t.Run("size", func(t *testing.T) {
+		for _, n := range []int{0, testBatchMaxSize + 1} {
+			items := make([]map[string]string, n)
+			for i := range items {
+				items[i] = map[string]string{}
+			}
+			if code := u.call(t, http.MethodPost, "/api/sign/batch", map[string]interface{}{"items": items}, nil); code != response.CodeInvalidParam {
+				t.Errorf("%d items: code = %d, want %d", n, code, response.CodeInvalidParam)
+			}
+		}
+	})
+}
+
+// signCode 发起一次协同签名，返回业务错误码
+func (u *testUser) signCode(t *testing.T) response.Code {
+	t.Helper()
+	e, err := u.ks.Digest([]byte("cached message"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := u.ks.NewSignSession(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.call(t, http.MethodPost, "/api/sign", u.signRequest(t, session), nil)
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	t.Run("logout", func(t *testing.T) {
+		u := newTestUser(t)
+		if code := u.signCode(t); code != response.CodeSuccess {
+			t.Fatalf("sign: code %d", code)
+		}
+		token := u.Token()
+		if err := u.Logout(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		u.SetToken(token, u.UserID())
+		if code := u.signCode(t); code != response.CodeTokenInvalid {
+			t.Errorf("sign after logout: got code %d, want %d", code, response.CodeTokenInvalid)
+		}
+	})
+
+	t.Run("delete-key", func(t *testing.T) {
+		u := newTestUser(t)
+		if code := u.signCode(t); code != response.CodeSuccess {
+			t.Fatalf("sign: code %d", code)
+		}
+		key, err := repository.NewKeyRepository().FindByUserID(u.UserID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code := u.call(t, http.MethodDelete, "/mapi/keys/"+key.ID, nil, nil); code != response.CodeSuccess {
+			t.Fatalf("delete key: code %d", code)
+		}
+		if code := u.signCode(t); code != response.CodeKeyNotFound {
+			t.Errorf("sign after key deletion: got code %d, want %d", code, response.CodeKeyNotFound)
+		}
+	})
+
+	t.Run("delete-user", func(t *testing.T) {
+		u := newTestUser(t)
+		if code := u.signCode(t); code != response.CodeSuccess {
+			t.Fatalf("sign: code %d", code)
+		}
+		if code := u.call(t, http.MethodDelete, "/mapi/users/"+u.UserID(), nil, nil); code != response.CodeSuccess {
+			t.Fatalf("delete user: code %d", code)
+		}
+		if code := u.signCode(t); code != response.CodeTokenInvalid {
+			t.Errorf("sign after user deletion: got code %d, want %d", code, response.CodeTokenInvalid)
+		}
+	})
+
+	t.Run("disable-user", func(t *testing.T) {
+		u := newTestUser(t)
+		if code := u.signCode(t); code != response.CodeSuccess {
+			t.Fatalf("sign: code %d", code)
+		}
+		admin := loginAdmin(t)
+		path := "/mapi/users/" + u.UserID() + "/status"
+		if code := admin.call(t, http.MethodPut, path, map[string]int{"status": model.UserStatusDisabled}, nil); code != response.CodeSuccess {
+			t.Fatalf("disable user: code %d", code)
+		}
+		if code := u.signCode(t); code != response.CodeTokenInvalid {
+			t.Errorf("sign after disabling user: got code %d, want %d", code, response.CodeTokenInvalid)
+		}
+		if code := u.call(t, http.MethodGet, "/api/keys", nil, nil); code != response.CodeTokenInvalid {
+			t.Errorf("list keys after disabling user: got code %d, want %d", code, response.CodeTokenInvalid)
+		}
+
+		// 重新启用后旧 token 仍无效，须重新登录
+		if code := admin.call(t, http.MethodPut, path, map[string]int{"status": model.UserStatusEnabled}, nil); code != response.CodeSuccess {
+			t.Fatalf("enable user: code %d", code)
+		}
+		if code := u.signCode(t); code != response.CodeTokenInvalid {
+			t.Errorf("sign with the pre-disable token: got code %d, want %d", code, response.CodeTokenInvalid)
+		}
+		if err := u.Login(context.Background(), u.username, "password-123"); err != nil {
+			t.Fatal(err)
+		}
+		if code := u.signCode(t); code != response.CodeSuccess {
+			t.Errorf("sign after re-login: code %d", code)
+		}
+	})
+}
+
+func TestKeyRecordFormat(t *testing.T) {
+	ctx := context.Background()
+	keyRepo := repository.NewKeyRepository()
+
+	// loadKey 读取用户的密钥记录，并返回按旧格式存储时的 d2
+	loadKey := func(t *testing.T, u *testUser) (*model.Key, string) {
+		t.Helper()
+		key, err := keyRepo.FindByUserID(u.UserID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		d2Inv, err := crypto.DecodeSecretBase64(key.D2Inv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d2, err := crypto.DeriveD2Inv(d2Inv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key, encode(d2)
+	}
+
+	u := newTestUser(t)
+	key, d2 := loadKey(t, u)
+	if key.D2 != "" || key.D2Inv == "" {
+		t.Fatalf("new key record stores d2 = %q, d2_inv = %q", key.D2, key.D2Inv)
+	}
+
+	// 仅存储 d2 的旧记录由 d2 推导 d2Inv
+	legacy := newTestUser(t)
+	legacyKey, legacyD2 := loadKey(t, legacy)
+	legacyKey.D2, legacyKey.D2Inv = legacyD2, ""
+	if err := keyRepo.Update(legacyKey); err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("legacy key record")
+	sig, err := legacy.Sign(ctx, legacy.ks, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sm2.VerifyASN1WithSM2(legacy.ks.PublicKey(), nil, msg, sig) {
+		t.Fatal("signature with legacy key record does not verify")
+	}
+
+	// 迁移清除同时存储 d2 与 d2_inv 的记录中的 d2，只存 d2 的记录保持不变
+	key.D2 = d2
+	if err := keyRepo.Update(key); err != nil {
+		t.Fatal(err)
+	}
+	// 回滚到版本 1 再重新执行后续迁移
+	current, err := repository.SchemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repository.MigrateDown(current - 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repository.MigrateUp(0); err != nil {
+		t.Fatal(err)
+	}
+	if key, _ := keyRepo.FindByID(key.ID); key.D2 != "" {
+		t.Error("migration left d2 in a record that has d2_inv")
+	}
+	if key, _ := keyRepo.FindByID(legacyKey.ID); key.D2 != legacyD2 {
+		t.Error("migration cleared d2 in a record without d2_inv")
+	}
+}
+
+// shareExists 检查密钥记录引用的私钥分量是否仍在后端中
+func shareExists(t *testing.T, key *model.Key) bool {
+	t.Helper()
+	store, err := keystore.Get(key.Store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	share, err := store.Open(key)
+	if errors.Is(err, keystore.ErrKeyNotFound) {
+		return false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer share.Close()
+	// HSM 后端的句柄在运算时才访问分量，以协同公钥作为任意合法点
+	pa, err := crypto.DecodeFromBase64(key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = share.KeyExchangeInit(pa)
+	if errors.Is(err, keystore.ErrKeyNotFound) {
+		return false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return true
+}
+
+func TestKeyStoreBackends(t *testing.T) {
+	ctx := context.Background()
+	keyRepo := repository.NewKeyRepository()
+	t.Cleanup(func() {
+		if err := keystore.Init(config.KeyStoreConfig{}, ""); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	masterKey := "000102030405060708090a0b0c0d0e0f"
+	for _, cfg := range []config.KeyStoreConfig{
+		{Backend: keystore.BackendFile, Dir: t.TempDir()},
+		{Backend: keystore.BackendSoftHSM, AllowSoftHSM: true},
+	} {
+		t.Run(cfg.Backend, func(t *testing.T) {
+			if err := keystore.Init(cfg, masterKey); err != nil {
+				t.Fatal(err)
+			}
+			u := newTestUser(t)
+			key, err := keyRepo.FindByUserID(u.UserID())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key.Store != cfg.Backend {
+				t.Fatalf("key record store %q, want %q", key.Store, cfg.Backend)
+			}
+			if _, err := crypto.DecodeSecretBase64(key.D2Inv); err == nil {
+				t.Fatal("key record holds key material outside the db backend")
+			}
+
+			msg := []byte("key store " + cfg.Backend)
+			sig, err := u.Sign(ctx, u.ks, msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sm2.VerifyASN1WithSM2(u.ks.PublicKey(), nil, msg, sig) {
+				t.Fatal("signature does not verify")
+			}
+			ciphertext, err := sm2.Encrypt(rand.Reader, u.ks.PublicKey(), msg, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			plaintext, err := u.DecryptCiphertext(ctx, u.ks, ciphertext, client.CiphertextAuto)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(plaintext, msg) {
+				t.Fatalf("decrypted %q, want %q", plaintext, msg)
+			}
+
+			// 重新生成密钥后旧密钥归档，分量保留
+			ks, err := u.KeyInit(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u.ks = ks
+			if !shareExists(t, key) {
+				t.Error("replaced key share deleted")
+			}
+			if archived, err := keyRepo.FindByID(key.ID); err != nil || !archived.IsArchived() {
+				t.Errorf("replaced key not archived: %v", err)
+			}
+			key, err = keyRepo.FindByUserID(u.UserID())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// 切换回 db 后端后，已有密钥仍由原后端完成运算
+			if err := keystore.Init(config.KeyStoreConfig{}, ""); err != nil {
+				t.Fatal(err)
+			}
+			if code := u.signCode(t); code != response.CodeSuccess {
+				t.Fatalf("sign after switching backend: code %d", code)
+			}
+
+			// 归档用户时保留分量，清除用户时删除分量
+			if code := u.call(t, http.MethodDelete, "/mapi/users/"+u.UserID(), nil, nil); code != response.CodeSuccess {
+				t.Fatalf("archive user: code %d", code)
+			}
+			if !shareExists(t, key) {
+				t.Error("key share of an archived user was deleted")
+			}
+			if code := loginAdmin(t).call(t, http.MethodDelete, "/mapi/users/"+u.UserID()+"/purge", nil, nil); code != response.CodeSuccess {
+				t.Fatalf("purge user: code %d", code)
+			}
+			if shareExists(t, key) {
+				t.Error("key share of a purged user still exists")
+			}
+		})
+	}
+}
+
+// auditCount 查询用户指定操作的审计日志条数
+func auditCount(t *testing.T, action, userID string) int64 {
+	t.Helper()
+	_, total, err := repository.NewAuditLogRepository().List(1, 1, action, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return total
+}
+
+func TestKeyRefresh(t *testing.T) {
+	ctx := context.Background()
+	keyRepo := repository.NewKeyRepository()
+	t.Cleanup(func() {
+		if err := keystore.Init(config.KeyStoreConfig{}, ""); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	masterKey := "000102030405060708090a0b0c0d0e0f"
+	for _, cfg := range []config.KeyStoreConfig{
+		{Backend: keystore.BackendDB},
+		{Backend: keystore.BackendFile, Dir: t.TempDir()},
+		{Backend: keystore.BackendSoftHSM, AllowSoftHSM: true},
+	} {
+		t.Run(cfg.Backend, func(t *testing.T) {
+			if err := keystore.Init(cfg, masterKey); err != nil {
+				t.Fatal(err)
+			}
+			u := newTestUser(t)
+			pa := u.ks.PublicKeyBytes()
+			old, err := keyRepo.FindByUserID(u.UserID())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			refresh, err := u.PrepareKeyRefresh(ctx, u.ks)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// 提交前原分量保持可用
+			if code := u.signCode(t); code != response.CodeSuccess {
+				t.Fatalf("sign before commit: code %d", code)
+			}
+
+			if err := u.CommitKeyRefresh(ctx, refresh); err != nil {
+				t.Fatal(err)
+			}
+
+			key, err := keyRepo.FindByUserID(u.UserID())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key.PublicKey != old.PublicKey || !bytes.Equal(refresh.Share.PublicKeyBytes(), pa) {
+				t.Fatal("refresh changed the public key")
+			}
+			if key.D2Inv == old.D2Inv || key.PendingD2Inv != "" {
+				t.Fatalf("key record after commit: d2_inv unchanged = %v, pending = %q", key.D2Inv == old.D2Inv, key.PendingD2Inv)
+			}
+			if cfg.Backend != keystore.BackendDB && shareExists(t, old) {
+				t.Error("replaced key share still exists")
+			}
+
+			// 原客户端分量不再可用，新分量可以签名与解密
+			if _, err := u.Sign(ctx, u.ks, []byte("stale share")); err == nil {
+				t.Error("sign with the replaced client share succeeded")
+			}
+			u.ks = refresh.Share
+			msg := []byte("refreshed " + cfg.Backend)
+			sig, err := u.Sign(ctx, u.ks, msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sm2.VerifyASN1WithSM2(u.ks.PublicKey(), nil, msg, sig) {
+				t.Fatal("signature with refreshed shares does not verify")
+			}
+			ciphertext, err := sm2.Encrypt(rand.Reader, u.ks.PublicKey(), msg, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			plaintext, err := u.DecryptCiphertext(ctx, u.ks, ciphertext, client.CiphertextAuto)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(plaintext, msg) {
+				t.Fatalf("decrypted %q, want %q", plaintext, msg)
+			}
+		})
+	}
+}
+
+// writeRecoveryKey 生成 SM2 恢复密钥对，公钥写入临时文件，返回公钥文件路径与私钥
+// saveConfig 保存当前配置，测试结束时恢复；
+// 依赖配置的清理函数（如重新加载 CA）须在此之前注册，才能看到恢复后的配置
+func saveConfig(t *testing.T) {
+	t.Helper()
+	saved := *config.AppConfig
+	t.Cleanup(func() { *config.AppConfig = saved })
+}
+
+func writeRecoveryKey(t *testing.T) (string, *sm2.PrivateKey) {
+	t.Helper()
+	priv, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := smx509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "recovery.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path, priv
+}
+
+func TestKeyBackup(t *testing.T) {
+	ctx := context.Background()
+	keyRepo := repository.NewKeyRepository()
+	backupService := service.NewBackupService()
+	t.Cleanup(func() {
+		if err := keystore.Init(config.KeyStoreConfig{}, ""); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// export 导出选定密钥，返回备份包与错误码
+	admin := loginAdmin(t)
+	export := func(t *testing.T, keyIDs ...string) (*service.KeyBackup, response.Code) {
+		t.Helper()
+		var backup service.KeyBackup
+		code := admin.call(t, http.MethodPost, "/mapi/keys/export", map[string][]string{"keyIds": keyIDs}, &backup)
+		return &backup, code
+	}
+	keyOf := func(t *testing.T, u *testUser) *model.Key {
+		t.Helper()
+		key, err := keyRepo.FindByUserID(u.UserID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	dbUser := newTestUser(t)
+	dbKey := keyOf(t, dbUser)
+	if _, code := export(t, dbKey.ID); code != response.CodeUnavailable {
+		t.Fatalf("export without recovery key: got code %d, want %d", code, response.CodeUnavailable)
+	}
+
+	pubPath, priv := writeRecoveryKey(t)
+	saveConfig(t)
+	config.AppConfig.Backup.RecoveryKeyFile = pubPath
+	masterKey := "000102030405060708090a0b0c0d0e0f"
+	fileStore := config.KeyStoreConfig{Backend: keystore.BackendFile, Dir: t.TempDir()}
+	if err := keystore.Init(fileStore, masterKey); err != nil {
+		t.Fatal(err)
+	}
+	fileUser := newTestUser(t)
+	fileKey := keyOf(t, fileUser)
+	if err := keystore.Init(config.KeyStoreConfig{Backend: keystore.BackendSoftHSM, AllowSoftHSM: true}, ""); err != nil {
+		t.Fatal(err)
+	}
+	hsmUser := newTestUser(t)
+	if err := keystore.Init(config.KeyStoreConfig{}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, code := export(t, dbKey.ID, keyOf(t, hsmUser).ID); code != response.CodeNotExportable {
+		t.Fatalf("export hsm key: got code %d, want %d", code, response.CodeNotExportable)
+	}
+	if _, code := export(t, "no-such-key"); code != response.CodeKeyNotFound {
+		t.Fatalf("export unknown key: got code %d, want %d", code, response.CodeKeyNotFound)
+	}
+	if _, code := export(t); code != response.CodeInvalidParam {
+		t.Fatalf("export without keys: got code %d, want %d", code, response.CodeInvalidParam)
+	}
+	backup, code := export(t, dbKey.ID, fileKey.ID)
+	if code != response.CodeSuccess {
+		t.Fatalf("export: code %d", code)
+	}
+	if got := auditCount(t, model.ActionKeyExport, dbUser.UserID()); got != 1 {
+		t.Errorf("key export audit entries: got %d, want 1", got)
+	}
+
+	// 清除用户后由备份恢复到 file 后端，未清除的密钥跳过
+	if code := dbUser.call(t, http.MethodDelete, "/mapi/users/"+dbUser.UserID(), nil, nil); code != response.CodeSuccess {
+		t.Fatalf("archive user: code %d", code)
+	}
+	if code := admin.call(t, http.MethodDelete, "/mapi/users/"+dbUser.UserID()+"/purge", nil, nil); code != response.CodeSuccess {
+		t.Fatalf("purge user: code %d", code)
+	}
+	if err := keystore.Init(fileStore, masterKey); err != nil {
+		t.Fatal(err)
+	}
+	items, err := backupService.ImportKeys(backup, priv, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{service.KeyImportRestored, service.KeyImportSkipped}
+	for i, item := range items {
+		if item.Status != want[i] {
+			t.Errorf("key %s: got status %s (%s), want %s", item.KeyID, item.Status, item.Reason, want[i])
+		}
+	}
+	restored := keyOf(t, dbUser)
+	if restored.ID != dbKey.ID || restored.PublicKey != dbKey.PublicKey || restored.Store != keystore.BackendFile {
+		t.Fatalf("restored key record: id %s, store %s", restored.ID, restored.Store)
+	}
+	if got := auditCount(t, model.ActionKeyImport, dbUser.UserID()); got != 1 {
+		t.Errorf("key import audit entries: got %d, want 1", got)
+	}
+
+	// 恢复的用户可以用原密码登录，原客户端分量仍可签名
+	if err := dbUser.Login(ctx, dbUser.username, "password-123"); err != nil {
+		t.Fatalf("login after restore: %v", err)
+	}
+	msg := []byte("restored key share")
+	sig, err := dbUser.Sign(ctx, dbUser.ks, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sm2.VerifyASN1WithSM2(dbUser.ks.PublicKey(), nil, msg, sig) {
+		t.Fatal("signature with restored key share does not verify")
+	}
+
+	// -force 覆盖已存在密钥记录的分量，旧分量被删除
+	items, err = backupService.ImportKeys(backup, priv, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items[1].Status != service.KeyImportReplaced {
+		t.Errorf("forced import: got status %s (%s), want %s", items[1].Status, items[1].Reason, service.KeyImportReplaced)
+	}
+	if shareExists(t, fileKey) {
+		t.Error("replaced key share still exists")
+	}
+	if code := fileUser.signCode(t); code != response.CodeSuccess {
+		t.Fatalf("sign after forced import: code %d", code)
+	}
+}
+
+// apiCode 返回客户端错误中的业务错误码
+func apiCode(err error) response.Code {
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		return response.Code(apiErr.Code)
+	}
+	if err != nil {
+		return response.CodeInternalError
+	}
+	return response.CodeSuccess
+}
+
+// userKey 查询用户唯一的密钥
+// userKey 返回用户当前（未归档）的密钥
+func (u *testUser) userKey(t *testing.T) client.KeyInfo {
+	t.Helper()
+	keys, err := u.Keys(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var current []client.KeyInfo
+	for _, key := range keys {
+		if key.Status != model.KeyStatusArchived {
+			current = append(current, key)
+		}
+	}
+	if len(current) != 1 {
+		t.Fatalf("got %d current keys, want 1", len(current))
+	}
+	return current[0]
+}
+
+func TestKeyValidity(t *testing.T) {
+	ctx := context.Background()
+	keyRepo := repository.NewKeyRepository()
+	saveConfig(t)
+	config.AppConfig.KeyPolicy = config.KeyPolicyConfig{RotateBefore: 24 * time.Hour}
+
+	// setValidity 直接修改密钥记录的有效期
+	setValidity := func(t *testing.T, u *testUser, notBefore, notAfter *time.Time) {
+		t.Helper()
+		key, err := keyRepo.FindByUserID(u.UserID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		key.NotBefore, key.NotAfter = notBefore, notAfter
+		if err := keyRepo.Update(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	decryptCode := func(u *testUser) response.Code {
+		ciphertext, err := sm2.Encrypt(rand.Reader, u.ks.PublicKey(), []byte("archived"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = u.Decrypt(ctx, u.ks, ciphertext)
+		return apiCode(err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	past, soon, later := now.Add(-time.Minute), now.Add(time.Hour), now.Add(48*time.Hour)
+
+	// 未配置有效期时密钥不过期
+	unlimited := newTestUser(t)
+	if key := unlimited.userKey(t); key.NotAfter != nil || key.Expired || key.Rotate {
+		t.Fatalf("unlimited key: %+v", key)
+	}
+
+	expired := newTestUser(t)
+	setValidity(t, expired, nil, &past)
+	if code := expired.signCode(t); code != response.CodeKeyExpired {
+		t.Errorf("sign with expired key: got code %d, want %d", code, response.CodeKeyExpired)
+	}
+	if code := decryptCode(expired); code != response.CodeKeyExpired {
+		t.Errorf("decrypt with expired key: got code %d, want %d", code, response.CodeKeyExpired)
+	}
+	if _, err := expired.KeyRefresh(ctx, expired.ks); apiCode(err) != response.CodeKeyExpired {
+		t.Errorf("refresh expired key: got %v, want code %d", err, response.CodeKeyExpired)
+	}
+	key := expired.userKey(t)
+	if !key.Expired || !key.Rotate || key.NotAfter == nil || !key.NotAfter.Equal(past) {
+		t.Errorf("expired key: %+v", key)
+	}
+
+	// 允许归档解密后过期密钥仍可解密，签名仍被拒绝（分量已被缓存）
+	config.AppConfig.KeyPolicy.DecryptAfterExpiry = true
+	if code := decryptCode(expired); code != response.CodeSuccess {
+		t.Errorf("decrypt after expiry: code %d", code)
+	}
+	if code := expired.signCode(t); code != response.CodeKeyExpired {
+		t.Errorf("sign with cached expired key: got code %d, want %d", code, response.CodeKeyExpired)
+	}
+	config.AppConfig.KeyPolicy.DecryptAfterExpiry = false
+
+	notYet := newTestUser(t)
+	setValidity(t, notYet, &soon, &later)
+	if code := notYet.signCode(t); code != response.CodeKeyNotYetValid {
+		t.Errorf("sign before not_before: got code %d, want %d", code, response.CodeKeyNotYetValid)
+	}
+	if code := decryptCode(notYet); code != response.CodeKeyNotYetValid {
+		t.Errorf("decrypt before not_before: got code %d, want %d", code, response.CodeKeyNotYetValid)
+	}
+
+	// 即将过期的密钥仍可使用，提示轮换
+	expiring := newTestUser(t)
+	setValidity(t, expiring, &past, &soon)
+	if code := expiring.signCode(t); code != response.CodeSuccess {
+		t.Errorf("sign with expiring key: code %d", code)
+	}
+	if key := expiring.userKey(t); key.Expired || !key.Rotate {
+		t.Errorf("expiring key: %+v", key)
+	}
+	if key := notYet.userKey(t); key.Rotate {
+		t.Errorf("key valid for 48h flagged for rotation: %+v", key)
+	}
+
+	// 重新生成后密钥可用且不再提示轮换
+	config.AppConfig.KeyPolicy.Validity = 30 * 24 * time.Hour
+	ks, err := expired.KeyInit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired.ks = ks
+	if code := expired.signCode(t); code != response.CodeSuccess {
+		t.Errorf("sign after rotation: code %d", code)
+	}
+	if key := expired.userKey(t); key.Expired || key.Rotate {
+		t.Errorf("rotated key: %+v", key)
+	}
+
+	// 注册时同样设置有效期
+	registered := newTestUser(t)
+	if key := registered.userKey(t); key.NotAfter == nil || key.Rotate {
+		t.Errorf("registered key: %+v", key)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	ctx := context.Background()
+	keyRepo := repository.NewKeyRepository()
+	admin := loginAdmin(t)
+	saveConfig(t)
+	config.AppConfig.Archive = config.ArchiveConfig{PurgeGrace: time.Hour, MaxApproval: 2 * time.Hour}
+
+	u := newTestUser(t)
+	oldKs := u.ks
+	oldKey, err := keyRepo.FindByUserID(u.UserID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("archived ciphertext")
+	ciphertext, err := sm2.Encrypt(rand.Reader, oldKs.PublicKey(), msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	approve := func(duration string) response.Code {
+		t.Helper()
+		return admin.call(t, http.MethodPost, "/mapi/keys/"+oldKey.ID+"/decrypt-approval", map[string]string{"duration": duration}, nil)
+	}
+
+	// 删除密钥只做归档：不再签名，未经批准不能解密
+	if code := u.call(t, http.MethodDelete, "/mapi/keys/"+oldKey.ID, nil, nil); code != response.CodeSuccess {
+		t.Fatalf("archive key: code %d", code)
+	}
+	if code := u.signCode(t); code != response.CodeKeyNotFound {
+		t.Errorf("sign with archived key: got code %d, want %d", code, response.CodeKeyNotFound)
+	}
+	if _, err := u.DecryptWithKey(ctx, oldKey.ID, oldKs, ciphertext); apiCode(err) != response.CodeKeyArchived {
+		t.Errorf("decrypt without approval: got %v, want code %d", err, response.CodeKeyArchived)
+	}
+	if code := admin.call(t, http.MethodDelete, "/mapi/keys/"+oldKey.ID+"/purge", nil, nil); code != response.CodePurgeNotAllowed {
+		t.Errorf("purge within grace period: got code %d, want %d", code, response.CodePurgeNotAllowed)
+	}
+
+	// 批准期限不超过 archive.max_approval，批准后只有所属用户可以解密
+	if code := approve("3h"); code != response.CodeInvalidParam {
+		t.Errorf("approval beyond max_approval: got code %d, want %d", code, response.CodeInvalidParam)
+	}
+	if code := approve("1h"); code != response.CodeSuccess {
+		t.Fatalf("approve decrypt: code %d", code)
+	}
+	plaintext, err := u.DecryptWithKey(ctx, oldKey.ID, oldKs, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, msg) {
+		t.Fatalf("decrypted %q, want %q", plaintext, msg)
+	}
+	other := newTestUser(t)
+	if _, err := other.DecryptWithKey(ctx, oldKey.ID, other.ks, ciphertext); apiCode(err) != response.CodeKeyNotFound {
+		t.Errorf("decrypt with another user's key: got %v, want code %d", err, response.CodeKeyNotFound)
+	}
+
+	// 重新生成的密钥与归档密钥并存
+	ks, err := u.KeyInit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.ks = ks
+	if code := u.signCode(t); code != response.CodeSuccess {
+		t.Errorf("sign with new key: code %d", code)
+	}
+	keys, err := u.Keys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].ID == oldKey.ID || keys[1].ID != oldKey.ID ||
+		keys[1].Status != model.KeyStatusArchived || keys[1].ArchivedAt == nil || keys[1].DecryptUntil == nil {
+		t.Fatalf("keys after rotation: %+v", keys)
+	}
+	if _, err := u.DecryptWithKey(ctx, oldKey.ID, oldKs, ciphertext); err != nil {
+		t.Errorf("decrypt with approved archived key after rotation: %v", err)
+	}
+
+	// 期限为 0 撤销批准
+	if code := approve("0s"); code != response.CodeSuccess {
+		t.Fatalf("revoke approval: code %d", code)
+	}
+	if _, err := u.DecryptWithKey(ctx, oldKey.ID, oldKs, ciphertext); apiCode(err) != response.CodeKeyArchived {
+		t.Errorf("decrypt after revocation: got %v, want code %d", err, response.CodeKeyArchived)
+	}
+	if got := auditCount(t, model.ActionKeyApprove, u.UserID()); got != 2 {
+		t.Errorf("approval audit entries: got %d, want 2", got)
+	}
+
+	// 归档用户：会话失效、不可登录，当前密钥一并归档，用户名保留
+	if code := u.call(t, http.MethodDelete, "/mapi/users/"+u.UserID(), nil, nil); code != response.CodeSuccess {
+		t.Fatalf("archive user: code %d", code)
+	}
+	if code := u.signCode(t); code != response.CodeTokenInvalid {
+		t.Errorf("sign after user archival: got code %d, want %d", code, response.CodeTokenInvalid)
+	}
+	if err := u.Login(ctx, u.username, "password-123"); apiCode(err) != response.CodeUserDisabled {
+		t.Errorf("login as archived user: got %v, want code %d", err, response.CodeUserDisabled)
+	}
+	if _, _, err := client.New(baseURL).Register(ctx, u.username, "password-123"); apiCode(err) != response.CodeUserExists {
+		t.Errorf("register archived username: got %v, want code %d", err, response.CodeUserExists)
+	}
+	if code := u.call(t, http.MethodPut, "/mapi/users/"+u.UserID()+"/status", map[string]int{"status": model.UserStatusEnabled}, nil); code != response.CodeInvalidParam {
+		t.Errorf("enable archived user: got code %d, want %d", code, response.CodeInvalidParam)
+	}
+	stored, err := keyRepo.ListByUserID(u.UserID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range stored {
+		if !key.IsArchived() {
+			t.Errorf("key %s of archived user is not archived", key.ID)
+		}
+	}
+	if got := auditCount(t, model.ActionKeyDel, u.UserID()); got != 2 {
+		t.Errorf("key archive audit entries: got %d, want 2", got)
+	}
+
+	// 超过宽限期后清除用户及其全部密钥
+	if code := admin.call(t, http.MethodDelete, "/mapi/users/"+u.UserID()+"/purge", nil, nil); code != response.CodePurgeNotAllowed {
+		t.Errorf("purge within grace period: got code %d, want %d", code, response.CodePurgeNotAllowed)
+	}
+	config.AppConfig.Archive.PurgeGrace = 0
+	if code := admin.call(t, http.MethodDelete, "/mapi/users/"+u.UserID()+"/purge", nil, nil); code != response.CodeSuccess {
+		t.Fatalf("purge user: code %d", code)
+	}
+	if stored, err := keyRepo.ListByUserID(u.UserID()); err != nil || len(stored) != 0 {
+		t.Errorf("keys after purge: %d, err %v", len(stored), err)
+	}
+	if got := auditCount(t, model.ActionUserDel, u.UserID()); got != 2 {
+		t.Errorf("user delete audit entries: got %d, want 2", got)
+	}
+	if got := auditCount(t, model.ActionKeyDel, u.UserID()); got != 4 {
+		t.Errorf("key delete audit entries: got %d, want 4", got)
+	}
+
+	// 恢复的用户可以登录，密钥保持归档，须重新生成
+	if code := other.call(t, http.MethodDelete, "/mapi/users/"+other.UserID(), nil, nil); code != response.CodeSuccess {
+		t.Fatalf("archive user: code %d", code)
+	}
+	if code := admin.call(t, http.MethodPost, "/mapi/users/"+other.UserID()+"/restore", nil, nil); code != response.CodeSuccess {
+		t.Fatalf("restore user: code %d", code)
+	}
+	if err := other.Login(ctx, other.username, "password-123"); err != nil {
+		t.Fatalf("login after restore: %v", err)
+	}
+	if code := other.signCode(t); code != response.CodeKeyNotFound {
+		t.Errorf("sign with archived key after restore: got code %d, want %d", code, response.CodeKeyNotFound)
+	}
+	if ks, err = other.KeyInit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	other.ks = ks
+	if code := other.signCode(t); code != response.CodeSuccess {
+		t.Errorf("sign with new key after restore: code %d", code)
+	}
+	if got := auditCount(t, model.ActionUserRestore, other.UserID()); got != 1 {
+		t.Errorf("user restore audit entries: got %d, want 1", got)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	keyRepo := repository.NewKeyRepository()
+	u := newTestUser(t)
+	oldKs := u.ks
+	oldKey := u.userKey(t)
+	msg := []byte("encrypted before rotation")
+	ciphertext, err := sm2.Encrypt(rand.Reader, oldKs.PublicKey(), msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新生成密钥：旧密钥归档并保留分量，新密钥使用新的密钥ID
+	ks, err := u.KeyInit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.ks = ks
+	newKey := u.userKey(t)
+	if newKey.ID == oldKey.ID || newKey.PublicKey == oldKey.PublicKey {
+		t.Fatalf("rotated key reuses id %s or public key", newKey.ID)
+	}
+	stored, err := keyRepo.FindByID(oldKey.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored.IsArchived() || stored.PublicKey != oldKey.PublicKey || stored.D2Inv == "" {
+		t.Fatalf("replaced key record: status %d, public key changed %v", stored.Status, stored.PublicKey != oldKey.PublicKey)
+	}
+	if got := auditCount(t, model.ActionKeyDel, u.UserID()); got != 1 {
+		t.Errorf("key rotation audit entries: got %d, want 1", got)
+	}
+
+	// 旧密文经管理员批准后以 keyId 指定旧密钥解密
+	if _, err := u.DecryptWithKey(ctx, oldKey.ID, oldKs, ciphertext); apiCode(err) != response.CodeKeyArchived {
+		t.Errorf("decrypt without approval: got %v, want code %d", err, response.CodeKeyArchived)
+	}
+	approval := map[string]string{"duration": "1h"}
+	anonymous := &testUser{Client: client.New(baseURL)}
+	if code := anonymous.call(t, http.MethodPost, "/mapi/keys/"+oldKey.ID+"/decrypt-approval", approval, nil); code != response.CodeUnauthorized {
+		t.Errorf("approve without token: got code %d, want %d", code, response.CodeUnauthorized)
+	}
+	if code := u.call(t, http.MethodPost, "/mapi/keys/"+oldKey.ID+"/decrypt-approval", approval, nil); code != response.CodeForbidden {
+		t.Errorf("approve as key owner: got code %d, want %d", code, response.CodeForbidden)
+	}
+	if code := loginAdmin(t).call(t, http.MethodPost, "/mapi/keys/"+oldKey.ID+"/decrypt-approval", approval, nil); code != response.CodeSuccess {
+		t.Fatalf("approve decrypt: code %d", code)
+	}
+	plaintext, err := u.DecryptWithKey(ctx, oldKey.ID, oldKs, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, msg) {
+		t.Fatalf("decrypted %q, want %q", plaintext, msg)
+	}
+	if code := u.signCode(t); code != response.CodeSuccess {
+		t.Errorf("sign with rotated key: code %d", code)
+	}
+}
+
+func TestCertificate(t *testing.T) {
+	ctx := context.Background()
+	u := newTestUser(t)
+	anonymous := client.New(baseURL)
+
+	// 未配置 ca.key_file 时不签发证书
+	if _, err := u.RequestCertificate(ctx, u.ks, pkix.Name{}); apiCode(err) != response.CodeUnavailable {
+		t.Fatalf("request without CA: got %v, want code %d", err, response.CodeUnavailable)
+	}
+	if _, err := anonymous.CACertificate(ctx); apiCode(err) != response.CodeUnavailable {
+		t.Fatalf("root certificate without CA: got %v, want code %d", err, response.CodeUnavailable)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "ca", "ca.json")
+	t.Cleanup(func() {
+		if _, err := service.InitCA(); err != nil {
+			t.Error(err)
+		}
+	})
+	saveConfig(t)
+	config.AppConfig.Auth.MasterKey = "000102030405060708090a0b0c0d0e0f"
+	config.AppConfig.CA = config.CAConfig{
+		KeyFile:      keyFile,
+		CommonName:   "Test Root CA",
+		RootValidity: 365 * 24 * time.Hour,
+		CertValidity: 24 * time.Hour,
+	}
+	generated, err := service.InitCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(keyFile); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("CA file: %v", err)
+	}
+
+	// 重新加载得到同一根证书，主密钥错误时拒绝加载
+	loaded, err := service.InitCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Certificate().Equal(generated.Certificate()) {
+		t.Fatal("reloaded root certificate differs")
+	}
+	config.AppConfig.Auth.MasterKey = "0f0e0d0c0b0a09080706050403020100"
+	if _, err := service.InitCA(); !errors.Is(err, crypto.ErrInvalidCAFile) {
+		t.Fatalf("wrong master key: got %v, want %v", err, crypto.ErrInvalidCAFile)
+	}
+	config.AppConfig.Auth.MasterKey = "000102030405060708090a0b0c0d0e0f"
+	if _, err := service.InitCA(); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := anonymous.CACertificate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !root.Equal(generated.Certificate()) || root.Subject.CommonName != "Test Root CA" {
+		t.Fatalf("unexpected root certificate %s", root.Subject)
+	}
+	roots := smx509.NewCertPool()
+	roots.AddCert(root)
+
+	// 证书主题的 CommonName 固定为用户名，请求中的其他主题字段不被采用；公钥为协同公钥 Pa
+	issued, err := u.RequestCertificate(ctx, u.ks, pkix.Name{CommonName: "mallory", Organization: []string{"Example"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := issued.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Subject.CommonName != u.username || len(cert.Subject.Organization) != 0 {
+		t.Errorf("unexpected subject %s", cert.Subject)
+	}
+	if !u.ks.PublicKey().Equal(cert.PublicKey) {
+		t.Error("certificate public key differs from Pa")
+	}
+	if issued.KeyID != u.userKey(t).ID || issued.SerialNumber != cert.SerialNumber.Text(16) {
+		t.Errorf("unexpected certificate record %+v", issued)
+	}
+	if _, err := cert.Verify(smx509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		t.Errorf("verify: %v", err)
+	}
+	if d := time.Until(cert.NotAfter); d > 24*time.Hour || d < 23*time.Hour {
+		t.Errorf("certificate valid for %v, want 24h", d)
+	}
+
+	// 非协同公钥签署的请求、他人的协同公钥签署的请求均被拒绝
+	key, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign, err := smx509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := u.call(t, http.MethodPost, "/api/cert", map[string]string{"csr": encode(foreign)}, nil); code != response.CodeInvalidCSR {
+		t.Errorf("foreign key request: got code %d, want %d", code, response.CodeInvalidCSR)
+	}
+	other := newTestUser(t)
+	csr, err := other.CreateCSR(ctx, other.ks, pkix.Name{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := u.call(t, http.MethodPost, "/api/cert", map[string]string{"csr": encode(csr)}, nil); code != response.CodeInvalidCSR {
+		t.Errorf("other user's request: got code %d, want %d", code, response.CodeInvalidCSR)
+	}
+	csrPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr})
+	if code := other.call(t, http.MethodPost, "/api/cert", map[string]string{"csr": string(csrPEM)}, nil); code != response.CodeSuccess {
+		t.Errorf("PEM request: code %d", code)
+	}
+	csr[len(csr)-1] ^= 1
+	if code := other.call(t, http.MethodPost, "/api/cert", map[string]string{"csr": encode(csr)}, nil); code != response.CodeInvalidCSR {
+		t.Errorf("tampered request: got code %d, want %d", code, response.CodeInvalidCSR)
+	}
+
+	// 证书到期时间不晚于密钥的到期时间
+	config.AppConfig.KeyPolicy = config.KeyPolicyConfig{Validity: time.Hour}
+	ks, err := u.KeyInit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.ks = ks
+	renewed, err := u.RequestCertificate(ctx, u.ks, pkix.Name{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notAfter := u.userKey(t).NotAfter; notAfter == nil || renewed.NotAfter.After(*notAfter) {
+		t.Errorf("certificate not after %v exceeds key not after %v", renewed.NotAfter, notAfter)
+	}
+
+	certs, err := u.Certificates(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 2 || certs[0].ID != renewed.ID || certs[1].ID != issued.ID {
+		t.Errorf("got %d certificates, want the renewed and the original certificate", len(certs))
+	}
+	if got := auditCount(t, model.ActionCertIssue, u.UserID()); got != 2 {
+		t.Errorf("certificate issue audit entries: got %d, want 2", got)
+	}
+}
+
+func TestRevocation(t *testing.T) {
+	ctx := context.Background()
+	anonymous := client.New(baseURL)
+	const crlURL = "http://cosign.example/api/cert/crl"
+
+	t.Cleanup(func() {
+		if _, err := service.InitCA(); err != nil {
+			t.Error(err)
+		}
+	})
+	saveConfig(t)
+	config.AppConfig.Auth.MasterKey = "000102030405060708090a0b0c0d0e0f"
+	config.AppConfig.CA = config.CAConfig{
+		KeyFile:      filepath.Join(t.TempDir(), "ca.json"),
+		CommonName:   "Test Root CA",
+		RootValidity: 365 * 24 * time.Hour,
+		CertValidity: 24 * time.Hour,
+		CRLURL:       crlURL,
+		CRLValidity:  time.Hour,
+	}
+	if _, err := service.InitCA(); err != nil {
+		t.Fatal(err)
+	}
+	root, err := anonymous.CACertificate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// crlReason 返回序列号在 CRL 中的吊销原因，不在 CRL 中时返回 -1
+	crlReason := func(serial *big.Int) int {
+		t.Helper()
+		crl, err := anonymous.CRL(ctx, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := crl.NextUpdate.Sub(crl.ThisUpdate); d != time.Hour {
+			t.Errorf("CRL valid for %v, want 1h", d)
+		}
+		for _, entry := range crl.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(serial) == 0 {
+				return entry.ReasonCode
+			}
+		}
+		return -1
+	}
+	checkStatus := func(name string, serial *big.Int, status string, reason int) {
+		t.Helper()
+		got, err := anonymous.CertificateStatus(ctx, serial, root)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got.Status != status || got.RevocationReason != reason || (got.RevokedAt != nil) != (status == crypto.CertStatusRevoked) {
+			t.Errorf("%s: got status %s reason %d, want %s reason %d", name, got.Status, got.RevocationReason, status, reason)
+		}
+		wantCRL := -1
+		if status == crypto.CertStatusRevoked {
+			wantCRL = reason
+		}
+		if got := crlReason(serial); got != wantCRL {
+			t.Errorf("%s: CRL reason %d, want %d", name, got, wantCRL)
+		}
+	}
+	admin := loginAdmin(t)
+	setStatus := func(u *testUser, status int) {
+		t.Helper()
+		if code := admin.call(t, http.MethodPut, "/mapi/users/"+u.UserID()+"/status", map[string]int{"status": status}, nil); code != response.CodeSuccess {
+			t.Fatalf("update user status: code %d", code)
+		}
+	}
+
+	u := newTestUser(t)
+	issued, err := u.RequestCertificate(ctx, u.ks, pkix.Name{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := issued.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cert.CRLDistributionPoints) != 1 || cert.CRLDistributionPoints[0] != crlURL {
+		t.Errorf("CRL distribution points: %v", cert.CRLDistributionPoints)
+	}
+	checkStatus("issued", cert.SerialNumber, crypto.CertStatusGood, 0)
+
+	// 未发生吊销时复用已签发的 CRL；状态查询结果由根证书签发的响应者签名，而非根私钥
+	first, err := anonymous.CRL(ctx, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := anonymous.CRL(ctx, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Raw, second.Raw) {
+		t.Error("CRL re-signed without a revocation change")
+	}
+	status, err := anonymous.CertificateStatus(ctx, cert.SerialNumber, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode([]byte(status.ResponderCertificate))
+	if block == nil {
+		t.Fatal("status response without responder certificate")
+	}
+	responder, err := smx509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if responder.PublicKey.(*ecdsa.PublicKey).Equal(root.PublicKey) {
+		t.Error("status response signed with the root key")
+	}
+
+	// 禁用用户暂停其证书，重新启用后恢复
+	setStatus(u, model.UserStatusDisabled)
+	checkStatus("disabled user", cert.SerialNumber, crypto.CertStatusRevoked, model.RevocationHold)
+	setStatus(u, model.UserStatusEnabled)
+	checkStatus("enabled user", cert.SerialNumber, crypto.CertStatusGood, 0)
+	if err := u.Login(ctx, u.username, "password-123"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新生成密钥吊销旧密钥的证书
+	ks, err := u.KeyInit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.ks = ks
+	checkStatus("superseded key", cert.SerialNumber, crypto.CertStatusRevoked, model.RevocationSuperseded)
+	renewed, err := u.RequestCertificate(ctx, u.ks, pkix.Name{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewedCert, err := renewed.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStatus("renewed", renewedCert.SerialNumber, crypto.CertStatusGood, 0)
+
+	// 归档密钥吊销其证书；已吊销的证书不受禁用、启用用户影响
+	if code := u.call(t, http.MethodDelete, "/mapi/keys/"+u.userKey(t).ID, nil, nil); code != response.CodeSuccess {
+		t.Fatalf("archive key: code %d", code)
+	}
+	checkStatus("archived key", renewedCert.SerialNumber, crypto.CertStatusRevoked, model.RevocationCessation)
+	setStatus(u, model.UserStatusDisabled)
+	setStatus(u, model.UserStatusEnabled)
+	checkStatus("archived key after hold", renewedCert.SerialNumber, crypto.CertStatusRevoked, model.RevocationCessation)
+	checkStatus("superseded key after hold", cert.SerialNumber, crypto.CertStatusRevoked, model.RevocationSuperseded)
+
+	if err := u.Login(ctx, u.username, "password-123"); err != nil {
+		t.Fatal(err)
+	}
+	certs, err := u.Certificates(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range certs {
+		if c.RevokedAt == nil || c.RevocationReason == 0 {
+			t.Errorf("certificate %s not marked revoked", c.SerialNumber)
+		}
+	}
+
+	// 非本 CA 签发的序列号状态未知，非法序列号被拒绝
+	checkStatus("unknown serial", big.NewInt(12345), crypto.CertStatusUnknown, 0)
+	if code := u.call(t, http.MethodGet, "/api/cert/status/not-hex", nil, nil); code != response.CodeInvalidParam {
+		t.Errorf("invalid serial: got code %d, want %d", code, response.CodeInvalidParam)
+	}
+}
+
+// TestMetricsAccess /metrics 默认在业务端口公开，可改为须管理员认证或只在独立监听地址提供
+func TestMetricsAccess(t *testing.T) {
+	admin := loginAdmin(t)
+	saveConfig(t)
+
+	// scrape 请求 /metrics，返回 HTTP 状态码、是否为指标文本与业务错误码
+	scrape := func(t *testing.T, app *fiber.App, token string) (int, bool, response.Code) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+			return resp.StatusCode, strings.Contains(string(body), "# TYPE cosign_http_requests_total counter"), response.CodeSuccess
+		}
+		var result struct {
+			Code response.Code `json:"code"`
+		}
+		if err := json.Unmarshal(body, &result); err != nil && resp.StatusCode == http.StatusOK {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, false, result.Code
+	}
+	u := newTestUser(t)
+
+	tests := []struct {
+		name     string
+		cfg      config.MetricsConfig
+		metrics  bool // 使用独立监听地址的应用
+		token    string
+		wantHTTP int
+		wantText bool
+		wantCode response.Code
+	}{
+		{"public", config.MetricsConfig{}, false, "", http.StatusOK, true, response.CodeSuccess},
+		{"admin required without token", config.MetricsConfig{RequireAdmin: true}, false, "", http.StatusOK, false, response.CodeUnauthorized},
+		{"admin required with user token", config.MetricsConfig{RequireAdmin: true}, false, u.Token(), http.StatusOK, false, response.CodeForbidden},
+		{"admin required with admin token", config.MetricsConfig{RequireAdmin: true}, false, admin.Token(), http.StatusOK, true, response.CodeSuccess},
+		{"separate listener hides main route", config.MetricsConfig{Listen: "127.0.0.1:0"}, false, "", http.StatusNotFound, false, 0},
+		{"separate listener", config.MetricsConfig{Listen: "127.0.0.1:0"}, true, "", http.StatusOK, true, response.CodeSuccess},
+		{"separate listener with admin required", config.MetricsConfig{Listen: "127.0.0.1:0", RequireAdmin: true}, true, "", http.StatusOK, false, response.CodeUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.AppConfig.Metrics = tt.cfg
+			app := newApp()
+			if tt.metrics {
+				app = newMetricsApp()
+			}
+			status, text, code := scrape(t, app, tt.token)
+			if status != tt.wantHTTP || text != tt.wantText || code != tt.wantCode {
+				t.Errorf("got status %d, metrics %v, code %d; want status %d, metrics %v, code %d",
+					status, text, code, tt.wantHTTP, tt.wantText, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestHealthAccess(t *testing.T) {
+	anonymous := &testUser{Client: client.New(baseURL)}
+	u := newTestUser(t)
+	admin := loginAdmin(t)
+
+	// 存活与就绪检查无需认证，按需自检须管理员 Token
+	tests := []struct {
+		name   string
+		caller *testUser
+		method string
+		path   string
+		want   response.Code
+	}{
+		{"live", anonymous, http.MethodGet, "/mapi/health/live", response.CodeSuccess},
+		{"ready", anonymous, http.MethodGet, "/mapi/health/ready", response.CodeSuccess},
+		{"self-test without token", anonymous, http.MethodPost, "/mapi/health/selftest", response.CodeUnauthorized},
+		{"self-test with user token", u, http.MethodPost, "/mapi/health/selftest", response.CodeForbidden},
+		{"self-test with admin token", admin, http.MethodPost, "/mapi/health/selftest", response.CodeSuccess},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := tt.caller.call(t, tt.method, tt.path, nil, nil); code != tt.want {
+				t.Errorf("got code %d, want %d", code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sm2-cosign/backend/internal/config"
+	"github.com/sm2-cosign/backend/internal/crypto"
+	"github.com/sm2-cosign/backend/internal/keystore"
+	"github.com/sm2-cosign/backend/internal/repository"
+	"github.com/sm2-cosign/backend/internal/service"
+)
+
+const importUsage = `Usage: server import [-config config.yaml] -key recovery.pem [-force] <backup.json>
+
+Restore server key shares from a backup exported by POST /mapi/keys/export.
+Shares are written to the configured keystore backend. Missing users are
+recreated; existing key records are skipped unless -force is given.
+
+Flags:
+`
+
+// runImport 执行 import 子命令，返回进程退出码
+func runImport(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "config file path")
+	keyPath := fs.String("key", "", "SM2 recovery private key (PEM)")
+	force := fs.Bool("force", false, "replace the key share of existing key records")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, importUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 || *keyPath == "" {
+		fs.Usage()
+		return 2
+	}
+
+	keyPEM, err := os.ReadFile(*keyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read recovery key: %v\n", err)
+		return 1
+	}
+	priv, err := crypto.ParseRecoveryPrivateKey(keyPEM)
+	clear(keyPEM)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse recovery key: %v\n", err)
+		return 1
+	}
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read backup: %v\n", err)
+		return 1
+	}
+	var backup service.KeyBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid backup: %v\n", err)
+		return 1
+	}
+
+	if err := config.Load(*configPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return 1
+	}
+	if err := keystore.Init(config.AppConfig.KeyStore, config.AppConfig.Auth.MasterKey); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize key store: %v\n", err)
+		return 1
+	}
+	if err := initDatabase(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	defer repository.CloseDB()
+
+	items, err := service.NewBackupService().ImportKeys(&backup, priv, *force)
+	for _, item := range items {
+		if item.Reason != "" {
+			fmt.Printf("%s  key %s (user %s): %s\n", item.Status, item.KeyID, item.UserID, item.Reason)
+		} else {
+			fmt.Printf("%s  key %s (user %s)\n", item.Status, item.KeyID, item.UserID)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Import failed: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Imported backup %s into %q key store\n", backup.ID, keystore.Primary().Name())
+	return 0
+}
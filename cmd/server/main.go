@@ -1,7 +1,6 @@
 package main
 
 import (
-	"database/sql"
 	"fmt"
 	"log"
 	"os"
@@ -15,13 +14,23 @@ import (
 	_ "modernc.org/sqlite"
 
 	"github.com/sm2-cosign/backend/internal/config"
+	"github.com/sm2-cosign/backend/internal/crypto"
 	"github.com/sm2-cosign/backend/internal/handler"
+	"github.com/sm2-cosign/backend/internal/keystore"
+	"github.com/sm2-cosign/backend/internal/metrics"
 	"github.com/sm2-cosign/backend/internal/middleware"
 	"github.com/sm2-cosign/backend/internal/repository"
 	"github.com/sm2-cosign/backend/internal/service"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(os.Args[2:]))
+	}
+
 	configPath := "config.yaml"
 	if len(os.Args) > 1 {
 		configPath = os.Args[1]
@@ -30,29 +39,71 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	if _, err := crypto.ParseNonceMode(config.AppConfig.Cosign.NonceMode); err != nil {
+		log.Fatalf("Invalid cosign.nonce_mode: %v", err)
+	}
+
+	if config.AppConfig.Cosign.NoncePool < 0 {
+		log.Fatalf("Invalid cosign.nonce_pool_size: %d", config.AppConfig.Cosign.NoncePool)
+	}
+
+	if err := crypto.SelfTest(); err != nil {
+		log.Fatalf("Crypto self-test failed: %v", err)
+	}
+
+	if size := config.AppConfig.Cosign.NoncePool; size > 0 {
+		// 池中的 k2 不绑定消息，random 以外的模式以主密钥派生 k2，随机数发生器失效时 k2 仍不重复
+		var secret []byte
+		if mode, _ := crypto.ParseNonceMode(config.AppConfig.Cosign.NonceMode); mode != crypto.NonceRandom {
+			masterKey, err := crypto.ParseMasterKey(config.AppConfig.Auth.MasterKey)
+			if err != nil {
+				log.Fatalf("cosign.nonce_pool_size requires auth.master_key unless cosign.nonce_mode is random: %v", err)
+			}
+			secret = masterKey
+		}
+		pool := crypto.NewNoncePool(size, secret)
+		crypto.SetNoncePool(pool)
+		defer pool.Close()
+		log.Printf("Nonce pool enabled, size %d", size)
+	}
+
+	if err := keystore.Init(config.AppConfig.KeyStore, config.AppConfig.Auth.MasterKey); err != nil {
+		log.Fatalf("Failed to initialize key store: %v", err)
+	}
+	log.Printf("Key store backend: %s", keystore.Primary().Name())
+
+	if pub, err := service.LoadRecoveryPublicKey(); err != nil {
+		log.Fatalf("Invalid backup.recovery_key_file: %v", err)
+	} else if pub != nil {
+		log.Printf("Key backup export enabled, recovery key %s", crypto.RecoveryKeyID(pub))
+	}
+
+	if ca, err := service.InitCA(); err != nil {
+		log.Fatalf("Failed to initialize CA: %v", err)
+	} else if ca != nil {
+		log.Printf("Certificate issuance enabled, root CA %q", ca.Certificate().Subject.CommonName)
+	}
+
 	if err := initDatabase(); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer repository.CloseDB()
 
+	if err := service.CheckKeyStores(); err != nil {
+		log.Fatalf("Key store check failed: %v", err)
+	}
+
 	if err := service.InitAdminUser(); err != nil {
 		log.Printf("Warning: Failed to initialize admin user: %v", err)
 	}
 
-	app := fiber.New(fiber.Config{
-		AppName:      "SM2 Co-Sign Server v1.0",
-		ServerHeader: "SM2-CoSign",
-	})
+	registerMetrics()
 
-	app.Use(recover.New())
-	app.Use(logger.New())
-	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders: "Origin,Content-Type,Accept,Authorization",
-	}))
+	monitor := service.NewKeyExpiryMonitor()
+	monitor.Start()
+	defer monitor.Close()
 
-	setupRoutes(app)
+	app := newApp()
 
 	go func() {
 		addr := fmt.Sprintf(":%d", config.AppConfig.Server.Port)
@@ -63,6 +114,17 @@ func main() {
 
 	log.Printf("Server started on port %d", config.AppConfig.Server.Port)
 
+	var metricsApp *fiber.App
+	if addr := config.AppConfig.Metrics.Listen; addr != "" {
+		metricsApp = newMetricsApp()
+		go func() {
+			if err := metricsApp.Listen(addr); err != nil {
+				log.Fatalf("Failed to start metrics server: %v", err)
+			}
+		}()
+		log.Printf("Metrics served on %s", addr)
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
@@ -71,25 +133,43 @@ func main() {
 	if err := app.Shutdown(); err != nil {
 		log.Printf("Server shutdown error: %v", err)
 	}
+	if metricsApp != nil {
+		if err := metricsApp.Shutdown(); err != nil {
+			log.Printf("Metrics server shutdown error: %v", err)
+		}
+	}
 	log.Println("Server stopped")
 }
 
 func initDatabase() error {
-	if err := repository.InitDB(config.AppConfig.Database.Path); err != nil {
+	if err := repository.InitDB(config.AppConfig.Database); err != nil {
 		return fmt.Errorf("failed to connect database: %w", err)
 	}
 
-	schemaPath := "scripts/schema.sql"
-	schema, err := os.ReadFile(schemaPath)
-	if err != nil {
-		log.Printf("Warning: schema.sql not found at %s, skipping initialization", schemaPath)
-		return nil
+	// 数据库结构版本高于程序版本时拒绝启动，避免旧程序写坏新结构
+	if err := repository.CheckSchemaVersion(); err != nil {
+		return err
 	}
 
-	db := repository.GetDB()
-	if _, err := db.Exec(string(schema)); err != nil {
-		if err != sql.ErrTxDone {
-			log.Printf("Schema execution warning: %v", err)
+	if config.AppConfig.Database.AutoMigrate {
+		applied, err := repository.MigrateUp(0)
+		if err != nil {
+			return fmt.Errorf("failed to migrate database: %w", err)
+		}
+		for _, version := range applied {
+			log.Printf("Applied migration %d", version)
+		}
+	} else {
+		current, err := repository.SchemaVersion()
+		if err != nil {
+			return err
+		}
+		latest, err := repository.LatestVersion()
+		if err != nil {
+			return err
+		}
+		if current < latest {
+			log.Printf("Warning: database schema version %d is behind %d, run 'migrate up'", current, latest)
 		}
 	}
 
@@ -97,30 +177,142 @@ func initDatabase() error {
 	return nil
 }
 
+// newApp 创建注册了中间件与路由的应用
+func newApp() *fiber.App {
+	app := fiber.New(fiber.Config{
+		AppName:      "SM2 Co-Sign Server v1.0",
+		ServerHeader: "SM2-CoSign",
+	})
+
+	app.Use(recover.New())
+	app.Use(logger.New())
+	app.Use(middleware.MetricsMiddleware())
+	app.Use(cors.New(cors.Config{
+		AllowOrigins: "*",
+		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
+		AllowHeaders: "Origin,Content-Type,Accept,Authorization",
+	}))
+
+	setupRoutes(app)
+	return app
+}
+
+// newMetricsApp 创建只提供 /metrics 的应用，监听 metrics.listen 配置的地址
+func newMetricsApp() *fiber.App {
+	app := fiber.New(fiber.Config{
+		AppName:               "SM2 Co-Sign Metrics",
+		ServerHeader:          "SM2-CoSign",
+		DisableStartupMessage: true,
+	})
+	app.Use(recover.New())
+	setupMetricsRoute(app, handler.NewAdminHandler())
+	return app
+}
+
+// setupMetricsRoute 注册 /metrics，metrics.require_admin 开启时须携带管理员 Token
+func setupMetricsRoute(router fiber.Router, adminHandler *handler.AdminHandler) {
+	handlers := []fiber.Handler{adminHandler.Metrics}
+	if config.AppConfig.Metrics.RequireAdmin {
+		handlers = append([]fiber.Handler{middleware.AdminMiddleware()}, handlers...)
+	}
+	router.Get("/metrics", handlers...)
+}
+
 func setupRoutes(app *fiber.App) {
 	userHandler := handler.NewUserHandler()
 	cosignHandler := handler.NewCosignHandler()
+	certHandler := handler.NewCertHandler()
 	adminHandler := handler.NewAdminHandler()
 
 	api := app.Group("/api")
 	api.Post("/register", userHandler.Register)
 	api.Post("/login", userHandler.Login)
 	api.Post("/logout", userHandler.Logout)
+	api.Get("/cert/ca", certHandler.CA)
+	api.Get("/cert/crl", certHandler.CRL)
+	api.Get("/cert/status/:serial", certHandler.Status)
 
 	authGroup := api.Group("", middleware.AuthMiddleware())
 	authGroup.Get("/user/info", userHandler.GetUserInfo)
+	authGroup.Get("/keys", cosignHandler.Keys)
 	authGroup.Post("/key/init", cosignHandler.KeyInit)
+	authGroup.Post("/key/refresh", cosignHandler.KeyRefresh)
+	authGroup.Post("/key/refresh/commit", cosignHandler.KeyRefreshCommit)
 	authGroup.Post("/sign", cosignHandler.Sign)
+	authGroup.Post("/sign/batch", cosignHandler.SignBatch)
 	authGroup.Post("/decrypt", cosignHandler.Decrypt)
+	authGroup.Post("/keyexchange/init", cosignHandler.KeyExchangeInit)
+	authGroup.Post("/keyexchange/compute", cosignHandler.KeyExchange)
+	authGroup.Post("/cert", certHandler.Issue)
+	authGroup.Get("/cert", certHandler.List)
+
+	// 配置了独立监听地址时指标只在该地址提供
+	if config.AppConfig.Metrics.Listen == "" {
+		setupMetricsRoute(app, adminHandler)
+	}
+
+	// 恢复、清除、导出、解密批准与按需自检须由管理员调用；存活与就绪检查保持公开
+	adminAuth := middleware.AdminMiddleware()
 
 	mapi := app.Group("/mapi")
 	mapi.Get("/health", adminHandler.Health)
+	mapi.Get("/health/live", adminHandler.Health)
+	mapi.Get("/health/ready", adminHandler.Ready)
+	mapi.Post("/health/selftest", adminAuth, adminHandler.SelfTest)
 	mapi.Get("/stats", adminHandler.Stats)
 	mapi.Get("/users", adminHandler.ListUsers)
 	mapi.Get("/users/:id", adminHandler.GetUser)
 	mapi.Delete("/users/:id", adminHandler.DeleteUser)
+	mapi.Post("/users/:id/restore", adminAuth, adminHandler.RestoreUser)
+	mapi.Delete("/users/:id/purge", adminAuth, adminHandler.PurgeUser)
 	mapi.Put("/users/:id/status", adminHandler.UpdateUserStatus)
 	mapi.Get("/keys", adminHandler.ListKeys)
+	mapi.Post("/keys/export", adminAuth, adminHandler.ExportKeys)
 	mapi.Delete("/keys/:id", adminHandler.DeleteKey)
+	mapi.Post("/keys/:id/decrypt-approval", adminAuth, adminHandler.ApproveDecrypt)
+	mapi.Delete("/keys/:id/purge", adminAuth, adminHandler.PurgeKey)
 	mapi.Get("/logs", adminHandler.ListLogs)
 }
+
+func registerMetrics() {
+	sessionRepo := repository.NewSessionRepository()
+	metrics.Default.MustRegister(metrics.NewGaugeFunc(
+		"cosign_active_sessions",
+		"Number of unexpired sessions.",
+		func() (float64, error) {
+			count, err := sessionRepo.CountActive()
+			return float64(count), err
+		},
+	))
+	metrics.Default.MustRegister(metrics.NewGaugeFunc(
+		"cosign_keys_expiring",
+		"Number of keys expiring within key_policy.rotate_before, including expired keys.",
+		func() (float64, error) {
+			count, err := service.CountExpiringKeys()
+			return float64(count), err
+		},
+	))
+	metrics.Default.MustRegister(
+		metrics.NewGaugeFunc(
+			"cosign_nonce_pool_available",
+			"Number of precomputed signing nonces available in the pool.",
+			func() (float64, error) {
+				return float64(crypto.NoncePoolStatus().Available), nil
+			},
+		),
+		metrics.NewCounterFunc(
+			"cosign_nonce_pool_hits_total",
+			"Signatures that took a precomputed nonce from the pool.",
+			func() (float64, error) {
+				return float64(crypto.NoncePoolStatus().Hits), nil
+			},
+		),
+		metrics.NewCounterFunc(
+			"cosign_nonce_pool_misses_total",
+			"Signatures that computed a nonce inline because the pool was empty.",
+			func() (float64, error) {
+				return float64(crypto.NoncePoolStatus().Misses), nil
+			},
+		),
+	)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/sm2-cosign/backend/internal/config"
+	"github.com/sm2-cosign/backend/internal/repository"
+)
+
+const migrateUsage = `Usage: server migrate [-config config.yaml] <command> [arg]
+
+Commands:
+  up [version]   apply pending migrations (up to version, default latest)
+  down [steps]   revert the most recent migrations (default 1)
+  status         show migration status
+`
+
+// runMigrate 执行 migrate 子命令，返回进程退出码
+func runMigrate(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "config file path")
+	fs.Usage = func() { fmt.Fprint(os.Stderr, migrateUsage) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return 2
+	}
+
+	if err := config.Load(*configPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return 1
+	}
+	if err := repository.InitDB(config.AppConfig.Database); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect database: %v\n", err)
+		return 1
+	}
+	defer repository.CloseDB()
+
+	command, arg := fs.Arg(0), fs.Arg(1)
+	switch command {
+	case "up":
+		target := 0
+		if arg != "" {
+			v, err := strconv.Atoi(arg)
+			if err != nil || v <= 0 {
+				fmt.Fprintf(os.Stderr, "Invalid version: %s\n", arg)
+				return 2
+			}
+			target = v
+		}
+		applied, err := repository.MigrateUp(target)
+		for _, v := range applied {
+			fmt.Printf("Applied migration %d\n", v)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Migrate up failed: %v\n", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("No pending migrations")
+		}
+	case "down":
+		steps := 1
+		if arg != "" {
+			v, err := strconv.Atoi(arg)
+			if err != nil || v <= 0 {
+				fmt.Fprintf(os.Stderr, "Invalid steps: %s\n", arg)
+				return 2
+			}
+			steps = v
+		}
+		reverted, err := repository.MigrateDown(steps)
+		for _, v := range reverted {
+			fmt.Printf("Reverted migration %d\n", v)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Migrate down failed: %v\n", err)
+			return 1
+		}
+	case "status":
+		current, err := repository.SchemaVersion()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read schema version: %v\n", err)
+			return 1
+		}
+		statuses, err := repository.MigrationStatuses()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read migration status: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Current version: %d\n", current)
+		for _, st := range statuses {
+			state := "pending"
+			if st.Applied {
+				state = "applied " + st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("  %04d_%s  %s\n", st.Version, st.Name, state)
+		}
+		if err := repository.CheckSchemaVersion(); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+	default:
+		fs.Usage()
+		return 2
+	}
+	return 0
+}
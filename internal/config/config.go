@@ -7,11 +7,76 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Auth     AuthConfig     `mapstructure:"auth"`
-	Log      LogConfig      `mapstructure:"log"`
-	Admin    AdminConfig    `mapstructure:"admin"`
+	Server    ServerConfig    `mapstructure:"server"`
+	Database  DatabaseConfig  `mapstructure:"database"`
+	Auth      AuthConfig      `mapstructure:"auth"`
+	Log       LogConfig       `mapstructure:"log"`
+	Admin     AdminConfig     `mapstructure:"admin"`
+	Cosign    CosignConfig    `mapstructure:"cosign"`
+	Cache     CacheConfig     `mapstructure:"cache"`
+	KeyStore  KeyStoreConfig  `mapstructure:"keystore"`
+	Backup    BackupConfig    `mapstructure:"backup"`
+	KeyPolicy KeyPolicyConfig `mapstructure:"key_policy"`
+	Archive   ArchiveConfig   `mapstructure:"archive"`
+	CA        CAConfig        `mapstructure:"ca"`
+	Metrics   MetricsConfig   `mapstructure:"metrics"`
+}
+
+type MetricsConfig struct {
+	// Listen 指标接口独立监听地址，如 127.0.0.1:9102；配置后业务端口不再提供 /metrics
+	Listen string `mapstructure:"listen"`
+	// RequireAdmin 访问 /metrics 须携带管理员 Token
+	RequireAdmin bool `mapstructure:"require_admin"`
+}
+
+type CAConfig struct {
+	KeyFile      string        `mapstructure:"key_file"`
+	CommonName   string        `mapstructure:"common_name"`
+	RootValidity time.Duration `mapstructure:"root_validity"`
+	CertValidity time.Duration `mapstructure:"cert_validity"`
+	CRLURL       string        `mapstructure:"crl_url"`
+	CRLValidity  time.Duration `mapstructure:"crl_validity"`
+	// Organization 与 OrganizationalUnit 写入签发证书的主题，请求中的主题字段不被采用
+	Organization       []string `mapstructure:"organization"`
+	OrganizationalUnit []string `mapstructure:"organizational_unit"`
+}
+
+type ArchiveConfig struct {
+	PurgeGrace  time.Duration `mapstructure:"purge_grace"`
+	MaxApproval time.Duration `mapstructure:"max_approval"`
+}
+
+type KeyPolicyConfig struct {
+	Validity           time.Duration `mapstructure:"validity"`
+	RotateBefore       time.Duration `mapstructure:"rotate_before"`
+	DecryptAfterExpiry bool          `mapstructure:"decrypt_after_expiry"`
+	CheckInterval      time.Duration `mapstructure:"check_interval"`
+}
+
+type BackupConfig struct {
+	RecoveryKeyFile string `mapstructure:"recovery_key_file"`
+}
+
+type KeyStoreConfig struct {
+	Backend string `mapstructure:"backend"`
+	Dir     string `mapstructure:"dir"`
+	// AllowSoftHSM 允许选用进程内软件 HSM，其分量在进程退出后丢失，仅用于测试
+	AllowSoftHSM bool `mapstructure:"allow_softhsm"`
+}
+
+type CacheConfig struct {
+	SessionSize int           `mapstructure:"session_size"`
+	SessionTTL  time.Duration `mapstructure:"session_ttl"`
+	KeySize     int           `mapstructure:"key_size"`
+	KeyTTL      time.Duration `mapstructure:"key_ttl"`
+}
+
+type CosignConfig struct {
+	NonceMode    string `mapstructure:"nonce_mode"`
+	RequireProof bool   `mapstructure:"require_proof"`
+	BatchMaxSize int    `mapstructure:"batch_max_size"`
+	BatchWorkers int    `mapstructure:"batch_workers"`
+	NoncePool    int    `mapstructure:"nonce_pool_size"`
 }
 
 type AdminConfig struct {
@@ -26,7 +91,16 @@ type ServerConfig struct {
 }
 
 type DatabaseConfig struct {
-	Path string `mapstructure:"path"`
+	Driver          string        `mapstructure:"driver"`
+	Path            string        `mapstructure:"path"`
+	DSN             string        `mapstructure:"dsn"`
+	AutoMigrate     bool          `mapstructure:"auto_migrate"`
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	BusyTimeout     time.Duration `mapstructure:"busy_timeout"`
+	JournalMode     string        `mapstructure:"journal_mode"`
+	Synchronous     string        `mapstructure:"synchronous"`
 }
 
 type AuthConfig struct {
@@ -41,11 +115,44 @@ type LogConfig struct {
 
 var AppConfig *Config
 
+// setDefaults 设置配置默认值
+func setDefaults() {
+	viper.SetDefault("database.driver", "sqlite")
+	viper.SetDefault("database.auto_migrate", true)
+	viper.SetDefault("database.max_open_conns", 10)
+	viper.SetDefault("database.max_idle_conns", 5)
+	viper.SetDefault("database.conn_max_lifetime", time.Hour)
+	viper.SetDefault("database.busy_timeout", 5*time.Second)
+	viper.SetDefault("database.journal_mode", "WAL")
+	viper.SetDefault("database.synchronous", "NORMAL")
+	viper.SetDefault("cosign.nonce_mode", "hedged")
+	viper.SetDefault("cosign.batch_max_size", 1000)
+	viper.SetDefault("cosign.batch_workers", 0)
+	viper.SetDefault("cosign.nonce_pool_size", 0)
+	viper.SetDefault("cache.session_size", 10000)
+	viper.SetDefault("cache.session_ttl", time.Minute)
+	viper.SetDefault("cache.key_size", 10000)
+	viper.SetDefault("cache.key_ttl", 5*time.Minute)
+	viper.SetDefault("keystore.backend", "db")
+	viper.SetDefault("keystore.dir", "./data/keystore")
+	viper.SetDefault("key_policy.validity", 0)
+	viper.SetDefault("key_policy.rotate_before", 30*24*time.Hour)
+	viper.SetDefault("key_policy.decrypt_after_expiry", false)
+	viper.SetDefault("key_policy.check_interval", time.Hour)
+	viper.SetDefault("archive.purge_grace", 30*24*time.Hour)
+	viper.SetDefault("archive.max_approval", 24*time.Hour)
+	viper.SetDefault("ca.common_name", "SM2 Cosign Root CA")
+	viper.SetDefault("ca.root_validity", 20*365*24*time.Hour)
+	viper.SetDefault("ca.cert_validity", 365*24*time.Hour)
+	viper.SetDefault("ca.crl_validity", 24*time.Hour)
+}
+
 func Load(configPath string) error {
 	viper.SetConfigFile(configPath)
 	viper.SetConfigType("yaml")
 
 	viper.AutomaticEnv()
+	setDefaults()
 
 	if err := viper.ReadInConfig(); err != nil {
 		return err
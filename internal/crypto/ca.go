@@ -0,0 +1,324 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/smx509"
+)
+
+// caFileVersion CA 文件格式版本
+const caFileVersion = 1
+
+// caKeyLabel 由主密钥派生 CA 私钥加密密钥的标签
+const caKeyLabel = "sm2-cosign ca key v1"
+
+var (
+	ErrInvalidCAFile = errors.New("invalid CA file")
+	ErrInvalidCSR    = errors.New("invalid SM2 certificate request")
+)
+
+// CA 内置 SM2 证书签发机构，以自签名根证书签发 SM2/SM3 证书
+type CA struct {
+	cert *smx509.Certificate
+	key  *sm2.PrivateKey
+	// CRLURL 写入签发证书的 CRL 分发点，为空时不写入
+	CRLURL string
+}
+
+// caFile CA 文件内容
+// 根证书明文保存，私钥 (PKCS#8) 以主密钥派生的 SM4-GCM 密钥加密，附加数据为根证书 DER
+type caFile struct {
+	Version     int    `json:"version"`
+	Certificate string `json:"certificate"`
+	Nonce       string `json:"nonce"`
+	Key         string `json:"key"`
+}
+
+// NewCA 生成 SM2 根密钥与自签名根证书
+func NewCA(subject pkix.Name, validity time.Duration) (*CA, error) {
+	key, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	template := &x509.Certificate{
+		Subject:               subject,
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+		SubjectKeyId:          subjectKeyID(&key.PublicKey),
+	}
+	der, err := smx509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := smx509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{cert: cert, key: key}, nil
+}
+
+// LoadCA 解析 Marshal 生成的 CA 文件，以主密钥解密根私钥并核对其与根证书匹配
+func LoadCA(data, masterKey []byte) (*CA, error) {
+	var file caFile
+	if err := json.Unmarshal(data, &file); err != nil || file.Version != caFileVersion {
+		return nil, ErrInvalidCAFile
+	}
+	block, _ := pem.Decode([]byte(file.Certificate))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, ErrInvalidCAFile
+	}
+	cert, err := smx509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, ErrInvalidCAFile
+	}
+	nonce, err := base64.StdEncoding.DecodeString(file.Nonce)
+	if err != nil {
+		return nil, ErrInvalidCAFile
+	}
+	sealed, err := base64.StdEncoding.DecodeString(file.Key)
+	if err != nil {
+		return nil, ErrInvalidCAFile
+	}
+
+	aead, err := DeriveMasterSubkeyAEAD(masterKey, caKeyLabel)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, ErrInvalidCAFile
+	}
+	der, err := aead.Open(nil, nonce, sealed, cert.Raw)
+	if err != nil {
+		return nil, ErrInvalidCAFile
+	}
+	defer clear(der)
+	parsed, err := smx509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, ErrInvalidCAFile
+	}
+	key, ok := parsed.(*sm2.PrivateKey)
+	if !ok || !key.PublicKey.Equal(cert.PublicKey) {
+		return nil, ErrInvalidCAFile
+	}
+	return &CA{cert: cert, key: key}, nil
+}
+
+// Marshal 以主密钥加密根私钥，返回可由 LoadCA 读取的 CA 文件内容
+func (ca *CA) Marshal(masterKey []byte) ([]byte, error) {
+	der, err := smx509.MarshalPKCS8PrivateKey(ca.key)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(der)
+
+	aead, err := DeriveMasterSubkeyAEAD(masterKey, caKeyLabel)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(caFile{
+		Version:     caFileVersion,
+		Certificate: string(ca.CertificatePEM()),
+		Nonce:       base64.StdEncoding.EncodeToString(nonce),
+		Key:         base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, der, ca.cert.Raw)),
+	}, "", "  ")
+}
+
+// Certificate 返回根证书
+func (ca *CA) Certificate() *smx509.Certificate {
+	return ca.cert
+}
+
+// CertificatePEM 返回 PEM 编码的根证书
+func (ca *CA) CertificatePEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+}
+
+// Issue 为 64 字节公钥坐标 pub 签发 SM2/SM3 终端证书，序列号随机生成
+// 到期时间不晚于根证书的到期时间
+func (ca *CA) Issue(pub []byte, subject pkix.Name, notBefore, notAfter time.Time) (*smx509.Certificate, error) {
+	x, y, err := parsePoint(pub)
+	if err != nil {
+		return nil, err
+	}
+	publicKey := &ecdsa.PublicKey{Curve: sm2.P256(), X: x, Y: y}
+	if notAfter.After(ca.cert.NotAfter) {
+		notAfter = ca.cert.NotAfter
+	}
+	if !notBefore.Before(notAfter) {
+		return nil, errors.New("certificate validity is empty")
+	}
+
+	template := &x509.Certificate{
+		Subject:               subject,
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageKeyAgreement,
+		BasicConstraintsValid: true,
+		SubjectKeyId:          subjectKeyID(publicKey),
+	}
+	if ca.CRLURL != "" {
+		template.CRLDistributionPoints = []string{ca.CRLURL}
+	}
+	der, err := smx509.CreateCertificate(rand.Reader, template, ca.cert, publicKey, ca.key)
+	if err != nil {
+		return nil, err
+	}
+	return smx509.ParseCertificate(der)
+}
+
+// CreateCRL 以根私钥签发 CRL，返回 DER 编码；number 为递增的 CRL 编号
+func (ca *CA) CreateCRL(entries []x509.RevocationListEntry, number *big.Int, thisUpdate, nextUpdate time.Time) ([]byte, error) {
+	return smx509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		RevokedCertificateEntries: entries,
+		Number:                    number,
+		ThisUpdate:                thisUpdate,
+		NextUpdate:                nextUpdate,
+	}, ca.cert, ca.key)
+}
+
+// Responder 证书状态响应者，由根私钥签发、扩展密钥用途为 OCSPSigning 的委托签名密钥
+// 证书状态查询结果以响应者私钥签名，根私钥只在签发响应者证书时使用
+type Responder struct {
+	cert *smx509.Certificate
+	key  *sm2.PrivateKey
+}
+
+// ErrInvalidResponder 响应者证书不是由根证书签发的有效状态签名证书
+var ErrInvalidResponder = errors.New("invalid certificate status responder")
+
+// NewResponder 生成响应者密钥并以根私钥签发其证书，到期时间不晚于根证书的到期时间
+func (ca *CA) NewResponder(notBefore, notAfter time.Time) (*Responder, error) {
+	key, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if notAfter.After(ca.cert.NotAfter) {
+		notAfter = ca.cert.NotAfter
+	}
+	if !notBefore.Before(notAfter) {
+		return nil, errors.New("responder validity is empty")
+	}
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: ca.cert.Subject.CommonName + " Status Responder"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+		BasicConstraintsValid: true,
+		SubjectKeyId:          subjectKeyID(&key.PublicKey),
+	}
+	der, err := smx509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := smx509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &Responder{cert: cert, key: key}, nil
+}
+
+// Certificate 返回响应者证书
+func (r *Responder) Certificate() *smx509.Certificate {
+	return r.cert
+}
+
+// CertificatePEM 返回 PEM 编码的响应者证书
+func (r *Responder) CertificatePEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: r.cert.Raw})
+}
+
+// Sign 以响应者私钥和默认用户标识对 msg 签名，返回 ASN.1 DER 编码的 SM2 签名
+func (r *Responder) Sign(msg []byte) ([]byte, error) {
+	return r.key.Sign(rand.Reader, msg, sm2.DefaultSM2SignerOpts)
+}
+
+// VerifyResponder 校验 cert 由根证书 root 签发、扩展密钥用途含 OCSPSigning 且在 at 时刻有效
+func VerifyResponder(cert, root *smx509.Certificate, at time.Time) error {
+	if err := cert.CheckSignatureFrom(root); err != nil {
+		return ErrInvalidResponder
+	}
+	if cert.IsCA || at.Before(cert.NotBefore) || at.After(cert.NotAfter) {
+		return ErrInvalidResponder
+	}
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageOCSPSigning {
+			return nil
+		}
+	}
+	return ErrInvalidResponder
+}
+
+// 证书状态
+const (
+	CertStatusGood    = "good"
+	CertStatusRevoked = "revoked"
+	CertStatusUnknown = "unknown"
+)
+
+// CertStatusMessage 证书状态查询结果的签名原文，时间以秒精度的 RFC 3339 (UTC) 编码，未吊销时吊销时间为空
+func CertStatusMessage(serialNumber, status string, revokedAt *time.Time, reason int, producedAt, nextUpdate time.Time) []byte {
+	revoked := ""
+	if revokedAt != nil {
+		revoked = revokedAt.UTC().Format(time.RFC3339)
+	}
+	return []byte(fmt.Sprintf("sm2-cosign cert status v1\n%s\n%s\n%s\n%d\n%s\n%s",
+		serialNumber, status, revoked, reason,
+		producedAt.UTC().Format(time.RFC3339), nextUpdate.UTC().Format(time.RFC3339)))
+}
+
+// ParseCSR 解析 DER 或 PEM 编码的 SM2 证书请求并以默认用户标识验证其签名
+// 返回请求与其中公钥的 64 字节坐标
+func ParseCSR(data []byte) (*smx509.CertificateRequest, []byte, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != "CERTIFICATE REQUEST" {
+			return nil, nil, ErrInvalidCSR
+		}
+		data = block.Bytes
+	}
+	csr, err := smx509.ParseCertificateRequest(data)
+	if err != nil {
+		return nil, nil, ErrInvalidCSR
+	}
+	pub, ok := csr.PublicKey.(*ecdsa.PublicKey)
+	if !ok || pub.Curve != sm2.P256() || csr.SignatureAlgorithm != smx509.SM2WithSM3 {
+		return nil, nil, ErrInvalidCSR
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, nil, ErrInvalidCSR
+	}
+	point, err := pointBytes(pub.X, pub.Y)
+	if err != nil {
+		return nil, nil, ErrInvalidCSR
+	}
+	return csr, point, nil
+}
+
+// subjectKeyID 证书主体密钥标识 = SM3(04 || X || Y) 前 20 字节
+func subjectKeyID(pub *ecdsa.PublicKey) []byte {
+	point, err := pointBytes(pub.X, pub.Y)
+	if err != nil {
+		return nil
+	}
+	return SM3Hash(append([]byte{0x04}, point...))[:20]
+}
@@ -0,0 +1,216 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/smx509"
+)
+
+func TestCAFile(t *testing.T) {
+	ca, err := NewCA(pkix.Name{CommonName: "Test Root CA"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ca.Certificate().IsCA || ca.Certificate().Subject.CommonName != "Test Root CA" {
+		t.Fatalf("unexpected root certificate: %+v", ca.Certificate().Subject)
+	}
+
+	masterKey := bytes.Repeat([]byte{0x42}, MasterKeySize)
+	data, err := ca.Marshal(masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadCA(data, masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Certificate().Equal(ca.Certificate()) {
+		t.Fatal("loaded root certificate differs")
+	}
+
+	// 错误的主密钥、被替换的根证书均无法加载
+	if _, err := LoadCA(data, bytes.Repeat([]byte{0x24}, MasterKeySize)); err != ErrInvalidCAFile {
+		t.Fatalf("wrong master key: %v", err)
+	}
+	other, err := NewCA(pkix.Name{CommonName: "Other CA"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	swapped := bytes.Replace(data, bytes.ReplaceAll(ca.CertificatePEM(), []byte("\n"), []byte(`\n`)),
+		bytes.ReplaceAll(other.CertificatePEM(), []byte("\n"), []byte(`\n`)), 1)
+	if bytes.Equal(swapped, data) {
+		t.Fatal("certificate not found in CA file")
+	}
+	if _, err := LoadCA(swapped, masterKey); err != ErrInvalidCAFile {
+		t.Fatalf("swapped certificate: %v", err)
+	}
+}
+
+func TestCAIssue(t *testing.T) {
+	ca, err := NewCA(pkix.Name{CommonName: "Test Root CA"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := smx509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "alice"},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csr, pub, err := ParseCSR(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := pointBytes(key.X, key.Y)
+	if !bytes.Equal(pub, want) || csr.Subject.CommonName != "alice" {
+		t.Fatal("unexpected certificate request content")
+	}
+
+	// 签名被篡改的请求
+	tampered := bytes.Clone(der)
+	tampered[len(tampered)-1] ^= 1
+	if _, _, err := ParseCSR(tampered); err != ErrInvalidCSR {
+		t.Fatalf("tampered request: %v", err)
+	}
+
+	now := time.Now()
+	cert, err := ca.Issue(pub, pkix.Name{CommonName: "alice"}, now.Add(-time.Minute), now.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cert.NotAfter.Equal(ca.Certificate().NotAfter) {
+		t.Fatalf("not after %v exceeds root certificate %v", cert.NotAfter, ca.Certificate().NotAfter)
+	}
+	if cert.IsCA || cert.SignatureAlgorithm != smx509.SM2WithSM3 || cert.SerialNumber.Sign() <= 0 {
+		t.Fatal("unexpected issued certificate")
+	}
+
+	roots := smx509.NewCertPool()
+	roots.AddCert(ca.Certificate())
+	if _, err := cert.Verify(smx509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	if _, err := ca.Issue(make([]byte, PointSize), pkix.Name{}, now, now.Add(time.Hour)); err == nil {
+		t.Fatal("invalid public key accepted")
+	}
+}
+
+func TestCACRL(t *testing.T) {
+	ca, err := NewCA(pkix.Name{CommonName: "Test Root CA"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca.CRLURL = "http://127.0.0.1/api/cert/crl"
+	key, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _ := pointBytes(key.X, key.Y)
+	now := time.Now().UTC().Truncate(time.Second)
+	cert, err := ca.Issue(pub, pkix.Name{CommonName: "alice"}, now, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cert.CRLDistributionPoints) != 1 || cert.CRLDistributionPoints[0] != ca.CRLURL {
+		t.Fatalf("CRL distribution points: %v", cert.CRLDistributionPoints)
+	}
+
+	der, err := ca.CreateCRL([]x509.RevocationListEntry{
+		{SerialNumber: cert.SerialNumber, RevocationTime: now, ReasonCode: 4},
+	}, big.NewInt(7), now, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := smx509.ParseRevocationList(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crl.CheckSignatureFrom(ca.Certificate()); err != nil {
+		t.Fatalf("CRL signature: %v", err)
+	}
+	if crl.Number.Int64() != 7 || len(crl.RevokedCertificateEntries) != 1 {
+		t.Fatalf("unexpected CRL number %v or entries %d", crl.Number, len(crl.RevokedCertificateEntries))
+	}
+	entry := crl.RevokedCertificateEntries[0]
+	if entry.SerialNumber.Cmp(cert.SerialNumber) != 0 || entry.ReasonCode != 4 || !entry.RevocationTime.Equal(now) {
+		t.Fatalf("unexpected CRL entry %+v", entry)
+	}
+}
+
+func TestCAResponder(t *testing.T) {
+	ca, err := NewCA(pkix.Name{CommonName: "Test Root CA"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	responder, err := ca.NewResponder(now, now.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := responder.Certificate()
+	if !cert.NotAfter.Equal(ca.Certificate().NotAfter) {
+		t.Errorf("responder expires %v, want root expiry %v", cert.NotAfter, ca.Certificate().NotAfter)
+	}
+	if cert.PublicKey.(*ecdsa.PublicKey).Equal(ca.Certificate().PublicKey) {
+		t.Fatal("responder reuses the root key")
+	}
+
+	msg := []byte("certificate status")
+	sig, err := responder.Sign(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sm2.VerifyASN1WithSM2(cert.PublicKey.(*ecdsa.PublicKey), nil, msg, sig) {
+		t.Fatal("status signature verification failed")
+	}
+
+	other, err := NewCA(pkix.Name{CommonName: "Other Root CA"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _ := pointBytes(key.X, key.Y)
+	endEntity, err := ca.Issue(pub, pkix.Name{CommonName: "alice"}, now, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		cert *smx509.Certificate
+		root *smx509.Certificate
+		at   time.Time
+		ok   bool
+	}{
+		{"valid", cert, ca.Certificate(), now, true},
+		{"other root", cert, other.Certificate(), now, false},
+		{"expired", cert, ca.Certificate(), cert.NotAfter.Add(time.Second), false},
+		{"root certificate", ca.Certificate(), ca.Certificate(), now, false},
+		{"without OCSPSigning", endEntity, ca.Certificate(), now, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyResponder(tt.cert, tt.root, tt.at)
+			if (err == nil) != tt.ok {
+				t.Errorf("got %v, want ok = %v", err, tt.ok)
+			}
+		})
+	}
+}
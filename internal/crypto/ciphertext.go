@@ -0,0 +1,99 @@
+package crypto
+
+import (
+	"encoding/asn1"
+	"errors"
+	"math/big"
+
+	"github.com/emmansun/gmsm/sm3"
+)
+
+// CiphertextFormat SM2 密文编码格式
+type CiphertextFormat string
+
+const (
+	// CiphertextAuto 按首字节识别 C1C3C2 (0x04) 与 ASN.1 (0x30)，C1C2C3 无法自动识别
+	CiphertextAuto CiphertextFormat = ""
+	// CiphertextC1C3C2 GB/T 32918.4-2016 拼接顺序
+	CiphertextC1C3C2 CiphertextFormat = "c1c3c2"
+	// CiphertextC1C2C3 旧标准拼接顺序
+	CiphertextC1C2C3 CiphertextFormat = "c1c2c3"
+	// CiphertextASN1 GM/T 0009 ASN.1 编码
+	CiphertextASN1 CiphertextFormat = "asn1"
+)
+
+var (
+	ErrInvalidCiphertext       = errors.New("invalid SM2 ciphertext")
+	ErrInvalidCiphertextFormat = errors.New("invalid ciphertext format")
+)
+
+// ParseCiphertextFormat 解析密文格式，空字符串表示自动识别
+func ParseCiphertextFormat(s string) (CiphertextFormat, error) {
+	switch f := CiphertextFormat(s); f {
+	case CiphertextAuto, CiphertextC1C3C2, CiphertextC1C2C3, CiphertextASN1:
+		return f, nil
+	default:
+		return "", ErrInvalidCiphertextFormat
+	}
+}
+
+// Ciphertext 拆分后的 SM2 密文
+type Ciphertext struct {
+	C1 []byte // 64 字节坐标，已校验在曲线上
+	C3 []byte // SM3 杂凑值
+	C2 []byte // 密文数据
+}
+
+// asn1Ciphertext GM/T 0009 SM2Cipher
+type asn1Ciphertext struct {
+	X, Y *big.Int
+	Hash []byte
+	Data []byte
+}
+
+// ParseCiphertext 按指定格式拆分 SM2 密文，拼接格式的 C1 须为 04 前缀的未压缩点
+// 返回的各分量引用 data 的底层数组
+func ParseCiphertext(data []byte, format CiphertextFormat) (*Ciphertext, error) {
+	if format == CiphertextAuto {
+		format = CiphertextC1C3C2
+		if len(data) > 0 && data[0] == 0x30 {
+			format = CiphertextASN1
+		}
+	}
+
+	var ct Ciphertext
+	switch format {
+	case CiphertextASN1:
+		var raw asn1Ciphertext
+		rest, err := asn1.Unmarshal(data, &raw)
+		if err != nil || len(rest) != 0 || raw.X.Sign() < 0 || raw.Y.Sign() < 0 ||
+			raw.X.BitLen() > 8*ScalarSize || raw.Y.BitLen() > 8*ScalarSize {
+			return nil, ErrInvalidCiphertext
+		}
+		ct.C1 = make([]byte, PointSize)
+		raw.X.FillBytes(ct.C1[:ScalarSize])
+		raw.Y.FillBytes(ct.C1[ScalarSize:])
+		ct.C3, ct.C2 = raw.Hash, raw.Data
+	case CiphertextC1C3C2, CiphertextC1C2C3:
+		if len(data) < 1+PointSize+sm3.Size || data[0] != 0x04 {
+			return nil, ErrInvalidCiphertext
+		}
+		ct.C1 = data[1 : 1+PointSize]
+		body := data[1+PointSize:]
+		if format == CiphertextC1C3C2 {
+			ct.C3, ct.C2 = body[:sm3.Size], body[sm3.Size:]
+		} else {
+			ct.C2, ct.C3 = body[:len(body)-sm3.Size], body[len(body)-sm3.Size:]
+		}
+	default:
+		return nil, ErrInvalidCiphertextFormat
+	}
+
+	if len(ct.C3) != sm3.Size || len(ct.C2) == 0 {
+		return nil, ErrInvalidCiphertext
+	}
+	if _, _, err := parsePoint(ct.C1); err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return &ct, nil
+}
@@ -0,0 +1,137 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/emmansun/gmsm/sm2"
+)
+
+// encryptAll 以三种格式加密同一明文
+func encryptAll(t *testing.T, pub []byte, plaintext []byte) map[CiphertextFormat][]byte {
+	t.Helper()
+	pk, err := sm2.NewPublicKey(append([]byte{0x04}, pub...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := map[CiphertextFormat]*sm2.EncrypterOpts{
+		CiphertextC1C3C2: sm2.NewPlainEncrypterOpts(sm2.MarshalUncompressed, sm2.C1C3C2),
+		CiphertextC1C2C3: sm2.NewPlainEncrypterOpts(sm2.MarshalUncompressed, sm2.C1C2C3),
+		CiphertextASN1:   sm2.ASN1EncrypterOpts,
+	}
+	out := make(map[CiphertextFormat][]byte, len(opts))
+	for format, opt := range opts {
+		ct, err := sm2.Encrypt(rand.Reader, pk, plaintext, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[format] = ct
+	}
+	return out
+}
+
+func TestParseCiphertext(t *testing.T) {
+	key, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := make([]byte, PointSize)
+	key.X.FillBytes(pub[:ScalarSize])
+	key.Y.FillBytes(pub[ScalarSize:])
+	plaintext := []byte("ciphertext formats")
+
+	for format, data := range encryptAll(t, pub, plaintext) {
+		t.Run(string(format), func(t *testing.T) {
+			ct, err := ParseCiphertext(data, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ct.C1) != PointSize || len(ct.C2) != len(plaintext) {
+				t.Fatalf("unexpected sizes: C1 %d, C2 %d", len(ct.C1), len(ct.C2))
+			}
+
+			// 按拆分结果重新拼接为 C1C3C2 后应能用私钥解密
+			plain := append(append(append([]byte{0x04}, ct.C1...), ct.C3...), ct.C2...)
+			got, err := sm2.Decrypt(key, plain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Fatalf("plaintext = %q, want %q", got, plaintext)
+			}
+
+			if format != CiphertextC1C2C3 {
+				auto, err := ParseCiphertext(data, CiphertextAuto)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(auto.C1, ct.C1) || !bytes.Equal(auto.C2, ct.C2) || !bytes.Equal(auto.C3, ct.C3) {
+					t.Fatal("auto-detected ciphertext differs")
+				}
+			}
+		})
+	}
+}
+
+func TestParseCiphertextRejectsMalformed(t *testing.T) {
+	formats := encryptAll(t, randomPoint(t), []byte("malformed"))
+	plain := formats[CiphertextC1C3C2]
+
+	offCurve := append([]byte(nil), plain...)
+	offCurve[PointSize] ^= 1
+	compressed := append([]byte(nil), plain...)
+	compressed[0] = 0x02
+
+	tests := []struct {
+		name   string
+		data   []byte
+		format CiphertextFormat
+		want   error
+	}{
+		{"empty", nil, CiphertextAuto, ErrInvalidCiphertext},
+		{"no-c2", plain[:1+PointSize+32], CiphertextC1C3C2, ErrInvalidCiphertext},
+		{"off-curve", offCurve, CiphertextC1C3C2, ErrInvalidCiphertext},
+		{"compressed", compressed, CiphertextC1C3C2, ErrInvalidCiphertext},
+		{"asn1-as-plain", formats[CiphertextASN1], CiphertextC1C3C2, ErrInvalidCiphertext},
+		{"asn1-trailing", append(append([]byte(nil), formats[CiphertextASN1]...), 0), CiphertextASN1, ErrInvalidCiphertext},
+		{"plain-as-asn1", plain, CiphertextASN1, ErrInvalidCiphertext},
+		{"unknown-format", plain, "c2c1c3", ErrInvalidCiphertextFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCiphertext(tt.data, tt.format); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseCiphertextFormat("C1C3C2"); !errors.Is(err, ErrInvalidCiphertextFormat) {
+		t.Errorf("ParseCiphertextFormat: got %v, want %v", err, ErrInvalidCiphertextFormat)
+	}
+}
+
+func TestCoopDecryptCiphertext(t *testing.T) {
+	keyResult, err := CoopKeyGenInit(randomPoint(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for format, data := range encryptAll(t, keyResult.Pa, []byte("cooperative")) {
+		ct, err := ParseCiphertext(data, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := CoopDecrypt(keyResult.D2Inv, ct.C1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := CoopDecryptCiphertext(keyResult.D2Inv, data, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s: T2 mismatch", format)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package crypto
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/emmansun/gmsm/sm3"
+)
+
+// 协同密钥交换 (GB/T 32918.3 SM2 密钥交换协议的两方协同变体)
+//
+// 协同私钥 d = d1*d2Inv - 1。本方临时私钥取 r = d2Inv*k1，k1 由客户端生成：
+//   1. 客户端发送 K1 = k1*G，服务端返回临时公钥 R = d2Inv*K1，客户端将 R 发给对端
+//   2. 收到对端 (P_B, R_B) 后客户端计算 U = P_B + x̄_B*R_B，W = (d1 + x̄*k1)*U
+//   3. 服务端返回 T2 = d2Inv*W，客户端得到 V = T2 - U = (d + x̄*r)*U
+// 服务端只看到 K1 与 W，无法得到 U 与共享点 V；双方都不会恢复 d 或 r。
+
+// KeyExchange 确认值前缀：响应方 S_B 使用 0x02，发起方 S_A 使用 0x03
+const (
+	KeyExchangeConfirmResponder byte = 0x02
+	KeyExchangeConfirmInitiator byte = 0x03
+)
+
+var (
+	ErrInvalidK1         = errors.New("invalid K1 format")
+	ErrInvalidW          = errors.New("invalid W format")
+	ErrKeyExchangeFailed = errors.New("key exchange failed")
+)
+
+// keyExchangeW SM2 密钥交换参数 w = ceil(ceil(log2(n)) / 2) - 1
+const keyExchangeW = 127
+
+var keyExchangeMask = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), keyExchangeW), big.NewInt(1))
+
+// CoopKeyExchangeInit 生成本方临时公钥 R = d2Inv*K1
+func CoopKeyExchangeInit(d2Inv, k1 []byte) ([]byte, error) {
+	return coopMultiply(d2Inv, k1, ErrInvalidK1)
+}
+
+// CoopKeyExchange 计算 T2 = d2Inv*W，客户端据此得到共享点 V = T2 - U
+func CoopKeyExchange(d2Inv, w []byte) ([]byte, error) {
+	return coopMultiply(d2Inv, w, ErrInvalidW)
+}
+
+// coopMultiply 校验客户端提交的点并乘以 d2Inv
+func coopMultiply(d2Inv, point []byte, errInvalid error) ([]byte, error) {
+	x, y, err := parsePoint(point)
+	if err != nil {
+		return nil, errInvalid
+	}
+	d, err := scalarFromBytes(d2Inv)
+	if err != nil {
+		return nil, ErrKeyExchangeFailed
+	}
+	defer wipeScalar(d)
+	out, err := pointBytes(scalarMult(x, y, d))
+	if err != nil {
+		return nil, ErrKeyExchangeFailed
+	}
+	return out, nil
+}
+
+// KeyExchangeReduce 计算 x̄ = 2^w + (x & (2^w - 1))，x 为临时公钥的横坐标
+func KeyExchangeReduce(x *big.Int) *big.Int {
+	t := new(big.Int).And(x, keyExchangeMask)
+	return t.SetBit(t, keyExchangeW, 1)
+}
+
+// KeyExchangeKey 由共享点 V 派生共享密钥 K = KDF(x_V || y_V || Z_A || Z_B, keyLen)
+// Z_A 为发起方、Z_B 为响应方的用户杂凑值，双方顺序一致
+func KeyExchangeKey(v, zA, zB []byte, keyLen int) []byte {
+	buf := make([]byte, 0, len(v)+len(zA)+len(zB))
+	buf = append(append(append(buf, v...), zA...), zB...)
+	return sm3.Kdf(buf, keyLen)
+}
+
+// KeyExchangeConfirmation 计算可选确认值
+// S = SM3(prefix || y_V || SM3(x_V || Z_A || Z_B || x_RA || y_RA || x_RB || y_RB))
+// rA / rB 分别为发起方与响应方的临时公钥
+func KeyExchangeConfirmation(prefix byte, v, zA, zB, rA, rB []byte) []byte {
+	h := sm3.New()
+	h.Write(v[:ScalarSize])
+	h.Write(zA)
+	h.Write(zB)
+	h.Write(rA)
+	h.Write(rB)
+	inner := h.Sum(nil)
+
+	h.Reset()
+	h.Write([]byte{prefix})
+	h.Write(v[ScalarSize:])
+	h.Write(inner)
+	return h.Sum(nil)
+}
@@ -0,0 +1,202 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/emmansun/gmsm/sm2"
+)
+
+// kepClient 模拟持有 d1 的客户端
+type kepClient struct {
+	d1    *big.Int
+	d2Inv []byte
+	pa    *ecdsa.PublicKey
+	k1    *big.Int
+	r     []byte
+}
+
+func newKEPClient(t *testing.T) *kepClient {
+	t.Helper()
+	d1, err := randomScalar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p1, err := pointBytes(scalarBaseMult(d1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyResult, err := CoopKeyGenInit(p1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pa, err := sm2.NewPublicKey(append([]byte{0x04}, keyResult.Pa...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &kepClient{d1: new(big.Int).SetBytes(scalarBytes(d1)), d2Inv: keyResult.D2Inv, pa: pa}
+}
+
+// init 生成 k1 并由服务端得到临时公钥 R
+func (c *kepClient) init(t *testing.T) *ecdsa.PublicKey {
+	t.Helper()
+	k1, err := randomScalar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.k1 = new(big.Int).SetBytes(scalarBytes(k1))
+	K1, err := pointBytes(scalarBaseMult(k1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.r, err = CoopKeyExchangeInit(c.d2Inv, K1); err != nil {
+		t.Fatal(err)
+	}
+	return toPublicKey(t, c.r)
+}
+
+// sharedPoint 计算 V = d2Inv*(d1 + x̄*k1)*U - U
+func (c *kepClient) sharedPoint(t *testing.T, peerPub, peerR *ecdsa.PublicKey) []byte {
+	t.Helper()
+	ux, uy := SM2Curve.ScalarMult(peerR.X, peerR.Y, KeyExchangeReduce(peerR.X).Bytes())
+	ux, uy = SM2Curve.Add(peerPub.X, peerPub.Y, ux, uy)
+
+	coef := KeyExchangeReduce(new(big.Int).SetBytes(c.r[:ScalarSize]))
+	coef.Mul(coef, c.k1).Add(coef, c.d1).Mod(coef, N)
+	wx, wy := SM2Curve.ScalarMult(ux, uy, coef.Bytes())
+	w, err := pointBytes(wx, wy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t2, err := CoopKeyExchange(c.d2Inv, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, ty, _ := parsePoint(t2)
+	vx, vy := SM2Curve.Add(tx, ty, ux, new(big.Int).Sub(SM2Curve.Params().P, uy))
+	v, err := pointBytes(vx, vy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func toPublicKey(t *testing.T, p []byte) *ecdsa.PublicKey {
+	t.Helper()
+	pub, err := sm2.NewPublicKey(append([]byte{0x04}, p...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub
+}
+
+func publicKeyBytes(pub *ecdsa.PublicKey) []byte {
+	b := make([]byte, PointSize)
+	pub.X.FillBytes(b[:ScalarSize])
+	pub.Y.FillBytes(b[ScalarSize:])
+	return b
+}
+
+func TestCoopKeyExchange(t *testing.T) {
+	const keyLen = 48
+	uid, peerUID := []byte("cooperative"), []byte("peer")
+
+	peerKey, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("initiator", func(t *testing.T) {
+		c := newKEPClient(t)
+		peer, err := sm2.NewKeyExchange(peerKey, c.pa, peerUID, uid, keyLen, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rA := c.init(t)
+		rB, sB, err := peer.RepondKeyExchange(rand.Reader, rA)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		v := c.sharedPoint(t, &peerKey.PublicKey, rB)
+		zA, _ := sm2.CalculateZA(c.pa, uid)
+		zB, _ := sm2.CalculateZA(&peerKey.PublicKey, peerUID)
+		if want := KeyExchangeConfirmation(KeyExchangeConfirmResponder, v, zA, zB, c.r, publicKeyBytes(rB)); !bytes.Equal(sB, want) {
+			t.Fatal("responder confirmation mismatch")
+		}
+		sA := KeyExchangeConfirmation(KeyExchangeConfirmInitiator, v, zA, zB, c.r, publicKeyBytes(rB))
+		peerShared, err := peer.ConfirmInitiator(sA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key := KeyExchangeKey(v, zA, zB, keyLen); !bytes.Equal(key, peerShared) {
+			t.Fatalf("shared key mismatch: %x != %x", key, peerShared)
+		}
+	})
+
+	t.Run("responder", func(t *testing.T) {
+		c := newKEPClient(t)
+		peer, err := sm2.NewKeyExchange(peerKey, c.pa, peerUID, uid, keyLen, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rA, err := peer.InitKeyExchange(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rB := c.init(t)
+
+		v := c.sharedPoint(t, &peerKey.PublicKey, rA)
+		zA, _ := sm2.CalculateZA(&peerKey.PublicKey, peerUID)
+		zB, _ := sm2.CalculateZA(c.pa, uid)
+		sB := KeyExchangeConfirmation(KeyExchangeConfirmResponder, v, zA, zB, publicKeyBytes(rA), c.r)
+		peerShared, sA, err := peer.ConfirmResponder(rB, sB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := KeyExchangeConfirmation(KeyExchangeConfirmInitiator, v, zA, zB, publicKeyBytes(rA), c.r); !bytes.Equal(sA, want) {
+			t.Fatal("initiator confirmation mismatch")
+		}
+		if key := KeyExchangeKey(v, zA, zB, keyLen); !bytes.Equal(key, peerShared) {
+			t.Fatalf("shared key mismatch: %x != %x", key, peerShared)
+		}
+	})
+}
+
+func TestCoopKeyExchangeRejectsInvalidPoints(t *testing.T) {
+	keyResult, err := CoopKeyGenInit(randomPoint(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	offCurve := randomPoint(t)
+	offCurve[PointSize-1] ^= 1
+	for name, p := range map[string][]byte{
+		"infinity":  make([]byte, PointSize),
+		"off-curve": offCurve,
+		"short":     make([]byte, PointSize-1),
+	} {
+		if _, err := CoopKeyExchangeInit(keyResult.D2Inv, p); !errors.Is(err, ErrInvalidK1) {
+			t.Errorf("%s: CoopKeyExchangeInit: got %v, want %v", name, err, ErrInvalidK1)
+		}
+		if _, err := CoopKeyExchange(keyResult.D2Inv, p); !errors.Is(err, ErrInvalidW) {
+			t.Errorf("%s: CoopKeyExchange: got %v, want %v", name, err, ErrInvalidW)
+		}
+	}
+}
+
+func TestKeyExchangeReduce(t *testing.T) {
+	x, _ := new(big.Int).SetString("ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", 16)
+	want, _ := new(big.Int).SetString("ffffffffffffffffffffffffffffffff", 16)
+	if got := KeyExchangeReduce(x); got.Cmp(want) != 0 {
+		t.Errorf("got %x, want %x", got, want)
+	}
+	want.SetBit(big.NewInt(0), keyExchangeW, 1)
+	if got := KeyExchangeReduce(big.NewInt(0)); got.Cmp(want) != 0 {
+		t.Errorf("got %x, want %x", got, want)
+	}
+}
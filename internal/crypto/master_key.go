@@ -0,0 +1,45 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"encoding/hex"
+	"errors"
+
+	"github.com/emmansun/gmsm/sm3"
+	"github.com/emmansun/gmsm/sm4"
+)
+
+// MasterKeySize 主密钥长度 (SM4-128)
+const MasterKeySize = 16
+
+var (
+	ErrMasterKeyMissing = errors.New("master key not configured")
+	ErrMasterKeyInvalid = errors.New("master key must be 32 hex characters")
+)
+
+// ParseMasterKey 解析 hex 编码的主密钥
+func ParseMasterKey(s string) ([]byte, error) {
+	if s == "" {
+		return nil, ErrMasterKeyMissing
+	}
+	key, err := hex.DecodeString(s)
+	if err != nil || len(key) != MasterKeySize {
+		return nil, ErrMasterKeyInvalid
+	}
+	return key, nil
+}
+
+// DeriveMasterSubkeyAEAD 以 HMAC-SM3(主密钥, label) 的前 16 字节为密钥创建 SM4-GCM
+// 不同用途使用不同的 label，派生密钥相互隔离，泄露其一不影响主密钥与其他用途
+func DeriveMasterSubkeyAEAD(masterKey []byte, label string) (cipher.AEAD, error) {
+	mac := hmac.New(sm3.New, masterKey)
+	mac.Write([]byte(label))
+	key := mac.Sum(nil)
+	defer clear(key)
+	block, err := sm4.NewCipher(key[:sm4.BlockSize])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
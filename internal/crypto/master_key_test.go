@@ -0,0 +1,49 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDeriveMasterSubkeyAEAD(t *testing.T) {
+	masterKey := bytes.Repeat([]byte{0x42}, MasterKeySize)
+	seal := func(key []byte, label string) []byte {
+		t.Helper()
+		aead, err := DeriveMasterSubkeyAEAD(key, label)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return aead.Seal(nil, make([]byte, aead.NonceSize()), []byte("plaintext"), nil)
+	}
+	open := func(key []byte, label string, ciphertext []byte) bool {
+		t.Helper()
+		aead, err := DeriveMasterSubkeyAEAD(key, label)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = aead.Open(nil, make([]byte, aead.NonceSize()), ciphertext, nil)
+		return err == nil
+	}
+
+	ciphertext := seal(masterKey, "label a")
+	if !bytes.Equal(ciphertext, seal(masterKey, "label a")) {
+		t.Fatal("derivation is not deterministic")
+	}
+	tests := []struct {
+		name  string
+		key   []byte
+		label string
+		ok    bool
+	}{
+		{"same key and label", masterKey, "label a", true},
+		{"other label", masterKey, "label b", false},
+		{"other master key", bytes.Repeat([]byte{0x43}, MasterKeySize), "label a", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := open(tt.key, tt.label, ciphertext); got != tt.ok {
+				t.Errorf("open = %v, want %v", got, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	"filippo.io/bigmod"
+	"github.com/emmansun/gmsm/sm3"
+)
+
+// NonceMode 协同签名随机数 k2/k3 的生成方式
+type NonceMode string
+
+const (
+	// NonceRandom 直接从 crypto/rand 采样
+	NonceRandom NonceMode = "random"
+	// NonceHedged 由 d2Inv、e、Q1 与新鲜随机数经 HMAC-SM3 DRBG 派生 (RFC 6979 3.6 附加数据)
+	// 随机数发生器失效时退化为确定性派生，不会因随机数重复泄露服务端私钥分量
+	NonceHedged NonceMode = "hedged"
+	// NonceDeterministic 仅由 d2Inv、e、Q1 派生 (RFC 6979)，相同输入得到相同签名，可用于已知答案测试
+	NonceDeterministic NonceMode = "deterministic"
+)
+
+// DefaultNonceMode 默认随机数生成方式
+const DefaultNonceMode = NonceHedged
+
+// ErrInvalidNonceMode 不支持的随机数生成方式
+var ErrInvalidNonceMode = errors.New("invalid nonce mode")
+
+// ParseNonceMode 解析随机数生成方式，空字符串返回默认值
+func ParseNonceMode(s string) (NonceMode, error) {
+	if s == "" {
+		return DefaultNonceMode, nil
+	}
+	switch mode := NonceMode(strings.ToLower(s)); mode {
+	case NonceRandom, NonceHedged, NonceDeterministic:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrInvalidNonceMode, s)
+	}
+}
+
+// nonceSource 标量随机数来源，使用完毕后调用 wipe 清零内部状态
+type nonceSource interface {
+	next() (*bigmod.Nat, error)
+	wipe()
+}
+
+// randomNonces 从 crypto/rand 采样
+type randomNonces struct{}
+
+func (randomNonces) next() (*bigmod.Nat, error) {
+	return randomScalar()
+}
+
+func (randomNonces) wipe() {}
+
+// newNonceSource 按生成方式创建协同签名的随机数来源
+// 派生输入: x = d2Inv, h1 = SM3(e || Q1)，hedged 模式附加 32 字节新鲜随机数
+func newNonceSource(mode NonceMode, d2Inv *bigmod.Nat, e, q1 []byte) (nonceSource, error) {
+	switch mode {
+	case NonceRandom:
+		return randomNonces{}, nil
+	case NonceHedged, NonceDeterministic:
+		h := sm3.New()
+		h.Write(e)
+		h.Write(q1)
+		var extra []byte
+		if mode == NonceHedged {
+			var err error
+			if extra, err = GenerateRandom(ScalarSize); err != nil {
+				return nil, err
+			}
+		}
+		x := scalarBytes(d2Inv)
+		defer clear(x)
+		return newHMACDRBG(sm3.New, orderModulus, x, h.Sum(nil), extra)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidNonceMode, mode)
+	}
+}
+
+// hmacDRBG RFC 6979 3.2 的 HMAC-DRBG
+// 仅支持哈希输出长度与阶的字节长度相同的情形 (SM3 / SM2 均为 256 位)
+type hmacDRBG struct {
+	newHash func() hash.Hash
+	q       *bigmod.Modulus
+	k, v    []byte
+	started bool
+}
+
+// newHMACDRBG 以私钥 x、消息摘要 h1 和可选附加数据初始化 DRBG (RFC 6979 3.2 b-g, 3.6)
+func newHMACDRBG(newHash func() hash.Hash, q *bigmod.Modulus, x, h1, extra []byte) (*hmacDRBG, error) {
+	size := newHash().Size()
+	if q.Size() != size || len(x) != size {
+		return nil, errors.New("crypto: unsupported DRBG parameters")
+	}
+	// bits2octets(h1) = (h1 mod q)
+	h, err := bigmod.NewNat().SetOverflowingBytes(h1, q)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &hmacDRBG{
+		newHash: newHash,
+		q:       q,
+		k:       make([]byte, size),
+		v:       make([]byte, size),
+	}
+	for i := range g.v {
+		g.v[i] = 0x01
+	}
+	hb := h.Bytes(q)
+	replace(&g.k, g.mac(g.k, g.v, []byte{0x00}, x, hb, extra))
+	replace(&g.v, g.mac(g.k, g.v))
+	replace(&g.k, g.mac(g.k, g.v, []byte{0x01}, x, hb, extra))
+	replace(&g.v, g.mac(g.k, g.v))
+	return g, nil
+}
+
+// replace 以 b 替换 *dst 并清零旧值，DRBG 的历史状态可推出此前输出的随机数
+func replace(dst *[]byte, b []byte) {
+	clear(*dst)
+	*dst = b
+}
+
+// wipe 清零 DRBG 状态
+func (g *hmacDRBG) wipe() {
+	clear(g.k)
+	clear(g.v)
+}
+
+func (g *hmacDRBG) mac(key []byte, data ...[]byte) []byte {
+	m := hmac.New(g.newHash, key)
+	for _, d := range data {
+		m.Write(d)
+	}
+	return m.Sum(nil)
+}
+
+// next 生成下一个 [1, q-1] 内的随机数 (RFC 6979 3.2 h)
+// 候选值超出范围或需要更多随机数时按 3.2 h.3 更新状态
+func (g *hmacDRBG) next() (*bigmod.Nat, error) {
+	for {
+		if g.started {
+			replace(&g.k, g.mac(g.k, g.v, []byte{0x00}))
+			replace(&g.v, g.mac(g.k, g.v))
+		}
+		g.started = true
+
+		replace(&g.v, g.mac(g.k, g.v))
+		k, err := bigmod.NewNat().SetBytes(g.v, g.q)
+		if err == nil && k.IsZero() == 0 {
+			return k, nil
+		}
+	}
+}
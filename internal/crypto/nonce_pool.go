@@ -0,0 +1,178 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"filippo.io/bigmod"
+	"github.com/emmansun/gmsm/sm3"
+)
+
+// noncePoolLabel 由服务端密钥派生随机数池 DRBG 密钥的标签
+const noncePoolLabel = "sm2-cosign nonce pool v1"
+
+// NoncePool 协同签名随机数 k2 的预计算池，条目为 (k2, k2*G)
+// 后台 goroutine 持续补充至容量上限；条目经通道取出，每个 k2 只会被一次签名使用。
+// k2 重复使用会由 s3 = d2*(r + k2) 泄露 d2，因此条目取出后不会放回，池关闭时剩余条目全部丢弃。
+// 以服务端密钥创建时，k2 由 HMAC-SM3 DRBG 以密钥、池实例标识与计数器及新鲜随机数派生 (与 hedged 模式相同的构造)，
+// 随机数发生器输出重复时 k2 仍互不相同；deterministic 模式不使用池。
+type NoncePool struct {
+	nonces chan *pooledNonce
+	stop   chan struct{}
+	done   chan struct{}
+	close  sync.Once
+
+	// seed DRBG 密钥，nil 时 k2 直接来自系统随机数；instance 与 counter 仅由 fill 访问
+	seed     []byte
+	instance []byte
+	counter  uint64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+type pooledNonce struct {
+	k    *bigmod.Nat
+	x, y *big.Int
+}
+
+// NoncePoolStats 随机数池统计
+type NoncePoolStats struct {
+	Size      int    // 容量
+	Available int    // 当前可用条目数
+	Hits      uint64 // 签名时从池中取到条目的次数
+	Misses    uint64 // 池为空、签名时现场计算的次数
+}
+
+// noncePoolRetryInterval 随机数生成失败后的重试间隔
+const noncePoolRetryInterval = time.Second
+
+// noncePool 协同签名使用的随机数池，nil 表示不使用
+var noncePool atomic.Pointer[NoncePool]
+
+// SetNoncePool 设置协同签名使用的随机数池，nil 表示关闭，返回之前的池
+func SetNoncePool(p *NoncePool) *NoncePool {
+	return noncePool.Swap(p)
+}
+
+// NoncePoolStatus 返回当前协同签名随机数池的统计信息，未设置池时返回零值
+func NoncePoolStatus() NoncePoolStats {
+	if p := noncePool.Load(); p != nil {
+		return p.Stats()
+	}
+	return NoncePoolStats{}
+}
+
+// NewNoncePool 创建容量为 size 的随机数池并启动后台补充
+// secret 为服务端密钥（如主密钥），非空时以其派生 k2，为空时 k2 直接来自系统随机数，仅适用于 random 模式
+func NewNoncePool(size int, secret []byte) *NoncePool {
+	p := &NoncePool{
+		nonces: make(chan *pooledNonce, size),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if len(secret) > 0 {
+		mac := hmac.New(sm3.New, secret)
+		mac.Write([]byte(noncePoolLabel))
+		p.seed = mac.Sum(nil)
+		// 实例标识区分不同进程的池，随机部分失效时仍有启动时间
+		p.instance = binary.BigEndian.AppendUint64(nil, uint64(time.Now().UnixNano()))
+		if random, err := GenerateRandom(16); err == nil {
+			p.instance = append(p.instance, random...)
+		}
+	}
+	go p.fill()
+	return p
+}
+
+// fill 持续生成条目，池满时阻塞等待消费
+func (p *NoncePool) fill() {
+	defer close(p.done)
+	for {
+		k, err := p.generate()
+		if err != nil {
+			select {
+			case <-p.stop:
+				return
+			case <-time.After(noncePoolRetryInterval):
+				continue
+			}
+		}
+		x, y := scalarBaseMult(k)
+		select {
+		case p.nonces <- &pooledNonce{k: k, x: x, y: y}:
+		case <-p.stop:
+			wipeScalar(k)
+			return
+		}
+	}
+}
+
+// generate 生成一个 k2：未设置密钥时从系统随机数采样，
+// 否则以 x = seed, h1 = SM3(instance || counter) 与 32 字节新鲜随机数初始化 HMAC-SM3 DRBG 派生
+func (p *NoncePool) generate() (*bigmod.Nat, error) {
+	if p.seed == nil {
+		return randomScalar()
+	}
+	extra, err := GenerateRandom(ScalarSize)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(extra)
+	p.counter++
+	h := sm3.New()
+	h.Write(p.instance)
+	h.Write(binary.BigEndian.AppendUint64(nil, p.counter))
+	drbg, err := newHMACDRBG(sm3.New, orderModulus, p.seed, h.Sum(nil), extra)
+	if err != nil {
+		return nil, err
+	}
+	defer drbg.wipe()
+	return drbg.next()
+}
+
+// take 取出一个条目，池未设置或为空时返回 nil，由调用方现场计算
+func (p *NoncePool) take() *pooledNonce {
+	if p == nil {
+		return nil
+	}
+	select {
+	case n := <-p.nonces:
+		p.hits.Add(1)
+		return n
+	default:
+		p.misses.Add(1)
+		return nil
+	}
+}
+
+// Stats 返回池的统计信息
+func (p *NoncePool) Stats() NoncePoolStats {
+	return NoncePoolStats{
+		Size:      cap(p.nonces),
+		Available: len(p.nonces),
+		Hits:      p.hits.Load(),
+		Misses:    p.misses.Load(),
+	}
+}
+
+// Close 停止后台补充并清零丢弃剩余条目，可重复调用
+func (p *NoncePool) Close() {
+	p.close.Do(func() {
+		close(p.stop)
+		<-p.done
+		clear(p.seed)
+		for {
+			select {
+			case n := <-p.nonces:
+				wipeScalar(n.k)
+			default:
+				return
+			}
+		}
+	})
+}
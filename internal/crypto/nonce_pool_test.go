@@ -0,0 +1,161 @@
+package crypto
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emmansun/gmsm/sm3"
+)
+
+// waitFull 等待随机数池补满
+func waitFull(t testing.TB, p *NoncePool) {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for p.Stats().Available < p.Stats().Size {
+		if time.Now().After(deadline) {
+			t.Fatalf("nonce pool not filled: %+v", p.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// usePool 在测试期间设置协同签名随机数池
+func usePool(t testing.TB, p *NoncePool) {
+	prev := SetNoncePool(p)
+	t.Cleanup(func() {
+		SetNoncePool(prev)
+		p.Close()
+	})
+}
+
+func TestNoncePool(t *testing.T) {
+	// 未设置密钥时 k2 直接来自系统随机数，设置密钥时由 DRBG 派生
+	for name, secret := range map[string][]byte{
+		"random": nil,
+		"drbg":   bytes.Repeat([]byte{0x42}, MasterKeySize),
+	} {
+		t.Run(name, func(t *testing.T) {
+			testNoncePool(t, secret)
+		})
+	}
+}
+
+func testNoncePool(t *testing.T, secret []byte) {
+	const size, takers = 32, 8
+	p := NewNoncePool(size, secret)
+	defer p.Close()
+	waitFull(t, p)
+
+	// 并发取出的 k2 互不相同，且 (x, y) = k2*G
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	var wg sync.WaitGroup
+	for i := 0; i < takers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < size/takers; j++ {
+				n := p.take()
+				if n == nil {
+					continue
+				}
+				x, y := scalarBaseMult(n.k)
+				if x.Cmp(n.x) != 0 || y.Cmp(n.y) != 0 {
+					t.Error("pooled point does not match nonce")
+				}
+				mu.Lock()
+				key := string(scalarBytes(n.k))
+				if seen[key] {
+					t.Error("nonce handed out twice")
+				}
+				seen[key] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := p.Stats()
+	if stats.Size != size || stats.Hits != uint64(len(seen)) || stats.Hits+stats.Misses != size {
+		t.Errorf("unexpected stats %+v with %d distinct nonces", stats, len(seen))
+	}
+}
+
+func TestNoncePoolClose(t *testing.T) {
+	p := NewNoncePool(4, bytes.Repeat([]byte{0x42}, MasterKeySize))
+	waitFull(t, p)
+	p.Close()
+	p.Close()
+	if n := p.take(); n != nil {
+		t.Error("closed pool handed out a nonce")
+	}
+	if stats := p.Stats(); stats.Available != 0 || stats.Misses != 1 {
+		t.Errorf("unexpected stats after close %+v", stats)
+	}
+	if len(p.seed) != sm3.Size || !bytes.Equal(p.seed, make([]byte, sm3.Size)) {
+		t.Error("DRBG key not wiped after close")
+	}
+
+	var nilPool *NoncePool
+	if nilPool.take() != nil {
+		t.Error("nil pool handed out a nonce")
+	}
+}
+
+func TestCoopSignWithNoncePool(t *testing.T) {
+	p := NewNoncePool(16, bytes.Repeat([]byte{0x42}, MasterKeySize))
+	usePool(t, p)
+	waitFull(t, p)
+
+	// 确定性模式不使用池，已知答案向量不变；协同签名仍可通过标准验签
+	if err := SelfTest(); err != nil {
+		t.Fatal(err)
+	}
+	if stats := p.Stats(); stats.Hits != 1 {
+		t.Errorf("hits = %d, want 1", stats.Hits)
+	}
+
+	d2Inv, q1, e := katCoopSignInput(t)
+	for _, mode := range []NonceMode{NonceHedged, NonceRandom} {
+		if _, err := CoopSignWithNonce(d2Inv, q1, e, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := NoncePoolStatus(); stats.Hits != 3 {
+		t.Errorf("hits = %d, want 3", stats.Hits)
+	}
+}
+
+// BenchmarkCoopSignNoncePool 对比现场计算 k2*G 与使用预先补满的随机数池的签名延迟
+func BenchmarkCoopSignNoncePool(b *testing.B) {
+	keyResult, err := CoopKeyGenInit(randomPoint(b))
+	if err != nil {
+		b.Fatal(err)
+	}
+	q1 := randomPoint(b)
+	e := SM3Hash([]byte("benchmark"))
+
+	b.Run("inline", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := CoopSign(keyResult.D2Inv, q1, e); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("pool", func(b *testing.B) {
+		p := NewNoncePool(b.N, bytes.Repeat([]byte{0x42}, MasterKeySize))
+		usePool(b, p)
+		waitFull(b, p)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := CoopSign(keyResult.D2Inv, q1, e); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.StopTimer()
+		stats := p.Stats()
+		b.ReportMetric(float64(stats.Hits)/float64(stats.Hits+stats.Misses), "hit-ratio")
+	})
+}
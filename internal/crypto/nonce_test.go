@@ -0,0 +1,144 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"filippo.io/bigmod"
+)
+
+func mustHex(t testing.TB, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TestHMACDRBGRFC6979 使用 RFC 6979 A.2.5 (P-256, SHA-256) 向量校验 DRBG 构造
+func TestHMACDRBGRFC6979(t *testing.T) {
+	q, err := bigmod.NewModulus(mustHex(t, "ffffffff00000000ffffffffffffffffbce6faada7179e84f3b9cac2fc632551"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := mustHex(t, "c9afa9d845ba75166b5c215767b1d6934e50c3db36e89b127b8a622b120f6721")
+
+	tests := []struct {
+		msg string
+		k   string
+	}{
+		{"sample", "a6e3c57dd01abe90086538398355dd4c3b17aa873382b0f24d6129493d8aad60"},
+		{"test", "d16b6ae827f17175e040871a1c7ec3500192c4c92677336ec2537acaee0008e0"},
+	}
+	for _, tt := range tests {
+		h1 := sha256.Sum256([]byte(tt.msg))
+		g, err := newHMACDRBG(sha256.New, q, x, h1[:], nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		k, err := g.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(k.Bytes(q)); got != tt.k {
+			t.Errorf("%s: k = %s, want %s", tt.msg, got, tt.k)
+		}
+	}
+}
+
+func katCoopSignInput(t *testing.T) (d2Inv, q1, e []byte) {
+	d2Inv = mustHex(t, katSM2PrivKey)
+	q1 = append(mustHex(t, katSM2PubX), mustHex(t, katSM2PubY)...)
+	e = SM3Hash([]byte(katSM3Msg))
+	return d2Inv, q1, e
+}
+
+func TestCoopSignDeterministicKAT(t *testing.T) {
+	d2Inv, q1, e := katCoopSignInput(t)
+	for i := 0; i < 2; i++ {
+		result, err := CoopSignWithNonce(d2Inv, q1, e, NonceDeterministic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(result.R); got != katCoopSignR {
+			t.Errorf("r = %s, want %s", got, katCoopSignR)
+		}
+		if got := hex.EncodeToString(result.S2); got != katCoopSignS2 {
+			t.Errorf("s2 = %s, want %s", got, katCoopSignS2)
+		}
+		if got := hex.EncodeToString(result.S3); got != katCoopSignS3 {
+			t.Errorf("s3 = %s, want %s", got, katCoopSignS3)
+		}
+	}
+}
+
+func TestCoopSignNonceInputs(t *testing.T) {
+	d2Inv, q1, e := katCoopSignInput(t)
+	base, err := CoopSignWithNonce(d2Inv, q1, e, NonceDeterministic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 任一派生输入变化都应得到不同的随机数
+	otherE := SM3Hash([]byte("abd"))
+	otherQ1 := randomPoint(t)
+	otherD2Inv := append([]byte(nil), d2Inv...)
+	otherD2Inv[31] ^= 1
+	for name, in := range map[string][3][]byte{
+		"e":     {d2Inv, q1, otherE},
+		"q1":    {d2Inv, otherQ1, e},
+		"d2Inv": {otherD2Inv, q1, e},
+	} {
+		result, err := CoopSignWithNonce(in[0], in[1], in[2], NonceDeterministic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Equal(result.R, base.R) {
+			t.Errorf("changing %s did not change r", name)
+		}
+	}
+
+	// hedged 模式每次签名的随机数不同
+	for _, mode := range []NonceMode{NonceHedged, NonceRandom} {
+		a, err := CoopSignWithNonce(d2Inv, q1, e, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := CoopSignWithNonce(d2Inv, q1, e, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Equal(a.R, b.R) || bytes.Equal(a.R, base.R) {
+			t.Errorf("%s: nonces repeated", mode)
+		}
+	}
+}
+
+func TestParseNonceMode(t *testing.T) {
+	tests := []struct {
+		in   string
+		want NonceMode
+	}{
+		{"", DefaultNonceMode},
+		{"random", NonceRandom},
+		{"Hedged", NonceHedged},
+		{"DETERMINISTIC", NonceDeterministic},
+	}
+	for _, tt := range tests {
+		got, err := ParseNonceMode(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseNonceMode(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := ParseNonceMode("rfc6979"); !errors.Is(err, ErrInvalidNonceMode) {
+		t.Errorf("ParseNonceMode(rfc6979) error = %v, want %v", err, ErrInvalidNonceMode)
+	}
+	d2Inv, q1, e := katCoopSignInput(t)
+	if _, err := CoopSignWithNonce(d2Inv, q1, e, "rfc6979"); err == nil {
+		t.Error("CoopSignWithNonce accepted invalid mode")
+	}
+}
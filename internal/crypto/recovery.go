@@ -0,0 +1,133 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/sm4"
+	"github.com/emmansun/gmsm/smx509"
+)
+
+var (
+	ErrInvalidRecoveryKey = errors.New("invalid SM2 recovery key")
+	ErrRecoveryDecrypt    = errors.New("recovery envelope decryption failed")
+)
+
+// RecoveryEnvelope 以 SM2 恢复公钥封装的数据
+// 随机 SM4 密钥以 SM2 公钥加密 (ASN.1)，数据以该密钥 SM4-GCM 加密，附加数据由调用方绑定
+type RecoveryEnvelope struct {
+	WrappedKey []byte
+	Nonce      []byte
+	Data       []byte
+}
+
+// ParseRecoveryPublicKey 解析 PEM 编码 (PUBLIC KEY) 的 SM2 恢复公钥
+func ParseRecoveryPublicKey(data []byte) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, ErrInvalidRecoveryKey
+	}
+	key, err := smx509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, ErrInvalidRecoveryKey
+	}
+	pub, ok := key.(*ecdsa.PublicKey)
+	if !ok || pub.Curve != sm2.P256() {
+		return nil, ErrInvalidRecoveryKey
+	}
+	return pub, nil
+}
+
+// ParseRecoveryPrivateKey 解析 PEM 编码 (PKCS#8 PRIVATE KEY 或 SEC1 EC PRIVATE KEY) 的 SM2 恢复私钥
+func ParseRecoveryPrivateKey(data []byte) (*sm2.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidRecoveryKey
+	}
+	defer clear(block.Bytes)
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := smx509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, ErrInvalidRecoveryKey
+		}
+		if priv, ok := key.(*sm2.PrivateKey); ok {
+			return priv, nil
+		}
+	case "EC PRIVATE KEY":
+		if priv, err := smx509.ParseSM2PrivateKey(block.Bytes); err == nil {
+			return priv, nil
+		}
+	}
+	return nil, ErrInvalidRecoveryKey
+}
+
+// RecoveryKeyID 恢复公钥标识 = SM3(04 || X || Y) 前 8 字节的 hex，用于核对导出与导入使用同一密钥对
+func RecoveryKeyID(pub *ecdsa.PublicKey) string {
+	point, err := pointBytes(pub.X, pub.Y)
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(SM3Hash(append([]byte{0x04}, point...))[:8])
+}
+
+// SealRecovery 以恢复公钥封装 plaintext，aad 在解封时须原样提供
+func SealRecovery(pub *ecdsa.PublicKey, plaintext, aad []byte) (*RecoveryEnvelope, error) {
+	key := make(SecretBytes, sm4.BlockSize)
+	defer key.Wipe()
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	wrapped, err := sm2.EncryptASN1(rand.Reader, pub, key)
+	if err != nil {
+		return nil, ErrInvalidRecoveryKey
+	}
+	aead, err := newRecoveryAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return &RecoveryEnvelope{
+		WrappedKey: wrapped,
+		Nonce:      nonce,
+		Data:       aead.Seal(nil, nonce, plaintext, aad),
+	}, nil
+}
+
+// OpenRecovery 以恢复私钥解封，返回的明文含私钥分量，调用方负责清零
+func OpenRecovery(priv *sm2.PrivateKey, env *RecoveryEnvelope, aad []byte) (SecretBytes, error) {
+	key, err := sm2.Decrypt(priv, env.WrappedKey)
+	if err != nil || len(key) != sm4.BlockSize {
+		clear(key)
+		return nil, ErrRecoveryDecrypt
+	}
+	defer clear(key)
+	aead, err := newRecoveryAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, ErrRecoveryDecrypt
+	}
+	plaintext, err := aead.Open(nil, env.Nonce, env.Data, aad)
+	if err != nil {
+		return nil, ErrRecoveryDecrypt
+	}
+	return plaintext, nil
+}
+
+func newRecoveryAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := sm4.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,105 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/smx509"
+)
+
+// recoveryKeyPEM 生成 SM2 恢复密钥对，返回 PEM 编码的公钥与 PKCS#8 私钥
+func recoveryKeyPEM(t *testing.T) (pubPEM, privPEM []byte) {
+	t.Helper()
+	key, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubDER, err := smx509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privDER, err := smx509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER})
+}
+
+func TestRecoveryEnvelope(t *testing.T) {
+	pubPEM, privPEM := recoveryKeyPEM(t)
+	pub, err := ParseRecoveryPublicKey(pubPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := ParseRecoveryPrivateKey(privPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if RecoveryKeyID(pub) != RecoveryKeyID(&priv.PublicKey) {
+		t.Fatal("recovery key id differs between public and private key")
+	}
+
+	// SEC1 编码的私钥
+	sec1, err := smx509.MarshalSM2PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseRecoveryPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1})); err != nil {
+		t.Fatalf("SEC1 private key: %v", err)
+	}
+
+	plaintext := []byte("server key shares")
+	aad := []byte("bundle")
+	env, err := SealRecovery(pub, plaintext, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(env.Data, plaintext) {
+		t.Fatal("envelope contains the plaintext")
+	}
+	got, err := OpenRecovery(priv, env, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("opened %q, want %q", got, plaintext)
+	}
+
+	// 附加数据、数据篡改与其他私钥均无法解封
+	if _, err := OpenRecovery(priv, env, []byte("other")); !errors.Is(err, ErrRecoveryDecrypt) {
+		t.Errorf("wrong aad: got %v, want %v", err, ErrRecoveryDecrypt)
+	}
+	tampered := *env
+	tampered.Data = bytes.Clone(env.Data)
+	tampered.Data[0] ^= 1
+	if _, err := OpenRecovery(priv, &tampered, aad); !errors.Is(err, ErrRecoveryDecrypt) {
+		t.Errorf("tampered data: got %v, want %v", err, ErrRecoveryDecrypt)
+	}
+	_, otherPEM := recoveryKeyPEM(t)
+	other, err := ParseRecoveryPrivateKey(otherPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenRecovery(other, env, aad); !errors.Is(err, ErrRecoveryDecrypt) {
+		t.Errorf("other private key: got %v, want %v", err, ErrRecoveryDecrypt)
+	}
+
+	// 非 SM2 或格式错误的密钥
+	for name, data := range map[string][]byte{
+		"empty":       nil,
+		"private-pem": privPEM,
+		"garbage":     pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte{1, 2, 3}}),
+	} {
+		if _, err := ParseRecoveryPublicKey(data); !errors.Is(err, ErrInvalidRecoveryKey) {
+			t.Errorf("public key %s: got %v, want %v", name, err, ErrInvalidRecoveryKey)
+		}
+	}
+	if _, err := ParseRecoveryPrivateKey(pubPEM); !errors.Is(err, ErrInvalidRecoveryKey) {
+		t.Errorf("private key from public PEM: got %v, want %v", err, ErrInvalidRecoveryKey)
+	}
+}
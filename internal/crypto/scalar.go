@@ -0,0 +1,125 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+
+	"filippo.io/bigmod"
+)
+
+// ScalarSize SM2 标量与坐标的字节长度
+const ScalarSize = 32
+
+// PointSize 未压缩点坐标 (x||y，无前缀 04) 的字节长度
+const PointSize = 2 * ScalarSize
+
+var (
+	ErrInvalidScalar = errors.New("invalid scalar")
+	ErrInvalidPoint  = errors.New("invalid curve point")
+)
+
+// orderModulus 曲线阶 n，秘密标量的模运算均通过 bigmod 以常数时间完成
+var orderModulus *bigmod.Modulus
+
+// orderMinusTwo n-2，用于费马小定理求逆
+var orderMinusTwo []byte
+
+func init() {
+	m, err := bigmod.NewModulus(N.FillBytes(make([]byte, ScalarSize)))
+	if err != nil {
+		panic("crypto: invalid curve order: " + err.Error())
+	}
+	orderModulus = m
+	orderMinusTwo = new(big.Int).Sub(N, big.NewInt(2)).FillBytes(make([]byte, ScalarSize))
+}
+
+// randomScalar 生成 [1, n-1] 内的随机标量
+// 使用拒绝采样，被拒绝的候选值与秘密无关
+func randomScalar() (*bigmod.Nat, error) {
+	b := make([]byte, ScalarSize)
+	defer clear(b)
+	for {
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		k, err := bigmod.NewNat().SetBytes(b, orderModulus)
+		if err != nil {
+			continue
+		}
+		if k.IsZero() == 1 {
+			continue
+		}
+		return k, nil
+	}
+}
+
+// scalarFromBytes 解析 [1, n-1] 内的标量，不足 32 字节时左侧补零
+func scalarFromBytes(b []byte) (*bigmod.Nat, error) {
+	if len(b) == 0 || len(b) > ScalarSize {
+		return nil, ErrInvalidScalar
+	}
+	k, err := bigmod.NewNat().SetBytes(b, orderModulus)
+	if err != nil || k.IsZero() == 1 {
+		return nil, ErrInvalidScalar
+	}
+	return k, nil
+}
+
+// scalarReduce 将不超过 32 字节的任意值约减到 [0, n-1]
+func scalarReduce(b []byte) (*bigmod.Nat, error) {
+	if len(b) > ScalarSize {
+		return nil, ErrInvalidScalar
+	}
+	return bigmod.NewNat().SetOverflowingBytes(b, orderModulus)
+}
+
+// scalarInverse 计算 k^(n-2) mod n，即 k 在模 n 下的逆
+func scalarInverse(k *bigmod.Nat) *bigmod.Nat {
+	return bigmod.NewNat().Exp(k, orderMinusTwo, orderModulus)
+}
+
+// scalarBytes 将标量编码为定长 32 字节
+func scalarBytes(k *bigmod.Nat) []byte {
+	return k.Bytes(orderModulus)
+}
+
+// parsePoint 解析 64 字节点坐标并校验其位于曲线上且不是无穷远点
+// sm2ec 对非法点会 panic，所有外部输入的点必须先经过校验
+func parsePoint(b []byte) (x, y *big.Int, err error) {
+	if len(b) != PointSize {
+		return nil, nil, ErrInvalidPoint
+	}
+	x = new(big.Int).SetBytes(b[:ScalarSize])
+	y = new(big.Int).SetBytes(b[ScalarSize:])
+	if !SM2Curve.IsOnCurve(x, y) {
+		return nil, nil, ErrInvalidPoint
+	}
+	return x, y, nil
+}
+
+// pointBytes 将点编码为 64 字节坐标，无穷远点返回错误
+func pointBytes(x, y *big.Int) ([]byte, error) {
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return nil, ErrInvalidPoint
+	}
+	out := make([]byte, PointSize)
+	x.FillBytes(out[:ScalarSize])
+	y.FillBytes(out[ScalarSize:])
+	return out, nil
+}
+
+// scalarBaseMult 计算 k*G
+// sm2ec 对定长 32 字节标量使用常数时间实现
+func scalarBaseMult(k *bigmod.Nat) (x, y *big.Int) {
+	b := scalarBytes(k)
+	defer clear(b)
+	return SM2Curve.ScalarBaseMult(b)
+}
+
+// scalarMult 计算 k*P，P 必须已通过 parsePoint 校验
+func scalarMult(px, py *big.Int, k *bigmod.Nat) (x, y *big.Int) {
+	b := scalarBytes(k)
+	defer clear(b)
+	return SM2Curve.ScalarMult(px, py, b)
+}
@@ -0,0 +1,46 @@
+package crypto
+
+import (
+	"encoding/base64"
+
+	"filippo.io/bigmod"
+)
+
+// SecretBytes 私钥分量等秘密字节串，使用完毕后须调用 Wipe 清零
+// 清零只能缩短秘密在内存中的驻留时间：Base64 字符串、垃圾回收与栈增长留下的副本无法清除
+type SecretBytes []byte
+
+// Wipe 清零整个底层数组
+func (s SecretBytes) Wipe() {
+	clear(s[:cap(s)])
+}
+
+// Clone 返回独立的副本，调用方负责清零
+func (s SecretBytes) Clone() SecretBytes {
+	if s == nil {
+		return nil
+	}
+	return append(SecretBytes(nil), s...)
+}
+
+// DecodeSecretBase64 将 Base64 编码的秘密解码为 SecretBytes，解码失败时清零已写入的部分
+func DecodeSecretBase64(data string) (SecretBytes, error) {
+	src := []byte(data)
+	defer clear(src)
+	buf := make(SecretBytes, base64.StdEncoding.DecodedLen(len(src)))
+	n, err := base64.StdEncoding.Decode(buf, src)
+	if err != nil {
+		buf.Wipe()
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// wipeScalar 清零标量
+func wipeScalar(ks ...*bigmod.Nat) {
+	for _, k := range ks {
+		if k != nil {
+			clear(k.Bits())
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/emmansun/gmsm/sm3"
+)
+
+// isZero 检查字节串是否已全部清零
+func isZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSecretBytes(t *testing.T) {
+	want := bytes.Repeat([]byte{0xa5}, ScalarSize)
+	s, err := DecodeSecretBase64(base64.StdEncoding.EncodeToString(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(s, want) {
+		t.Fatalf("decoded %x, want %x", s, want)
+	}
+
+	c := s.Clone()
+	s.Wipe()
+	if !isZero(s[:cap(s)]) {
+		t.Error("Wipe left secret bytes in the backing array")
+	}
+	if !bytes.Equal(c, want) {
+		t.Error("Wipe cleared an independent clone")
+	}
+
+	if _, err := DecodeSecretBase64("not base64!"); err == nil {
+		t.Error("invalid base64 accepted")
+	}
+	var empty SecretBytes
+	empty.Wipe()
+	if empty.Clone() != nil {
+		t.Error("clone of nil secret is not nil")
+	}
+}
+
+func TestWipeScalar(t *testing.T) {
+	k, err := randomScalar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wipeScalar(k, nil)
+	if k.IsZero() != 1 {
+		t.Error("scalar not cleared")
+	}
+	for _, limb := range k.Bits() {
+		if limb != 0 {
+			t.Fatal("scalar limbs not cleared")
+		}
+	}
+}
+
+func TestCoopKeyGenResultWipe(t *testing.T) {
+	result, err := CoopKeyGenInit(randomPoint(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2Inv := result.D2Inv.Clone()
+	defer d2Inv.Wipe()
+
+	// 协同运算不修改调用方持有的 d2Inv
+	if _, err := CoopSign(d2Inv, randomPoint(t), SM3Hash([]byte("wipe"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CoopDecrypt(d2Inv, randomPoint(t)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(d2Inv, result.D2Inv) {
+		t.Fatal("cooperative operation modified the caller's d2Inv")
+	}
+
+	result.Wipe()
+	if !isZero(result.D2Inv) {
+		t.Error("key generation result still holds the private key shares")
+	}
+	if isZero(result.P2) || isZero(result.Pa) {
+		t.Error("Wipe cleared public points")
+	}
+}
+
+func TestHMACDRBGWipe(t *testing.T) {
+	x := bytes.Repeat([]byte{0x01}, ScalarSize)
+	g, err := newHMACDRBG(sm3.New, orderModulus, x, SM3Hash([]byte("wipe")), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.next(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 状态更新后旧的 K、V 即被清零
+	oldK, oldV := g.k, g.v
+	k, err := g.next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isZero(oldK) || !isZero(oldV) {
+		t.Error("previous DRBG state not cleared")
+	}
+
+	g.wipe()
+	if !isZero(g.k) || !isZero(g.v) {
+		t.Error("DRBG state not cleared")
+	}
+	wipeScalar(k)
+}
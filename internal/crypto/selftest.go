@@ -0,0 +1,275 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"filippo.io/bigmod"
+	"github.com/emmansun/gmsm/sm2"
+)
+
+// 自检已知答案向量 (GB/T 32918 / GB/T 32905 示例)
+const (
+	katSM3Msg     = "abc"
+	katSM3Digest  = "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0"
+	katSM2PrivKey = "3945208f7b2144b13f36e38ac6d39f95889393692860b51a42fb81ef4df7c5b8"
+	katSM2PubX    = "09f9df311e5421a150dd7d161e4bc5c672179fad1833fc076bb08ff356f35020"
+	katSM2PubY    = "ccea490ce26775a52dc6ea718cc1aa600aed05fbf35e084a6632f6072da9ad13"
+	katSM2UID     = "1234567812345678"
+	katSM2Msg     = "message digest"
+	katSM2SigR    = "f5a03b0648d2c4630eeac513e1bb81a15944da3827d5b74143ac7eaceee720b3"
+	katSM2SigS    = "b1b6aa29df212fd8763182bc0d421ca1bb9038fd1f7f42d4840b69c485bbc1aa"
+)
+
+// GB/T 32918 示例签名所用的随机数 k
+const katSM2K = "59276e27d506861a16680f3ad9c02dccef3cc1fa3cdbe4ce6d54b80deac1bc21"
+
+// 协同签名确定性随机数模式已知答案向量
+// d2Inv 取上述 SM2 示例私钥，Q1 取其公钥，e = SM3("abc")；
+// 客户端取 d1 = (d+1)*d2Inv^(-1)、k1 = d 合成的签名须能以示例公钥验签
+const (
+	katCoopSignR  = "ce33e4a19f96e2cebd0748a89af5f63d687bd1ce2ba99a5adb5fa81a7846ba5b"
+	katCoopSignS2 = "9c62e7b8036130231ec4e3992dd5370c9b55d4d12d797abe6eacebd5c448d2c8"
+	katCoopSignS3 = "31f94c7efbe3fcd714a9b0335ea8cf8e8367c5cf933b8f134140965ad6b222cf"
+)
+
+// ErrSelfTestFailed 密码自检失败
+var ErrSelfTestFailed = errors.New("crypto self-test failed")
+
+// SelfTest 执行密码算法自检
+// 包括 SM3 已知答案测试、SM2 公钥派生与验签已知答案测试、协同签名标准示例向量与确定性随机数已知答案测试，
+// 以及协同密钥生成/解密一致性测试
+func SelfTest() error {
+	tests := []struct {
+		name string
+		fn   func() error
+	}{
+		{"sm3", selfTestSM3},
+		{"sm2", selfTestSM2},
+		{"coop_sign", selfTestCoopSign},
+		{"coop", selfTestCoop},
+	}
+	for _, t := range tests {
+		if err := t.fn(); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrSelfTestFailed, t.name, err)
+		}
+	}
+	return nil
+}
+
+func selfTestSM3() error {
+	want, _ := hex.DecodeString(katSM3Digest)
+	if !bytes.Equal(SM3Hash([]byte(katSM3Msg)), want) {
+		return errors.New("digest mismatch")
+	}
+	return nil
+}
+
+func selfTestSM2() error {
+	d, _ := hex.DecodeString(katSM2PrivKey)
+	priv, err := sm2.NewPrivateKey(d)
+	if err != nil {
+		return err
+	}
+	wantX, _ := new(big.Int).SetString(katSM2PubX, 16)
+	wantY, _ := new(big.Int).SetString(katSM2PubY, 16)
+	if priv.X.Cmp(wantX) != 0 || priv.Y.Cmp(wantY) != 0 {
+		return errors.New("public key mismatch")
+	}
+
+	r, _ := new(big.Int).SetString(katSM2SigR, 16)
+	s, _ := new(big.Int).SetString(katSM2SigS, 16)
+	if !sm2.VerifyWithSM2(&priv.PublicKey, []byte(katSM2UID), []byte(katSM2Msg), r, s) {
+		return errors.New("known signature rejected")
+	}
+	// 篡改消息后必须验签失败
+	if sm2.VerifyWithSM2(&priv.PublicKey, []byte(katSM2UID), []byte(katSM2Msg+"."), r, s) {
+		return errors.New("tampered signature accepted")
+	}
+	return nil
+}
+
+// selfTestCoopSign 将 GB/T 32918 示例私钥 d 与随机数 k 拆分为协同签名的两方分量，
+// 合成的签名须与示例签名 (r, s) 一致；再校验确定性随机数模式的已知答案
+func selfTestCoopSign() error {
+	d, _ := new(big.Int).SetString(katSM2PrivKey, 16)
+	k, _ := new(big.Int).SetString(katSM2K, 16)
+	pub := &ecdsa.PublicKey{Curve: SM2Curve}
+	pub.X, _ = new(big.Int).SetString(katSM2PubX, 16)
+	pub.Y, _ = new(big.Int).SetString(katSM2PubY, 16)
+	e, err := sm2.CalculateSM2Hash(pub, []byte(katSM2Msg), []byte(katSM2UID))
+	if err != nil {
+		return err
+	}
+
+	// 任取 d2Inv、k1、k3，令 d1 = (d+1)*d2Inv^(-1)、k2 = k - k1*k3，使 d1*d2Inv - 1 = d、k1*k3 + k2 = k
+	d2Inv := katScalar("d2Inv")
+	k1 := katScalar("k1")
+	k3 := katScalar("k3")
+	d1 := new(big.Int).Add(d, big.NewInt(1))
+	d1.Mul(d1, new(big.Int).ModInverse(d2Inv, N)).Mod(d1, N)
+	k2 := new(big.Int).Mul(k1, k3)
+	k2.Sub(k, k2).Mod(k2, N)
+
+	q1X, q1Y := SM2Curve.ScalarBaseMult(k1.Bytes())
+	q1 := make([]byte, 64)
+	q1X.FillBytes(q1[:32])
+	q1Y.FillBytes(q1[32:])
+	nonces := fixedNonces{natFromInt(k2), natFromInt(k3)}
+	result, err := coopSign(d2Inv.FillBytes(make([]byte, ScalarSize)), q1, e,
+		func(*bigmod.Nat) (nonceSource, error) { return &nonces, nil }, nil)
+	if err != nil {
+		return err
+	}
+	r, s := coopClientSignature(d1, k1, result)
+	if hex.EncodeToString(r.FillBytes(make([]byte, ScalarSize))) != katSM2SigR ||
+		hex.EncodeToString(s.FillBytes(make([]byte, ScalarSize))) != katSM2SigS {
+		return errors.New("known signature mismatch")
+	}
+
+	// 确定性随机数模式: 结果须与已知答案一致，且合成的签名须能以示例公钥验签
+	d2InvBytes, _ := hex.DecodeString(katSM2PrivKey)
+	q1, _ = hex.DecodeString(katSM2PubX + katSM2PubY)
+	e = SM3Hash([]byte(katSM3Msg))
+	result, err = CoopSignWithNonce(d2InvBytes, q1, e, NonceDeterministic)
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(result.R) != katCoopSignR ||
+		hex.EncodeToString(result.S2) != katCoopSignS2 ||
+		hex.EncodeToString(result.S3) != katCoopSignS3 {
+		return errors.New("signature mismatch")
+	}
+	d1 = new(big.Int).Add(d, big.NewInt(1))
+	d1.Mul(d1, new(big.Int).ModInverse(d, N)).Mod(d1, N)
+	r, s = coopClientSignature(d1, d, result)
+	if !sm2.Verify(pub, e, r, s) {
+		return errors.New("deterministic signature rejected")
+	}
+	return nil
+}
+
+// katScalar 由标签派生自检用的标量 SM3(label) mod n
+func katScalar(label string) *big.Int {
+	k := new(big.Int).SetBytes(SM3Hash([]byte(label)))
+	return k.Mod(k, N)
+}
+
+func natFromInt(k *big.Int) *bigmod.Nat {
+	n, _ := bigmod.NewNat().SetBytes(k.FillBytes(make([]byte, ScalarSize)), orderModulus)
+	return n
+}
+
+// fixedNonces 依次返回给定的随机数，仅用于已知答案测试
+type fixedNonces []*bigmod.Nat
+
+func (f *fixedNonces) next() (*bigmod.Nat, error) {
+	if len(*f) == 0 {
+		return nil, errors.New("fixed nonces exhausted")
+	}
+	k := (*f)[0]
+	*f = (*f)[1:]
+	return k, nil
+}
+
+func (f *fixedNonces) wipe() {}
+
+// coopClientSignature 模拟客户端完成协同签名: s = d1^(-1) * (k1*s2 + s3) - r mod n
+func coopClientSignature(d1, k1 *big.Int, sig *SM2CoopSignResult) (r, s *big.Int) {
+	r = new(big.Int).SetBytes(sig.R)
+	s = new(big.Int).Mul(k1, new(big.Int).SetBytes(sig.S2))
+	s.Add(s, new(big.Int).SetBytes(sig.S3))
+	s.Mul(s, new(big.Int).ModInverse(d1, N))
+	s.Sub(s, r)
+	s.Mod(s, N)
+	return r, s
+}
+
+// selfTestCoop 使用随机 d1 模拟客户端，校验 Pa = (d1*d2Inv - 1)*G、协同签名可通过标准验签，以及 T2 - C1 = d*C1
+func selfTestCoop() error {
+	d1, err := rand.Int(rand.Reader, N)
+	if err != nil {
+		return err
+	}
+	if d1.Sign() == 0 {
+		d1.SetInt64(1)
+	}
+	p1X, p1Y := SM2Curve.ScalarBaseMult(d1.Bytes())
+	p1 := make([]byte, 64)
+	p1X.FillBytes(p1[:32])
+	p1Y.FillBytes(p1[32:])
+
+	keyResult, err := CoopKeyGenInit(p1)
+	if err != nil {
+		return err
+	}
+	d2Inv := new(big.Int).SetBytes(keyResult.D2Inv)
+
+	// d = d1 * d2Inv - 1 mod n
+	d := new(big.Int).Mul(d1, d2Inv)
+	d.Sub(d, big.NewInt(1))
+	d.Mod(d, N)
+	paX, paY := SM2Curve.ScalarBaseMult(d.Bytes())
+	if paX.Cmp(new(big.Int).SetBytes(keyResult.Pa[:32])) != 0 ||
+		paY.Cmp(new(big.Int).SetBytes(keyResult.Pa[32:])) != 0 {
+		return errors.New("cooperative public key mismatch")
+	}
+
+	// 模拟客户端完成协同签名: s = d1^(-1) * (k1*s2 + s3) - r，结果须通过标准 SM2 验签
+	pub := &ecdsa.PublicKey{
+		Curve: SM2Curve,
+		X:     new(big.Int).SetBytes(keyResult.Pa[:32]),
+		Y:     new(big.Int).SetBytes(keyResult.Pa[32:]),
+	}
+	e, err := sm2.CalculateSM2Hash(pub, []byte(katSM2Msg), nil)
+	if err != nil {
+		return err
+	}
+	k1, err := rand.Int(rand.Reader, N)
+	if err != nil {
+		return err
+	}
+	k1.Add(k1, big.NewInt(1)).Mod(k1, N)
+	q1X, q1Y := SM2Curve.ScalarBaseMult(k1.Bytes())
+	q1 := make([]byte, 64)
+	q1X.FillBytes(q1[:32])
+	q1Y.FillBytes(q1[32:])
+	sig, err := CoopSign(keyResult.D2Inv, q1, e)
+	if err != nil {
+		return err
+	}
+	r, s := coopClientSignature(d1, k1, sig)
+	if !sm2.Verify(pub, e, r, s) {
+		return errors.New("cooperative signature rejected")
+	}
+
+	// 任取 C1，模拟客户端计算 T1 = d1 * C1
+	c, err := rand.Int(rand.Reader, N)
+	if err != nil {
+		return err
+	}
+	c.Add(c, big.NewInt(1))
+	c1X, c1Y := SM2Curve.ScalarBaseMult(c.Bytes())
+	t1X, t1Y := SM2Curve.ScalarMult(c1X, c1Y, d1.Bytes())
+	t1 := make([]byte, 64)
+	t1X.FillBytes(t1[:32])
+	t1Y.FillBytes(t1[32:])
+
+	t2, err := CoopDecrypt(keyResult.D2Inv, t1)
+	if err != nil {
+		return err
+	}
+	// T2 - C1 = T2 + (-C1)
+	negC1Y := new(big.Int).Sub(SM2Curve.Params().P, c1Y)
+	gotX, gotY := SM2Curve.Add(new(big.Int).SetBytes(t2[:32]), new(big.Int).SetBytes(t2[32:]), c1X, negC1Y)
+	wantX, wantY := SM2Curve.ScalarMult(c1X, c1Y, d.Bytes())
+	if gotX.Cmp(wantX) != 0 || gotY.Cmp(wantY) != 0 {
+		return errors.New("cooperative decrypt mismatch")
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"math/big"
 
+	"filippo.io/bigmod"
 	"github.com/emmansun/gmsm/sm2"
 	"github.com/emmansun/gmsm/sm3"
 )
@@ -26,12 +27,20 @@ var SM2Curve = sm2.P256()
 // N SM2曲线阶
 var N = SM2Curve.Params().N
 
+// -G，用于计算 Pa = d2Inv * P1 - G
+var negGX, negGY = SM2Curve.Params().Gx, new(big.Int).Sub(SM2Curve.Params().P, SM2Curve.Params().Gy)
+
 // SM2CoopKeyGenResult 协同密钥生成结果
+// 服务端私钥分量只返回 D2Inv，所有协同运算只需要 D2Inv，签名所需的 d2 = D2Inv^(-1) 在运算时推导
 type SM2CoopKeyGenResult struct {
-	D2     []byte // 服务端私钥分量
-	D2Inv  []byte // D2的逆
-	P2     []byte // 服务端公钥分量
-	Pa     []byte // 协同公钥
+	D2Inv SecretBytes // D2的逆
+	P2    []byte      // 服务端公钥分量
+	Pa    []byte      // 协同公钥
+}
+
+// Wipe 清零结果中的私钥分量，调用方持久化后须立即调用
+func (r *SM2CoopKeyGenResult) Wipe() {
+	r.D2Inv.Wipe()
 }
 
 // SM2CoopSignResult 协同签名结果
@@ -70,126 +79,225 @@ func SM3HashWithPassword(password, salt []byte) []byte {
 
 // CoopKeyGenInit 协同密钥生成初始化
 // 输入: P1 - 客户端公钥分量 (64字节, 未压缩格式, 无前缀04)
-// 输出: D2, D2Inv, P2, Pa
+// 输出: D2Inv, P2, Pa
 func CoopKeyGenInit(p1 []byte) (*SM2CoopKeyGenResult, error) {
-	if len(p1) != 64 {
+	// 解析并校验 P1 为曲线上的点
+	p1X, p1Y, err := parsePoint(p1)
+	if err != nil {
 		return nil, ErrInvalidP1
 	}
 
-	// 解析 P1 为椭圆曲线点
-	p1X := new(big.Int).SetBytes(p1[:32])
-	p1Y := new(big.Int).SetBytes(p1[32:64])
-
 	// 生成随机 d2 (服务端私钥分量)
-	d2, err := rand.Int(rand.Reader, N)
+	d2, err := randomScalar()
 	if err != nil {
 		return nil, ErrKeyGenFailed
 	}
 
 	// 计算 d2Inv = d2^(-1) mod n
-	d2Inv := new(big.Int).ModInverse(d2, N)
+	d2Inv := scalarInverse(d2)
+	defer wipeScalar(d2, d2Inv)
 
 	// 计算 P2 = d2Inv * G
-	p2X, p2Y := SM2Curve.ScalarBaseMult(d2Inv.Bytes())
-	p2 := make([]byte, 64)
-	copy(p2[:32], p2X.Bytes())
-	copy(p2[32:64], p2Y.Bytes())
-
-	// 计算 Pa = d2Inv * P1 + (n-1) * G
-	// 先计算 d2Inv * P1
-	paX, paY := SM2Curve.ScalarMult(p1X, p1Y, d2Inv.Bytes())
-	// 再计算 (n-1) * G = -G (因为 (n-1) ≡ -1 mod n)
-	minusOne := new(big.Int).Sub(N, big.NewInt(1))
-	gX, gY := SM2Curve.ScalarBaseMult(minusOne.Bytes())
-	// 最后相加
-	paX, paY = SM2Curve.Add(paX, paY, gX, gY)
-	pa := make([]byte, 64)
-	copy(pa[:32], paX.Bytes())
-	copy(pa[32:64], paY.Bytes())
+	p2, err := pointBytes(scalarBaseMult(d2Inv))
+	if err != nil {
+		return nil, ErrKeyGenFailed
+	}
+
+	// 计算 Pa = d2Inv * P1 - G
+	paX, paY := scalarMult(p1X, p1Y, d2Inv)
+	paX, paY = SM2Curve.Add(paX, paY, negGX, negGY)
+	// d1 * d2Inv = 1 时 Pa 为无穷远点
+	pa, err := pointBytes(paX, paY)
+	if err != nil {
+		return nil, ErrKeyGenFailed
+	}
 
 	return &SM2CoopKeyGenResult{
-		D2:    d2.Bytes(),
-		D2Inv: d2Inv.Bytes(),
+		D2Inv: scalarBytes(d2Inv),
 		P2:    p2,
 		Pa:    pa,
 	}, nil
 }
 
-// CoopSign 协同签名
+// DeriveD2Inv 由服务端私钥分量 d2 计算 d2Inv = d2^(-1) mod n，用于读取仅存储 d2 的旧密钥记录
+func DeriveD2Inv(d2 []byte) (SecretBytes, error) {
+	d2Scalar, err := scalarFromBytes(d2)
+	if err != nil {
+		return nil, err
+	}
+	d2Inv := scalarInverse(d2Scalar)
+	defer wipeScalar(d2Scalar, d2Inv)
+	return scalarBytes(d2Inv), nil
+}
+
+// GenerateRefreshDelta 生成私钥分量刷新因子 δ ∈ [1, n-1]
+func GenerateRefreshDelta() (SecretBytes, error) {
+	delta, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+	defer wipeScalar(delta)
+	return scalarBytes(delta), nil
+}
+
+// CoopRefresh 私钥分量刷新：d2Inv' = d2Inv * δ^(-1)（即 d2' = d2 * δ），返回 D2Inv' 与 P2' = d2Inv' * G
+// 客户端同时令 d1' = d1 * δ，d1' * d2Inv' = d1 * d2Inv，协同公钥 Pa 不变（结果中 Pa 为空）
+// 刷新后泄露的旧 d2Inv 无法与新的 d1' 组合
+func CoopRefresh(d2Inv, delta []byte) (*SM2CoopKeyGenResult, error) {
+	d, err := scalarFromBytes(d2Inv)
+	if err != nil {
+		return nil, ErrKeyGenFailed
+	}
+	deltaScalar, err := scalarFromBytes(delta)
+	if err != nil {
+		wipeScalar(d)
+		return nil, ErrInvalidScalar
+	}
+	deltaInv := scalarInverse(deltaScalar)
+	newD2Inv := d.Mul(deltaInv, orderModulus)
+	defer wipeScalar(newD2Inv, deltaScalar, deltaInv)
+
+	p2, err := pointBytes(scalarBaseMult(newD2Inv))
+	if err != nil {
+		return nil, ErrKeyGenFailed
+	}
+	return &SM2CoopKeyGenResult{
+		D2Inv: scalarBytes(newD2Inv),
+		P2:    p2,
+	}, nil
+}
+
+// CoopSign 协同签名，随机数按 DefaultNonceMode 生成
 // 输入: d2Inv - D2的逆, q1 - 客户端盲化因子 (64字节), e - 消息哈希 (32字节)
-// 输出: r, s2, s3
+// 输出: r, s2, s3 (均为 32 字节)
 func CoopSign(d2Inv, q1, e []byte) (*SM2CoopSignResult, error) {
-	if len(q1) != 64 {
+	return CoopSignWithNonce(d2Inv, q1, e, DefaultNonceMode)
+}
+
+// CoopSignWithNonce 协同签名，随机数 k2/k3 按 mode 生成
+func CoopSignWithNonce(d2Inv, q1, e []byte, mode NonceMode) (*SM2CoopSignResult, error) {
+	// deterministic 模式要求签名可复现，不使用预计算池
+	var pool *NoncePool
+	if mode != NonceDeterministic {
+		pool = noncePool.Load()
+	}
+	return coopSign(d2Inv, q1, e, func(x *bigmod.Nat) (nonceSource, error) {
+		return newNonceSource(mode, x, e, q1)
+	}, pool)
+}
+
+// coopSign 协同签名，k2 优先取自 pool，其余随机数由 newNonces 以 d2Inv 创建的来源依次生成
+func coopSign(d2Inv, q1, e []byte, newNonces func(*bigmod.Nat) (nonceSource, error), pool *NoncePool) (*SM2CoopSignResult, error) {
+	// 解析并校验 Q1 为曲线上的点
+	q1X, q1Y, err := parsePoint(q1)
+	if err != nil {
 		return nil, ErrInvalidQ1
 	}
 	if len(e) != 32 {
 		return nil, ErrInvalidE
 	}
-
-	// 解析 Q1 为椭圆曲线点
-	q1X := new(big.Int).SetBytes(q1[:32])
-	q1Y := new(big.Int).SetBytes(q1[32:64])
-
-	// 生成随机 k2, k3
-	k2, err := rand.Int(rand.Reader, N)
+	d2InvScalar, err := scalarFromBytes(d2Inv)
 	if err != nil {
 		return nil, ErrSignFailed
 	}
-	k3, err := rand.Int(rand.Reader, N)
+	// 完整私钥 d = d1*d2Inv - 1，SM2 签名所需的 (1+d)^(-1) = d1^(-1)*d2，
+	// 因此服务端分量使用 d2 = d2Inv^(-1)，客户端计算 s = d1^(-1)*(k1*s2 + s3) - r
+	d := scalarInverse(d2InvScalar)
+	defer wipeScalar(d2InvScalar, d)
+	eScalar, err := scalarReduce(e)
+	if err != nil {
+		return nil, ErrInvalidE
+	}
+	nonces, err := newNonces(d2InvScalar)
 	if err != nil {
 		return nil, ErrSignFailed
 	}
-
-	// 计算 Q2 = k2 * G
-	q2X, q2Y := SM2Curve.ScalarBaseMult(k2.Bytes())
-
-	// 计算 x1 = k3 * Q1 + Q2
-	x1X, x1Y := SM2Curve.ScalarMult(q1X, q1Y, k3.Bytes())
-	x1X, x1Y = SM2Curve.Add(x1X, x1Y, q2X, q2Y)
-
-	// 计算 r = (e + x1) mod n
-	r := new(big.Int).SetBytes(e)
-	r.Add(r, x1X)
-	r.Mod(r, N)
-
-	// 计算 s2 = d2Inv * k3 mod n
-	d2InvBig := new(big.Int).SetBytes(d2Inv)
-	s2 := new(big.Int).Mul(d2InvBig, k3)
-	s2.Mod(s2, N)
-
-	// 计算 s3 = d2Inv * (r + k2) mod n
-	rPlusK2 := new(big.Int).Add(r, k2)
-	s3 := new(big.Int).Mul(d2InvBig, rPlusK2)
-	s3.Mod(s3, N)
-
-	return &SM2CoopSignResult{
-		R:  r.Bytes(),
-		S2: s2.Bytes(),
-		S3: s3.Bytes(),
-	}, nil
+	defer nonces.wipe()
+
+	for {
+		// 生成随机 k2 与 Q2 = k2 * G，优先使用预计算池
+		var k2 *bigmod.Nat
+		var q2X, q2Y *big.Int
+		if n := pool.take(); n != nil {
+			k2, q2X, q2Y = n.k, n.x, n.y
+		} else {
+			if k2, err = nonces.next(); err != nil {
+				return nil, ErrSignFailed
+			}
+			q2X, q2Y = scalarBaseMult(k2)
+		}
+		k3, err := nonces.next()
+		if err != nil {
+			wipeScalar(k2)
+			return nil, ErrSignFailed
+		}
+
+		// 计算 (x1, y1) = k3 * Q1 + Q2
+		x1X, x1Y := scalarMult(q1X, q1Y, k3)
+		x1X, _ = SM2Curve.Add(x1X, x1Y, q2X, q2Y)
+
+		// 计算 r = (e + x1) mod n，r 为 0 时重新选取随机数
+		r, err := scalarReduce(x1X.FillBytes(make([]byte, ScalarSize)))
+		if err != nil {
+			wipeScalar(k2, k3)
+			return nil, ErrSignFailed
+		}
+		r.Add(eScalar, orderModulus)
+		if r.IsZero() == 1 {
+			wipeScalar(k2, k3)
+			continue
+		}
+
+		// 计算 s2 = d2 * k3 mod n
+		s2 := bigmod.NewNat().ExpandFor(orderModulus)
+		s2.Add(k3, orderModulus).Mul(d, orderModulus)
+
+		// 计算 s3 = d2 * (r + k2) mod n
+		s3 := bigmod.NewNat().ExpandFor(orderModulus)
+		s3.Add(r, orderModulus).Add(k2, orderModulus).Mul(d, orderModulus)
+		wipeScalar(k2, k3)
+
+		return &SM2CoopSignResult{
+			R:  scalarBytes(r),
+			S2: scalarBytes(s2),
+			S3: scalarBytes(s3),
+		}, nil
+	}
 }
 
 // CoopDecrypt 协同解密
 // 输入: d2Inv - D2的逆, t1 - 客户端密文变换 (64字节)
 // 输出: t2
 func CoopDecrypt(d2Inv, t1 []byte) ([]byte, error) {
-	if len(t1) != 64 {
+	// 解析并校验 T1 为曲线上的点
+	t1X, t1Y, err := parsePoint(t1)
+	if err != nil {
 		return nil, ErrInvalidT1
 	}
-
-	// 解析 T1 为椭圆曲线点
-	t1X := new(big.Int).SetBytes(t1[:32])
-	t1Y := new(big.Int).SetBytes(t1[32:64])
+	d, err := scalarFromBytes(d2Inv)
+	if err != nil {
+		return nil, ErrDecryptFailed
+	}
+	defer wipeScalar(d)
 
 	// 计算 T2 = d2Inv * T1
-	t2X, t2Y := SM2Curve.ScalarMult(t1X, t1Y, d2Inv)
-	t2 := make([]byte, 64)
-	copy(t2[:32], t2X.Bytes())
-	copy(t2[32:64], t2Y.Bytes())
-
+	t2, err := pointBytes(scalarMult(t1X, t1Y, d))
+	if err != nil {
+		return nil, ErrDecryptFailed
+	}
 	return t2, nil
 }
 
+// CoopDecryptCiphertext 对完整密文执行协同解密
+// 服务端只使用 C1，计算 T2 = d2Inv * C1；客户端据此计算 d1*T2 - C1 = d*C1 完成解密，C2 不参与服务端运算
+func CoopDecryptCiphertext(d2Inv, ciphertext []byte, format CiphertextFormat) ([]byte, error) {
+	ct, err := ParseCiphertext(ciphertext, format)
+	if err != nil {
+		return nil, err
+	}
+	return CoopDecrypt(d2Inv, ct.C1)
+}
+
 // EncodeToBase64 将字节切片编码为 Base64 字符串
 func EncodeToBase64(data []byte) string {
 	return base64.StdEncoding.EncodeToString(data)
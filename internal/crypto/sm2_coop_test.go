@@ -0,0 +1,277 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func randomPoint(t testing.TB) []byte {
+	t.Helper()
+	k, err := randomScalar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := pointBytes(scalarBaseMult(k))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestSelfTest(t *testing.T) {
+	if err := SelfTest(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScalarArithmetic(t *testing.T) {
+	for i := 0; i < 64; i++ {
+		a, err := randomScalar()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := randomScalar()
+		if err != nil {
+			t.Fatal(err)
+		}
+		aBig := new(big.Int).SetBytes(scalarBytes(a))
+		bBig := new(big.Int).SetBytes(scalarBytes(b))
+
+		wantInv := new(big.Int).ModInverse(aBig, N)
+		if got := new(big.Int).SetBytes(scalarBytes(scalarInverse(a))); got.Cmp(wantInv) != 0 {
+			t.Fatalf("inverse mismatch: got %x, want %x", got, wantInv)
+		}
+
+		wantMul := new(big.Int).Mul(aBig, bBig)
+		wantMul.Mod(wantMul, N)
+		prod, _ := scalarFromBytes(scalarBytes(a))
+		prod.Mul(b, orderModulus)
+		if got := new(big.Int).SetBytes(scalarBytes(prod)); got.Cmp(wantMul) != 0 {
+			t.Fatalf("mul mismatch: got %x, want %x", got, wantMul)
+		}
+	}
+}
+
+func TestScalarFromBytes(t *testing.T) {
+	if _, err := scalarFromBytes(make([]byte, ScalarSize)); err == nil {
+		t.Error("zero scalar accepted")
+	}
+	if _, err := scalarFromBytes(N.Bytes()); err == nil {
+		t.Error("scalar equal to n accepted")
+	}
+	if _, err := scalarFromBytes(make([]byte, ScalarSize+1)); err == nil {
+		t.Error("oversized scalar accepted")
+	}
+	// 旧版本存储的 d2Inv 可能不足 32 字节
+	k, err := scalarFromBytes([]byte{0x01, 0x02})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := new(big.Int).SetBytes(scalarBytes(k)); got.Int64() != 0x0102 {
+		t.Errorf("short scalar decoded as %x", got)
+	}
+}
+
+func TestCoopRejectsInvalidPoints(t *testing.T) {
+	keyResult, err := CoopKeyGenInit(randomPoint(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	offCurve := randomPoint(t)
+	offCurve[PointSize-1] ^= 1
+
+	points := map[string][]byte{
+		"infinity":  make([]byte, PointSize),
+		"off-curve": offCurve,
+		"short":     make([]byte, PointSize-1),
+	}
+	e := make([]byte, 32)
+	for name, p := range points {
+		t.Run(name, func(t *testing.T) {
+			if _, err := CoopKeyGenInit(p); !errors.Is(err, ErrInvalidP1) {
+				t.Errorf("CoopKeyGenInit: got %v, want %v", err, ErrInvalidP1)
+			}
+			if _, err := CoopSign(keyResult.D2Inv, p, e); !errors.Is(err, ErrInvalidQ1) {
+				t.Errorf("CoopSign: got %v, want %v", err, ErrInvalidQ1)
+			}
+			if _, err := CoopDecrypt(keyResult.D2Inv, p); !errors.Is(err, ErrInvalidT1) {
+				t.Errorf("CoopDecrypt: got %v, want %v", err, ErrInvalidT1)
+			}
+		})
+	}
+}
+
+func TestCoopOutputsFixedSize(t *testing.T) {
+	for i := 0; i < 32; i++ {
+		keyResult, err := CoopKeyGenInit(randomPoint(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keyResult.D2Inv) != ScalarSize || len(keyResult.P2) != PointSize || len(keyResult.Pa) != PointSize {
+			t.Fatalf("unexpected key sizes: %d %d %d",
+				len(keyResult.D2Inv), len(keyResult.P2), len(keyResult.Pa))
+		}
+		sig, err := CoopSign(keyResult.D2Inv, randomPoint(t), make([]byte, 32))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sig.R) != ScalarSize || len(sig.S2) != ScalarSize || len(sig.S3) != ScalarSize {
+			t.Fatalf("unexpected signature sizes: %d %d %d", len(sig.R), len(sig.S2), len(sig.S3))
+		}
+	}
+}
+
+// coopSignBigInt 基于 big.Int 的等价变时实现，仅用于基准对比
+func coopSignBigInt(d2Inv, q1, e []byte) (r, s2, s3 *big.Int) {
+	q1X := new(big.Int).SetBytes(q1[:32])
+	q1Y := new(big.Int).SetBytes(q1[32:])
+	k2, _ := rand.Int(rand.Reader, N)
+	k3, _ := rand.Int(rand.Reader, N)
+	q2X, q2Y := SM2Curve.ScalarBaseMult(k2.Bytes())
+	x1X, x1Y := SM2Curve.ScalarMult(q1X, q1Y, k3.Bytes())
+	x1X, _ = SM2Curve.Add(x1X, x1Y, q2X, q2Y)
+
+	r = new(big.Int).SetBytes(e)
+	r.Add(r, x1X)
+	r.Mod(r, N)
+	d := new(big.Int).ModInverse(new(big.Int).SetBytes(d2Inv), N)
+	s2 = new(big.Int).Mul(d, k3)
+	s2.Mod(s2, N)
+	s3 = new(big.Int).Add(r, k2)
+	s3.Mul(s3, d)
+	s3.Mod(s3, N)
+	return r, s2, s3
+}
+
+// coopDecryptBigInt 基于 big.Int 的等价变时实现，仅用于基准对比
+func coopDecryptBigInt(d2Inv, t1 []byte) (x, y *big.Int) {
+	return SM2Curve.ScalarMult(new(big.Int).SetBytes(t1[:32]), new(big.Int).SetBytes(t1[32:]), d2Inv)
+}
+
+func BenchmarkCoopKeyGenInit(b *testing.B) {
+	p1 := randomPoint(b)
+	for i := 0; i < b.N; i++ {
+		if _, err := CoopKeyGenInit(p1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCoopSign(b *testing.B) {
+	keyResult, err := CoopKeyGenInit(randomPoint(b))
+	if err != nil {
+		b.Fatal(err)
+	}
+	q1 := randomPoint(b)
+	e := SM3Hash([]byte("benchmark"))
+
+	b.Run("bigmod", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := CoopSign(keyResult.D2Inv, q1, e); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("bigint", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			coopSignBigInt(keyResult.D2Inv, q1, e)
+		}
+	})
+}
+
+func BenchmarkCoopDecrypt(b *testing.B) {
+	keyResult, err := CoopKeyGenInit(randomPoint(b))
+	if err != nil {
+		b.Fatal(err)
+	}
+	t1 := randomPoint(b)
+
+	b.Run("bigmod", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := CoopDecrypt(keyResult.D2Inv, t1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("bigint", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			coopDecryptBigInt(keyResult.D2Inv, t1)
+		}
+	})
+}
+
+// BenchmarkScalarMulInverse 单独对比 s2/s3 所用的模乘与求逆
+func BenchmarkScalarMulInverse(b *testing.B) {
+	k, _ := randomScalar()
+	d, _ := randomScalar()
+	kBig := new(big.Int).SetBytes(scalarBytes(k))
+	dBig := new(big.Int).SetBytes(scalarBytes(d))
+
+	b.Run("bigmod", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			s, _ := scalarFromBytes(scalarBytes(k))
+			s.Mul(scalarInverse(d), orderModulus)
+		}
+	})
+	b.Run("bigint", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			s := new(big.Int).ModInverse(dBig, N)
+			s.Mul(s, kBig)
+			s.Mod(s, N)
+		}
+	})
+}
+
+func TestDeriveD2Inv(t *testing.T) {
+	d2, err := randomScalar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2Inv, err := DeriveD2Inv(scalarBytes(d2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := new(big.Int).SetBytes(d2Inv); got.Cmp(new(big.Int).ModInverse(new(big.Int).SetBytes(scalarBytes(d2)), N)) != 0 {
+		t.Errorf("d2Inv = %x, want d2^(-1) mod n", d2Inv)
+	}
+	if _, err := DeriveD2Inv(make([]byte, ScalarSize)); !errors.Is(err, ErrInvalidScalar) {
+		t.Errorf("zero d2: got %v, want %v", err, ErrInvalidScalar)
+	}
+}
+
+func TestCoopRefresh(t *testing.T) {
+	p1 := randomPoint(t)
+	result, err := CoopKeyGenInit(p1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delta, err := GenerateRefreshDelta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshed, err := CoopRefresh(result.D2Inv, delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// d2Inv' * δ = d2Inv，d1' * d2Inv' = d1 * d2Inv
+	got := new(big.Int).SetBytes(refreshed.D2Inv)
+	got.Mul(got, new(big.Int).SetBytes(delta)).Mod(got, N)
+	if got.Cmp(new(big.Int).SetBytes(result.D2Inv)) != 0 {
+		t.Error("d2Inv' * delta != d2Inv")
+	}
+	x, y := SM2Curve.ScalarBaseMult(refreshed.D2Inv)
+	if p2, _ := pointBytes(x, y); !bytes.Equal(p2, refreshed.P2) {
+		t.Error("P2' != d2Inv' * G")
+	}
+	if refreshed.Pa != nil {
+		t.Error("refresh returned a public key")
+	}
+
+	if _, err := CoopRefresh(result.D2Inv, make([]byte, ScalarSize)); !errors.Is(err, ErrInvalidScalar) {
+		t.Errorf("zero delta: got %v, want %v", err, ErrInvalidScalar)
+	}
+}
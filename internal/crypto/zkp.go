@@ -0,0 +1,133 @@
+package crypto
+
+import (
+	"errors"
+	"io"
+
+	"filippo.io/bigmod"
+	"github.com/emmansun/gmsm/sm3"
+)
+
+// ProofSize Schnorr 证明长度: R (64字节) || s (32字节)
+const ProofSize = PointSize + ScalarSize
+
+// 证明用途标签，参与 Fiat–Shamir 挑战计算，使不同用途的证明不能互相替换
+const (
+	ProofLabelP1 = "P1"
+	ProofLabelQ1 = "Q1"
+	ProofLabelP2 = "P2"
+)
+
+// proofDomain 挑战哈希的域分隔前缀
+const proofDomain = "SM2-COSIGN-SCHNORR-V1"
+
+// ErrInvalidProof 零知识证明验证失败
+var ErrInvalidProof = errors.New("invalid proof of knowledge")
+
+// ProveKnowledge 生成 P = x*G 中 x 的 Schnorr 知识证明 (SM3 Fiat–Shamir 变换)
+// 输入: x - 私钥标量, p - 公钥点 (64字节), label - 证明用途, context - 绑定的会话上下文
+// 输出: R || s，其中 R = k*G, c = SM3(domain || label || context || G || P || R), s = k + c*x mod n
+func ProveKnowledge(x, p []byte, label string, context []byte) ([]byte, error) {
+	xScalar, err := scalarFromBytes(x)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeScalar(xScalar)
+	if _, _, err := parsePoint(p); err != nil {
+		return nil, err
+	}
+
+	// k 由 x、P、上下文与新鲜随机数派生，随机数发生器失效时也不会重复
+	h := sm3.New()
+	writeChallengePrefix(h, label, context, p)
+	extra, err := GenerateRandom(ScalarSize)
+	if err != nil {
+		return nil, err
+	}
+	xBytes := scalarBytes(xScalar)
+	defer clear(xBytes)
+	nonces, err := newHMACDRBG(sm3.New, orderModulus, xBytes, h.Sum(nil), extra)
+	if err != nil {
+		return nil, err
+	}
+	defer nonces.wipe()
+	k, err := nonces.next()
+	if err != nil {
+		return nil, err
+	}
+	defer wipeScalar(k)
+
+	r, err := pointBytes(scalarBaseMult(k))
+	if err != nil {
+		return nil, err
+	}
+	c, err := proofChallenge(label, context, p, r)
+	if err != nil {
+		return nil, err
+	}
+
+	// s = k + c*x mod n
+	s := bigmod.NewNat().ExpandFor(orderModulus)
+	s.Add(c, orderModulus).Mul(xScalar, orderModulus).Add(k, orderModulus)
+
+	return append(r, scalarBytes(s)...), nil
+}
+
+// VerifyKnowledge 验证 P 的 Schnorr 知识证明: s*G == R + c*P
+// P 本身不是合法曲线点时返回 ErrInvalidPoint，其余失败返回 ErrInvalidProof
+func VerifyKnowledge(p, proof []byte, label string, context []byte) error {
+	pX, pY, err := parsePoint(p)
+	if err != nil {
+		return err
+	}
+	if len(proof) != ProofSize {
+		return ErrInvalidProof
+	}
+	r := proof[:PointSize]
+	rX, rY, err := parsePoint(r)
+	if err != nil {
+		return ErrInvalidProof
+	}
+	s, err := scalarFromBytes(proof[PointSize:])
+	if err != nil {
+		return ErrInvalidProof
+	}
+	c, err := proofChallenge(label, context, p, r)
+	if err != nil {
+		return ErrInvalidProof
+	}
+
+	// 验证方只处理公开值，无需常数时间
+	lhsX, lhsY := scalarBaseMult(s)
+	cpX, cpY := SM2Curve.ScalarMult(pX, pY, scalarBytes(c))
+	rhsX, rhsY := SM2Curve.Add(rX, rY, cpX, cpY)
+	if lhsX.Cmp(rhsX) != 0 || lhsY.Cmp(rhsY) != 0 {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// writeChallengePrefix 写入挑战哈希中除 R 以外的部分，变长字段带长度前缀
+func writeChallengePrefix(h io.Writer, label string, context, p []byte) {
+	h.Write([]byte(proofDomain))
+	writeLengthPrefixed(h, []byte(label))
+	writeLengthPrefixed(h, context)
+	params := SM2Curve.Params()
+	h.Write(params.Gx.FillBytes(make([]byte, ScalarSize)))
+	h.Write(params.Gy.FillBytes(make([]byte, ScalarSize)))
+	h.Write(p)
+}
+
+func writeLengthPrefixed(h io.Writer, b []byte) {
+	n := len(b)
+	h.Write([]byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)})
+	h.Write(b)
+}
+
+// proofChallenge 计算挑战 c = SM3(domain || label || context || G || P || R) mod n
+func proofChallenge(label string, context, p, r []byte) (*bigmod.Nat, error) {
+	h := sm3.New()
+	writeChallengePrefix(h, label, context, p)
+	h.Write(r)
+	return scalarReduce(h.Sum(nil))
+}
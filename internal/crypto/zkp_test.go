@@ -0,0 +1,114 @@
+package crypto
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSchnorrProof(t *testing.T) {
+	x, err := randomScalar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := pointBytes(scalarBaseMult(x))
+	if err != nil {
+		t.Fatal(err)
+	}
+	context := []byte("alice")
+
+	proof, err := ProveKnowledge(scalarBytes(x), p, ProofLabelP1, context)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proof) != ProofSize {
+		t.Fatalf("proof size = %d, want %d", len(proof), ProofSize)
+	}
+	if err := VerifyKnowledge(p, proof, ProofLabelP1, context); err != nil {
+		t.Fatalf("valid proof rejected: %v", err)
+	}
+
+	if err := VerifyKnowledge(make([]byte, PointSize), proof, ProofLabelP1, context); !errors.Is(err, ErrInvalidPoint) {
+		t.Errorf("invalid statement point: got %v, want %v", err, ErrInvalidPoint)
+	}
+
+	tampered := append([]byte(nil), proof...)
+	tampered[ProofSize-1] ^= 1
+	otherPoint := randomPoint(t)
+
+	tests := []struct {
+		name    string
+		point   []byte
+		proof   []byte
+		label   string
+		context []byte
+	}{
+		{"label", p, proof, ProofLabelQ1, context},
+		{"context", p, proof, ProofLabelP1, []byte("bob")},
+		{"point", otherPoint, proof, ProofLabelP1, context},
+		{"tampered", p, tampered, ProofLabelP1, context},
+		{"short", p, proof[:ProofSize-1], ProofLabelP1, context},
+		{"invalid-r", p, append(make([]byte, PointSize), proof[PointSize:]...), ProofLabelP1, context},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := VerifyKnowledge(tt.point, tt.proof, tt.label, tt.context); !errors.Is(err, ErrInvalidProof) {
+				t.Errorf("got %v, want %v", err, ErrInvalidProof)
+			}
+		})
+	}
+}
+
+// 上下文带长度前缀，拼接方式不同的标签与上下文不能产生相同挑战
+func TestSchnorrProofDomainSeparation(t *testing.T) {
+	x, _ := randomScalar()
+	p, _ := pointBytes(scalarBaseMult(x))
+	proof, err := ProveKnowledge(scalarBytes(x), p, "P", []byte("1ctx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyKnowledge(p, proof, "P1", []byte("ctx")); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("got %v, want %v", err, ErrInvalidProof)
+	}
+}
+
+func TestP2Proof(t *testing.T) {
+	p1 := randomPoint(t)
+	keyResult, err := CoopKeyGenInit(p1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	context := append([]byte("user-id"), p1...)
+	proof, err := ProveKnowledge(keyResult.D2Inv, keyResult.P2, ProofLabelP2, context)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyKnowledge(keyResult.P2, proof, ProofLabelP2, context); err != nil {
+		t.Fatal(err)
+	}
+	// 证明必须与私钥匹配
+	other, err := randomScalar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong, err := ProveKnowledge(scalarBytes(other), keyResult.P2, ProofLabelP2, context)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyKnowledge(keyResult.P2, wrong, ProofLabelP2, context); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("proof with wrong secret: got %v, want %v", err, ErrInvalidProof)
+	}
+}
+
+func BenchmarkVerifyKnowledge(b *testing.B) {
+	x, _ := randomScalar()
+	p, _ := pointBytes(scalarBaseMult(x))
+	proof, err := ProveKnowledge(scalarBytes(x), p, ProofLabelQ1, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		if err := VerifyKnowledge(p, proof, ProofLabelQ1, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package handler
 
 import (
+	"bytes"
+	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/sm2-cosign/backend/internal/metrics"
 	"github.com/sm2-cosign/backend/internal/repository"
 	"github.com/sm2-cosign/backend/internal/service"
 	"github.com/sm2-cosign/backend/pkg/response"
@@ -11,17 +15,29 @@ import (
 
 // AdminHandler 管理处理器
 type AdminHandler struct {
-	userService   *service.UserService
-	cosignService *service.CosignService
-	auditRepo     *repository.AuditLogRepository
+	userService    *service.UserService
+	cosignService  *service.CosignService
+	backupService  *service.BackupService
+	archiveService *service.ArchiveService
+	healthService  *service.HealthService
+	userRepo       repository.UserRepository
+	keyRepo        repository.KeyRepository
+	sessionRepo    repository.SessionRepository
+	auditRepo      repository.AuditLogRepository
 }
 
 // NewAdminHandler 创建管理处理器实例
 func NewAdminHandler() *AdminHandler {
 	return &AdminHandler{
-		userService:   service.NewUserService(),
-		cosignService: service.NewCosignService(),
-		auditRepo:     repository.NewAuditLogRepository(),
+		userService:    service.NewUserService(),
+		cosignService:  service.NewCosignService(),
+		backupService:  service.NewBackupService(),
+		archiveService: service.NewArchiveService(),
+		healthService:  service.NewHealthService(),
+		userRepo:       repository.NewUserRepository(),
+		keyRepo:        repository.NewKeyRepository(),
+		sessionRepo:    repository.NewSessionRepository(),
+		auditRepo:      repository.NewAuditLogRepository(),
 	}
 }
 
@@ -82,9 +98,9 @@ func (h *AdminHandler) GetUser(c *fiber.Ctx) error {
 	return response.Success(c, user)
 }
 
-// DeleteUser 删除用户
-// @Summary 删除用户
-// @Description 删除指定用户
+// DeleteUser 归档用户
+// @Summary 归档用户
+// @Description 归档指定用户：禁止登录，密钥一并归档，私钥分量保留至清除
 // @Tags 管理
 // @Accept json
 // @Produce json
@@ -97,7 +113,53 @@ func (h *AdminHandler) DeleteUser(c *fiber.Ctx) error {
 		return response.Error(c, response.CodeInvalidParam)
 	}
 
-	code := h.userService.DeleteUser(id)
+	code := h.archiveService.ArchiveUser(id, c.IP())
+	if code != response.CodeSuccess {
+		return response.Error(c, code)
+	}
+
+	return response.Success(c, nil)
+}
+
+// RestoreUser 恢复用户
+// @Summary 恢复用户
+// @Description 恢复已归档的用户，密钥保持归档
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Param id path string true "用户ID"
+// @Success 200 {object} response.Response
+// @Router /mapi/users/{id}/restore [post]
+func (h *AdminHandler) RestoreUser(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return response.Error(c, response.CodeInvalidParam)
+	}
+
+	code := h.archiveService.RestoreUser(id, c.IP())
+	if code != response.CodeSuccess {
+		return response.Error(c, code)
+	}
+
+	return response.Success(c, nil)
+}
+
+// PurgeUser 清除用户
+// @Summary 清除用户
+// @Description 永久删除已归档且超过 archive.purge_grace 的用户及其密钥与私钥分量
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Param id path string true "用户ID"
+// @Success 200 {object} response.Response
+// @Router /mapi/users/{id}/purge [delete]
+func (h *AdminHandler) PurgeUser(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return response.Error(c, response.CodeInvalidParam)
+	}
+
+	code := h.archiveService.PurgeUser(id, c.IP())
 	if code != response.CodeSuccess {
 		return response.Error(c, code)
 	}
@@ -170,9 +232,9 @@ func (h *AdminHandler) ListKeys(c *fiber.Ctx) error {
 	})
 }
 
-// DeleteKey 删除密钥
-// @Summary 删除密钥
-// @Description 删除指定密钥
+// DeleteKey 归档密钥
+// @Summary 归档密钥
+// @Description 归档指定密钥：不再用于签名，私钥分量保留，经批准后可用于解密
 // @Tags 管理
 // @Accept json
 // @Produce json
@@ -185,7 +247,59 @@ func (h *AdminHandler) DeleteKey(c *fiber.Ctx) error {
 		return response.Error(c, response.CodeInvalidParam)
 	}
 
-	code := h.cosignService.DeleteKey(id)
+	code := h.archiveService.ArchiveKey(id, c.IP())
+	if code != response.CodeSuccess {
+		return response.Error(c, code)
+	}
+
+	return response.Success(c, nil)
+}
+
+// ApproveDecrypt 批准归档密钥解密
+// @Summary 批准归档密钥解密
+// @Description 允许密钥所属用户在期限内使用归档密钥解密，期限为 0 时撤销批准
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Param id path string true "密钥ID"
+// @Param request body service.KeyApproveRequest true "批准请求"
+// @Success 200 {object} response.Response{data=service.KeyApproveResponse}
+// @Router /mapi/keys/{id}/decrypt-approval [post]
+func (h *AdminHandler) ApproveDecrypt(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return response.Error(c, response.CodeInvalidParam)
+	}
+
+	var req service.KeyApproveRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.Error(c, response.CodeInvalidParam)
+	}
+
+	resp, code := h.archiveService.ApproveDecrypt(id, &req, c.IP())
+	if code != response.CodeSuccess {
+		return response.Error(c, code)
+	}
+
+	return response.Success(c, resp)
+}
+
+// PurgeKey 清除密钥
+// @Summary 清除密钥
+// @Description 永久删除已归档且超过 archive.purge_grace 的密钥及其私钥分量
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Param id path string true "密钥ID"
+// @Success 200 {object} response.Response
+// @Router /mapi/keys/{id}/purge [delete]
+func (h *AdminHandler) PurgeKey(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return response.Error(c, response.CodeInvalidParam)
+	}
+
+	code := h.archiveService.PurgeKey(id, c.IP())
 	if code != response.CodeSuccess {
 		return response.Error(c, code)
 	}
@@ -193,6 +307,29 @@ func (h *AdminHandler) DeleteKey(c *fiber.Ctx) error {
 	return response.Success(c, nil)
 }
 
+// ExportKeys 导出私钥分量备份
+// @Summary 导出私钥分量备份
+// @Description 以 SM2 恢复公钥封装选定密钥的服务端私钥分量，需配置 backup.recovery_key_file
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Param request body service.KeyExportRequest true "导出请求"
+// @Success 200 {object} response.Response{data=service.KeyBackup}
+// @Router /mapi/keys/export [post]
+func (h *AdminHandler) ExportKeys(c *fiber.Ctx) error {
+	var req service.KeyExportRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.Error(c, response.CodeInvalidParam)
+	}
+
+	backup, code := h.backupService.ExportKeys(&req, c.IP())
+	if code != response.CodeSuccess {
+		return response.Error(c, code)
+	}
+
+	return response.Success(c, backup)
+}
+
 // ListLogs 查询审计日志
 // @Summary 查询审计日志
 // @Description 查询审计日志（分页）
@@ -232,20 +369,55 @@ func (h *AdminHandler) ListLogs(c *fiber.Ctx) error {
 	})
 }
 
-// Health 健康检查
-// @Summary 健康检查
-// @Description 服务健康检查
+// Health 存活检查
+// @Summary 存活检查
+// @Description 进程存活检查，不依赖外部资源
 // @Tags 管理
 // @Accept json
 // @Produce json
 // @Success 200 {object} response.Response
 // @Router /mapi/health [get]
+// @Router /mapi/health/live [get]
 func (h *AdminHandler) Health(c *fiber.Ctx) error {
 	return response.Success(c, fiber.Map{
-		"status": "ok",
+		"status":    service.HealthStatusOK,
+		"timestamp": time.Now().Format(time.RFC3339),
 	})
 }
 
+// Ready 就绪检查
+// @Summary 就绪检查
+// @Description 检查数据库连通性与可写性、表结构、主密钥及密码自检，关键检查失败时返回 503
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=service.ReadinessReport}
+// @Failure 503 {object} response.Response{data=service.ReadinessReport}
+// @Router /mapi/health/ready [get]
+func (h *AdminHandler) Ready(c *fiber.Ctx) error {
+	report := h.healthService.Readiness()
+	if !report.Ready() {
+		return response.ErrorWithStatus(c, http.StatusServiceUnavailable, response.CodeUnavailable, report)
+	}
+	return response.Success(c, report)
+}
+
+// SelfTest 密码自检
+// @Summary 密码自检
+// @Description 按需执行 SM2/SM3 已知答案测试及协同运算一致性测试，须管理员 Token
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=service.HealthCheck}
+// @Router /mapi/health/selftest [post]
+func (h *AdminHandler) SelfTest(c *fiber.Ctx) error {
+	result := h.healthService.SelfTest()
+	if result.Status != service.HealthStatusOK {
+		return response.ErrorWithStatus(c, http.StatusServiceUnavailable, response.CodeCryptoError, result)
+	}
+	return response.Success(c, result)
+}
+
 // Stats 系统统计
 // @Summary 系统统计
 // @Description 获取系统统计数据
@@ -256,11 +428,9 @@ func (h *AdminHandler) Health(c *fiber.Ctx) error {
 // @Router /mapi/stats [get]
 func (h *AdminHandler) Stats(c *fiber.Ctx) error {
 	// 获取统计数据
-	var userCount, keyCount, sessionCount int64
-	db := repository.GetDB()
-	db.QueryRow("SELECT COUNT(*) FROM users").Scan(&userCount)
-	db.QueryRow("SELECT COUNT(*) FROM keys").Scan(&keyCount)
-	db.QueryRow("SELECT COUNT(*) FROM sessions WHERE expires_at > datetime('now')").Scan(&sessionCount)
+	userCount, _ := h.userRepo.Count()
+	keyCount, _ := h.keyRepo.Count()
+	sessionCount, _ := h.sessionRepo.CountActive()
 
 	return response.Success(c, fiber.Map{
 		"users":    userCount,
@@ -268,3 +438,17 @@ func (h *AdminHandler) Stats(c *fiber.Ctx) error {
 		"sessions": sessionCount,
 	})
 }
+
+// Metrics Prometheus 指标
+// @Summary Prometheus 指标
+// @Description 以 Prometheus 文本格式输出运行指标；metrics.require_admin 开启时须管理员 Token，配置 metrics.listen 后只在该地址提供
+// @Tags 管理
+// @Produce plain
+// @Success 200 {string} string
+// @Router /metrics [get]
+func (h *AdminHandler) Metrics(c *fiber.Ctx) error {
+	var buf bytes.Buffer
+	metrics.Default.Export(&buf)
+	c.Set(fiber.HeaderContentType, "text/plain; version=0.0.4; charset=utf-8")
+	return c.Send(buf.Bytes())
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sm2-cosign/backend/internal/middleware"
+	"github.com/sm2-cosign/backend/internal/service"
+	"github.com/sm2-cosign/backend/pkg/response"
+)
+
+// CertHandler 证书处理器
+type CertHandler struct {
+	certService *service.CertService
+}
+
+// NewCertHandler 创建证书处理器实例
+func NewCertHandler() *CertHandler {
+	return &CertHandler{
+		certService: service.NewCertService(),
+	}
+}
+
+// Issue 申请证书
+// @Summary 申请证书
+// @Description 以内置 CA 为当前密钥的协同公钥签发 SM2 证书，CSR 须以协同签名签署
+// @Tags 证书
+// @Accept json
+// @Produce json
+// @Param request body service.CertRequest true "证书申请"
+// @Success 200 {object} response.Response{data=model.Certificate}
+// @Router /api/cert [post]
+func (h *CertHandler) Issue(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		return response.Error(c, response.CodeUnauthorized)
+	}
+
+	var req service.CertRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.Error(c, response.CodeInvalidParam)
+	}
+
+	// 使用当前登录用户的ID
+	req.UserID = userID
+
+	result, code := h.certService.Issue(&req, c.IP())
+	if code != response.CodeSuccess {
+		return response.Error(c, code)
+	}
+
+	return response.Success(c, result)
+}
+
+// List 获取当前用户的证书
+// @Summary 获取证书列表
+// @Description 获取当前用户已签发的证书，按签发时间倒序
+// @Tags 证书
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=[]model.Certificate}
+// @Router /api/cert [get]
+func (h *CertHandler) List(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		return response.Error(c, response.CodeUnauthorized)
+	}
+
+	certs, code := h.certService.UserCertificates(userID)
+	if code != response.CodeSuccess {
+		return response.Error(c, code)
+	}
+
+	return response.Success(c, certs)
+}
+
+// CA 获取根证书
+// @Summary 获取根证书
+// @Description 获取内置 CA 的根证书 (PEM)，无需登录
+// @Tags 证书
+// @Produce json
+// @Success 200 {object} response.Response{data=service.CACertResponse}
+// @Router /api/cert/ca [get]
+func (h *CertHandler) CA(c *fiber.Ctx) error {
+	result, code := h.certService.CACertificate()
+	if code != response.CodeSuccess {
+		return response.Error(c, code)
+	}
+
+	return response.Success(c, result)
+}
+
+// CRL 获取证书吊销列表
+// @Summary 获取 CRL
+// @Description 获取以根私钥签名的证书吊销列表 (DER)，无需登录
+// @Tags 证书
+// @Produce application/pkix-crl
+// @Success 200 {file} binary
+// @Router /api/cert/crl [get]
+func (h *CertHandler) CRL(c *fiber.Ctx) error {
+	crl, code := h.certService.CRL()
+	if code != response.CodeSuccess {
+		return response.Error(c, code)
+	}
+
+	c.Set(fiber.HeaderContentType, "application/pkix-crl")
+	return c.Send(crl)
+}
+
+// Status 查询证书状态
+// @Summary 查询证书状态
+// @Description 按序列号查询证书的吊销状态，结果以根私钥签名，无需登录
+// @Tags 证书
+// @Produce json
+// @Param serial path string true "证书序列号 (hex)"
+// @Success 200 {object} response.Response{data=service.CertStatusResponse}
+// @Router /api/cert/status/{serial} [get]
+func (h *CertHandler) Status(c *fiber.Ctx) error {
+	serial := c.Params("serial")
+	if serial == "" {
+		return response.Error(c, response.CodeInvalidParam)
+	}
+
+	result, code := h.certService.CertStatus(serial)
+	if code != response.CodeSuccess {
+		return response.Error(c, code)
+	}
+
+	return response.Success(c, result)
+}
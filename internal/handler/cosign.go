@@ -1,7 +1,10 @@
 package handler
 
 import (
+	"strconv"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/sm2-cosign/backend/internal/metrics"
 	"github.com/sm2-cosign/backend/internal/middleware"
 	"github.com/sm2-cosign/backend/internal/service"
 	"github.com/sm2-cosign/backend/pkg/response"
@@ -43,6 +46,93 @@ func (h *CosignHandler) KeyInit(c *fiber.Ctx) error {
 	req.UserID = userID
 
 	result, code := h.cosignService.KeyInit(&req, c.IP())
+	observeOperation(metrics.OpKeyGen, code)
+	if code != response.CodeSuccess {
+		return response.Error(c, code)
+	}
+
+	return response.Success(c, result)
+}
+
+// Keys 获取当前用户的密钥
+// @Summary 获取密钥列表
+// @Description 获取当前用户的密钥及有效期，rotate 为 true 时客户端应重新生成密钥
+// @Tags 协同签名
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=[]service.KeyInfo}
+// @Router /api/keys [get]
+func (h *CosignHandler) Keys(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		return response.Error(c, response.CodeUnauthorized)
+	}
+
+	keys, code := h.cosignService.UserKeys(userID)
+	if code != response.CodeSuccess {
+		return response.Error(c, code)
+	}
+
+	return response.Success(c, keys)
+}
+
+// KeyRefresh 私钥分量刷新（准备）
+// @Summary 私钥分量刷新（准备）
+// @Description 生成刷新因子 δ 与待提交的服务端分量，协同公钥不变；客户端令 d1' = d1*δ 并校验后调用提交接口
+// @Tags 协同签名
+// @Accept json
+// @Produce json
+// @Param request body service.KeyRefreshRequest true "私钥分量刷新请求"
+// @Success 200 {object} response.Response{data=service.KeyRefreshResponse}
+// @Router /api/key/refresh [post]
+func (h *CosignHandler) KeyRefresh(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		return response.Error(c, response.CodeUnauthorized)
+	}
+
+	var req service.KeyRefreshRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.Error(c, response.CodeInvalidParam)
+	}
+
+	// 使用当前登录用户的ID
+	req.UserID = userID
+
+	result, code := h.cosignService.KeyRefresh(&req, c.IP())
+	observeOperation(metrics.OpKeyRefresh, code)
+	if code != response.CodeSuccess {
+		return response.Error(c, code)
+	}
+
+	return response.Success(c, result)
+}
+
+// KeyRefreshCommit 私钥分量刷新（提交）
+// @Summary 私钥分量刷新（提交）
+// @Description 以待提交分量替换当前服务端分量，重复提交已提交的刷新返回成功
+// @Tags 协同签名
+// @Accept json
+// @Produce json
+// @Param request body service.KeyRefreshCommitRequest true "私钥分量刷新提交请求"
+// @Success 200 {object} response.Response{data=service.KeyRefreshCommitResponse}
+// @Router /api/key/refresh/commit [post]
+func (h *CosignHandler) KeyRefreshCommit(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		return response.Error(c, response.CodeUnauthorized)
+	}
+
+	var req service.KeyRefreshCommitRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.Error(c, response.CodeInvalidParam)
+	}
+
+	// 使用当前登录用户的ID
+	req.UserID = userID
+
+	result, code := h.cosignService.KeyRefreshCommit(&req, c.IP())
+	observeOperation(metrics.OpKeyRefresh, code)
 	if code != response.CodeSuccess {
 		return response.Error(c, code)
 	}
@@ -74,9 +164,45 @@ func (h *CosignHandler) Sign(c *fiber.Ctx) error {
 	req.UserID = userID
 
 	result, code := h.cosignService.Sign(&req, c.IP())
+	observeOperation(metrics.OpSign, code)
+	if code != response.CodeSuccess {
+		return response.Error(c, code)
+	}
+
+	return response.Success(c, result)
+}
+
+// SignBatch 批量协同签名
+// @Summary 批量协同签名
+// @Description 同一密钥下批量执行SM2协同签名，逐项返回结果码
+// @Tags 协同签名
+// @Accept json
+// @Produce json
+// @Param request body service.SignBatchRequest true "批量签名请求"
+// @Success 200 {object} response.Response{data=service.SignBatchResponse}
+// @Router /api/sign/batch [post]
+func (h *CosignHandler) SignBatch(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		return response.Error(c, response.CodeUnauthorized)
+	}
+
+	var req service.SignBatchRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.Error(c, response.CodeInvalidParam)
+	}
+
+	// 使用当前登录用户的ID
+	req.UserID = userID
+
+	result, code := h.cosignService.SignBatch(&req, c.IP())
 	if code != response.CodeSuccess {
+		observeOperation(metrics.OpSign, code)
 		return response.Error(c, code)
 	}
+	for _, item := range result.Items {
+		observeOperation(metrics.OpSign, item.Code)
+	}
 
 	return response.Success(c, result)
 }
@@ -105,9 +231,79 @@ func (h *CosignHandler) Decrypt(c *fiber.Ctx) error {
 	req.UserID = userID
 
 	result, code := h.cosignService.Decrypt(&req, c.IP())
+	observeOperation(metrics.OpDecrypt, code)
+	if code != response.CodeSuccess {
+		return response.Error(c, code)
+	}
+
+	return response.Success(c, result)
+}
+
+// KeyExchangeInit 协同密钥交换初始化
+// @Summary 协同密钥交换初始化
+// @Description 由客户端 K1 生成本方 SM2 密钥交换临时公钥 R = d2Inv*K1
+// @Tags 协同签名
+// @Accept json
+// @Produce json
+// @Param request body service.KeyExchangeInitRequest true "密钥交换初始化请求"
+// @Success 200 {object} response.Response{data=service.KeyExchangeInitResponse}
+// @Router /api/keyexchange/init [post]
+func (h *CosignHandler) KeyExchangeInit(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		return response.Error(c, response.CodeUnauthorized)
+	}
+
+	var req service.KeyExchangeInitRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.Error(c, response.CodeInvalidParam)
+	}
+
+	// 使用当前登录用户的ID
+	req.UserID = userID
+
+	result, code := h.cosignService.KeyExchangeInit(&req, c.IP())
+	observeOperation(metrics.OpKeyExchange, code)
+	if code != response.CodeSuccess {
+		return response.Error(c, code)
+	}
+
+	return response.Success(c, result)
+}
+
+// KeyExchange 协同密钥交换
+// @Summary 协同密钥交换
+// @Description 执行SM2协同密钥交换，返回 T2 = d2Inv*W，共享密钥由客户端计算
+// @Tags 协同签名
+// @Accept json
+// @Produce json
+// @Param request body service.KeyExchangeRequest true "密钥交换请求"
+// @Success 200 {object} response.Response{data=service.KeyExchangeResponse}
+// @Router /api/keyexchange/compute [post]
+func (h *CosignHandler) KeyExchange(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		return response.Error(c, response.CodeUnauthorized)
+	}
+
+	var req service.KeyExchangeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.Error(c, response.CodeInvalidParam)
+	}
+
+	// 使用当前登录用户的ID
+	req.UserID = userID
+
+	result, code := h.cosignService.KeyExchange(&req, c.IP())
+	observeOperation(metrics.OpKeyExchange, code)
 	if code != response.CodeSuccess {
 		return response.Error(c, code)
 	}
 
 	return response.Success(c, result)
 }
+
+// observeOperation 按结果码记录协同运算次数
+func observeOperation(op string, code response.Code) {
+	metrics.CosignOperations.WithLabelValues(op, strconv.Itoa(int(code))).Inc()
+}
@@ -0,0 +1,99 @@
+package keystore
+
+import (
+	"github.com/sm2-cosign/backend/internal/crypto"
+	"github.com/sm2-cosign/backend/internal/model"
+)
+
+// BackendDB 数据库后端：d2Inv 以 Base64 保存在密钥记录的 d2_inv 列
+const BackendDB = "db"
+
+// DBStore 数据库后端，分量随密钥记录读写
+type DBStore struct{}
+
+// NewDBStore 创建数据库后端
+func NewDBStore() *DBStore {
+	return &DBStore{}
+}
+
+// Name 后端名称
+func (s *DBStore) Name() string {
+	return BackendDB
+}
+
+// Generate 生成服务端私钥分量，Ref 即 Base64 编码的 d2Inv
+func (s *DBStore) Generate(p1, proofContext []byte) (*Generated, error) {
+	result, proof, err := softGenerate(p1, proofContext)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Wipe()
+	return &Generated{
+		Ref:     crypto.EncodeToBase64(result.D2Inv),
+		P2:      result.P2,
+		Pa:      result.Pa,
+		P2Proof: proof,
+	}, nil
+}
+
+// Refresh 派生刷新后的分量，Ref 即 Base64 编码的 d2Inv'
+func (s *DBStore) Refresh(key *model.Key, delta []byte) (*Generated, error) {
+	share, err := s.open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer share.Close()
+	result, err := share.refresh(delta)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Wipe()
+	return &Generated{Ref: crypto.EncodeToBase64(result.D2Inv), P2: result.P2}, nil
+}
+
+// Open 解码密钥记录中的 d2Inv，d2Inv 为空的旧记录由 d2 推导
+func (s *DBStore) Open(key *model.Key) (Share, error) {
+	return s.open(key)
+}
+
+func (s *DBStore) open(key *model.Key) (*SoftShare, error) {
+	if key.D2Inv != "" {
+		d2Inv, err := crypto.DecodeSecretBase64(key.D2Inv)
+		if err != nil {
+			return nil, err
+		}
+		return NewSoftShare(d2Inv), nil
+	}
+	if key.D2 == "" {
+		return nil, ErrKeyNotFound
+	}
+	d2, err := crypto.DecodeSecretBase64(key.D2)
+	if err != nil {
+		return nil, err
+	}
+	defer d2.Wipe()
+	d2Inv, err := crypto.DeriveD2Inv(d2)
+	if err != nil {
+		return nil, err
+	}
+	return NewSoftShare(d2Inv), nil
+}
+
+// Export 导出 d2Inv
+func (s *DBStore) Export(key *model.Key) (crypto.SecretBytes, error) {
+	share, err := s.open(key)
+	if err != nil {
+		return nil, err
+	}
+	return share.d2Inv, nil
+}
+
+// Import 返回 Base64 编码的 d2Inv 作为 Ref
+func (s *DBStore) Import(d2Inv crypto.SecretBytes) (string, error) {
+	return crypto.EncodeToBase64(d2Inv), nil
+}
+
+// Delete 分量随密钥记录删除，无需额外操作
+func (s *DBStore) Delete(key *model.Key) error {
+	return nil
+}
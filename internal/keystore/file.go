@@ -0,0 +1,218 @@
+package keystore
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+
+	"github.com/sm2-cosign/backend/internal/crypto"
+	"github.com/sm2-cosign/backend/internal/model"
+)
+
+// BackendFile 本地文件后端：每个分量一个文件，以主密钥派生的 SM4-GCM 密钥加密
+const BackendFile = "file"
+
+// fileVersion 分量文件格式版本
+const fileVersion = 1
+
+// fileKeyLabel 由主密钥派生文件加密密钥的标签
+const fileKeyLabel = "sm2-cosign keystore file v1"
+
+// fileRecord 分量文件内容，Data 为 SM4-GCM 加密的 d2Inv，附加数据为分量标识
+type fileRecord struct {
+	Version int    `json:"version"`
+	Nonce   string `json:"nonce"`
+	Data    string `json:"data"`
+}
+
+// FileStore 本地加密文件后端
+type FileStore struct {
+	dir  string
+	aead cipher.AEAD
+}
+
+// NewFileStore 创建文件后端，目录不存在时以 0700 权限创建
+func NewFileStore(dir string, masterKey []byte) (*FileStore, error) {
+	if dir == "" {
+		return nil, errors.New("keystore directory not configured")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	aead, err := crypto.DeriveMasterSubkeyAEAD(masterKey, fileKeyLabel)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir, aead: aead}, nil
+}
+
+// Name 后端名称
+func (s *FileStore) Name() string {
+	return BackendFile
+}
+
+// Generate 生成服务端私钥分量并写入新文件，Ref 为随机分配的分量标识
+func (s *FileStore) Generate(p1, proofContext []byte) (*Generated, error) {
+	result, proof, err := softGenerate(p1, proofContext)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Wipe()
+
+	ref := uuid.New().String()
+	if err := s.write(ref, result.D2Inv); err != nil {
+		return nil, err
+	}
+	return &Generated{
+		Ref:     ref,
+		P2:      result.P2,
+		Pa:      result.Pa,
+		P2Proof: proof,
+	}, nil
+}
+
+// Refresh 派生刷新后的分量并写入新文件，原文件保持不变
+func (s *FileStore) Refresh(key *model.Key, delta []byte) (*Generated, error) {
+	share, err := s.open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer share.Close()
+	result, err := share.refresh(delta)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Wipe()
+
+	ref := uuid.New().String()
+	if err := s.write(ref, result.D2Inv); err != nil {
+		return nil, err
+	}
+	return &Generated{Ref: ref, P2: result.P2}, nil
+}
+
+// Open 读取并解密分量文件
+func (s *FileStore) Open(key *model.Key) (Share, error) {
+	return s.open(key)
+}
+
+func (s *FileStore) open(key *model.Key) (*SoftShare, error) {
+	path, err := s.path(key.D2Inv)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var record fileRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("keystore file %s: %w", key.D2Inv, err)
+	}
+	if record.Version != fileVersion {
+		return nil, fmt.Errorf("keystore file %s: unsupported version %d", key.D2Inv, record.Version)
+	}
+	nonce, err := crypto.DecodeFromBase64(record.Nonce)
+	if err != nil || len(nonce) != s.aead.NonceSize() {
+		return nil, fmt.Errorf("keystore file %s: invalid nonce", key.D2Inv)
+	}
+	ciphertext, err := crypto.DecodeFromBase64(record.Data)
+	if err != nil {
+		return nil, fmt.Errorf("keystore file %s: invalid data", key.D2Inv)
+	}
+	d2Inv, err := s.aead.Open(nil, nonce, ciphertext, []byte(key.D2Inv))
+	if err != nil {
+		return nil, fmt.Errorf("keystore file %s: decryption failed", key.D2Inv)
+	}
+	return NewSoftShare(d2Inv), nil
+}
+
+// Export 导出分量文件中的 d2Inv
+func (s *FileStore) Export(key *model.Key) (crypto.SecretBytes, error) {
+	share, err := s.open(key)
+	if err != nil {
+		return nil, err
+	}
+	return share.d2Inv, nil
+}
+
+// Import 将 d2Inv 写入新的分量文件
+func (s *FileStore) Import(d2Inv crypto.SecretBytes) (string, error) {
+	ref := uuid.New().String()
+	if err := s.write(ref, d2Inv); err != nil {
+		return "", err
+	}
+	return ref, nil
+}
+
+// Delete 删除分量文件
+func (s *FileStore) Delete(key *model.Key) error {
+	path, err := s.path(key.D2Inv)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// path 返回分量文件路径，只接受规范格式的 UUID，防止路径穿越
+func (s *FileStore) path(ref string) (string, error) {
+	id, err := uuid.Parse(ref)
+	if err != nil || id.String() != ref {
+		return "", ErrInvalidRef
+	}
+	return filepath.Join(s.dir, ref+".key"), nil
+}
+
+// write 加密 d2Inv 并原子地写入分量文件
+func (s *FileStore) write(ref string, d2Inv []byte) error {
+	path, err := s.path(ref)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	data, err := json.Marshal(fileRecord{
+		Version: fileVersion,
+		Nonce:   crypto.EncodeToBase64(nonce),
+		Data:    crypto.EncodeToBase64(s.aead.Seal(nil, nonce, d2Inv, []byte(ref))),
+	})
+	if err != nil {
+		return err
+	}
+
+	// 先写入同目录下的临时文件 (CreateTemp 以 0600 权限创建) 再重命名，避免留下不完整的分量文件
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
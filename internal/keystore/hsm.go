@@ -0,0 +1,127 @@
+package keystore
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+
+	"github.com/sm2-cosign/backend/internal/crypto"
+	"github.com/sm2-cosign/backend/internal/model"
+)
+
+// Provider PKCS#11 风格的私钥分量提供者
+// 分量在提供者内部生成并以标签 (CKA_LABEL) 标识，不可导出；协同运算由提供者完成，只返回公开结果。
+// 接入真实 HSM 时按厂商的 PKCS#11 模块实现该接口，再以 NewHSMStore 包装并调用 Register 注册
+type Provider interface {
+	// GenerateKey 生成服务端私钥分量 d2Inv，返回 P2 = d2Inv*G 与 Pa = d2Inv*P1 - G
+	GenerateKey(label string, p1 []byte) (p2, pa []byte, err error)
+	// ProveKnowledge 生成对 P2 = d2Inv*G 的知识证明
+	ProveKnowledge(label string, p2 []byte, proofLabel string, context []byte) ([]byte, error)
+	// RefreshKey 由 label 的分量派生 d2Inv' = d2Inv * δ^(-1) 保存为 newLabel，返回 P2' = d2Inv' * G
+	RefreshKey(label, newLabel string, delta []byte) (p2 []byte, err error)
+	// CoopSign 协同签名
+	CoopSign(label string, q1, e []byte, mode crypto.NonceMode) (*crypto.SM2CoopSignResult, error)
+	// CoopDecrypt 计算 T2 = d2Inv*T1
+	CoopDecrypt(label string, t1 []byte) ([]byte, error)
+	// CoopKeyExchangeInit 计算 R = d2Inv*K1
+	CoopKeyExchangeInit(label string, k1 []byte) ([]byte, error)
+	// CoopKeyExchange 计算 T2 = d2Inv*W
+	CoopKeyExchange(label string, w []byte) ([]byte, error)
+	// DestroyKey 销毁分量，分量不存在时返回 ErrKeyNotFound
+	DestroyKey(label string) error
+}
+
+// HSMStore 由提供者保管分量的后端，密钥记录的 d2_inv 列保存分量标签
+type HSMStore struct {
+	name     string
+	provider Provider
+}
+
+// NewHSMStore 以 name 为后端名称包装提供者
+func NewHSMStore(name string, provider Provider) *HSMStore {
+	return &HSMStore{name: name, provider: provider}
+}
+
+// Name 后端名称
+func (s *HSMStore) Name() string {
+	return s.name
+}
+
+// Generate 在提供者内生成分量及 P2 知识证明，Ref 为随机分配的标签
+func (s *HSMStore) Generate(p1, proofContext []byte) (*Generated, error) {
+	label := uuid.New().String()
+	p2, pa, err := s.provider.GenerateKey(label, p1)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := s.provider.ProveKnowledge(label, p2, crypto.ProofLabelP2, proofContext)
+	if err != nil {
+		s.provider.DestroyKey(label)
+		return nil, err
+	}
+	return &Generated{Ref: label, P2: p2, Pa: pa, P2Proof: proof}, nil
+}
+
+// Refresh 在提供者内派生刷新后的分量，Ref 为随机分配的新标签
+func (s *HSMStore) Refresh(key *model.Key, delta []byte) (*Generated, error) {
+	if key.D2Inv == "" {
+		return nil, ErrKeyNotFound
+	}
+	label := uuid.New().String()
+	p2, err := s.provider.RefreshKey(key.D2Inv, label, delta)
+	if err != nil {
+		return nil, err
+	}
+	return &Generated{Ref: label, P2: p2}, nil
+}
+
+// Open 返回以标签引用分量的句柄，不读取分量本身
+func (s *HSMStore) Open(key *model.Key) (Share, error) {
+	if key.D2Inv == "" {
+		return nil, ErrKeyNotFound
+	}
+	return &hsmShare{provider: s.provider, label: key.D2Inv}, nil
+}
+
+// Export 分量不能离开提供者，须使用 HSM 自身的备份机制
+func (s *HSMStore) Export(key *model.Key) (crypto.SecretBytes, error) {
+	return nil, ErrNotExportable
+}
+
+// Import 不支持向提供者导入分量
+func (s *HSMStore) Import(d2Inv crypto.SecretBytes) (string, error) {
+	return "", ErrNotExportable
+}
+
+// Delete 销毁分量
+func (s *HSMStore) Delete(key *model.Key) error {
+	if err := s.provider.DestroyKey(key.D2Inv); err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return err
+	}
+	return nil
+}
+
+// hsmShare 提供者内分量的句柄
+type hsmShare struct {
+	provider Provider
+	label    string
+}
+
+func (h *hsmShare) Sign(q1, e []byte, mode crypto.NonceMode) (*crypto.SM2CoopSignResult, error) {
+	return h.provider.CoopSign(h.label, q1, e, mode)
+}
+
+func (h *hsmShare) Decrypt(t1 []byte) ([]byte, error) {
+	return h.provider.CoopDecrypt(h.label, t1)
+}
+
+func (h *hsmShare) KeyExchangeInit(k1 []byte) ([]byte, error) {
+	return h.provider.CoopKeyExchangeInit(h.label, k1)
+}
+
+func (h *hsmShare) KeyExchange(w []byte) ([]byte, error) {
+	return h.provider.CoopKeyExchange(h.label, w)
+}
+
+// Close 句柄不持有分量，无需清零
+func (h *hsmShare) Close() {}
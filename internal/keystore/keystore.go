@@ -0,0 +1,63 @@
+// Package keystore 服务端私钥分量存储后端
+//
+// 服务端私钥分量 d2Inv 可以保存在数据库 keys 表 (db)、本地加密文件目录 (file)，
+// 或由 PKCS#11 风格的提供者 (HSM) 生成并保管；HSM 后端的分量不离开提供者，协同运算由提供者完成。
+// 密钥记录的 key_store 列记录分量所在后端，切换后端后旧记录仍由原后端读取。
+package keystore
+
+import (
+	"errors"
+
+	"github.com/sm2-cosign/backend/internal/crypto"
+	"github.com/sm2-cosign/backend/internal/model"
+)
+
+var (
+	ErrKeyNotFound    = errors.New("key share not found")
+	ErrUnknownBackend = errors.New("unknown key store backend")
+	ErrInvalidRef     = errors.New("invalid key share reference")
+	ErrNotExportable  = errors.New("key share is not exportable")
+)
+
+// KeyStore 服务端私钥分量存储后端
+type KeyStore interface {
+	// Name 后端名称，写入密钥记录的 key_store 列
+	Name() string
+	// Generate 根据客户端 P1 生成新的服务端私钥分量及 P2 知识证明，proofContext 为 P2 证明的完整上下文
+	// 分量在事务提交前已保存，事务失败时调用方须调用 Delete 清理
+	Generate(p1, proofContext []byte) (*Generated, error)
+	// Refresh 由密钥记录中的分量派生刷新后的分量 d2Inv' = d2Inv * δ^(-1) 并另行保存，原分量保持不变
+	// 返回的 Generated 只包含新分量的 Ref 与 P2'，协同公钥不变
+	Refresh(key *model.Key, delta []byte) (*Generated, error)
+	// Open 打开密钥记录中的服务端私钥分量，使用完毕后须调用 Share.Close
+	Open(key *model.Key) (Share, error)
+	// Export 导出密钥记录中的私钥分量 d2Inv 用于备份，调用方负责清零；分量不能离开后端时返回 ErrNotExportable
+	Export(key *model.Key) (crypto.SecretBytes, error)
+	// Import 保存由备份恢复的私钥分量，返回写入密钥记录 d2_inv 列的 Ref
+	Import(d2Inv crypto.SecretBytes) (string, error)
+	// Delete 删除密钥记录引用的私钥分量，分量不存在时不返回错误
+	Delete(key *model.Key) error
+}
+
+// Generated 新生成的服务端私钥分量
+type Generated struct {
+	// Ref 写入密钥记录 d2_inv 列：db 后端为 Base64 编码的 d2Inv，其他后端为分量在后端内的标识
+	Ref     string
+	P2      []byte
+	Pa      []byte
+	P2Proof []byte
+}
+
+// Share 打开的服务端私钥分量，方法可并发调用
+type Share interface {
+	// Sign 协同签名，返回 r, s2 = d2*k3, s3 = d2*(r+k2)
+	Sign(q1, e []byte, mode crypto.NonceMode) (*crypto.SM2CoopSignResult, error)
+	// Decrypt 协同解密，返回 T2 = d2Inv*T1
+	Decrypt(t1 []byte) ([]byte, error)
+	// KeyExchangeInit 协同密钥交换第一步，返回 R = d2Inv*K1
+	KeyExchangeInit(k1 []byte) ([]byte, error)
+	// KeyExchange 协同密钥交换第二步，返回 T2 = d2Inv*W
+	KeyExchange(w []byte) ([]byte, error)
+	// Close 释放分量，进程内的分量被清零
+	Close()
+}
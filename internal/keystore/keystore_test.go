@@ -0,0 +1,424 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emmansun/gmsm/sm2"
+
+	"github.com/sm2-cosign/backend/internal/config"
+	"github.com/sm2-cosign/backend/internal/crypto"
+	"github.com/sm2-cosign/backend/internal/model"
+	"github.com/sm2-cosign/backend/pkg/client"
+)
+
+var testMasterKey = bytes.Repeat([]byte{0x42}, crypto.MasterKeySize)
+
+// newKey 由后端生成分量并完成客户端密钥协商，返回客户端分量与密钥记录
+func newKey(t *testing.T, store KeyStore) (*client.KeyShare, *model.Key) {
+	t.Helper()
+	ks, err := client.GenerateKeyShare()
+	if err != nil {
+		t.Fatal(err)
+	}
+	context := []byte("alice")
+	generated, err := store.Generate(ks.P1(), append(context, ks.P1()...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Complete(generated.P2, generated.Pa, generated.P2Proof, context); err != nil {
+		t.Fatal(err)
+	}
+	return ks, &model.Key{ID: "key", D2Inv: generated.Ref, Store: store.Name()}
+}
+
+// checkShare 使用打开的分量完成一次协同签名与协同解密
+func checkShare(t *testing.T, store KeyStore, ks *client.KeyShare, key *model.Key) {
+	t.Helper()
+	share, err := store.Open(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer share.Close()
+
+	msg := []byte("key store")
+	e, err := ks.Digest(msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := ks.NewSignSession(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := share.Sign(session.Q1(), e, crypto.NonceHedged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := session.FinishASN1(result.R, result.S2, result.S3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sm2.VerifyASN1WithSM2(ks.PublicKey(), nil, msg, sig) {
+		t.Fatal("signature does not verify")
+	}
+
+	ciphertext, err := sm2.Encrypt(rand.Reader, ks.PublicKey(), msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypt, err := ks.NewDecryptSession(ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t2, err := share.Decrypt(decrypt.T1())
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := decrypt.Finish(t2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, msg) {
+		t.Fatalf("decrypted %q, want %q", plaintext, msg)
+	}
+
+	if _, err := share.Decrypt(make([]byte, crypto.PointSize)); !errors.Is(err, crypto.ErrInvalidT1) {
+		t.Errorf("invalid T1: got %v, want %v", err, crypto.ErrInvalidT1)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	fileStore, err := NewFileStore(t.TempDir(), testMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, store := range []KeyStore{NewDBStore(), fileStore, NewHSMStore(BackendSoftHSM, NewSoftHSM())} {
+		t.Run(store.Name(), func(t *testing.T) {
+			ks, key := newKey(t, store)
+			delta, err := crypto.GenerateRefreshDelta()
+			if err != nil {
+				t.Fatal(err)
+			}
+			generated, err := store.Refresh(key, delta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if generated.Ref == key.D2Inv {
+				t.Fatal("refreshed share reuses the original reference")
+			}
+			next, err := ks.Refresh(delta, generated.P2)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// 提交前原分量保持可用，刷新后的分量与 d1' 配合
+			checkShare(t, store, ks, key)
+			refreshed := &model.Key{ID: key.ID, D2Inv: generated.Ref, Store: store.Name()}
+			checkShare(t, store, next, refreshed)
+
+			if _, err := store.Refresh(key, make([]byte, crypto.ScalarSize)); !errors.Is(err, crypto.ErrInvalidScalar) {
+				t.Errorf("zero delta: got %v, want %v", err, crypto.ErrInvalidScalar)
+			}
+		})
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	fileStore, err := NewFileStore(t.TempDir(), testMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbStore := NewDBStore()
+
+	// 分量在 db 与 file 后端之间导出后导入
+	for _, pair := range [][2]KeyStore{{dbStore, fileStore}, {fileStore, dbStore}} {
+		from, to := pair[0], pair[1]
+		t.Run(from.Name()+"-"+to.Name(), func(t *testing.T) {
+			ks, key := newKey(t, from)
+			d2Inv, err := from.Export(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d2Inv.Wipe()
+			ref, err := to.Import(d2Inv)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkShare(t, to, ks, &model.Key{ID: key.ID, D2Inv: ref, Store: to.Name()})
+		})
+	}
+
+	hsm := NewHSMStore(BackendSoftHSM, NewSoftHSM())
+	_, key := newKey(t, hsm)
+	if _, err := hsm.Export(key); !errors.Is(err, ErrNotExportable) {
+		t.Errorf("hsm export: got %v, want %v", err, ErrNotExportable)
+	}
+	if _, err := hsm.Import(crypto.SecretBytes{1}); !errors.Is(err, ErrNotExportable) {
+		t.Errorf("hsm import: got %v, want %v", err, ErrNotExportable)
+	}
+	if _, err := fileStore.Export(&model.Key{D2Inv: "00000000-0000-0000-0000-000000000000"}); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("missing file: got %v, want %v", err, ErrKeyNotFound)
+	}
+}
+
+func TestDBStore(t *testing.T) {
+	store := NewDBStore()
+	ks, key := newKey(t, store)
+	if _, err := crypto.DecodeSecretBase64(key.D2Inv); err != nil {
+		t.Fatalf("db store reference is not a Base64 d2Inv: %v", err)
+	}
+	checkShare(t, store, ks, key)
+
+	// 仅存储 d2 的旧记录
+	d2Inv, err := crypto.DecodeSecretBase64(key.D2Inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, err := crypto.DeriveD2Inv(d2Inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkShare(t, store, ks, &model.Key{D2: crypto.EncodeToBase64(d2)})
+
+	if _, err := store.Open(&model.Key{}); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("empty record: got %v, want %v", err, ErrKeyNotFound)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir, testMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks, key := newKey(t, store)
+	checkShare(t, store, ks, key)
+
+	path := filepath.Join(dir, key.D2Inv+".key")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("key file mode %v, want 0600", info.Mode().Perm())
+	}
+
+	// 重新打开目录（模拟重启）后仍可读取
+	reopened, err := NewFileStore(dir, testMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkShare(t, reopened, ks, key)
+
+	// 主密钥错误或文件被篡改时解密失败
+	other, err := NewFileStore(dir, bytes.Repeat([]byte{0x24}, crypto.MasterKeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Open(key); err == nil {
+		t.Error("key file opened with a different master key")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record fileRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, _ := crypto.DecodeFromBase64(record.Data)
+	ciphertext[0] ^= 1
+	record.Data = crypto.EncodeToBase64(ciphertext)
+	tampered, _ := json.Marshal(record)
+	if err := os.WriteFile(path, tampered, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Open(key); err == nil {
+		t.Error("tampered key file accepted")
+	}
+
+	// 文件内容绑定分量标识，改名后无法解密
+	_, key2 := newKey(t, store)
+	moved := &model.Key{D2Inv: "00000000-0000-4000-8000-000000000000"}
+	if err := os.Rename(filepath.Join(dir, key2.D2Inv+".key"), filepath.Join(dir, moved.D2Inv+".key")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Open(moved); err == nil {
+		t.Error("renamed key file accepted")
+	}
+
+	if _, err := store.Open(&model.Key{D2Inv: "../escape"}); !errors.Is(err, ErrInvalidRef) {
+		t.Errorf("path traversal: got %v, want %v", err, ErrInvalidRef)
+	}
+
+	if err := store.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("key file not deleted")
+	}
+	if _, err := store.Open(key); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("deleted key: got %v, want %v", err, ErrKeyNotFound)
+	}
+	if err := store.Delete(key); err != nil {
+		t.Errorf("deleting a missing key file: %v", err)
+	}
+}
+
+func TestHSMStore(t *testing.T) {
+	hsm := NewSoftHSM()
+	store := NewHSMStore(BackendSoftHSM, hsm)
+	ks, key := newKey(t, store)
+	if hsm.Len() != 1 {
+		t.Fatalf("provider holds %d keys, want 1", hsm.Len())
+	}
+	if _, err := crypto.DecodeSecretBase64(key.D2Inv); err == nil {
+		t.Fatal("HSM reference decodes as key material")
+	}
+	checkShare(t, store, ks, key)
+
+	// 句柄不持有分量，协同密钥交换同样由提供者完成
+	share, err := store.Open(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	share.Close()
+	if _, err := share.KeyExchangeInit(ks.P1()); err != nil {
+		t.Errorf("handle unusable after Close: %v", err)
+	}
+
+	if err := store.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+	if hsm.Len() != 0 {
+		t.Error("key not destroyed")
+	}
+	if _, err := share.KeyExchange(ks.P1()); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("destroyed key: got %v, want %v", err, ErrKeyNotFound)
+	}
+	if err := store.Delete(key); err != nil {
+		t.Errorf("deleting a destroyed key: %v", err)
+	}
+
+	// 生成失败时不留下分量
+	if _, err := store.Generate(make([]byte, crypto.PointSize), nil); !errors.Is(err, crypto.ErrInvalidP1) {
+		t.Errorf("invalid P1: got %v, want %v", err, crypto.ErrInvalidP1)
+	}
+	if hsm.Len() != 0 {
+		t.Error("failed generation left a key in the provider")
+	}
+}
+
+func TestInit(t *testing.T) {
+	t.Cleanup(func() {
+		if err := Init(config.KeyStoreConfig{}, ""); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if err := Init(config.KeyStoreConfig{Backend: BackendFile, Dir: t.TempDir()}, ""); !errors.Is(err, crypto.ErrMasterKeyMissing) {
+		t.Errorf("file backend without master key: got %v", err)
+	}
+	if err := Init(config.KeyStoreConfig{Backend: "unknown"}, ""); !errors.Is(err, ErrUnknownBackend) {
+		t.Errorf("unknown backend: got %v", err)
+	}
+	if Primary().Name() != BackendDB {
+		t.Errorf("failed Init changed primary backend to %s", Primary().Name())
+	}
+
+	masterKey := "42424242424242424242424242424242"
+	if err := Init(config.KeyStoreConfig{Backend: BackendFile, Dir: t.TempDir()}, masterKey); err != nil {
+		t.Fatal(err)
+	}
+	if Primary().Name() != BackendFile {
+		t.Fatalf("primary backend %s, want %s", Primary().Name(), BackendFile)
+	}
+
+	// softhsm 须显式开启
+	if err := Init(config.KeyStoreConfig{Backend: BackendSoftHSM}, ""); !errors.Is(err, ErrSoftHSMNotAllowed) {
+		t.Errorf("softhsm without opt-in: got %v, want %v", err, ErrSoftHSMNotAllowed)
+	}
+	if Primary().Name() != BackendFile {
+		t.Errorf("rejected softhsm changed primary backend to %s", Primary().Name())
+	}
+
+	// 切换后端后原有后端仍可按名称读取旧记录
+	if err := Init(config.KeyStoreConfig{Backend: BackendSoftHSM, AllowSoftHSM: true}, ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"", BackendDB, BackendFile, BackendSoftHSM} {
+		if _, err := Get(name); err != nil {
+			t.Errorf("Get(%q): %v", name, err)
+		}
+	}
+	if err := CheckRegistered([]string{"", BackendDB, BackendFile, BackendSoftHSM}); err != nil {
+		t.Errorf("CheckRegistered: %v", err)
+	}
+	if err := CheckRegistered([]string{BackendDB, "pkcs11", "kms"}); !errors.Is(err, ErrUnknownBackend) {
+		t.Errorf("unregistered backends: got %v, want %v", err, ErrUnknownBackend)
+	} else if !strings.Contains(err.Error(), "kms, pkcs11") {
+		t.Errorf("unregistered backends not listed: %v", err)
+	}
+
+	// 已注册的自定义提供者可按名称选用
+	Register(NewHSMStore("pkcs11", NewSoftHSM()))
+	if err := Init(config.KeyStoreConfig{Backend: "pkcs11"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if Primary().Name() != "pkcs11" {
+		t.Errorf("primary backend %s, want pkcs11", Primary().Name())
+	}
+}
+
+// TestInitFileStoreFallback 新密钥改用其他后端后，已有分量目录仍注册为 file 后端
+func TestInitFileStoreFallback(t *testing.T) {
+	t.Cleanup(func() {
+		mu.Lock()
+		delete(stores, BackendFile)
+		mu.Unlock()
+		if err := Init(config.KeyStoreConfig{}, ""); err != nil {
+			t.Fatal(err)
+		}
+	})
+	masterKey := "42424242424242424242424242424242"
+
+	tests := []struct {
+		name      string
+		dir       string
+		masterKey string
+		want      bool
+	}{
+		{"missing dir", filepath.Join(t.TempDir(), "missing"), masterKey, false},
+		{"no master key", t.TempDir(), "", false},
+		{"invalid master key", t.TempDir(), "00", false},
+		{"existing dir", t.TempDir(), masterKey, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			delete(stores, BackendFile)
+			mu.Unlock()
+			if err := Init(config.KeyStoreConfig{Backend: BackendDB, Dir: tt.dir}, tt.masterKey); err != nil {
+				t.Fatal(err)
+			}
+			if Primary().Name() != BackendDB {
+				t.Errorf("primary backend %s, want %s", Primary().Name(), BackendDB)
+			}
+			_, err := Get(BackendFile)
+			if got := err == nil; got != tt.want {
+				t.Errorf("file backend registered = %v, want %v", got, tt.want)
+			}
+			if !tt.want {
+				if _, err := os.Stat(tt.dir); tt.name == "missing dir" && !os.IsNotExist(err) {
+					t.Error("missing key store dir was created")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package keystore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sm2-cosign/backend/internal/config"
+	"github.com/sm2-cosign/backend/internal/crypto"
+)
+
+// ErrSoftHSMNotAllowed 未显式开启时拒绝选用软件 HSM
+var ErrSoftHSMNotAllowed = errors.New("softhsm key store loses all key shares on restart; set keystore.allow_softhsm to use it for testing")
+
+var (
+	mu sync.RWMutex
+	// stores 已注册的后端，db 后端始终可用
+	stores = map[string]KeyStore{BackendDB: NewDBStore()}
+	// primary 新密钥使用的后端
+	primary = stores[BackendDB]
+)
+
+// Register 注册后端（如基于真实 PKCS#11 模块的 HSMStore），同名后端被替换
+func Register(store KeyStore) {
+	mu.Lock()
+	defer mu.Unlock()
+	stores[store.Name()] = store
+}
+
+// Init 按配置创建后端并设为新密钥使用的后端，未配置时使用 db 后端
+// file 后端使用 auth.master_key 加密分量文件；已通过 Register 注册的后端可直接按名称选用。
+// 新密钥使用其他后端时，分量目录已存在且主密钥有效则同时注册 file 后端，供此前生成的密钥读取；
+// softhsm 的分量进程退出即丢失，须以 keystore.allow_softhsm 显式开启
+func Init(cfg config.KeyStoreConfig, masterKey string) error {
+	var store KeyStore
+	switch cfg.Backend {
+	case "", BackendDB:
+		store = NewDBStore()
+	case BackendFile:
+		key, err := crypto.ParseMasterKey(masterKey)
+		if err != nil {
+			return fmt.Errorf("file key store: %w", err)
+		}
+		if store, err = NewFileStore(cfg.Dir, key); err != nil {
+			return fmt.Errorf("file key store: %w", err)
+		}
+	case BackendSoftHSM:
+		if !cfg.AllowSoftHSM {
+			return ErrSoftHSMNotAllowed
+		}
+		// 重复初始化时沿用已创建的软件 HSM，保留其中的分量
+		if registered, err := Get(BackendSoftHSM); err == nil {
+			store = registered
+		} else {
+			store = NewHSMStore(BackendSoftHSM, NewSoftHSM())
+		}
+	default:
+		registered, err := Get(cfg.Backend)
+		if err != nil {
+			return err
+		}
+		store = registered
+	}
+
+	var fileStore KeyStore
+	if store.Name() != BackendFile {
+		fileStore = existingFileStore(cfg.Dir, masterKey)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	stores[store.Name()] = store
+	if fileStore != nil {
+		stores[BackendFile] = fileStore
+	}
+	primary = store
+	return nil
+}
+
+// existingFileStore 分量目录已存在且主密钥有效时创建 file 后端，否则返回 nil
+func existingFileStore(dir, masterKey string) KeyStore {
+	if dir == "" {
+		return nil
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil
+	}
+	key, err := crypto.ParseMasterKey(masterKey)
+	if err != nil {
+		return nil
+	}
+	store, err := NewFileStore(dir, key)
+	if err != nil {
+		return nil
+	}
+	return store
+}
+
+// CheckRegistered 检查密钥记录引用的后端均已注册，返回未注册的后端
+func CheckRegistered(names []string) error {
+	mu.RLock()
+	defer mu.RUnlock()
+	var missing []string
+	for _, name := range names {
+		if name == "" {
+			name = BackendDB
+		}
+		if _, ok := stores[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("%w: %s", ErrUnknownBackend, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Primary 返回新密钥使用的后端
+func Primary() KeyStore {
+	mu.RLock()
+	defer mu.RUnlock()
+	return primary
+}
+
+// Get 按密钥记录的 key_store 列查找后端，空值视为 db 后端
+func Get(name string) (KeyStore, error) {
+	if name == "" {
+		name = BackendDB
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	store, ok := stores[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownBackend, name)
+	}
+	return store, nil
+}
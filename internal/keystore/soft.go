@@ -0,0 +1,64 @@
+package keystore
+
+import (
+	"github.com/sm2-cosign/backend/internal/crypto"
+)
+
+// SoftShare 进程内的服务端私钥分量，db 与 file 后端使用
+type SoftShare struct {
+	d2Inv crypto.SecretBytes
+}
+
+// NewSoftShare 由 d2Inv 创建分量，取得 d2Inv 的所有权，Close 时清零
+func NewSoftShare(d2Inv crypto.SecretBytes) *SoftShare {
+	return &SoftShare{d2Inv: d2Inv}
+}
+
+// D2Inv 返回 d2Inv 副本（用于缓存），调用方负责清零
+func (s *SoftShare) D2Inv() crypto.SecretBytes {
+	return s.d2Inv.Clone()
+}
+
+// Sign 协同签名
+func (s *SoftShare) Sign(q1, e []byte, mode crypto.NonceMode) (*crypto.SM2CoopSignResult, error) {
+	return crypto.CoopSignWithNonce(s.d2Inv, q1, e, mode)
+}
+
+// Decrypt 协同解密
+func (s *SoftShare) Decrypt(t1 []byte) ([]byte, error) {
+	return crypto.CoopDecrypt(s.d2Inv, t1)
+}
+
+// KeyExchangeInit 协同密钥交换第一步
+func (s *SoftShare) KeyExchangeInit(k1 []byte) ([]byte, error) {
+	return crypto.CoopKeyExchangeInit(s.d2Inv, k1)
+}
+
+// KeyExchange 协同密钥交换第二步
+func (s *SoftShare) KeyExchange(w []byte) ([]byte, error) {
+	return crypto.CoopKeyExchange(s.d2Inv, w)
+}
+
+// refresh 派生刷新后的分量，调用方负责清零结果
+func (s *SoftShare) refresh(delta []byte) (*crypto.SM2CoopKeyGenResult, error) {
+	return crypto.CoopRefresh(s.d2Inv, delta)
+}
+
+// Close 清零 d2Inv
+func (s *SoftShare) Close() {
+	s.d2Inv.Wipe()
+}
+
+// softGenerate 在进程内生成服务端私钥分量及 P2 知识证明，调用方负责清零结果
+func softGenerate(p1, proofContext []byte) (*crypto.SM2CoopKeyGenResult, []byte, error) {
+	result, err := crypto.CoopKeyGenInit(p1)
+	if err != nil {
+		return nil, nil, err
+	}
+	proof, err := crypto.ProveKnowledge(result.D2Inv, result.P2, crypto.ProofLabelP2, proofContext)
+	if err != nil {
+		result.Wipe()
+		return nil, nil, err
+	}
+	return result, proof, nil
+}
@@ -0,0 +1,133 @@
+package keystore
+
+import (
+	"sync"
+
+	"github.com/sm2-cosign/backend/internal/crypto"
+)
+
+// BackendSoftHSM 进程内软件 HSM 替身后端
+const BackendSoftHSM = "softhsm"
+
+// SoftHSM 进程内的软件 HSM 替身，用于在没有 HSM 的环境中测试 HSM 后端
+// 分量只保存在内存中，不提供导出接口，进程退出即丢失，不能用于生产环境
+type SoftHSM struct {
+	mu      sync.RWMutex
+	objects map[string]crypto.SecretBytes
+}
+
+// NewSoftHSM 创建空的软件 HSM
+func NewSoftHSM() *SoftHSM {
+	return &SoftHSM{objects: make(map[string]crypto.SecretBytes)}
+}
+
+// GenerateKey 生成分量，标签已存在时覆盖
+func (h *SoftHSM) GenerateKey(label string, p1 []byte) ([]byte, []byte, error) {
+	result, err := crypto.CoopKeyGenInit(p1)
+	if err != nil {
+		return nil, nil, err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.objects[label].Wipe()
+	h.objects[label] = result.D2Inv
+	return result.P2, result.Pa, nil
+}
+
+// RefreshKey 派生刷新后的分量，原分量保持不变
+func (h *SoftHSM) RefreshKey(label, newLabel string, delta []byte) ([]byte, error) {
+	var result *crypto.SM2CoopKeyGenResult
+	err := h.use(label, func(d2Inv crypto.SecretBytes) (err error) {
+		result, err = crypto.CoopRefresh(d2Inv, delta)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.objects[newLabel].Wipe()
+	h.objects[newLabel] = result.D2Inv
+	return result.P2, nil
+}
+
+// ProveKnowledge 生成对 P2 的知识证明
+func (h *SoftHSM) ProveKnowledge(label string, p2 []byte, proofLabel string, context []byte) ([]byte, error) {
+	var proof []byte
+	err := h.use(label, func(d2Inv crypto.SecretBytes) (err error) {
+		proof, err = crypto.ProveKnowledge(d2Inv, p2, proofLabel, context)
+		return err
+	})
+	return proof, err
+}
+
+// CoopSign 协同签名
+func (h *SoftHSM) CoopSign(label string, q1, e []byte, mode crypto.NonceMode) (*crypto.SM2CoopSignResult, error) {
+	var result *crypto.SM2CoopSignResult
+	err := h.use(label, func(d2Inv crypto.SecretBytes) (err error) {
+		result, err = crypto.CoopSignWithNonce(d2Inv, q1, e, mode)
+		return err
+	})
+	return result, err
+}
+
+// CoopDecrypt 协同解密
+func (h *SoftHSM) CoopDecrypt(label string, t1 []byte) ([]byte, error) {
+	var t2 []byte
+	err := h.use(label, func(d2Inv crypto.SecretBytes) (err error) {
+		t2, err = crypto.CoopDecrypt(d2Inv, t1)
+		return err
+	})
+	return t2, err
+}
+
+// CoopKeyExchangeInit 协同密钥交换第一步
+func (h *SoftHSM) CoopKeyExchangeInit(label string, k1 []byte) ([]byte, error) {
+	var r []byte
+	err := h.use(label, func(d2Inv crypto.SecretBytes) (err error) {
+		r, err = crypto.CoopKeyExchangeInit(d2Inv, k1)
+		return err
+	})
+	return r, err
+}
+
+// CoopKeyExchange 协同密钥交换第二步
+func (h *SoftHSM) CoopKeyExchange(label string, w []byte) ([]byte, error) {
+	var t2 []byte
+	err := h.use(label, func(d2Inv crypto.SecretBytes) (err error) {
+		t2, err = crypto.CoopKeyExchange(d2Inv, w)
+		return err
+	})
+	return t2, err
+}
+
+// DestroyKey 清零并删除分量
+func (h *SoftHSM) DestroyKey(label string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	d2Inv, ok := h.objects[label]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	d2Inv.Wipe()
+	delete(h.objects, label)
+	return nil
+}
+
+// Len 保存的分量数
+func (h *SoftHSM) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.objects)
+}
+
+// use 持读锁执行运算，运算期间分量不会被销毁
+func (h *SoftHSM) use(label string, fn func(d2Inv crypto.SecretBytes) error) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	d2Inv, ok := h.objects[label]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	return fn(d2Inv)
+}
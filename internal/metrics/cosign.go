@@ -0,0 +1,68 @@
+package metrics
+
+// Default 默认指标注册表，由 /metrics 接口输出
+var Default = NewRegistry()
+
+// 协同运算类型标签
+const (
+	OpKeyGen      = "keygen"
+	OpSign        = "sign"
+	OpDecrypt     = "decrypt"
+	OpKeyExchange = "keyexchange"
+	OpKeyRefresh  = "keyrefresh"
+)
+
+var (
+	// HTTPRequests 按路由统计的请求数
+	HTTPRequests = NewCounterVec(
+		"cosign_http_requests_total",
+		"Total number of HTTP requests by method, route and status.",
+		"method", "route", "status",
+	)
+
+	// HTTPRequestDuration 按路由统计的请求耗时
+	HTTPRequestDuration = NewHistogramVec(
+		"cosign_http_request_duration_seconds",
+		"HTTP request latency in seconds by method and route.",
+		nil, "method", "route",
+	)
+
+	// CosignOperations 协同运算次数（按结果码）
+	CosignOperations = NewCounterVec(
+		"cosign_operations_total",
+		"Total number of cooperative key generation, sign and decrypt operations by result code.",
+		"operation", "code",
+	)
+
+	// DBQueryDuration 数据库语句耗时
+	DBQueryDuration = NewHistogramVec(
+		"cosign_db_query_duration_seconds",
+		"Database statement latency in seconds by operation.",
+		nil, "op",
+	)
+
+	// LoginFailures 登录失败次数（按原因）
+	LoginFailures = NewCounterVec(
+		"cosign_login_failures_total",
+		"Total number of failed login attempts by reason.",
+		"reason",
+	)
+
+	// CacheRequests 进程内缓存查询次数（按缓存与结果）
+	CacheRequests = NewCounterVec(
+		"cosign_cache_requests_total",
+		"Total number of in-memory cache lookups by cache and result.",
+		"cache", "result",
+	)
+)
+
+func init() {
+	Default.MustRegister(
+		HTTPRequests,
+		HTTPRequestDuration,
+		CosignOperations,
+		DBQueryDuration,
+		LoginFailures,
+		CacheRequests,
+	)
+}
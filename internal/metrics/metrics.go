@@ -0,0 +1,344 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Collector 指标采集接口
+type Collector interface {
+	// Name 指标名称
+	Name() string
+	// Write 以 Prometheus 文本格式输出指标
+	Write(w io.Writer)
+}
+
+// Registry 指标注册表
+type Registry struct {
+	mu         sync.RWMutex
+	collectors []Collector
+}
+
+// NewRegistry 创建指标注册表
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// MustRegister 注册指标，名称重复时 panic
+func (r *Registry) MustRegister(cs ...Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range cs {
+		for _, existing := range r.collectors {
+			if existing.Name() == c.Name() {
+				panic("metrics: duplicate collector " + c.Name())
+			}
+		}
+		r.collectors = append(r.collectors, c)
+	}
+}
+
+// Export 输出全部指标（Prometheus 文本格式 0.0.4）
+func (r *Registry) Export(w io.Writer) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, c := range r.collectors {
+		c.Write(w)
+	}
+}
+
+// labelKey 将标签值拼接为 map 键
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// formatLabels 格式化标签对
+func formatLabels(names, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(names)+len(extra)/2)
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// cloneValues 深拷贝标签值，调用方传入的字符串可能引用被复用的缓冲区
+func cloneValues(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strings.Clone(v)
+	}
+	return out
+}
+
+func escapeLabel(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, "\n", `\n`)
+	return strings.ReplaceAll(v, `"`, `\"`)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+func writeHeader(w io.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// CounterVec 带标签的计数器
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]*Counter
+}
+
+// Counter 单个计数器
+type Counter struct {
+	labelValues []string
+
+	mu    sync.Mutex
+	value float64
+}
+
+// NewCounterVec 创建计数器
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]*Counter),
+	}
+}
+
+// Name 指标名称
+func (v *CounterVec) Name() string { return v.name }
+
+// WithLabelValues 获取指定标签值的计数器
+func (v *CounterVec) WithLabelValues(values ...string) *Counter {
+	if len(values) != len(v.labels) {
+		panic("metrics: label cardinality mismatch for " + v.name)
+	}
+	key := labelKey(values)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	c, ok := v.values[key]
+	if !ok {
+		c = &Counter{labelValues: cloneValues(values)}
+		v.values[key] = c
+	}
+	return c
+}
+
+// Inc 计数加一
+func (c *Counter) Inc() { c.Add(1) }
+
+// Add 计数增加
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		return
+	}
+	c.mu.Lock()
+	c.value += delta
+	c.mu.Unlock()
+}
+
+// Value 当前计数
+func (c *Counter) Value() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.value
+}
+
+// Write 输出计数器
+func (v *CounterVec) Write(w io.Writer) {
+	writeHeader(w, v.name, v.help, "counter")
+	for _, c := range v.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", v.name, formatLabels(v.labels, c.labelValues), formatFloat(c.Value()))
+	}
+}
+
+func (v *CounterVec) sorted() []*Counter {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	keys := make([]string, 0, len(v.values))
+	for k := range v.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]*Counter, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, v.values[k])
+	}
+	return out
+}
+
+// DefBuckets 默认直方图分桶（秒）
+var DefBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}
+
+// HistogramVec 带标签的直方图
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*Histogram
+}
+
+// Histogram 单个直方图
+type Histogram struct {
+	labelValues []string
+	buckets     []float64
+
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec 创建直方图，buckets 为空时使用 DefBuckets
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	return &HistogramVec{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: b,
+		values:  make(map[string]*Histogram),
+	}
+}
+
+// Name 指标名称
+func (v *HistogramVec) Name() string { return v.name }
+
+// WithLabelValues 获取指定标签值的直方图
+func (v *HistogramVec) WithLabelValues(values ...string) *Histogram {
+	if len(values) != len(v.labels) {
+		panic("metrics: label cardinality mismatch for " + v.name)
+	}
+	key := labelKey(values)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	h, ok := v.values[key]
+	if !ok {
+		h = &Histogram{
+			labelValues: cloneValues(values),
+			buckets:     v.buckets,
+			counts:      make([]uint64, len(v.buckets)),
+		}
+		v.values[key] = h
+	}
+	return h
+}
+
+// Observe 记录观测值
+func (h *Histogram) Observe(value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, upper := range h.buckets {
+		if value <= upper {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+// Write 输出直方图
+func (v *HistogramVec) Write(w io.Writer) {
+	writeHeader(w, v.name, v.help, "histogram")
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.values))
+	for k := range v.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	hs := make([]*Histogram, 0, len(keys))
+	for _, k := range keys {
+		hs = append(hs, v.values[k])
+	}
+	v.mu.Unlock()
+
+	for _, h := range hs {
+		h.mu.Lock()
+		for i, upper := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", v.name,
+				formatLabels(v.labels, h.labelValues, "le", formatFloat(upper)), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", v.name, formatLabels(v.labels, h.labelValues, "le", "+Inf"), h.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", v.name, formatLabels(v.labels, h.labelValues), formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", v.name, formatLabels(v.labels, h.labelValues), h.count)
+		h.mu.Unlock()
+	}
+}
+
+// GaugeFunc 采集时回调取值的仪表
+type GaugeFunc struct {
+	name string
+	help string
+	fn   func() (float64, error)
+}
+
+// NewGaugeFunc 创建回调仪表，回调返回错误时本次采集不输出样本
+func NewGaugeFunc(name, help string, fn func() (float64, error)) *GaugeFunc {
+	return &GaugeFunc{name: name, help: help, fn: fn}
+}
+
+// Name 指标名称
+func (g *GaugeFunc) Name() string { return g.name }
+
+// Write 输出仪表
+func (g *GaugeFunc) Write(w io.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	if v, err := g.fn(); err == nil {
+		fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(v))
+	}
+}
+
+// CounterFunc 采集时回调取值的计数器，回调须返回单调递增的值
+type CounterFunc struct {
+	name string
+	help string
+	fn   func() (float64, error)
+}
+
+// NewCounterFunc 创建回调计数器，回调返回错误时本次采集不输出样本
+func NewCounterFunc(name, help string, fn func() (float64, error)) *CounterFunc {
+	return &CounterFunc{name: name, help: help, fn: fn}
+}
+
+// Name 指标名称
+func (c *CounterFunc) Name() string { return c.name }
+
+// Write 输出计数器
+func (c *CounterFunc) Write(w io.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	if v, err := c.fn(); err == nil {
+		fmt.Fprintf(w, "%s %s\n", c.name, formatFloat(v))
+	}
+}
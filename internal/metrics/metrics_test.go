@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+)
+
+// export 以新的注册表输出 cs
+func export(cs ...Collector) string {
+	r := NewRegistry()
+	r.MustRegister(cs...)
+	var buf bytes.Buffer
+	r.Export(&buf)
+	return buf.String()
+}
+
+func TestCounterVecOutput(t *testing.T) {
+	v := NewCounterVec("test_requests_total", "Requests by route.", "route", "status")
+	v.WithLabelValues("/api/sign", "200").Add(3)
+	v.WithLabelValues("/api/login", "401").Inc()
+	// 标签值中的反斜杠、换行与双引号需转义
+	v.WithLabelValues(`C:\path "quoted"`+"\nnext", "500").Inc()
+	// 负增量被忽略
+	v.WithLabelValues("/api/sign", "200").Add(-1)
+
+	want := `# HELP test_requests_total Requests by route.
+# TYPE test_requests_total counter
+test_requests_total{route="/api/login",status="401"} 1
+test_requests_total{route="/api/sign",status="200"} 3
+test_requests_total{route="C:\\path \"quoted\"\nnext",status="500"} 1
+`
+	if got := export(v); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCounterVecWithoutLabels(t *testing.T) {
+	v := NewCounterVec("test_events_total", "Events.")
+	v.WithLabelValues().Add(2.5)
+
+	want := `# HELP test_events_total Events.
+# TYPE test_events_total counter
+test_events_total 2.5
+`
+	if got := export(v); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHistogramVecOutput(t *testing.T) {
+	// 分桶按升序输出，观测值等于上界时计入该桶，各桶为累计计数
+	v := NewHistogramVec("test_duration_seconds", "Latency.", []float64{1, 0.1, 0.5}, "op")
+	h := v.WithLabelValues("sign")
+	for _, value := range []float64{0.05, 0.1, 0.3, 2} {
+		h.Observe(value)
+	}
+	v.WithLabelValues("decrypt")
+
+	want := `# HELP test_duration_seconds Latency.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{op="decrypt",le="0.1"} 0
+test_duration_seconds_bucket{op="decrypt",le="0.5"} 0
+test_duration_seconds_bucket{op="decrypt",le="1"} 0
+test_duration_seconds_bucket{op="decrypt",le="+Inf"} 0
+test_duration_seconds_sum{op="decrypt"} 0
+test_duration_seconds_count{op="decrypt"} 0
+test_duration_seconds_bucket{op="sign",le="0.1"} 2
+test_duration_seconds_bucket{op="sign",le="0.5"} 3
+test_duration_seconds_bucket{op="sign",le="1"} 3
+test_duration_seconds_bucket{op="sign",le="+Inf"} 4
+test_duration_seconds_sum{op="sign"} 2.45
+test_duration_seconds_count{op="sign"} 4
+`
+	if got := export(v); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHistogramDefaultBuckets(t *testing.T) {
+	v := NewHistogramVec("test_default_seconds", "Default buckets.", nil)
+	v.WithLabelValues().Observe(0.0005)
+
+	var want bytes.Buffer
+	want.WriteString("# HELP test_default_seconds Default buckets.\n# TYPE test_default_seconds histogram\n")
+	for _, upper := range DefBuckets {
+		want.WriteString(`test_default_seconds_bucket{le="` + formatFloat(upper) + `"} 1` + "\n")
+	}
+	want.WriteString("test_default_seconds_bucket{le=\"+Inf\"} 1\ntest_default_seconds_sum 0.0005\ntest_default_seconds_count 1\n")
+	if got := export(v); got != want.String() {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want.String())
+	}
+}
+
+func TestFuncCollectors(t *testing.T) {
+	errUnavailable := errors.New("unavailable")
+	tests := []struct {
+		name      string
+		collector Collector
+		want      string
+	}{
+		{
+			name:      "gauge",
+			collector: NewGaugeFunc("test_sessions", "Sessions.", func() (float64, error) { return 42, nil }),
+			want:      "# HELP test_sessions Sessions.\n# TYPE test_sessions gauge\ntest_sessions 42\n",
+		},
+		{
+			// 回调失败时只输出元数据
+			name:      "gauge error",
+			collector: NewGaugeFunc("test_sessions", "Sessions.", func() (float64, error) { return 0, errUnavailable }),
+			want:      "# HELP test_sessions Sessions.\n# TYPE test_sessions gauge\n",
+		},
+		{
+			name:      "counter",
+			collector: NewCounterFunc("test_hits_total", "Hits.", func() (float64, error) { return 1e6, nil }),
+			want:      "# HELP test_hits_total Hits.\n# TYPE test_hits_total counter\ntest_hits_total 1e+06\n",
+		},
+		{
+			name:      "counter error",
+			collector: NewCounterFunc("test_hits_total", "Hits.", func() (float64, error) { return 0, errUnavailable }),
+			want:      "# HELP test_hits_total Hits.\n# TYPE test_hits_total counter\n",
+		},
+		{
+			name:      "infinite gauge",
+			collector: NewGaugeFunc("test_limit", "Limit.", func() (float64, error) { return math.Inf(1), nil }),
+			want:      "# HELP test_limit Limit.\n# TYPE test_limit gauge\ntest_limit +Inf\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := export(tt.collector); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryOrderAndDuplicates(t *testing.T) {
+	a := NewCounterFunc("test_a_total", "A.", func() (float64, error) { return 1, nil })
+	b := NewGaugeFunc("test_b", "B.", func() (float64, error) { return 2, nil })
+
+	// 按注册顺序输出
+	want := "# HELP test_b B.\n# TYPE test_b gauge\ntest_b 2\n# HELP test_a_total A.\n# TYPE test_a_total counter\ntest_a_total 1\n"
+	if got := export(b, a); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("duplicate collector registered")
+		}
+	}()
+	NewRegistry().MustRegister(a, NewGaugeFunc("test_a_total", "Again.", nil))
+}
+
+func TestLabelCardinality(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("mismatched label values accepted")
+		}
+	}()
+	NewCounterVec("test_total", "Test.", "a", "b").WithLabelValues("only-one")
+}
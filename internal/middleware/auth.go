@@ -4,6 +4,7 @@ import (
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/sm2-cosign/backend/internal/model"
 	"github.com/sm2-cosign/backend/internal/service"
 	"github.com/sm2-cosign/backend/pkg/response"
 )
@@ -20,26 +21,32 @@ func AuthMiddleware() fiber.Handler {
 	userService := service.NewUserService()
 
 	return func(c *fiber.Ctx) error {
-		// 从 Header 获取 Token
-		authHeader := c.Get("Authorization")
-		if authHeader == "" {
-			return response.Error(c, response.CodeUnauthorized)
+		session, code := authenticate(c, userService)
+		if code != response.CodeSuccess {
+			return response.Error(c, code)
 		}
 
-		// 解析 Bearer Token
-		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			return response.Error(c, response.CodeUnauthorized)
-		}
-		token := parts[1]
+		// 将用户信息存入上下文
+		c.Locals(ContextKeyUserID, session.UserID)
+		c.Locals(ContextKeySession, session)
 
-		// 验证会话
-		session, code := userService.ValidateSession(token)
+		return c.Next()
+	}
+}
+
+// AdminMiddleware 管理员认证中间件：Token 认证后要求当前用户为 admin.username 配置的管理员
+func AdminMiddleware() fiber.Handler {
+	userService := service.NewUserService()
+
+	return func(c *fiber.Ctx) error {
+		session, code := authenticate(c, userService)
 		if code != response.CodeSuccess {
 			return response.Error(c, code)
 		}
+		if !userService.IsAdmin(session.UserID) {
+			return response.Error(c, response.CodeForbidden)
+		}
 
-		// 将用户信息存入上下文
 		c.Locals(ContextKeyUserID, session.UserID)
 		c.Locals(ContextKeySession, session)
 
@@ -47,6 +54,24 @@ func AuthMiddleware() fiber.Handler {
 	}
 }
 
+// authenticate 解析 Bearer Token 并验证会话
+func authenticate(c *fiber.Ctx, userService *service.UserService) (*model.Session, response.Code) {
+	// 从 Header 获取 Token
+	authHeader := c.Get("Authorization")
+	if authHeader == "" {
+		return nil, response.CodeUnauthorized
+	}
+
+	// 解析 Bearer Token
+	parts := strings.SplitN(authHeader, " ", 2)
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return nil, response.CodeUnauthorized
+	}
+
+	// 验证会话
+	return userService.ValidateSession(parts[1])
+}
+
 // GetUserID 从上下文获取用户ID
 func GetUserID(c *fiber.Ctx) string {
 	if userID, ok := c.Locals(ContextKeyUserID).(string); ok {
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sm2-cosign/backend/internal/metrics"
+)
+
+// MetricsMiddleware 请求计数与耗时统计中间件
+func MetricsMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		// 使用路由模板而非实际路径，避免标签基数膨胀
+		route := c.Route().Path
+		status := c.Response().StatusCode()
+		if err != nil {
+			if fe, ok := err.(*fiber.Error); ok {
+				status = fe.Code
+			} else {
+				status = fiber.StatusInternalServerError
+			}
+		}
+
+		method := c.Method()
+		metrics.HTTPRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
@@ -13,12 +13,20 @@ type AuditLog struct {
 
 // AuditAction 审计操作类型常量
 const (
-	ActionRegister = "register"
-	ActionLogin    = "login"
-	ActionLogout   = "logout"
-	ActionSign     = "sign"
-	ActionDecrypt  = "decrypt"
-	ActionKeyGen   = "key_gen"
-	ActionUserDel  = "user_delete"
-	ActionKeyDel   = "key_delete"
+	ActionRegister    = "register"
+	ActionLogin       = "login"
+	ActionLogout      = "logout"
+	ActionSign        = "sign"
+	ActionDecrypt     = "decrypt"
+	ActionKeyExchange = "key_exchange"
+	ActionKeyGen      = "key_gen"
+	ActionKeyRefresh  = "key_refresh"
+	ActionKeyExport   = "key_export"
+	ActionKeyImport   = "key_import"
+	ActionKeyExpiring = "key_expiring"
+	ActionUserDel     = "user_delete"
+	ActionUserRestore = "user_restore"
+	ActionKeyDel      = "key_delete"
+	ActionKeyApprove  = "key_decrypt_approve"
+	ActionCertIssue   = "cert_issue"
 )
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// Certificate 内置 CA 为协同公钥签发的证书
+type Certificate struct {
+	ID               string     `json:"id" db:"id"`
+	UserID           string     `json:"userId" db:"user_id"`
+	KeyID            string     `json:"keyId" db:"key_id"`
+	SerialNumber     string     `json:"serialNumber" db:"serial_number"` // 证书序列号 (hex)
+	Subject          string     `json:"subject" db:"subject"`            // 证书主题 (RFC 2253)
+	NotBefore        time.Time  `json:"notBefore" db:"not_before"`
+	NotAfter         time.Time  `json:"notAfter" db:"not_after"`
+	Certificate      string     `json:"certificate" db:"certificate"` // PEM 编码的证书
+	CreatedAt        time.Time  `json:"createdAt" db:"created_at"`
+	RevokedAt        *time.Time `json:"revokedAt,omitempty" db:"revoked_at"`               // 吊销时间，为空表示未吊销
+	RevocationReason int        `json:"revocationReason,omitempty" db:"revocation_reason"` // RFC 5280 吊销原因码
+}
+
+// 证书吊销原因码 (RFC 5280 CRLReason)
+const (
+	// RevocationSuperseded 用户重新生成密钥，旧公钥的证书被取代
+	RevocationSuperseded = 4
+	// RevocationCessation 密钥或用户被归档，不再使用
+	RevocationCessation = 5
+	// RevocationHold 用户被禁用，重新启用后解除
+	RevocationHold = 6
+)
+
+// IsRevoked 检查证书是否已吊销（含暂停）
+func (c *Certificate) IsRevoked() bool {
+	return c.RevokedAt != nil
+}
@@ -3,23 +3,58 @@ package model
 import "time"
 
 type Key struct {
-	ID        string    `json:"id" db:"id"`
-	UserID    string    `json:"userId" db:"user_id"`
-	D2        string    `json:"-" db:"d2"`
-	D2Inv     string    `json:"-" db:"d2_inv"`
-	PublicKey string    `json:"publicKey" db:"public_key"`
-	HMACKey   string    `json:"-" db:"hmac_key"`
-	Status    int       `json:"status" db:"status"`
-	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	ID              string     `json:"id" db:"id"`
+	UserID          string     `json:"userId" db:"user_id"`
+	D2              string     `json:"-" db:"d2"`                             // 已废弃：新记录为空，仅用于读取只存储 d2 的旧记录
+	D2Inv           string     `json:"-" db:"d2_inv"`                         // db 后端为 Base64 编码的 d2Inv，其他后端为分量标识
+	PendingD2Inv    string     `json:"-" db:"pending_d2_inv"`                 // 分量刷新准备阶段生成、尚未提交的新分量，引用方式同 D2Inv
+	ShareGeneration int        `json:"shareGeneration" db:"share_generation"` // 分量代次，每次提交刷新加 1，低于该值的备份分量已失效
+	Store           string     `json:"store" db:"key_store"`                  // 服务端私钥分量所在的存储后端
+	PublicKey       string     `json:"publicKey" db:"public_key"`
+	HMACKey         string     `json:"-" db:"hmac_key"`
+	Status          int        `json:"status" db:"status"`
+	NotBefore       *time.Time `json:"notBefore,omitempty" db:"not_before"`            // 生效时间，为空表示不限制
+	NotAfter        *time.Time `json:"notAfter,omitempty" db:"not_after"`              // 到期时间，为空表示不限制
+	ExpiryWarnedAt  *time.Time `json:"expiryWarnedAt,omitempty" db:"expiry_warned_at"` // 后台任务标记密钥即将过期的时间
+	ArchivedAt      *time.Time `json:"archivedAt,omitempty" db:"archived_at"`          // 归档时间，超过宽限期后才可清除
+	DecryptUntil    *time.Time `json:"decryptUntil,omitempty" db:"decrypt_until"`      // 管理员批准归档密钥解密的截止时间
+	CreatedAt       time.Time  `json:"createdAt" db:"created_at"`
 }
 
 // KeyStatus 密钥状态常量
 const (
 	KeyStatusDisabled = 0
 	KeyStatusEnabled  = 1
+	// KeyStatusArchived 已归档：私钥分量保留，仅在管理员批准的期限内可用于解密
+	KeyStatusArchived = 2
 )
 
 // IsEnabled 检查密钥是否启用
 func (k *Key) IsEnabled() bool {
 	return k.Status == KeyStatusEnabled
 }
+
+// IsArchived 检查密钥是否已归档
+func (k *Key) IsArchived() bool {
+	return k.Status == KeyStatusArchived
+}
+
+// DecryptApproved 检查归档密钥在 t 时刻是否处于批准解密的期限内
+func (k *Key) DecryptApproved(t time.Time) bool {
+	return k.DecryptUntil != nil && t.Before(*k.DecryptUntil)
+}
+
+// NotYetValid 检查密钥在 t 时刻是否尚未生效
+func (k *Key) NotYetValid(t time.Time) bool {
+	return k.NotBefore != nil && t.Before(*k.NotBefore)
+}
+
+// Expired 检查密钥在 t 时刻是否已过期
+func (k *Key) Expired(t time.Time) bool {
+	return k.NotAfter != nil && !t.Before(*k.NotAfter)
+}
+
+// RotateDue 检查密钥在 t 时刻是否应当轮换：距到期不足 window 或已过期
+func (k *Key) RotateDue(t time.Time, window time.Duration) bool {
+	return k.NotAfter != nil && !t.Before(k.NotAfter.Add(-window))
+}
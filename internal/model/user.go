@@ -3,22 +3,30 @@ package model
 import "time"
 
 type User struct {
-	ID           string    `json:"id" db:"id"`
-	Username     string    `json:"username" db:"username"`
-	PasswordHash string    `json:"-" db:"password_hash"`
-	PublicKey    string    `json:"publicKey" db:"public_key"`
-	Status       int       `json:"status" db:"status"`
-	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
-	UpdatedAt    time.Time `json:"updatedAt" db:"updated_at"`
+	ID           string     `json:"id" db:"id"`
+	Username     string     `json:"username" db:"username"`
+	PasswordHash string     `json:"-" db:"password_hash"`
+	PublicKey    string     `json:"publicKey" db:"public_key"`
+	Status       int        `json:"status" db:"status"`
+	ArchivedAt   *time.Time `json:"archivedAt,omitempty" db:"archived_at"` // 归档时间，超过宽限期后才可清除
+	CreatedAt    time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updatedAt" db:"updated_at"`
 }
 
 // UserStatus 用户状态常量
 const (
 	UserStatusDisabled = 0
 	UserStatusEnabled  = 1
+	// UserStatusArchived 已归档：不可登录，用户名保留，密钥随之归档
+	UserStatusArchived = 2
 )
 
 // IsEnabled 检查用户是否启用
 func (u *User) IsEnabled() bool {
 	return u.Status == UserStatusEnabled
 }
+
+// IsArchived 检查用户是否已归档
+func (u *User) IsArchived() bool {
+	return u.Status == UserStatusArchived
+}
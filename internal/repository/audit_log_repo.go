@@ -7,23 +7,30 @@ import (
 )
 
 // AuditLogRepository 审计日志数据访问
-type AuditLogRepository struct{}
+type AuditLogRepository interface {
+	Create(log *model.AuditLog) error
+	List(page, pageSize int, action, userID string) ([]model.AuditLog, int64, error)
+}
+
+type auditLogRepository struct {
+	db *executor
+}
 
 // NewAuditLogRepository 创建审计日志数据访问实例
-func NewAuditLogRepository() *AuditLogRepository {
-	return &AuditLogRepository{}
+func NewAuditLogRepository() AuditLogRepository {
+	return &auditLogRepository{db: defaultExecutor}
 }
 
 // Create 创建审计日志
-func (r *AuditLogRepository) Create(log *model.AuditLog) error {
+func (r *auditLogRepository) Create(log *model.AuditLog) error {
 	query := `INSERT INTO audit_logs (id, user_id, action, detail, ip_address, created_at) 
-	          VALUES (?, ?, ?, ?, ?, datetime('now'))`
-	_, err := db.Exec(query, log.ID, log.UserID, log.Action, log.Detail, log.IPAddress)
+	          VALUES (?, ?, ?, ?, ?, ?)`
+	_, err := r.db.Exec(query, log.ID, log.UserID, log.Action, log.Detail, log.IPAddress, now())
 	return err
 }
 
 // List 获取审计日志列表
-func (r *AuditLogRepository) List(page, pageSize int, action, userID string) ([]model.AuditLog, int64, error) {
+func (r *auditLogRepository) List(page, pageSize int, action, userID string) ([]model.AuditLog, int64, error) {
 	offset := (page - 1) * pageSize
 
 	// 构建查询条件
@@ -42,7 +49,7 @@ func (r *AuditLogRepository) List(page, pageSize int, action, userID string) ([]
 	// 获取总数
 	countQuery := `SELECT COUNT(*) FROM audit_logs ` + whereClause
 	var total int64
-	err := db.QueryRow(countQuery, args...).Scan(&total)
+	err := r.db.QueryRow(countQuery, args...).Scan(&total)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -51,7 +58,7 @@ func (r *AuditLogRepository) List(page, pageSize int, action, userID string) ([]
 	query := `SELECT id, user_id, action, detail, ip_address, created_at 
 	          FROM audit_logs ` + whereClause + ` ORDER BY created_at DESC LIMIT ? OFFSET ?`
 	args = append(args, pageSize, offset)
-	rows, err := db.Query(query, args...)
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/sm2-cosign/backend/internal/model"
+)
+
+// CertificateRepository 证书数据访问
+type CertificateRepository interface {
+	Create(cert *model.Certificate) error
+	FindBySerialNumber(serialNumber string) (*model.Certificate, error)
+	ListByUserID(userID string) ([]model.Certificate, error)
+	ListRevoked(now time.Time) ([]model.Certificate, error)
+	RevokeByKeyID(keyID string, reason int, at time.Time) error
+	HoldByUserID(userID string, at time.Time) error
+	ReleaseHoldByUserID(userID string) error
+}
+
+type certificateRepository struct {
+	db *executor
+}
+
+// NewCertificateRepository 创建证书数据访问实例
+func NewCertificateRepository() CertificateRepository {
+	return &certificateRepository{db: defaultExecutor}
+}
+
+// Create 保存签发的证书
+func (r *certificateRepository) Create(cert *model.Certificate) error {
+	query := `INSERT INTO certificates (id, user_id, key_id, serial_number, subject, not_before, not_after, certificate, created_at) 
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.db.Exec(query, cert.ID, cert.UserID, cert.KeyID, cert.SerialNumber, cert.Subject,
+		cert.NotBefore.UTC(), cert.NotAfter.UTC(), cert.Certificate, now())
+	return err
+}
+
+// FindBySerialNumber 根据序列号 (hex) 查询证书
+func (r *certificateRepository) FindBySerialNumber(serialNumber string) (*model.Certificate, error) {
+	query := `SELECT id, user_id, key_id, serial_number, subject, not_before, not_after, certificate, created_at, revoked_at, revocation_reason 
+	          FROM certificates WHERE serial_number = ?`
+	cert := &model.Certificate{}
+	err := r.db.QueryRow(query, serialNumber).Scan(
+		&cert.ID, &cert.UserID, &cert.KeyID, &cert.SerialNumber, &cert.Subject,
+		&cert.NotBefore, &cert.NotAfter, &cert.Certificate, &cert.CreatedAt, &cert.RevokedAt, &cert.RevocationReason,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// ListByUserID 查询用户的全部证书，按签发时间倒序
+func (r *certificateRepository) ListByUserID(userID string) ([]model.Certificate, error) {
+	query := `SELECT id, user_id, key_id, serial_number, subject, not_before, not_after, certificate, created_at, revoked_at, revocation_reason 
+	          FROM certificates WHERE user_id = ? ORDER BY created_at DESC`
+	return r.list(query, userID)
+}
+
+// ListRevoked 查询已吊销（含暂停）且在 now 时刻尚未到期的证书，按吊销时间排序
+func (r *certificateRepository) ListRevoked(now time.Time) ([]model.Certificate, error) {
+	query := `SELECT id, user_id, key_id, serial_number, subject, not_before, not_after, certificate, created_at, revoked_at, revocation_reason 
+	          FROM certificates WHERE revoked_at IS NOT NULL AND not_after > ? ORDER BY revoked_at`
+	return r.list(query, now.UTC())
+}
+
+// list 执行查询并读取证书列表
+func (r *certificateRepository) list(query string, args ...interface{}) ([]model.Certificate, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var certs []model.Certificate
+	for rows.Next() {
+		var cert model.Certificate
+		if err := rows.Scan(
+			&cert.ID, &cert.UserID, &cert.KeyID, &cert.SerialNumber, &cert.Subject,
+			&cert.NotBefore, &cert.NotAfter, &cert.Certificate, &cert.CreatedAt, &cert.RevokedAt, &cert.RevocationReason,
+		); err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	return certs, rows.Err()
+}
+
+// RevokeByKeyID 吊销密钥的全部未吊销或暂停中的证书
+func (r *certificateRepository) RevokeByKeyID(keyID string, reason int, at time.Time) error {
+	query := `UPDATE certificates SET revoked_at = ?, revocation_reason = ? 
+	          WHERE key_id = ? AND (revoked_at IS NULL OR revocation_reason = ?)`
+	_, err := r.db.Exec(query, at.UTC(), reason, keyID, model.RevocationHold)
+	return err
+}
+
+// HoldByUserID 暂停用户的全部未吊销证书
+func (r *certificateRepository) HoldByUserID(userID string, at time.Time) error {
+	query := `UPDATE certificates SET revoked_at = ?, revocation_reason = ? WHERE user_id = ? AND revoked_at IS NULL`
+	_, err := r.db.Exec(query, at.UTC(), model.RevocationHold, userID)
+	return err
+}
+
+// ReleaseHoldByUserID 解除用户证书的暂停
+func (r *certificateRepository) ReleaseHoldByUserID(userID string) error {
+	query := `UPDATE certificates SET revoked_at = NULL, revocation_reason = 0 WHERE user_id = ? AND revocation_reason = ?`
+	_, err := r.db.Exec(query, userID, model.RevocationHold)
+	return err
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 支持的数据库驱动
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+)
+
+// Dialect 数据库方言
+// 仓储层 SQL 统一使用 ? 占位符与双引号标识符书写，执行前由方言改写
+type Dialect struct {
+	// Name 方言名称，同时是迁移脚本子目录名
+	Name string
+	// DriverName database/sql 注册的驱动名
+	DriverName string
+	// placeholder 生成第 n 个 (从 1 开始) 占位符
+	placeholder func(n int) string
+	// quote 标识符引号
+	quote byte
+	// migrationsTable schema_migrations 建表语句
+	migrationsTable string
+}
+
+var dialects = map[string]*Dialect{
+	DriverSQLite: {
+		Name:        DriverSQLite,
+		DriverName:  "sqlite",
+		placeholder: func(int) string { return "?" },
+		quote:       '"',
+		migrationsTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME NOT NULL
+		)`,
+	},
+	DriverPostgres: {
+		Name:        DriverPostgres,
+		DriverName:  "pgx",
+		placeholder: func(n int) string { return "$" + strconv.Itoa(n) },
+		quote:       '"',
+		migrationsTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL
+		)`,
+	},
+	DriverMySQL: {
+		Name:        DriverMySQL,
+		DriverName:  "mysql",
+		placeholder: func(int) string { return "?" },
+		quote:       '`',
+		migrationsTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at DATETIME(6) NOT NULL
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+	},
+}
+
+// GetDialect 根据驱动名获取方言，空字符串视为 sqlite
+func GetDialect(driver string) (*Dialect, error) {
+	if driver == "" {
+		driver = DriverSQLite
+	}
+	d, ok := dialects[strings.ToLower(driver)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database driver: %s", driver)
+	}
+	return d, nil
+}
+
+// Rebind 将 ? 占位符与双引号标识符改写为方言格式，单引号字符串字面量内的内容保持不变
+func (d *Dialect) Rebind(query string) string {
+	if d.quote == '"' && d.placeholder(1) == "?" {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	inString := false
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '\'':
+			inString = !inString
+			b.WriteByte(ch)
+		case inString:
+			b.WriteByte(ch)
+		case ch == '?':
+			n++
+			b.WriteString(d.placeholder(n))
+		case ch == '"':
+			b.WriteByte(d.quote)
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
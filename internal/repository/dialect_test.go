@@ -0,0 +1,90 @@
+package repository
+
+import "testing"
+
+func TestGetDialect(t *testing.T) {
+	tests := []struct {
+		driver  string
+		want    string
+		wantErr bool
+	}{
+		{"", DriverSQLite, false},
+		{"sqlite", DriverSQLite, false},
+		{"Postgres", DriverPostgres, false},
+		{"MYSQL", DriverMySQL, false},
+		{"oracle", "", true},
+	}
+	for _, tt := range tests {
+		d, err := GetDialect(tt.driver)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: got error %v, want error %v", tt.driver, err, tt.wantErr)
+			continue
+		}
+		if err == nil && d.Name != tt.want {
+			t.Errorf("%q: got dialect %s, want %s", tt.driver, d.Name, tt.want)
+		}
+	}
+}
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name   string
+		driver string
+		query  string
+		want   string
+	}{
+		{
+			name:   "sqlite unchanged",
+			driver: DriverSQLite,
+			query:  `SELECT "key" FROM keys WHERE id = ? AND user_id = ?`,
+			want:   `SELECT "key" FROM keys WHERE id = ? AND user_id = ?`,
+		},
+		{
+			name:   "postgres placeholders",
+			driver: DriverPostgres,
+			query:  `UPDATE keys SET status = ?, archived_at = ? WHERE id = ?`,
+			want:   `UPDATE keys SET status = $1, archived_at = $2 WHERE id = $3`,
+		},
+		{
+			name:   "postgres identifiers",
+			driver: DriverPostgres,
+			query:  `SELECT "key" FROM keys WHERE id = ?`,
+			want:   `SELECT "key" FROM keys WHERE id = $1`,
+		},
+		{
+			name:   "mysql identifiers",
+			driver: DriverMySQL,
+			query:  `SELECT "key" FROM keys WHERE id = ?`,
+			want:   "SELECT `key` FROM keys WHERE id = ?",
+		},
+		{
+			name:   "postgres string literal",
+			driver: DriverPostgres,
+			query:  `SELECT id FROM audit_logs WHERE detail = '"what?"' AND user_id = ?`,
+			want:   `SELECT id FROM audit_logs WHERE detail = '"what?"' AND user_id = $1`,
+		},
+		{
+			name:   "mysql string literal",
+			driver: DriverMySQL,
+			query:  `SELECT "key" FROM keys WHERE store = '"db"' AND id = ?`,
+			want:   "SELECT `key` FROM keys WHERE store = '\"db\"' AND id = ?",
+		},
+		{
+			name:   "escaped quote in literal",
+			driver: DriverPostgres,
+			query:  `SELECT id FROM users WHERE username = 'it''s?' AND id = ?`,
+			want:   `SELECT id FROM users WHERE username = 'it''s?' AND id = $1`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := GetDialect(tt.driver)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := d.Rebind(tt.query); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
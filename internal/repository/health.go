@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"errors"
+)
+
+// ErrDBNotInitialized 数据库未初始化
+var ErrDBNotInitialized = errors.New("database not initialized")
+
+// Ping 检查数据库连通性
+func Ping() error {
+	if db == nil {
+		return ErrDBNotInitialized
+	}
+	return db.Ping()
+}
+
+// CheckWritable 检查数据库可写：在事务内写入一条记录后回滚
+func CheckWritable() error {
+	if db == nil {
+		return ErrDBNotInitialized
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ex := &executor{q: tx}
+	_, err = ex.Exec(`INSERT INTO audit_logs (id, action, created_at) VALUES (?, ?, ?)`,
+		"health-check", "health_check", now())
+	return err
+}
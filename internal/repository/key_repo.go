@@ -1,32 +1,55 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/sm2-cosign/backend/internal/model"
 )
 
 // KeyRepository 密钥数据访问
-type KeyRepository struct{}
+type KeyRepository interface {
+	Create(key *model.Key) error
+	FindByID(id string) (*model.Key, error)
+	FindByUserID(userID string) (*model.Key, error)
+	FindShareGeneration(userID string) (keyID string, generation int, err error)
+	ListByUserID(userID string) ([]model.Key, error)
+	List(page, pageSize int) ([]model.Key, int64, error)
+	Update(key *model.Key) error
+	UpdateHMACKey(id, hmacKey string) error
+	Delete(id string) error
+	DeleteByUserID(userID string) error
+	Count() (int64, error)
+	ListExpiring(before time.Time, limit int) ([]model.Key, error)
+	MarkExpiryWarned(id string, at time.Time) (bool, error)
+	CountExpiring(before time.Time) (int64, error)
+	ListStores() ([]string, error)
+}
+
+type keyRepository struct {
+	db *executor
+}
 
 // NewKeyRepository 创建密钥数据访问实例
-func NewKeyRepository() *KeyRepository {
-	return &KeyRepository{}
+func NewKeyRepository() KeyRepository {
+	return &keyRepository{db: defaultExecutor}
 }
 
 // Create 创建密钥记录
-func (r *KeyRepository) Create(key *model.Key) error {
-	query := `INSERT INTO keys (id, user_id, d2, d2_inv, public_key, hmac_key, status, created_at) 
-	          VALUES (?, ?, ?, ?, ?, ?, ?, datetime('now'))`
-	_, err := db.Exec(query, key.ID, key.UserID, key.D2, key.D2Inv, key.PublicKey, key.HMACKey, key.Status)
+func (r *keyRepository) Create(key *model.Key) error {
+	query := `INSERT INTO "keys" (id, user_id, d2, d2_inv, pending_d2_inv, share_generation, key_store, public_key, hmac_key, status, not_before, not_after, expiry_warned_at, archived_at, decrypt_until, created_at) 
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.db.Exec(query, key.ID, key.UserID, key.D2, key.D2Inv, key.PendingD2Inv, key.ShareGeneration, key.Store, key.PublicKey, key.HMACKey, key.Status,
+		key.NotBefore, key.NotAfter, key.ExpiryWarnedAt, key.ArchivedAt, key.DecryptUntil, now())
 	return err
 }
 
 // FindByID 根据ID查询密钥
-func (r *KeyRepository) FindByID(id string) (*model.Key, error) {
-	query := `SELECT id, user_id, d2, d2_inv, public_key, hmac_key, status, created_at FROM keys WHERE id = ?`
+func (r *keyRepository) FindByID(id string) (*model.Key, error) {
+	query := `SELECT id, user_id, d2, d2_inv, pending_d2_inv, share_generation, key_store, public_key, hmac_key, status, not_before, not_after, expiry_warned_at, archived_at, decrypt_until, created_at FROM "keys" WHERE id = ?`
 	key := &model.Key{}
-	err := db.QueryRow(query, id).Scan(
-		&key.ID, &key.UserID, &key.D2, &key.D2Inv,
-		&key.PublicKey, &key.HMACKey, &key.Status, &key.CreatedAt,
+	err := r.db.QueryRow(query, id).Scan(
+		&key.ID, &key.UserID, &key.D2, &key.D2Inv, &key.PendingD2Inv, &key.ShareGeneration, &key.Store,
+		&key.PublicKey, &key.HMACKey, &key.Status, &key.NotBefore, &key.NotAfter, &key.ExpiryWarnedAt, &key.ArchivedAt, &key.DecryptUntil, &key.CreatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -34,13 +57,13 @@ func (r *KeyRepository) FindByID(id string) (*model.Key, error) {
 	return key, nil
 }
 
-// FindByUserID 根据用户ID查询密钥
-func (r *KeyRepository) FindByUserID(userID string) (*model.Key, error) {
-	query := `SELECT id, user_id, d2, d2_inv, public_key, hmac_key, status, created_at FROM keys WHERE user_id = ?`
+// FindByUserID 根据用户ID查询当前密钥，不含已归档的密钥
+func (r *keyRepository) FindByUserID(userID string) (*model.Key, error) {
+	query := `SELECT id, user_id, d2, d2_inv, pending_d2_inv, share_generation, key_store, public_key, hmac_key, status, not_before, not_after, expiry_warned_at, archived_at, decrypt_until, created_at FROM "keys" WHERE user_id = ? AND status <> ?`
 	key := &model.Key{}
-	err := db.QueryRow(query, userID).Scan(
-		&key.ID, &key.UserID, &key.D2, &key.D2Inv,
-		&key.PublicKey, &key.HMACKey, &key.Status, &key.CreatedAt,
+	err := r.db.QueryRow(query, userID, model.KeyStatusArchived).Scan(
+		&key.ID, &key.UserID, &key.D2, &key.D2Inv, &key.PendingD2Inv, &key.ShareGeneration, &key.Store,
+		&key.PublicKey, &key.HMACKey, &key.Status, &key.NotBefore, &key.NotAfter, &key.ExpiryWarnedAt, &key.ArchivedAt, &key.DecryptUntil, &key.CreatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -48,19 +71,51 @@ func (r *KeyRepository) FindByUserID(userID string) (*model.Key, error) {
 	return key, nil
 }
 
+// FindShareGeneration 查询用户当前密钥的ID与分量代次，用于校验缓存的私钥分量是否仍有效
+func (r *keyRepository) FindShareGeneration(userID string) (string, int, error) {
+	query := `SELECT id, share_generation FROM "keys" WHERE user_id = ? AND status <> ?`
+	var keyID string
+	var generation int
+	err := r.db.QueryRow(query, userID, model.KeyStatusArchived).Scan(&keyID, &generation)
+	return keyID, generation, err
+}
+
+// ListByUserID 查询用户的全部密钥（含已归档），按创建时间倒序
+func (r *keyRepository) ListByUserID(userID string) ([]model.Key, error) {
+	query := `SELECT id, user_id, d2, d2_inv, pending_d2_inv, share_generation, key_store, public_key, hmac_key, status, not_before, not_after, expiry_warned_at, archived_at, decrypt_until, created_at 
+	          FROM "keys" WHERE user_id = ? ORDER BY created_at DESC`
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []model.Key
+	for rows.Next() {
+		var key model.Key
+		if err := rows.Scan(
+			&key.ID, &key.UserID, &key.D2, &key.D2Inv, &key.PendingD2Inv, &key.ShareGeneration, &key.Store,
+			&key.PublicKey, &key.HMACKey, &key.Status, &key.NotBefore, &key.NotAfter, &key.ExpiryWarnedAt, &key.ArchivedAt, &key.DecryptUntil, &key.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
 // List 获取密钥列表
-func (r *KeyRepository) List(page, pageSize int) ([]model.Key, int64, error) {
+func (r *keyRepository) List(page, pageSize int) ([]model.Key, int64, error) {
 	offset := (page - 1) * pageSize
 
-	var total int64
-	err := db.QueryRow(`SELECT COUNT(*) FROM keys`).Scan(&total)
+	total, err := r.Count()
 	if err != nil {
 		return nil, 0, err
 	}
 
-	query := `SELECT id, user_id, d2, d2_inv, public_key, hmac_key, status, created_at 
-	          FROM keys ORDER BY created_at DESC LIMIT ? OFFSET ?`
-	rows, err := db.Query(query, pageSize, offset)
+	query := `SELECT id, user_id, d2, d2_inv, pending_d2_inv, share_generation, key_store, public_key, hmac_key, status, not_before, not_after, expiry_warned_at, archived_at, decrypt_until, created_at 
+	          FROM "keys" ORDER BY created_at DESC LIMIT ? OFFSET ?`
+	rows, err := r.db.Query(query, pageSize, offset)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -70,8 +125,8 @@ func (r *KeyRepository) List(page, pageSize int) ([]model.Key, int64, error) {
 	for rows.Next() {
 		var key model.Key
 		if err := rows.Scan(
-			&key.ID, &key.UserID, &key.D2, &key.D2Inv,
-			&key.PublicKey, &key.HMACKey, &key.Status, &key.CreatedAt,
+			&key.ID, &key.UserID, &key.D2, &key.D2Inv, &key.PendingD2Inv, &key.ShareGeneration, &key.Store,
+			&key.PublicKey, &key.HMACKey, &key.Status, &key.NotBefore, &key.NotAfter, &key.ExpiryWarnedAt, &key.ArchivedAt, &key.DecryptUntil, &key.CreatedAt,
 		); err != nil {
 			return nil, 0, err
 		}
@@ -81,29 +136,101 @@ func (r *KeyRepository) List(page, pageSize int) ([]model.Key, int64, error) {
 }
 
 // Update 更新密钥
-func (r *KeyRepository) Update(key *model.Key) error {
-	query := `UPDATE keys SET d2 = ?, d2_inv = ?, public_key = ?, hmac_key = ?, status = ? WHERE id = ?`
-	_, err := db.Exec(query, key.D2, key.D2Inv, key.PublicKey, key.HMACKey, key.Status, key.ID)
+func (r *keyRepository) Update(key *model.Key) error {
+	query := `UPDATE "keys" SET d2 = ?, d2_inv = ?, pending_d2_inv = ?, share_generation = ?, key_store = ?, public_key = ?, hmac_key = ?, status = ?,
+	          not_before = ?, not_after = ?, expiry_warned_at = ?, archived_at = ?, decrypt_until = ? WHERE id = ?`
+	_, err := r.db.Exec(query, key.D2, key.D2Inv, key.PendingD2Inv, key.ShareGeneration, key.Store, key.PublicKey, key.HMACKey, key.Status,
+		key.NotBefore, key.NotAfter, key.ExpiryWarnedAt, key.ArchivedAt, key.DecryptUntil, key.ID)
 	return err
 }
 
 // UpdateHMACKey 更新HMAC密钥
-func (r *KeyRepository) UpdateHMACKey(id, hmacKey string) error {
-	query := `UPDATE keys SET hmac_key = ? WHERE id = ?`
-	_, err := db.Exec(query, hmacKey, id)
+func (r *keyRepository) UpdateHMACKey(id, hmacKey string) error {
+	query := `UPDATE "keys" SET hmac_key = ? WHERE id = ?`
+	_, err := r.db.Exec(query, hmacKey, id)
 	return err
 }
 
 // Delete 删除密钥
-func (r *KeyRepository) Delete(id string) error {
-	query := `DELETE FROM keys WHERE id = ?`
-	_, err := db.Exec(query, id)
+func (r *keyRepository) Delete(id string) error {
+	query := `DELETE FROM "keys" WHERE id = ?`
+	_, err := r.db.Exec(query, id)
 	return err
 }
 
 // DeleteByUserID 根据用户ID删除密钥
-func (r *KeyRepository) DeleteByUserID(userID string) error {
-	query := `DELETE FROM keys WHERE user_id = ?`
-	_, err := db.Exec(query, userID)
+func (r *keyRepository) DeleteByUserID(userID string) error {
+	query := `DELETE FROM "keys" WHERE user_id = ?`
+	_, err := r.db.Exec(query, userID)
 	return err
 }
+
+// Count 统计密钥数
+func (r *keyRepository) Count() (int64, error) {
+	var total int64
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM "keys"`).Scan(&total)
+	return total, err
+}
+
+// ListExpiring 查询在 before 之前到期（含已过期）且尚未标记的未归档密钥
+func (r *keyRepository) ListExpiring(before time.Time, limit int) ([]model.Key, error) {
+	query := `SELECT id, user_id, d2, d2_inv, pending_d2_inv, share_generation, key_store, public_key, hmac_key, status, not_before, not_after, expiry_warned_at, archived_at, decrypt_until, created_at 
+	          FROM "keys" WHERE not_after IS NOT NULL AND not_after <= ? AND expiry_warned_at IS NULL AND status <> ?
+	          ORDER BY not_after LIMIT ?`
+	rows, err := r.db.Query(query, before.UTC(), model.KeyStatusArchived, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []model.Key
+	for rows.Next() {
+		var key model.Key
+		if err := rows.Scan(
+			&key.ID, &key.UserID, &key.D2, &key.D2Inv, &key.PendingD2Inv, &key.ShareGeneration, &key.Store,
+			&key.PublicKey, &key.HMACKey, &key.Status, &key.NotBefore, &key.NotAfter, &key.ExpiryWarnedAt, &key.ArchivedAt, &key.DecryptUntil, &key.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// MarkExpiryWarned 标记密钥即将过期，已被标记时返回 false
+func (r *keyRepository) MarkExpiryWarned(id string, at time.Time) (bool, error) {
+	query := `UPDATE "keys" SET expiry_warned_at = ? WHERE id = ? AND expiry_warned_at IS NULL`
+	result, err := r.db.Exec(query, at.UTC(), id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// CountExpiring 统计在 before 之前到期（含已过期）的未归档密钥数
+func (r *keyRepository) CountExpiring(before time.Time) (int64, error) {
+	var total int64
+	query := `SELECT COUNT(*) FROM "keys" WHERE not_after IS NOT NULL AND not_after <= ? AND status <> ?`
+	err := r.db.QueryRow(query, before.UTC(), model.KeyStatusArchived).Scan(&total)
+	return total, err
+}
+
+// ListStores 查询密钥记录（含已归档）引用的存储后端
+func (r *keyRepository) ListStores() ([]string, error) {
+	rows, err := r.db.Query(`SELECT DISTINCT key_store FROM "keys"`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations
+var migrationFS embed.FS
+
+// ErrSchemaTooNew 数据库结构版本高于当前程序支持的版本
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary")
+
+// Migration 数据库迁移
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus 迁移状态
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrations 返回当前方言按版本升序排列的内置迁移
+// 脚本位于 migrations/<方言>/，命名规则: <版本号>_<名称>.up.sql / <版本号>_<名称>.down.sql
+func Migrations() ([]Migration, error) {
+	if dialect == nil {
+		return nil, ErrDBNotInitialized
+	}
+	dir := path.Join("migrations", dialect.Name)
+	entries, err := fs.ReadDir(migrationFS, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(fileName, "."+direction+".sql")
+		versionPart, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name: %s", fileName)
+		}
+		version, err := strconv.Atoi(versionPart)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version: %s", fileName)
+		}
+
+		content, err := fs.ReadFile(migrationFS, path.Join(dir, fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("conflicting names for migration %d: %s, %s", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d has no up script", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// LatestVersion 当前程序内置的最新结构版本
+func LatestVersion() (int, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+func ensureMigrationsTable() error {
+	_, err := defaultExecutor.Exec(dialect.migrationsTable)
+	return err
+}
+
+// SchemaVersion 数据库当前结构版本，未执行过迁移时为 0
+func SchemaVersion() (int, error) {
+	if db == nil {
+		return 0, ErrDBNotInitialized
+	}
+	if err := ensureMigrationsTable(); err != nil {
+		return 0, err
+	}
+	var version int
+	err := defaultExecutor.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+// CheckSchemaVersion 检查数据库结构版本不高于程序支持的版本
+func CheckSchemaVersion() error {
+	current, err := SchemaVersion()
+	if err != nil {
+		return err
+	}
+	latest, err := LatestVersion()
+	if err != nil {
+		return err
+	}
+	if current > latest {
+		return fmt.Errorf("%w: database version %d, binary supports up to %d", ErrSchemaTooNew, current, latest)
+	}
+	return nil
+}
+
+// MigrationStatuses 返回全部迁移及其执行状态
+func MigrationStatuses() ([]MigrationStatus, error) {
+	if db == nil {
+		return nil, ErrDBNotInitialized
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := defaultExecutor.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		appliedAt, ok := applied[m.Version]
+		statuses = append(statuses, MigrationStatus{
+			Version:   m.Version,
+			Name:      m.Name,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+	return statuses, nil
+}
+
+// MigrateUp 执行未应用的迁移直到 target 版本，target 为 0 表示最新版本
+// 返回本次应用的迁移版本
+func MigrateUp(target int) ([]int, error) {
+	if err := CheckSchemaVersion(); err != nil {
+		return nil, err
+	}
+	current, err := SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []int
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if target > 0 && m.Version > target {
+			break
+		}
+		if err := applyMigration(m.Version, m.Name, m.Up, true); err != nil {
+			return applied, fmt.Errorf("migration %d_%s up: %w", m.Version, m.Name, err)
+		}
+		applied = append(applied, m.Version)
+	}
+	return applied, nil
+}
+
+// MigrateDown 回滚最近的 steps 个迁移，返回本次回滚的迁移版本
+func MigrateDown(steps int) ([]int, error) {
+	if err := CheckSchemaVersion(); err != nil {
+		return nil, err
+	}
+	current, err := SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []int
+	for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+		m := migrations[i]
+		if m.Version > current {
+			continue
+		}
+		if m.Down == "" {
+			return reverted, fmt.Errorf("migration %d_%s has no down script", m.Version, m.Name)
+		}
+		if err := applyMigration(m.Version, m.Name, m.Down, false); err != nil {
+			return reverted, fmt.Errorf("migration %d_%s down: %w", m.Version, m.Name, err)
+		}
+		reverted = append(reverted, m.Version)
+	}
+	return reverted, nil
+}
+
+// applyMigration 在单个事务内执行迁移脚本并更新 schema_migrations
+// 注意 MySQL 的 DDL 会隐式提交，失败时需要人工检查结构
+func applyMigration(version int, name, script string, up bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 迁移脚本按方言原样执行，不经过占位符改写
+	if _, err := tx.Exec(script); err != nil {
+		return err
+	}
+	ex := &executor{q: tx}
+	if up {
+		_, err = ex.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+			version, name, now())
+	} else {
+		_, err = ex.Exec(`DELETE FROM schema_migrations WHERE version = ?`, version)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
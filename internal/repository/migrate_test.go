@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	openTestDB(t)
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %d_%s: want version %d", m.Version, m.Name, i+1)
+		}
+		if m.Down == "" {
+			t.Errorf("migration %d_%s has no down script", m.Version, m.Name)
+		}
+	}
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	openTestDB(t)
+	latest, err := LatestVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// checkVersion 检查当前结构版本与已应用的迁移数
+	checkVersion := func(t *testing.T, want int) {
+		t.Helper()
+		if got, err := SchemaVersion(); err != nil || got != want {
+			t.Fatalf("schema version: got %d, %v, want %d", got, err, want)
+		}
+		statuses, err := MigrationStatuses()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range statuses {
+			if s.Applied != (s.Version <= want) || s.Applied == s.AppliedAt.IsZero() {
+				t.Errorf("migration %d_%s: applied %v at %v", s.Version, s.Name, s.Applied, s.AppliedAt)
+			}
+		}
+	}
+	checkVersion(t, 0)
+
+	steps := []struct {
+		name    string
+		run     func() ([]int, error)
+		want    []int
+		version int
+	}{
+		{"up to 3", func() ([]int, error) { return MigrateUp(3) }, []int{1, 2, 3}, 3},
+		{"up to 3 again", func() ([]int, error) { return MigrateUp(3) }, nil, 3},
+		{"up to latest", func() ([]int, error) { return MigrateUp(0) }, versions(4, latest), latest},
+		{"down 2", func() ([]int, error) { return MigrateDown(2) }, versions(latest, latest-1), latest - 2},
+		{"down all", func() ([]int, error) { return MigrateDown(latest) }, versions(latest-2, 1), 0},
+		{"down on empty schema", func() ([]int, error) { return MigrateDown(1) }, nil, 0},
+		{"up from scratch", func() ([]int, error) { return MigrateUp(0) }, versions(1, latest), latest},
+	}
+	for _, step := range steps {
+		got, err := step.run()
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if !slices.Equal(got, step.want) {
+			t.Fatalf("%s: applied %v, want %v", step.name, got, step.want)
+		}
+		checkVersion(t, step.version)
+		if exists := tableExists(t, "users"); exists != (step.version > 0) {
+			t.Fatalf("%s: users table exists = %v", step.name, exists)
+		}
+	}
+}
+
+func TestSchemaTooNew(t *testing.T) {
+	openTestDB(t)
+	if _, err := MigrateUp(0); err != nil {
+		t.Fatal(err)
+	}
+	latest, err := LatestVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 模拟更新版本程序执行过的迁移
+	if _, err := defaultExecutor.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		latest+1, "future", now()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		run  func() error
+	}{
+		{"check", CheckSchemaVersion},
+		{"up", func() error { _, err := MigrateUp(0); return err }},
+		{"down", func() error { _, err := MigrateDown(1); return err }},
+	} {
+		if err := tt.run(); !errors.Is(err, ErrSchemaTooNew) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, ErrSchemaTooNew)
+		}
+	}
+	if got, err := SchemaVersion(); err != nil || got != latest+1 {
+		t.Errorf("schema version after refused migrations: %d, %v", got, err)
+	}
+}
+
+func TestMigrateNotInitialized(t *testing.T) {
+	if _, err := SchemaVersion(); !errors.Is(err, ErrDBNotInitialized) {
+		t.Errorf("schema version: got %v, want %v", err, ErrDBNotInitialized)
+	}
+}
+
+// versions 返回从 from 到 to（含两端，可递减）的版本序列
+func versions(from, to int) []int {
+	var v []int
+	step := 1
+	if to < from {
+		step = -1
+	}
+	for i := from; ; i += step {
+		v = append(v, i)
+		if i == to {
+			return v
+		}
+	}
+}
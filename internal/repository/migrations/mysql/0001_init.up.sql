@@ -0,0 +1,51 @@
+-- SM2 协同签名服务初始表结构
+-- 数据库: MySQL 8.0+ (InnoDB, utf8mb4)
+
+-- 用户表
+CREATE TABLE IF NOT EXISTS users (
+    id VARCHAR(64) PRIMARY KEY,
+    username VARCHAR(64) NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    public_key TEXT NOT NULL,
+    status INT DEFAULT 1,
+    created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 密钥分量表 (keys 为 MySQL 保留字，需要反引号)
+CREATE TABLE IF NOT EXISTS `keys` (
+    id VARCHAR(64) PRIMARY KEY,
+    user_id VARCHAR(64) NOT NULL,
+    d2 TEXT NOT NULL,
+    d2_inv TEXT NOT NULL,
+    public_key TEXT NOT NULL,
+    hmac_key TEXT,
+    status INT DEFAULT 1,
+    created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    INDEX idx_keys_user_id (user_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 会话表
+CREATE TABLE IF NOT EXISTS sessions (
+    id VARCHAR(128) PRIMARY KEY,
+    user_id VARCHAR(64) NOT NULL,
+    expires_at DATETIME(6) NOT NULL,
+    created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    INDEX idx_sessions_user_id (user_id),
+    INDEX idx_sessions_expires_at (expires_at),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 审计日志表
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id VARCHAR(64) PRIMARY KEY,
+    user_id VARCHAR(64),
+    action VARCHAR(64) NOT NULL,
+    detail TEXT,
+    ip_address VARCHAR(64),
+    created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    INDEX idx_audit_logs_user_id (user_id),
+    INDEX idx_audit_logs_created_at (created_at),
+    INDEX idx_audit_logs_action (action)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
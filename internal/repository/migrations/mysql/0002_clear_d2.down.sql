@@ -0,0 +1,2 @@
+-- 已清除的 d2 无法也无需恢复：程序只使用 d2_inv
+SELECT 1;
@@ -0,0 +1,2 @@
+-- 回滚前须确认没有非 db 后端的密钥记录，否则这些记录的 d2_inv 列会被旧程序当作 d2Inv 解码
+ALTER TABLE `keys` DROP COLUMN key_store;
@@ -0,0 +1,3 @@
+-- 记录服务端私钥分量所在的存储后端: db / file / softhsm 等
+-- db 后端的 d2_inv 列为 Base64 编码的 d2Inv，其他后端为分量在后端内的标识；已有记录均属于 db 后端
+ALTER TABLE `keys` ADD COLUMN key_store VARCHAR(32) NOT NULL DEFAULT 'db';
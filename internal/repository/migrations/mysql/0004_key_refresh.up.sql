@@ -0,0 +1,3 @@
+-- 私钥分量刷新的待提交分量：准备阶段写入，提交时替换 d2_inv，引用方式与 d2_inv 相同
+-- MySQL 的 TEXT 列不支持字面量默认值，已有记录取隐式默认值空字符串
+ALTER TABLE `keys` ADD COLUMN pending_d2_inv TEXT NOT NULL;
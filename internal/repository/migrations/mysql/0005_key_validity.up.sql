@@ -0,0 +1,6 @@
+-- 密钥有效期：not_before / not_after 为空表示不限制，已有记录均不限制
+-- expiry_warned_at 为后台任务标记密钥即将过期的时间，重新生成密钥时清空
+ALTER TABLE `keys` ADD COLUMN not_before DATETIME(6) NULL;
+ALTER TABLE `keys` ADD COLUMN not_after DATETIME(6) NULL;
+ALTER TABLE `keys` ADD COLUMN expiry_warned_at DATETIME(6) NULL;
+CREATE INDEX idx_keys_not_after ON `keys`(not_after);
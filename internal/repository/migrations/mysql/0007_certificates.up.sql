@@ -0,0 +1,16 @@
+-- 内置 CA 签发的证书
+-- 不设外键，密钥或用户清除后证书记录保留，便于查询已签发证书的状态
+CREATE TABLE IF NOT EXISTS certificates (
+    id VARCHAR(64) PRIMARY KEY,
+    user_id VARCHAR(64) NOT NULL,
+    key_id VARCHAR(64) NOT NULL,
+    serial_number VARCHAR(64) NOT NULL,
+    subject TEXT NOT NULL,
+    not_before DATETIME(6) NOT NULL,
+    not_after DATETIME(6) NOT NULL,
+    certificate TEXT NOT NULL,
+    created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    UNIQUE INDEX idx_certificates_serial_number (serial_number),
+    INDEX idx_certificates_user_id (user_id),
+    INDEX idx_certificates_key_id (key_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
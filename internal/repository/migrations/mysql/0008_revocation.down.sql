@@ -0,0 +1,4 @@
+-- 回滚后已吊销的证书不再出现在 CRL 中，状态查询均返回有效
+DROP INDEX idx_certificates_revoked_at ON certificates;
+ALTER TABLE certificates DROP COLUMN revocation_reason;
+ALTER TABLE certificates DROP COLUMN revoked_at;
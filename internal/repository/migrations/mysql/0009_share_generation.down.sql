@@ -0,0 +1,2 @@
+-- 回滚后导入不再比较分量代次，-force 可恢复刷新前的备份
+ALTER TABLE `keys` DROP COLUMN share_generation;
@@ -0,0 +1,3 @@
+-- 服务端只需要 d2_inv，清除冗余存储的私钥分量 d2（签名所需的 d2 由 d2_inv 求逆得到）
+-- 列保留为空字符串以兼容旧版本程序；d2_inv 为空的记录保留 d2，由程序推导 d2_inv
+UPDATE keys SET d2 = '' WHERE d2 <> '' AND d2_inv <> '';
@@ -0,0 +1,4 @@
+-- 回滚前须先清除已归档的用户与密钥，否则旧版本程序会将其视为普通记录
+ALTER TABLE keys DROP COLUMN decrypt_until;
+ALTER TABLE keys DROP COLUMN archived_at;
+ALTER TABLE users DROP COLUMN archived_at;
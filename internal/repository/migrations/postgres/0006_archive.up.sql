@@ -0,0 +1,5 @@
+-- 用户与密钥归档：删除改为归档 (status = 2)，archived_at 为归档时间，超过宽限期后才可清除
+-- decrypt_until 为管理员批准归档密钥解密的截止时间
+ALTER TABLE users ADD COLUMN archived_at TIMESTAMPTZ;
+ALTER TABLE keys ADD COLUMN archived_at TIMESTAMPTZ;
+ALTER TABLE keys ADD COLUMN decrypt_until TIMESTAMPTZ;
@@ -0,0 +1,16 @@
+-- 内置 CA 签发的证书
+-- 不设外键，密钥或用户清除后证书记录保留，便于查询已签发证书的状态
+CREATE TABLE IF NOT EXISTS certificates (
+    id VARCHAR(64) PRIMARY KEY,
+    user_id VARCHAR(64) NOT NULL,
+    key_id VARCHAR(64) NOT NULL,
+    serial_number VARCHAR(64) UNIQUE NOT NULL,
+    subject TEXT NOT NULL,
+    not_before TIMESTAMPTZ NOT NULL,
+    not_after TIMESTAMPTZ NOT NULL,
+    certificate TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_certificates_user_id ON certificates(user_id);
+CREATE INDEX IF NOT EXISTS idx_certificates_key_id ON certificates(key_id);
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS cleanup_expired_sessions;
+DROP TABLE IF EXISTS audit_logs;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS keys;
+DROP TABLE IF EXISTS users;
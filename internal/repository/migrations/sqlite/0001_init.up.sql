@@ -1,11 +1,6 @@
--- SM2 协同签名服务数据库初始化脚本
+-- SM2 协同签名服务初始表结构
 -- 数据库: SQLite3
 
--- 启用 WAL 模式以提高并发性能
-PRAGMA journal_mode = WAL;
-PRAGMA synchronous = NORMAL;
-PRAGMA foreign_keys = ON;
-
 -- 用户表
 CREATE TABLE IF NOT EXISTS users (
     id TEXT PRIMARY KEY,              -- 用户ID (UUID)
@@ -0,0 +1,2 @@
+-- 未提交的刷新随列删除，对应的分量不再被引用
+ALTER TABLE keys DROP COLUMN pending_d2_inv;
@@ -0,0 +1,2 @@
+-- 私钥分量刷新的待提交分量：准备阶段写入，提交时替换 d2_inv，引用方式与 d2_inv 相同
+ALTER TABLE keys ADD COLUMN pending_d2_inv TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+-- 回滚后所有密钥不再受有效期限制
+DROP INDEX IF EXISTS idx_keys_not_after;
+ALTER TABLE keys DROP COLUMN expiry_warned_at;
+ALTER TABLE keys DROP COLUMN not_after;
+ALTER TABLE keys DROP COLUMN not_before;
@@ -0,0 +1,16 @@
+-- 内置 CA 签发的证书
+-- 不设外键，密钥或用户清除后证书记录保留，便于查询已签发证书的状态
+CREATE TABLE IF NOT EXISTS certificates (
+    id TEXT PRIMARY KEY,              -- 证书记录ID (UUID)
+    user_id TEXT NOT NULL,            -- 所属用户ID
+    key_id TEXT NOT NULL,             -- 证书公钥对应的密钥ID
+    serial_number TEXT UNIQUE NOT NULL, -- 证书序列号 (hex)
+    subject TEXT NOT NULL,            -- 证书主题 (RFC 2253)
+    not_before DATETIME NOT NULL,
+    not_after DATETIME NOT NULL,
+    certificate TEXT NOT NULL,        -- 证书 (PEM)
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_certificates_user_id ON certificates(user_id);
+CREATE INDEX IF NOT EXISTS idx_certificates_key_id ON certificates(key_id);
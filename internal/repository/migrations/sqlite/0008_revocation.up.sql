@@ -0,0 +1,6 @@
+-- 证书吊销状态：revoked_at 为吊销时间，revocation_reason 为 RFC 5280 吊销原因码
+-- 原因码 6 (certificateHold) 为用户禁用时的暂停，重新启用后解除
+ALTER TABLE certificates ADD COLUMN revoked_at DATETIME;
+ALTER TABLE certificates ADD COLUMN revocation_reason INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_certificates_revoked_at ON certificates(revoked_at);
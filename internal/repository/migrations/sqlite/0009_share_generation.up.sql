@@ -0,0 +1,2 @@
+-- 私钥分量代次：每次提交分量刷新加 1，备份记录导出时的代次，导入时拒绝恢复低于当前代次的旧分量
+ALTER TABLE keys ADD COLUMN share_generation INTEGER NOT NULL DEFAULT 0;
@@ -2,48 +2,204 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/sm2-cosign/backend/internal/config"
+	"github.com/sm2-cosign/backend/internal/metrics"
 	_ "modernc.org/sqlite"
 )
 
 var (
-	db   *sql.DB
-	once sync.Once
+	db      *sql.DB
+	dialect *Dialect
+	mu      sync.Mutex
 )
 
-// InitDB 初始化数据库连接
-func InitDB(dbPath string) error {
-	var err error
-	once.Do(func() {
-		// 确保数据目录存在
-		dir := dbPath[:len(dbPath)-len("/cosign.db")]
-		if err = os.MkdirAll(dir, 0755); err != nil {
-			return
-		}
+// querier *sql.DB 与 *sql.Tx 的公共查询方法
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
 
-		db, err = sql.Open("sqlite", dbPath)
-		if err != nil {
-			return
-		}
+// executor 执行仓储层 SQL：按方言改写语句并记录耗时
+type executor struct {
+	q querier
+}
 
-		// 设置连接池
-		db.SetMaxOpenConns(10)
-		db.SetMaxIdleConns(5)
+// defaultExecutor 使用全局连接池的执行器
+var defaultExecutor = &executor{}
 
-		// 启用 WAL 模式
-		_, err = db.Exec("PRAGMA journal_mode = WAL")
-		if err != nil {
-			return
+func (e *executor) querier() querier {
+	if e.q != nil {
+		return e.q
+	}
+	return db
+}
+
+// now 写入数据库的当前时间，统一使用 UTC 以保证各方言下排序与比较一致
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+func observeQuery(op string, start time.Time) {
+	metrics.DBQueryDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+}
+
+// Exec 执行语句
+func (e *executor) Exec(query string, args ...interface{}) (sql.Result, error) {
+	defer observeQuery("exec", time.Now())
+	return e.querier().Exec(dialect.Rebind(query), args...)
+}
+
+// Query 执行查询
+func (e *executor) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	defer observeQuery("query", time.Now())
+	return e.querier().Query(dialect.Rebind(query), args...)
+}
+
+// QueryRow 执行单行查询
+func (e *executor) QueryRow(query string, args ...interface{}) *sql.Row {
+	defer observeQuery("query_row", time.Now())
+	return e.querier().QueryRow(dialect.Rebind(query), args...)
+}
+
+// 内存数据库路径
+const MemoryPath = ":memory:"
+
+var (
+	sqliteJournalModes = map[string]bool{
+		"DELETE": true, "TRUNCATE": true, "PERSIST": true, "MEMORY": true, "WAL": true, "OFF": true,
+	}
+	sqliteSynchronousLevels = map[string]bool{
+		"OFF": true, "NORMAL": true, "FULL": true, "EXTRA": true,
+	}
+)
+
+// InitDB 初始化数据库连接，重复调用时会先关闭已有连接
+func InitDB(cfg config.DatabaseConfig) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if db != nil {
+		db.Close()
+		db = nil
+	}
+
+	d, err := GetDialect(cfg.Driver)
+	if err != nil {
+		return err
+	}
+	dsn, err := buildDSN(d, cfg)
+	if err != nil {
+		return err
+	}
+
+	conn, err := sql.Open(d.DriverName, dsn)
+	if err != nil {
+		return err
+	}
+
+	// 设置连接池
+	if d.Name == DriverSQLite && cfg.Path == MemoryPath {
+		// 每个连接都是独立的内存数据库，只能使用单连接且不能回收
+		conn.SetMaxOpenConns(1)
+		conn.SetMaxIdleConns(1)
+		conn.SetConnMaxLifetime(0)
+		conn.SetConnMaxIdleTime(0)
+	} else {
+		conn.SetMaxOpenConns(cfg.MaxOpenConns)
+		conn.SetMaxIdleConns(cfg.MaxIdleConns)
+		conn.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return err
+	}
+
+	db, dialect = conn, d
+	return nil
+}
+
+// buildDSN 按驱动生成连接串
+func buildDSN(d *Dialect, cfg config.DatabaseConfig) (string, error) {
+	switch d.Name {
+	case DriverSQLite:
+		return buildSQLiteDSN(cfg)
+	case DriverMySQL:
+		if cfg.DSN == "" {
+			return "", errors.New("database.dsn is required for mysql")
 		}
-		_, err = db.Exec("PRAGMA synchronous = NORMAL")
+		mc, err := mysql.ParseDSN(cfg.DSN)
 		if err != nil {
-			return
+			return "", err
 		}
-		_, err = db.Exec("PRAGMA foreign_keys = ON")
-	})
-	return err
+		// 时间列扫描为 time.Time；迁移脚本包含多条语句
+		mc.ParseTime = true
+		mc.MultiStatements = true
+		return mc.FormatDSN(), nil
+	default:
+		if cfg.DSN == "" {
+			return "", errors.New("database.dsn is required for " + d.Name)
+		}
+		return cfg.DSN, nil
+	}
+}
+
+// buildSQLiteDSN 生成 SQLite 连接串
+// PRAGMA 通过 _pragma 参数传入，保证连接池中的每个连接都生效
+func buildSQLiteDSN(cfg config.DatabaseConfig) (string, error) {
+	if cfg.Path == "" {
+		return "", errors.New("database.path is required for sqlite")
+	}
+
+	journalMode := strings.ToUpper(cfg.JournalMode)
+	if journalMode == "" {
+		journalMode = "WAL"
+	}
+	if !sqliteJournalModes[journalMode] {
+		return "", fmt.Errorf("invalid database.journal_mode: %s", cfg.JournalMode)
+	}
+	synchronous := strings.ToUpper(cfg.Synchronous)
+	if synchronous == "" {
+		synchronous = "NORMAL"
+	}
+	if !sqliteSynchronousLevels[synchronous] {
+		return "", fmt.Errorf("invalid database.synchronous: %s", cfg.Synchronous)
+	}
+
+	params := url.Values{}
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", cfg.BusyTimeout.Milliseconds()))
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "synchronous("+synchronous+")")
+	// 读后写的事务直接获取写锁，避免锁升级时的 SQLITE_BUSY
+	params.Set("_txlock", "immediate")
+	// 时间按 SQLite 标准格式写入，与 CURRENT_TIMESTAMP 可比较
+	params.Set("_time_format", "sqlite")
+
+	if cfg.Path == MemoryPath {
+		return MemoryPath + "?" + params.Encode(), nil
+	}
+
+	params.Add("_pragma", "journal_mode("+journalMode+")")
+
+	// 确保数据目录存在
+	if dir := filepath.Dir(cfg.Path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", err
+		}
+	}
+	return cfg.Path + "?" + params.Encode(), nil
 }
 
 // GetDB 获取数据库连接
@@ -53,8 +209,13 @@ func GetDB() *sql.DB {
 
 // CloseDB 关闭数据库连接
 func CloseDB() error {
-	if db != nil {
-		return db.Close()
+	mu.Lock()
+	defer mu.Unlock()
+
+	if db == nil {
+		return nil
 	}
-	return nil
+	err := db.Close()
+	db = nil
+	return err
 }
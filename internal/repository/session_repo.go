@@ -7,26 +7,40 @@ import (
 )
 
 // SessionRepository 会话数据访问
-type SessionRepository struct{}
+type SessionRepository interface {
+	Create(session *model.Session) error
+	FindByID(id string) (*model.Session, error)
+	FindByUserID(userID string) ([]model.Session, error)
+	Delete(id string) error
+	DeleteByUserID(userID string) error
+	DeleteExpired() error
+	UpdateExpiresAt(id string, expiresAt time.Time) error
+	CleanupExpired() (int64, error)
+	CountActive() (int64, error)
+}
+
+type sessionRepository struct {
+	db *executor
+}
 
 // NewSessionRepository 创建会话数据访问实例
-func NewSessionRepository() *SessionRepository {
-	return &SessionRepository{}
+func NewSessionRepository() SessionRepository {
+	return &sessionRepository{db: defaultExecutor}
 }
 
 // Create 创建会话
-func (r *SessionRepository) Create(session *model.Session) error {
+func (r *sessionRepository) Create(session *model.Session) error {
 	query := `INSERT INTO sessions (id, user_id, expires_at, created_at) 
-	          VALUES (?, ?, ?, datetime('now'))`
-	_, err := db.Exec(query, session.ID, session.UserID, session.ExpiresAt)
+	          VALUES (?, ?, ?, ?)`
+	_, err := r.db.Exec(query, session.ID, session.UserID, session.ExpiresAt.UTC(), now())
 	return err
 }
 
 // FindByID 根据ID查询会话
-func (r *SessionRepository) FindByID(id string) (*model.Session, error) {
+func (r *sessionRepository) FindByID(id string) (*model.Session, error) {
 	query := `SELECT id, user_id, expires_at, created_at FROM sessions WHERE id = ?`
 	session := &model.Session{}
-	err := db.QueryRow(query, id).Scan(
+	err := r.db.QueryRow(query, id).Scan(
 		&session.ID, &session.UserID, &session.ExpiresAt, &session.CreatedAt,
 	)
 	if err != nil {
@@ -36,9 +50,9 @@ func (r *SessionRepository) FindByID(id string) (*model.Session, error) {
 }
 
 // FindByUserID 根据用户ID查询会话列表
-func (r *SessionRepository) FindByUserID(userID string) ([]model.Session, error) {
+func (r *sessionRepository) FindByUserID(userID string) ([]model.Session, error) {
 	query := `SELECT id, user_id, expires_at, created_at FROM sessions WHERE user_id = ? ORDER BY created_at DESC`
-	rows, err := db.Query(query, userID)
+	rows, err := r.db.Query(query, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -58,38 +72,45 @@ func (r *SessionRepository) FindByUserID(userID string) ([]model.Session, error)
 }
 
 // Delete 删除会话
-func (r *SessionRepository) Delete(id string) error {
+func (r *sessionRepository) Delete(id string) error {
 	query := `DELETE FROM sessions WHERE id = ?`
-	_, err := db.Exec(query, id)
+	_, err := r.db.Exec(query, id)
 	return err
 }
 
 // DeleteByUserID 根据用户ID删除所有会话
-func (r *SessionRepository) DeleteByUserID(userID string) error {
+func (r *sessionRepository) DeleteByUserID(userID string) error {
 	query := `DELETE FROM sessions WHERE user_id = ?`
-	_, err := db.Exec(query, userID)
+	_, err := r.db.Exec(query, userID)
 	return err
 }
 
 // DeleteExpired 删除过期会话
-func (r *SessionRepository) DeleteExpired() error {
+func (r *sessionRepository) DeleteExpired() error {
 	query := `DELETE FROM sessions WHERE expires_at < ?`
-	_, err := db.Exec(query, time.Now())
+	_, err := r.db.Exec(query, now())
 	return err
 }
 
 // UpdateExpiresAt 更新会话过期时间
-func (r *SessionRepository) UpdateExpiresAt(id string, expiresAt time.Time) error {
+func (r *sessionRepository) UpdateExpiresAt(id string, expiresAt time.Time) error {
 	query := `UPDATE sessions SET expires_at = ? WHERE id = ?`
-	_, err := db.Exec(query, expiresAt, id)
+	_, err := r.db.Exec(query, expiresAt.UTC(), id)
 	return err
 }
 
 // CleanupExpired 清理过期会话
-func (r *SessionRepository) CleanupExpired() (int64, error) {
-	result, err := db.Exec(`DELETE FROM sessions WHERE expires_at < datetime('now')`)
+func (r *sessionRepository) CleanupExpired() (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM sessions WHERE expires_at < ?`, now())
 	if err != nil {
 		return 0, err
 	}
 	return result.RowsAffected()
 }
+
+// CountActive 统计未过期会话数
+func (r *sessionRepository) CountActive() (int64, error) {
+	var count int64
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM sessions WHERE expires_at > ?`, now()).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Repositories 同一执行上下文下的仓储集合
+// 由 UnitOfWork 在事务内创建时，所有读写都经过同一个事务
+type Repositories struct {
+	Users     UserRepository
+	Keys      KeyRepository
+	Sessions  SessionRepository
+	AuditLogs AuditLogRepository
+	Certs     CertificateRepository
+}
+
+func newRepositories(ex *executor) *Repositories {
+	return &Repositories{
+		Users:     &userRepository{db: ex},
+		Keys:      &keyRepository{db: ex},
+		Sessions:  &sessionRepository{db: ex},
+		AuditLogs: &auditLogRepository{db: ex},
+		Certs:     &certificateRepository{db: ex},
+	}
+}
+
+// UnitOfWork 工作单元：将多步数据库操作放在同一事务内执行
+type UnitOfWork interface {
+	// Do 在事务内执行 fn，fn 返回错误或发生 panic 时回滚，否则提交
+	// fn 内只能使用传入的 repos 访问数据库，使用全局仓储会绕过事务
+	// (SQLite 内存模式下只有一个连接，还会导致死锁)
+	Do(fn func(repos *Repositories) error) error
+}
+
+type unitOfWork struct{}
+
+// NewUnitOfWork 创建工作单元实例
+func NewUnitOfWork() UnitOfWork {
+	return &unitOfWork{}
+}
+
+// Do 在事务内执行 fn
+func (u *unitOfWork) Do(fn func(repos *Repositories) error) error {
+	return RunInTx(fn)
+}
+
+// RunInTx 在新事务内执行 fn，fn 返回错误或发生 panic 时回滚，否则提交
+func RunInTx(fn func(repos *Repositories) error) (err error) {
+	if db == nil {
+		return ErrDBNotInitialized
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+				err = fmt.Errorf("%w (rollback: %v)", err, rbErr)
+			}
+		}
+	}()
+
+	if err = fn(newRepositories(&executor{q: tx})); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
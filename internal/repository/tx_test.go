@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/sm2-cosign/backend/internal/model"
+)
+
+func TestRunInTx(t *testing.T) {
+	openTestDB(t)
+	if _, err := MigrateUp(0); err != nil {
+		t.Fatal(err)
+	}
+	errAbort := errors.New("abort")
+
+	tests := []struct {
+		name       string
+		after      func(repos *Repositories) error
+		wantErr    error
+		wantPanic  bool
+		wantStored bool
+	}{
+		{"commit", func(*Repositories) error { return nil }, nil, false, true},
+		{"rollback on error", func(*Repositories) error { return errAbort }, errAbort, false, false},
+		{"rollback on panic", func(*Repositories) error { panic(errAbort) }, nil, true, false},
+		{
+			"rollback on query error",
+			func(repos *Repositories) error {
+				_, err := repos.Users.FindByID("no-such-user")
+				return err
+			},
+			sql.ErrNoRows, false, false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &model.User{ID: "user-" + tt.name, Username: tt.name, PasswordHash: "hash"}
+			var err error
+			panicked := func() (panicked bool) {
+				defer func() {
+					if p := recover(); p != nil {
+						if p != errAbort {
+							t.Errorf("got panic %v, want %v", p, errAbort)
+						}
+						panicked = true
+					}
+				}()
+				err = RunInTx(func(repos *Repositories) error {
+					if err := repos.Users.Create(user); err != nil {
+						return err
+					}
+					return tt.after(repos)
+				})
+				return false
+			}()
+			if panicked != tt.wantPanic {
+				t.Fatalf("panicked = %v, want %v", panicked, tt.wantPanic)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			// 回滚后事务内写入的记录不可见，且连接已释放可继续使用
+			_, err = NewUserRepository().FindByID(user.ID)
+			if stored := err == nil; stored != tt.wantStored {
+				t.Errorf("user stored = %v (%v), want %v", stored, err, tt.wantStored)
+			}
+		})
+	}
+}
+
+func TestRunInTxNotInitialized(t *testing.T) {
+	err := RunInTx(func(*Repositories) error {
+		t.Fatal("fn called without a database")
+		return nil
+	})
+	if !errors.Is(err, ErrDBNotInitialized) {
+		t.Errorf("got %v, want %v", err, ErrDBNotInitialized)
+	}
+}
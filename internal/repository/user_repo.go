@@ -5,28 +5,43 @@ import (
 )
 
 // UserRepository 用户数据访问
-type UserRepository struct{}
+type UserRepository interface {
+	Create(user *model.User) error
+	FindByID(id string) (*model.User, error)
+	FindByUsername(username string) (*model.User, error)
+	List(page, pageSize int) ([]model.User, int64, error)
+	Update(user *model.User) error
+	UpdateStatus(id string, status int) error
+	Delete(id string) error
+	ExistsByUsername(username string) (bool, error)
+	Count() (int64, error)
+}
+
+type userRepository struct {
+	db *executor
+}
 
 // NewUserRepository 创建用户数据访问实例
-func NewUserRepository() *UserRepository {
-	return &UserRepository{}
+func NewUserRepository() UserRepository {
+	return &userRepository{db: defaultExecutor}
 }
 
 // Create 创建用户
-func (r *UserRepository) Create(user *model.User) error {
-	query := `INSERT INTO users (id, username, password_hash, public_key, status, created_at, updated_at) 
-	          VALUES (?, ?, ?, ?, ?, datetime('now'), datetime('now'))`
-	_, err := db.Exec(query, user.ID, user.Username, user.PasswordHash, user.PublicKey, user.Status)
+func (r *userRepository) Create(user *model.User) error {
+	createdAt := now()
+	query := `INSERT INTO users (id, username, password_hash, public_key, status, archived_at, created_at, updated_at) 
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.db.Exec(query, user.ID, user.Username, user.PasswordHash, user.PublicKey, user.Status, user.ArchivedAt, createdAt, createdAt)
 	return err
 }
 
 // FindByID 根据ID查询用户
-func (r *UserRepository) FindByID(id string) (*model.User, error) {
-	query := `SELECT id, username, password_hash, public_key, status, created_at, updated_at FROM users WHERE id = ?`
+func (r *userRepository) FindByID(id string) (*model.User, error) {
+	query := `SELECT id, username, password_hash, public_key, status, archived_at, created_at, updated_at FROM users WHERE id = ?`
 	user := &model.User{}
-	err := db.QueryRow(query, id).Scan(
+	err := r.db.QueryRow(query, id).Scan(
 		&user.ID, &user.Username, &user.PasswordHash, &user.PublicKey,
-		&user.Status, &user.CreatedAt, &user.UpdatedAt,
+		&user.Status, &user.ArchivedAt, &user.CreatedAt, &user.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -35,12 +50,12 @@ func (r *UserRepository) FindByID(id string) (*model.User, error) {
 }
 
 // FindByUsername 根据用户名查询用户
-func (r *UserRepository) FindByUsername(username string) (*model.User, error) {
-	query := `SELECT id, username, password_hash, public_key, status, created_at, updated_at FROM users WHERE username = ?`
+func (r *userRepository) FindByUsername(username string) (*model.User, error) {
+	query := `SELECT id, username, password_hash, public_key, status, archived_at, created_at, updated_at FROM users WHERE username = ?`
 	user := &model.User{}
-	err := db.QueryRow(query, username).Scan(
+	err := r.db.QueryRow(query, username).Scan(
 		&user.ID, &user.Username, &user.PasswordHash, &user.PublicKey,
-		&user.Status, &user.CreatedAt, &user.UpdatedAt,
+		&user.Status, &user.ArchivedAt, &user.CreatedAt, &user.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -49,20 +64,19 @@ func (r *UserRepository) FindByUsername(username string) (*model.User, error) {
 }
 
 // List 获取用户列表
-func (r *UserRepository) List(page, pageSize int) ([]model.User, int64, error) {
+func (r *userRepository) List(page, pageSize int) ([]model.User, int64, error) {
 	offset := (page - 1) * pageSize
-	
+
 	// 获取总数
-	var total int64
-	err := db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&total)
+	total, err := r.Count()
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// 获取列表
-	query := `SELECT id, username, password_hash, public_key, status, created_at, updated_at 
+	query := `SELECT id, username, password_hash, public_key, status, archived_at, created_at, updated_at 
 	          FROM users ORDER BY created_at DESC LIMIT ? OFFSET ?`
-	rows, err := db.Query(query, pageSize, offset)
+	rows, err := r.db.Query(query, pageSize, offset)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -73,7 +87,7 @@ func (r *UserRepository) List(page, pageSize int) ([]model.User, int64, error) {
 		var user model.User
 		if err := rows.Scan(
 			&user.ID, &user.Username, &user.PasswordHash, &user.PublicKey,
-			&user.Status, &user.CreatedAt, &user.UpdatedAt,
+			&user.Status, &user.ArchivedAt, &user.CreatedAt, &user.UpdatedAt,
 		); err != nil {
 			return nil, 0, err
 		}
@@ -83,29 +97,36 @@ func (r *UserRepository) List(page, pageSize int) ([]model.User, int64, error) {
 }
 
 // Update 更新用户
-func (r *UserRepository) Update(user *model.User) error {
-	query := `UPDATE users SET password_hash = ?, public_key = ?, status = ?, updated_at = datetime('now') WHERE id = ?`
-	_, err := db.Exec(query, user.PasswordHash, user.PublicKey, user.Status, user.ID)
+func (r *userRepository) Update(user *model.User) error {
+	query := `UPDATE users SET password_hash = ?, public_key = ?, status = ?, archived_at = ?, updated_at = ? WHERE id = ?`
+	_, err := r.db.Exec(query, user.PasswordHash, user.PublicKey, user.Status, user.ArchivedAt, now(), user.ID)
 	return err
 }
 
 // UpdateStatus 更新用户状态
-func (r *UserRepository) UpdateStatus(id string, status int) error {
-	query := `UPDATE users SET status = ?, updated_at = datetime('now') WHERE id = ?`
-	_, err := db.Exec(query, status, id)
+func (r *userRepository) UpdateStatus(id string, status int) error {
+	query := `UPDATE users SET status = ?, updated_at = ? WHERE id = ?`
+	_, err := r.db.Exec(query, status, now(), id)
 	return err
 }
 
 // Delete 删除用户
-func (r *UserRepository) Delete(id string) error {
+func (r *userRepository) Delete(id string) error {
 	query := `DELETE FROM users WHERE id = ?`
-	_, err := db.Exec(query, id)
+	_, err := r.db.Exec(query, id)
 	return err
 }
 
 // ExistsByUsername 检查用户名是否存在
-func (r *UserRepository) ExistsByUsername(username string) (bool, error) {
+func (r *userRepository) ExistsByUsername(username string) (bool, error) {
 	var count int
-	err := db.QueryRow(`SELECT COUNT(*) FROM users WHERE username = ?`, username).Scan(&count)
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM users WHERE username = ?`, username).Scan(&count)
 	return count > 0, err
 }
+
+// Count 统计用户数
+func (r *userRepository) Count() (int64, error) {
+	var total int64
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&total)
+	return total, err
+}
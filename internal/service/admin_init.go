@@ -57,17 +57,30 @@ func InitAdminUser() error {
 
 	publicKeyBytes := crypto.EncodeToBase64(append(keyPair.PublicKey.X.Bytes(), keyPair.PublicKey.Y.Bytes()...))
 
-	userID := utils.GenerateUUID()
 	user := &model.User{
-		ID:           userID,
+		ID:           utils.GenerateUUID(),
 		Username:     username,
 		PasswordHash: hex.EncodeToString(salt) + hex.EncodeToString(passwordHash),
 		PublicKey:    publicKeyBytes,
 		Status:       model.UserStatusEnabled,
 	}
-	if err := userRepo.Create(user); err != nil {
+	// 多实例同时启动时以事务内的检查为准
+	created := false
+	err = repository.RunInTx(func(repos *repository.Repositories) error {
+		exists, err := repos.Users.ExistsByUsername(username)
+		if err != nil || exists {
+			return err
+		}
+		created = true
+		return repos.Users.Create(user)
+	})
+	if err != nil {
 		return err
 	}
+	if !created {
+		log.Printf("Admin user already exists")
+		return nil
+	}
 
 	log.Printf("Admin user created successfully: %s", username)
 	return nil
@@ -0,0 +1,356 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/sm2-cosign/backend/internal/config"
+	"github.com/sm2-cosign/backend/internal/model"
+	"github.com/sm2-cosign/backend/internal/repository"
+	"github.com/sm2-cosign/backend/pkg/response"
+	"github.com/sm2-cosign/backend/pkg/utils"
+)
+
+// 归档默认参数
+const (
+	defaultPurgeGrace  = 30 * 24 * time.Hour
+	defaultMaxApproval = 24 * time.Hour
+)
+
+// archivePolicy 返回归档策略
+func archivePolicy() config.ArchiveConfig {
+	policy := config.ArchiveConfig{PurgeGrace: defaultPurgeGrace, MaxApproval: defaultMaxApproval}
+	if config.AppConfig != nil {
+		policy = config.AppConfig.Archive
+	}
+	return policy
+}
+
+// purgeable 检查归档时间为 archivedAt 的记录在 now 时刻是否已超过清除宽限期
+func purgeable(archivedAt *time.Time, now time.Time) bool {
+	return archivedAt != nil && !now.Before(archivedAt.Add(archivePolicy().PurgeGrace))
+}
+
+// archiveDetail 归档/清除审计日志详情
+func archiveDetail(phase, keyID string) string {
+	detail := map[string]string{"phase": phase}
+	if keyID != "" {
+		detail["keyId"] = keyID
+	}
+	data, _ := json.Marshal(detail)
+	return string(data)
+}
+
+// ArchiveService 用户与密钥的归档、恢复与清除
+// 删除用户或密钥只做归档，私钥分量保留，超过 archive.purge_grace 后才可清除
+type ArchiveService struct {
+	uow repository.UnitOfWork
+}
+
+// NewArchiveService 创建归档服务实例
+func NewArchiveService() *ArchiveService {
+	initCaches()
+	return &ArchiveService{
+		uow: repository.NewUnitOfWork(),
+	}
+}
+
+// archiveKeyTx 在事务内归档密钥、以 reason 吊销其证书并记录审计日志，返回需要删除的未提交刷新分量
+// 重新生成密钥时 reason 为 RevocationSuperseded，审计日志详情的 phase 为 rotate
+func archiveKeyTx(repos *repository.Repositories, key *model.Key, now time.Time, reason int, ipAddress string) (*model.Key, error) {
+	var pending *model.Key
+	if key.PendingD2Inv != "" {
+		pending = pendingKey(key)
+	}
+	key.Status = model.KeyStatusArchived
+	key.ArchivedAt = &now
+	key.DecryptUntil = nil
+	key.PendingD2Inv = ""
+	if err := repos.Keys.Update(key); err != nil {
+		return nil, err
+	}
+	if err := repos.Certs.RevokeByKeyID(key.ID, reason, now); err != nil {
+		return nil, err
+	}
+	phase := "archive"
+	if reason == model.RevocationSuperseded {
+		phase = "rotate"
+	}
+	err := repos.AuditLogs.Create(&model.AuditLog{
+		ID:        utils.GenerateUUID(),
+		UserID:    key.UserID,
+		Action:    model.ActionKeyDel,
+		Detail:    archiveDetail(phase, key.ID),
+		IPAddress: ipAddress,
+	})
+	return pending, err
+}
+
+// ArchiveUser 归档用户：禁止登录并删除会话，用户的密钥一并归档，用户名保留
+func (s *ArchiveService) ArchiveUser(userID, ipAddress string) response.Code {
+	now := time.Now().UTC()
+	var stale []*model.Key
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		user, err := repos.Users.FindByID(userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return codeError(response.CodeUserNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		if user.IsArchived() {
+			return nil
+		}
+		user.Status = model.UserStatusArchived
+		user.ArchivedAt = &now
+		if err := repos.Users.Update(user); err != nil {
+			return err
+		}
+		if err := repos.Sessions.DeleteByUserID(userID); err != nil {
+			return err
+		}
+
+		keys, err := repos.Keys.ListByUserID(userID)
+		if err != nil {
+			return err
+		}
+		for i := range keys {
+			if keys[i].IsArchived() {
+				continue
+			}
+			pending, err := archiveKeyTx(repos, &keys[i], now, model.RevocationCessation, ipAddress)
+			if err != nil {
+				return err
+			}
+			if pending != nil {
+				stale = append(stale, pending)
+			}
+		}
+		return repos.AuditLogs.Create(&model.AuditLog{
+			ID:        utils.GenerateUUID(),
+			UserID:    userID,
+			Action:    model.ActionUserDel,
+			Detail:    archiveDetail("archive", ""),
+			IPAddress: ipAddress,
+		})
+	})
+	invalidateUser(userID)
+	invalidateCRL()
+	if err != nil {
+		return txCode(err)
+	}
+	for _, key := range stale {
+		deleteShare(key)
+	}
+	return response.CodeSuccess
+}
+
+// RestoreUser 恢复已归档的用户，用户的密钥保持归档，须重新生成密钥
+func (s *ArchiveService) RestoreUser(userID, ipAddress string) response.Code {
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		user, err := repos.Users.FindByID(userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return codeError(response.CodeUserNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		if !user.IsArchived() {
+			return nil
+		}
+		user.Status = model.UserStatusEnabled
+		user.ArchivedAt = nil
+		if err := repos.Users.Update(user); err != nil {
+			return err
+		}
+		return repos.AuditLogs.Create(&model.AuditLog{
+			ID:        utils.GenerateUUID(),
+			UserID:    userID,
+			Action:    model.ActionUserRestore,
+			IPAddress: ipAddress,
+		})
+	})
+	invalidateUser(userID)
+	if err != nil {
+		return txCode(err)
+	}
+	return response.CodeSuccess
+}
+
+// PurgeUser 清除已归档且超过宽限期的用户及其全部密钥，私钥分量从所在后端删除
+func (s *ArchiveService) PurgeUser(userID, ipAddress string) response.Code {
+	now := time.Now()
+	var keys []model.Key
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		user, err := repos.Users.FindByID(userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return codeError(response.CodeUserNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		if !user.IsArchived() || !purgeable(user.ArchivedAt, now) {
+			return codeError(response.CodePurgeNotAllowed)
+		}
+
+		if keys, err = repos.Keys.ListByUserID(userID); err != nil {
+			return err
+		}
+		for i := range keys {
+			err := repos.AuditLogs.Create(&model.AuditLog{
+				ID:        utils.GenerateUUID(),
+				UserID:    userID,
+				Action:    model.ActionKeyDel,
+				Detail:    archiveDetail("purge", keys[i].ID),
+				IPAddress: ipAddress,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if err := repos.Keys.DeleteByUserID(userID); err != nil {
+			return err
+		}
+		if err := repos.Users.Delete(userID); err != nil {
+			return err
+		}
+		return repos.AuditLogs.Create(&model.AuditLog{
+			ID:        utils.GenerateUUID(),
+			UserID:    userID,
+			Action:    model.ActionUserDel,
+			Detail:    archiveDetail("purge", ""),
+			IPAddress: ipAddress,
+		})
+	})
+	invalidateUser(userID)
+	if err != nil {
+		return txCode(err)
+	}
+	for i := range keys {
+		deleteShare(&keys[i])
+	}
+	return response.CodeSuccess
+}
+
+// ArchiveKey 归档密钥：不再用于签名、密钥交换与刷新，分量保留供批准后解密
+func (s *ArchiveService) ArchiveKey(keyID, ipAddress string) response.Code {
+	now := time.Now().UTC()
+	var stale *model.Key
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		key, err := repos.Keys.FindByID(keyID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return codeError(response.CodeKeyNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		if key.IsArchived() {
+			return nil
+		}
+		stale, err = archiveKeyTx(repos, key, now, model.RevocationCessation, ipAddress)
+		return err
+	})
+	keyShareCache.removeFunc(func(k *cachedKeyShare) bool { return k.keyID == keyID })
+	invalidateCRL()
+	if err != nil {
+		return txCode(err)
+	}
+	if stale != nil {
+		deleteShare(stale)
+	}
+	return response.CodeSuccess
+}
+
+// KeyApproveRequest 归档密钥解密批准请求
+type KeyApproveRequest struct {
+	// Duration 批准期限，如 "1h"，不超过 archive.max_approval；"0s" 撤销批准
+	Duration string `json:"duration" validate:"required"`
+}
+
+// KeyApproveResponse 归档密钥解密批准结果
+type KeyApproveResponse struct {
+	KeyID        string     `json:"keyId"`
+	DecryptUntil *time.Time `json:"decryptUntil,omitempty"`
+}
+
+// ApproveDecrypt 批准在期限内使用归档密钥解密
+func (s *ArchiveService) ApproveDecrypt(keyID string, req *KeyApproveRequest, ipAddress string) (*KeyApproveResponse, response.Code) {
+	duration, err := time.ParseDuration(req.Duration)
+	if err != nil || duration < 0 {
+		return nil, response.CodeInvalidParam
+	}
+	if limit := archivePolicy().MaxApproval; limit > 0 && duration > limit {
+		return nil, response.CodeInvalidParam
+	}
+	var until *time.Time
+	if duration > 0 {
+		t := time.Now().UTC().Add(duration)
+		until = &t
+	}
+
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		key, err := repos.Keys.FindByID(keyID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return codeError(response.CodeKeyNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		// 未归档的密钥无需批准
+		if !key.IsArchived() {
+			return codeError(response.CodeInvalidParam)
+		}
+		key.DecryptUntil = until
+		if err := repos.Keys.Update(key); err != nil {
+			return err
+		}
+		detail, _ := json.Marshal(map[string]interface{}{"keyId": keyID, "decryptUntil": until})
+		return repos.AuditLogs.Create(&model.AuditLog{
+			ID:        utils.GenerateUUID(),
+			UserID:    key.UserID,
+			Action:    model.ActionKeyApprove,
+			Detail:    string(detail),
+			IPAddress: ipAddress,
+		})
+	})
+	if err != nil {
+		return nil, txCode(err)
+	}
+	return &KeyApproveResponse{KeyID: keyID, DecryptUntil: until}, response.CodeSuccess
+}
+
+// PurgeKey 清除已归档且超过宽限期的密钥，私钥分量从所在后端删除
+func (s *ArchiveService) PurgeKey(keyID, ipAddress string) response.Code {
+	now := time.Now()
+	var purged *model.Key
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		key, err := repos.Keys.FindByID(keyID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return codeError(response.CodeKeyNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		if !key.IsArchived() || !purgeable(key.ArchivedAt, now) {
+			return codeError(response.CodePurgeNotAllowed)
+		}
+		if err := repos.Keys.Delete(keyID); err != nil {
+			return err
+		}
+		purged = key
+		return repos.AuditLogs.Create(&model.AuditLog{
+			ID:        utils.GenerateUUID(),
+			UserID:    key.UserID,
+			Action:    model.ActionKeyDel,
+			Detail:    archiveDetail("purge", keyID),
+			IPAddress: ipAddress,
+		})
+	})
+	if err != nil {
+		return txCode(err)
+	}
+	deleteShare(purged)
+	return response.CodeSuccess
+}
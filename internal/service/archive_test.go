@@ -0,0 +1,100 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sm2-cosign/backend/internal/config"
+	"github.com/sm2-cosign/backend/internal/model"
+	"github.com/sm2-cosign/backend/internal/repository"
+	"github.com/sm2-cosign/backend/pkg/response"
+)
+
+func TestPurgeable(t *testing.T) {
+	saveConfig(t)
+	config.AppConfig.Archive.PurgeGrace = time.Hour
+	now := time.Now()
+	recent, old := now.Add(-time.Minute), now.Add(-time.Hour)
+	tests := []struct {
+		name       string
+		archivedAt *time.Time
+		want       bool
+	}{
+		{"not archived", nil, false},
+		{"within grace", &recent, false},
+		{"grace elapsed", &old, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := purgeable(tt.archivedAt, now); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArchiveKey(t *testing.T) {
+	saveConfig(t)
+	config.AppConfig.Archive = config.ArchiveConfig{PurgeGrace: time.Hour, MaxApproval: 2 * time.Hour}
+	s := NewArchiveService()
+	keyRepo := repository.NewKeyRepository()
+
+	userID, _ := newTestUser(t)
+	key := findKey(t, userID)
+	if code := s.PurgeKey(key.ID, ""); code != response.CodePurgeNotAllowed {
+		t.Fatalf("purge current key: got code %d, want %d", code, response.CodePurgeNotAllowed)
+	}
+	if _, code := s.ApproveDecrypt(key.ID, &KeyApproveRequest{Duration: "1h"}, ""); code != response.CodeInvalidParam {
+		t.Fatalf("approve current key: got code %d, want %d", code, response.CodeInvalidParam)
+	}
+
+	// 重复归档不重复记录审计日志
+	for i := 0; i < 2; i++ {
+		if code := s.ArchiveKey(key.ID, ""); code != response.CodeSuccess {
+			t.Fatalf("archive key: code %d", code)
+		}
+	}
+	archived, err := keyRepo.FindByID(key.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !archived.IsArchived() || archived.ArchivedAt == nil {
+		t.Fatalf("archived key: status %d, archived at %v", archived.Status, archived.ArchivedAt)
+	}
+	if got := auditCount(t, model.ActionKeyDel, userID); got != 1 {
+		t.Errorf("key archive audit entries: got %d, want 1", got)
+	}
+
+	// 批准期限不超过 archive.max_approval，0s 撤销批准
+	for _, tt := range []struct {
+		duration  string
+		want      response.Code
+		wantUntil bool
+	}{
+		{"3h", response.CodeInvalidParam, false},
+		{"-1h", response.CodeInvalidParam, false},
+		{"1h", response.CodeSuccess, true},
+		{"0s", response.CodeSuccess, false},
+	} {
+		resp, code := s.ApproveDecrypt(key.ID, &KeyApproveRequest{Duration: tt.duration}, "")
+		if code != tt.want {
+			t.Errorf("approve %s: got code %d, want %d", tt.duration, code, tt.want)
+			continue
+		}
+		if code == response.CodeSuccess && (resp.DecryptUntil != nil) != tt.wantUntil {
+			t.Errorf("approve %s: decrypt until %v", tt.duration, resp.DecryptUntil)
+		}
+	}
+
+	// 超过宽限期后才可清除
+	if code := s.PurgeKey(key.ID, ""); code != response.CodePurgeNotAllowed {
+		t.Fatalf("purge within grace period: got code %d, want %d", code, response.CodePurgeNotAllowed)
+	}
+	config.AppConfig.Archive.PurgeGrace = 0
+	if code := s.PurgeKey(key.ID, ""); code != response.CodeSuccess {
+		t.Fatalf("purge key: code %d", code)
+	}
+	if code := s.PurgeKey(key.ID, ""); code != response.CodeKeyNotFound {
+		t.Errorf("purge purged key: got code %d, want %d", code, response.CodeKeyNotFound)
+	}
+}
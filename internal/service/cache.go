@@ -0,0 +1,174 @@
+package service
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+
+	"github.com/sm2-cosign/backend/internal/config"
+	"github.com/sm2-cosign/backend/internal/crypto"
+	"github.com/sm2-cosign/backend/internal/metrics"
+	"github.com/sm2-cosign/backend/internal/model"
+)
+
+// 缓存默认参数
+const (
+	defaultSessionCacheSize = 10000
+	defaultSessionCacheTTL  = time.Minute
+	defaultKeyCacheSize     = 10000
+	defaultKeyCacheTTL      = 5 * time.Minute
+)
+
+// ttlCache 带过期时间的 LRU 缓存，nil 表示不缓存
+// 每次失效递增版本号，读库前记录的版本号与写缓存时不一致则放弃写入，
+// 避免失效前读到的旧数据在失效后被写回缓存
+type ttlCache[V any] struct {
+	name string
+	lru  *expirable.LRU[string, V]
+
+	mu  sync.Mutex // 串行化写入与失效
+	gen atomic.Uint64
+}
+
+// newTTLCache 创建缓存，容量或过期时间不大于 0 时返回 nil；onEvict 在条目被淘汰、过期或失效时调用
+func newTTLCache[V any](name string, size int, ttl time.Duration, onEvict func(V)) *ttlCache[V] {
+	if size <= 0 || ttl <= 0 {
+		return nil
+	}
+	var evict expirable.EvictCallback[string, V]
+	if onEvict != nil {
+		evict = func(_ string, v V) { onEvict(v) }
+	}
+	return &ttlCache[V]{name: name, lru: expirable.NewLRU(size, evict, ttl)}
+}
+
+// get 查询缓存
+func (c *ttlCache[V]) get(key string) (V, bool) {
+	if c == nil {
+		var zero V
+		return zero, false
+	}
+	v, ok := c.lru.Get(key)
+	result := "miss"
+	if ok {
+		result = "hit"
+	}
+	metrics.CacheRequests.WithLabelValues(c.name, result).Inc()
+	return v, ok
+}
+
+// generation 返回当前版本号，须在读库之前调用
+func (c *ttlCache[V]) generation() uint64 {
+	if c == nil {
+		return 0
+	}
+	return c.gen.Load()
+}
+
+// add 写入缓存，读库后缓存已失效时放弃写入并返回 false
+func (c *ttlCache[V]) add(key string, v V, gen uint64) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen.Load() != gen {
+		return false
+	}
+	// 覆盖已有条目时 expirable.LRU 不会回调旧值，先移除以触发 onEvict
+	c.lru.Remove(key)
+	// 键可能引用 fiber 复用的请求缓冲区（如 Authorization 头），须深拷贝
+	c.lru.Add(strings.Clone(key), v)
+	return true
+}
+
+// remove 使指定条目失效
+func (c *ttlCache[V]) remove(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen.Add(1)
+	c.lru.Remove(key)
+}
+
+// removeFunc 使满足条件的条目失效
+func (c *ttlCache[V]) removeFunc(match func(V) bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen.Add(1)
+	for _, key := range c.lru.Keys() {
+		if v, ok := c.lru.Peek(key); ok && match(v) {
+			c.lru.Remove(key)
+		}
+	}
+}
+
+// cachedKeyShare 缓存的已解码服务端私钥分量，淘汰时清零
+// keyID 与 generation 用于命中时核对密钥记录，其他实例重新生成、刷新或归档密钥后不再使用
+type cachedKeyShare struct {
+	keyID      string
+	generation int
+	notBefore  *time.Time
+	notAfter   *time.Time
+
+	mu    sync.RWMutex
+	d2Inv crypto.SecretBytes
+}
+
+// copyD2Inv 返回 d2Inv 副本，条目已被清零时返回 false
+// 调用方持有的是副本，缓存淘汰清零不会影响进行中的运算
+func (k *cachedKeyShare) copyD2Inv() (crypto.SecretBytes, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.d2Inv == nil {
+		return nil, false
+	}
+	return k.d2Inv.Clone(), true
+}
+
+// wipe 清零并释放 d2Inv
+func (k *cachedKeyShare) wipe() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.d2Inv.Wipe()
+	k.d2Inv = nil
+}
+
+var (
+	cacheOnce sync.Once
+	// sessionCache 按 token 缓存会话
+	sessionCache *ttlCache[*model.Session]
+	// keyShareCache 按用户ID缓存已解码的服务端私钥分量
+	keyShareCache *ttlCache[*cachedKeyShare]
+)
+
+// initCaches 按配置创建进程内缓存，各服务实例共享
+func initCaches() {
+	cacheOnce.Do(func() {
+		cfg := config.CacheConfig{
+			SessionSize: defaultSessionCacheSize,
+			SessionTTL:  defaultSessionCacheTTL,
+			KeySize:     defaultKeyCacheSize,
+			KeyTTL:      defaultKeyCacheTTL,
+		}
+		if config.AppConfig != nil {
+			cfg = config.AppConfig.Cache
+		}
+		sessionCache = newTTLCache[*model.Session]("session", cfg.SessionSize, cfg.SessionTTL, nil)
+		keyShareCache = newTTLCache("key_share", cfg.KeySize, cfg.KeyTTL, (*cachedKeyShare).wipe)
+	})
+}
+
+// invalidateUser 使用户的会话与私钥分量缓存失效，用于归档、清除用户与修改用户状态
+func invalidateUser(userID string) {
+	sessionCache.removeFunc(func(s *model.Session) bool { return s.UserID == userID })
+	keyShareCache.remove(userID)
+}
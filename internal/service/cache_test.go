@@ -0,0 +1,164 @@
+package service
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/sm2-cosign/backend/internal/crypto"
+	"github.com/sm2-cosign/backend/internal/keystore"
+	"github.com/sm2-cosign/backend/pkg/response"
+)
+
+func TestTTLCacheGeneration(t *testing.T) {
+	tests := []struct {
+		name       string
+		invalidate func(c *ttlCache[int])
+		wantAdded  bool
+	}{
+		{"unchanged", func(c *ttlCache[int]) {}, true},
+		{"remove", func(c *ttlCache[int]) { c.remove("other") }, false},
+		{"removeFunc", func(c *ttlCache[int]) { c.removeFunc(func(int) bool { return false }) }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTTLCache[int]("test", 10, time.Minute, nil)
+			// 读库前记录版本号，期间发生失效则读到的值不得写入
+			gen := c.generation()
+			tt.invalidate(c)
+			if added := c.add("key", 1, gen); added != tt.wantAdded {
+				t.Fatalf("add: got %v, want %v", added, tt.wantAdded)
+			}
+			if _, ok := c.get("key"); ok != tt.wantAdded {
+				t.Fatalf("get: got %v, want %v", ok, tt.wantAdded)
+			}
+			// 失效后重新读取的值可以写入
+			if !c.add("key", 2, c.generation()) {
+				t.Fatal("add with current generation was refused")
+			}
+			if v, ok := c.get("key"); !ok || v != 2 {
+				t.Fatalf("get after re-read: %d, %v", v, ok)
+			}
+		})
+	}
+}
+
+func TestTTLCacheEvict(t *testing.T) {
+	var evicted []int
+	c := newTTLCache("test", 10, time.Minute, func(v int) { evicted = append(evicted, v) })
+	c.add("a", 1, c.generation())
+	c.add("b", 2, c.generation())
+	c.add("c", 3, c.generation())
+
+	// 覆盖、按键失效与按条件失效均回调被移除的值
+	c.add("a", 4, c.generation())
+	c.remove("b")
+	c.removeFunc(func(v int) bool { return v == 3 })
+	want := []int{1, 2, 3}
+	if len(evicted) != len(want) {
+		t.Fatalf("evicted %v, want %v", evicted, want)
+	}
+	for i := range want {
+		if evicted[i] != want[i] {
+			t.Fatalf("evicted %v, want %v", evicted, want)
+		}
+	}
+	if v, ok := c.get("a"); !ok || v != 4 {
+		t.Fatalf("get a: %d, %v", v, ok)
+	}
+}
+
+func TestTTLCacheDisabled(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		size int
+		ttl  time.Duration
+	}{
+		{"zero size", 0, time.Minute},
+		{"zero ttl", 10, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTTLCache[int]("test", tt.size, tt.ttl, nil)
+			if c != nil {
+				t.Fatal("cache created")
+			}
+			// nil 缓存的各方法均可安全调用
+			if c.add("key", 1, c.generation()) {
+				t.Error("add to disabled cache succeeded")
+			}
+			if _, ok := c.get("key"); ok {
+				t.Error("get from disabled cache hit")
+			}
+			c.remove("key")
+			c.removeFunc(func(int) bool { return true })
+		})
+	}
+}
+
+// TestKeyShareCacheStale 其他实例重新生成、刷新或归档密钥后，本实例缓存的旧分量不再使用
+func TestKeyShareCacheStale(t *testing.T) {
+	s := NewCosignService()
+
+	// openD2Inv 打开用户当前的分量并返回 d2Inv 副本
+	openD2Inv := func(t *testing.T, userID string) (crypto.SecretBytes, response.Code) {
+		t.Helper()
+		share, code := s.openShare(userID, useSign)
+		if code != response.CodeSuccess {
+			return nil, code
+		}
+		defer share.Close()
+		return share.(*keystore.SoftShare).D2Inv().Clone(), code
+	}
+
+	tests := []struct {
+		name     string
+		change   func(t *testing.T, userID string)
+		wantCode response.Code
+	}{
+		{"key init", func(t *testing.T, userID string) { regenerate(t, userID) }, response.CodeSuccess},
+		{"refresh commit", func(t *testing.T, userID string) {
+			refresh, code := s.KeyRefresh(&KeyRefreshRequest{UserID: userID}, "")
+			if code != response.CodeSuccess {
+				t.Fatalf("prepare refresh: code %d", code)
+			}
+			if _, code := s.KeyRefreshCommit(&KeyRefreshCommitRequest{UserID: userID, RefreshID: refresh.RefreshID}, ""); code != response.CodeSuccess {
+				t.Fatalf("commit refresh: code %d", code)
+			}
+		}, response.CodeSuccess},
+		{"archive key", func(t *testing.T, userID string) {
+			if code := NewArchiveService().ArchiveKey(findKey(t, userID).ID, ""); code != response.CodeSuccess {
+				t.Fatalf("archive key: code %d", code)
+			}
+		}, response.CodeKeyNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID, _ := newTestUser(t)
+			old, code := openD2Inv(t, userID)
+			if code != response.CodeSuccess {
+				t.Fatalf("open share: code %d", code)
+			}
+			cached, ok := keyShareCache.get(userID)
+			if !ok {
+				t.Fatal("share not cached")
+			}
+			stale := &cachedKeyShare{keyID: cached.keyID, generation: cached.generation, d2Inv: old.Clone()}
+
+			// 变更在本实例清除了缓存，写回旧条目模拟未收到失效的其他实例
+			tt.change(t, userID)
+			if !keyShareCache.add(userID, stale, keyShareCache.generation()) {
+				t.Fatal("stale entry not added")
+			}
+			current, code := openD2Inv(t, userID)
+			if code != tt.wantCode {
+				t.Fatalf("open share after change: got code %d, want %d", code, tt.wantCode)
+			}
+			if code == response.CodeSuccess && bytes.Equal(current, old) {
+				t.Fatal("stale cached share used")
+			}
+			if entry, ok := keyShareCache.get(userID); ok && entry.keyID == stale.keyID && entry.generation == stale.generation {
+				t.Error("stale entry still cached")
+			}
+		})
+	}
+}
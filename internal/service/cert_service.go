@@ -0,0 +1,427 @@
+package service
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/fs"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sm2-cosign/backend/internal/config"
+	"github.com/sm2-cosign/backend/internal/crypto"
+	"github.com/sm2-cosign/backend/internal/model"
+	"github.com/sm2-cosign/backend/internal/repository"
+	"github.com/sm2-cosign/backend/pkg/response"
+	"github.com/sm2-cosign/backend/pkg/utils"
+)
+
+// 证书签发默认参数
+const (
+	defaultCACommonName   = "SM2 Cosign Root CA"
+	defaultCARootValidity = 20 * 365 * 24 * time.Hour
+	defaultCertValidity   = 365 * 24 * time.Hour
+	defaultCRLValidity    = 24 * time.Hour
+	// 状态响应者证书的最短有效期，剩余有效期不足一个 crl_validity 时重新签发
+	minResponderValidity = 7 * 24 * time.Hour
+)
+
+var (
+	caMu sync.RWMutex
+	// certAuthority 内置 CA，未配置 ca.key_file 时为 nil
+	certAuthority *crypto.CA
+
+	crlMu sync.Mutex
+	// crlCache 已签发的 CRL，在下次更新时间前复用；吊销或暂停状态变化时失效
+	crlCache *signedCRL
+	// crlGen 每次失效递增，生成期间发生失效的 CRL 不写入缓存
+	crlGen uint64
+
+	responderMu sync.Mutex
+	// statusResponder 签名证书状态查询结果的委托密钥，首次查询时由根私钥签发，仅保存在内存中
+	statusResponder *crypto.Responder
+)
+
+// signedCRL 缓存的 CRL
+type signedCRL struct {
+	ca         *crypto.CA
+	der        []byte
+	nextUpdate time.Time
+}
+
+// caPolicy 返回证书签发配置
+func caPolicy() config.CAConfig {
+	policy := config.CAConfig{
+		CommonName:   defaultCACommonName,
+		RootValidity: defaultCARootValidity,
+		CertValidity: defaultCertValidity,
+		CRLValidity:  defaultCRLValidity,
+	}
+	if config.AppConfig != nil {
+		policy = config.AppConfig.CA
+	}
+	return policy
+}
+
+// InitCA 加载 ca.key_file 配置的 CA 文件，文件不存在时生成根密钥与自签名根证书并写入
+// 根私钥以 auth.master_key 派生的密钥加密；未配置 ca.key_file 时关闭证书签发，返回 nil
+func InitCA() (*crypto.CA, error) {
+	policy := caPolicy()
+	var ca *crypto.CA
+	if policy.KeyFile != "" {
+		masterKey, err := crypto.ParseMasterKey(config.AppConfig.Auth.MasterKey)
+		if err != nil {
+			return nil, err
+		}
+		defer clear(masterKey)
+
+		data, err := os.ReadFile(policy.KeyFile)
+		switch {
+		case err == nil:
+			ca, err = crypto.LoadCA(data, masterKey)
+		case errors.Is(err, fs.ErrNotExist):
+			ca, err = createCA(policy, masterKey)
+		}
+		if err != nil {
+			return nil, err
+		}
+		ca.CRLURL = policy.CRLURL
+	}
+
+	caMu.Lock()
+	certAuthority = ca
+	caMu.Unlock()
+	invalidateCRL()
+	responderMu.Lock()
+	statusResponder = nil
+	responderMu.Unlock()
+	return ca, nil
+}
+
+// createCA 生成 CA 并以 0600 权限写入新文件，文件已存在时失败，避免覆盖其他实例生成的根密钥
+func createCA(policy config.CAConfig, masterKey []byte) (*crypto.CA, error) {
+	ca, err := crypto.NewCA(pkix.Name{CommonName: policy.CommonName}, policy.RootValidity)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ca.Marshal(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(policy.KeyFile), 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(policy.KeyFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(policy.KeyFile)
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(policy.KeyFile)
+		return nil, err
+	}
+	log.Printf("Generated CA root certificate %q in %s", policy.CommonName, policy.KeyFile)
+	return ca, nil
+}
+
+// currentCA 返回内置 CA，未启用时返回 nil
+func currentCA() *crypto.CA {
+	caMu.RLock()
+	defer caMu.RUnlock()
+	return certAuthority
+}
+
+// CertService 证书签发服务
+type CertService struct {
+	userRepo repository.UserRepository
+	keyRepo  repository.KeyRepository
+	certRepo repository.CertificateRepository
+	uow      repository.UnitOfWork
+}
+
+// NewCertService 创建证书签发服务实例
+func NewCertService() *CertService {
+	return &CertService{
+		userRepo: repository.NewUserRepository(),
+		keyRepo:  repository.NewKeyRepository(),
+		certRepo: repository.NewCertificateRepository(),
+		uow:      repository.NewUnitOfWork(),
+	}
+}
+
+// CertRequest 证书申请
+// CSR 的签名须由协同签名完成，服务端以当前密钥的协同公钥 Pa 验证，证明申请者持有该密钥
+type CertRequest struct {
+	UserID string `json:"userId" validate:"required"`
+	CSR    string `json:"csr" validate:"required"` // PEM 或 Base64 编码的 DER
+}
+
+// decodeCSR 解码 PEM 或 Base64 编码的证书请求
+func decodeCSR(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "-----BEGIN") {
+		return []byte(s), nil
+	}
+	return crypto.DecodeFromBase64(s)
+}
+
+// Issue 以内置 CA 为用户当前密钥的协同公钥签发证书
+// 证书主题只含服务端决定的字段：CommonName 为用户名，O/OU 取自 ca.organization 与 ca.organizational_unit，
+// CSR 中的主题被忽略；到期时间不晚于密钥的到期时间
+func (s *CertService) Issue(req *CertRequest, ipAddress string) (*model.Certificate, response.Code) {
+	ca := currentCA()
+	if ca == nil {
+		return nil, response.CodeUnavailable
+	}
+
+	data, err := decodeCSR(req.CSR)
+	if err != nil {
+		return nil, response.CodeInvalidParam
+	}
+	_, pub, err := crypto.ParseCSR(data)
+	if err != nil {
+		return nil, response.CodeInvalidCSR
+	}
+
+	user, err := s.userRepo.FindByID(req.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, response.CodeUserNotFound
+	}
+	if err != nil {
+		return nil, response.CodeDBError
+	}
+	key, err := s.keyRepo.FindByUserID(req.UserID)
+	if err != nil {
+		return nil, response.CodeKeyNotFound
+	}
+	if code := checkValidity(key.NotBefore, key.NotAfter, useSign); code != response.CodeSuccess {
+		return nil, code
+	}
+	pa, err := crypto.DecodeFromBase64(key.PublicKey)
+	if err != nil || !bytes.Equal(pa, pub) {
+		return nil, response.CodeInvalidCSR
+	}
+
+	policy := caPolicy()
+	subject := pkix.Name{
+		CommonName:         user.Username,
+		Organization:       policy.Organization,
+		OrganizationalUnit: policy.OrganizationalUnit,
+	}
+	now := time.Now().UTC()
+	notAfter := now.Add(policy.CertValidity)
+	if key.NotAfter != nil && key.NotAfter.Before(notAfter) {
+		notAfter = *key.NotAfter
+	}
+	cert, err := ca.Issue(pa, subject, now, notAfter)
+	if err != nil {
+		log.Printf("Failed to issue certificate for key %s: %v", key.ID, err)
+		return nil, response.CodeCryptoError
+	}
+
+	record := &model.Certificate{
+		ID:           utils.GenerateUUID(),
+		UserID:       req.UserID,
+		KeyID:        key.ID,
+		SerialNumber: cert.SerialNumber.Text(16),
+		Subject:      cert.Subject.String(),
+		NotBefore:    cert.NotBefore,
+		NotAfter:     cert.NotAfter,
+		Certificate:  string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+		CreatedAt:    now,
+	}
+	// 证书记录与审计日志在同一事务中写入，任一失败时不返回证书
+	detail, _ := json.Marshal(map[string]string{"keyId": key.ID, "serialNumber": record.SerialNumber})
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		if err := repos.Certs.Create(record); err != nil {
+			return err
+		}
+		return repos.AuditLogs.Create(&model.AuditLog{
+			ID:        utils.GenerateUUID(),
+			UserID:    req.UserID,
+			Action:    model.ActionCertIssue,
+			Detail:    string(detail),
+			IPAddress: ipAddress,
+		})
+	})
+	if err != nil {
+		return nil, response.CodeDBError
+	}
+	return record, response.CodeSuccess
+}
+
+// UserCertificates 获取用户的全部证书，按签发时间倒序
+func (s *CertService) UserCertificates(userID string) ([]model.Certificate, response.Code) {
+	certs, err := s.certRepo.ListByUserID(userID)
+	if err != nil {
+		return nil, response.CodeDBError
+	}
+	if certs == nil {
+		certs = []model.Certificate{}
+	}
+	return certs, response.CodeSuccess
+}
+
+// CACertResponse 根证书
+type CACertResponse struct {
+	Certificate string `json:"certificate"` // PEM 编码的根证书
+}
+
+// CACertificate 获取内置 CA 的根证书
+func (s *CertService) CACertificate() (*CACertResponse, response.Code) {
+	ca := currentCA()
+	if ca == nil {
+		return nil, response.CodeUnavailable
+	}
+	return &CACertResponse{Certificate: string(ca.CertificatePEM())}, response.CodeSuccess
+}
+
+// invalidateCRL 使缓存的 CRL 失效，须在吊销或暂停证书的事务提交后调用
+func invalidateCRL() {
+	crlMu.Lock()
+	defer crlMu.Unlock()
+	crlGen++
+	crlCache = nil
+}
+
+// CRL 以根私钥签发 CRL (DER)，包含已吊销（含暂停）且尚未到期的证书
+// 签发结果缓存至下次更新时间（ca.crl_validity 之后），证书被吊销或暂停状态变化时重新签发；
+// CRL 编号为签发时间的毫秒数。多实例部署时其他实例上的吊销最长在下次更新时间后出现在本实例的 CRL 中
+func (s *CertService) CRL() ([]byte, response.Code) {
+	ca := currentCA()
+	if ca == nil {
+		return nil, response.CodeUnavailable
+	}
+	now := time.Now().UTC()
+	crlMu.Lock()
+	if cached := crlCache; cached != nil && cached.ca == ca && now.Before(cached.nextUpdate) {
+		crlMu.Unlock()
+		return cached.der, response.CodeSuccess
+	}
+	gen := crlGen
+	crlMu.Unlock()
+
+	revoked, err := s.certRepo.ListRevoked(now)
+	if err != nil {
+		return nil, response.CodeDBError
+	}
+
+	entries := make([]x509.RevocationListEntry, 0, len(revoked))
+	for _, cert := range revoked {
+		serial, ok := new(big.Int).SetString(cert.SerialNumber, 16)
+		if !ok {
+			continue
+		}
+		entries = append(entries, x509.RevocationListEntry{
+			SerialNumber:   serial,
+			RevocationTime: *cert.RevokedAt,
+			ReasonCode:     cert.RevocationReason,
+		})
+	}
+	nextUpdate := now.Add(caPolicy().CRLValidity)
+	crl, err := ca.CreateCRL(entries, big.NewInt(now.UnixMilli()), now, nextUpdate)
+	if err != nil {
+		log.Printf("Failed to create CRL: %v", err)
+		return nil, response.CodeCryptoError
+	}
+
+	crlMu.Lock()
+	if crlGen == gen {
+		crlCache = &signedCRL{ca: ca, der: crl, nextUpdate: nextUpdate}
+	}
+	crlMu.Unlock()
+	return crl, response.CodeSuccess
+}
+
+// currentResponder 返回状态响应者，不存在或剩余有效期不足 validity 时以根私钥重新签发，InitCA 更换 CA 时清空
+// 响应者证书的有效期为 validity 的两倍，且不短于 minResponderValidity
+func currentResponder(ca *crypto.CA, now time.Time, validity time.Duration) (*crypto.Responder, error) {
+	responderMu.Lock()
+	defer responderMu.Unlock()
+	if r := statusResponder; r != nil && !now.Add(validity).After(r.Certificate().NotAfter) {
+		return r, nil
+	}
+	lifetime := 2 * validity
+	if lifetime < minResponderValidity {
+		lifetime = minResponderValidity
+	}
+	r, err := ca.NewResponder(now.Add(-time.Minute), now.Add(lifetime))
+	if err != nil {
+		return nil, err
+	}
+	statusResponder = r
+	return r, nil
+}
+
+// CertStatusResponse 证书状态查询结果，以根证书签发的状态响应者私钥签名
+type CertStatusResponse struct {
+	SerialNumber     string     `json:"serialNumber"`
+	Status           string     `json:"status"` // good / revoked / unknown
+	RevokedAt        *time.Time `json:"revokedAt,omitempty"`
+	RevocationReason int        `json:"revocationReason,omitempty"`
+	ProducedAt       time.Time  `json:"producedAt"`
+	NextUpdate       time.Time  `json:"nextUpdate"`
+	// Signature 响应者私钥对 crypto.CertStatusMessage 的 SM2 签名 (Base64 编码的 DER)
+	Signature string `json:"signature"`
+	// ResponderCertificate PEM 编码的响应者证书，由根证书签发，扩展密钥用途为 OCSPSigning
+	ResponderCertificate string `json:"responderCertificate"`
+}
+
+// CertStatus 查询序列号 (hex) 对应证书的吊销状态，非本 CA 签发的序列号返回 unknown
+func (s *CertService) CertStatus(serialNumber string) (*CertStatusResponse, response.Code) {
+	ca := currentCA()
+	if ca == nil {
+		return nil, response.CodeUnavailable
+	}
+	serial, ok := new(big.Int).SetString(serialNumber, 16)
+	if !ok || serial.Sign() <= 0 {
+		return nil, response.CodeInvalidParam
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	result := &CertStatusResponse{
+		SerialNumber: serial.Text(16),
+		Status:       crypto.CertStatusUnknown,
+		ProducedAt:   now,
+		NextUpdate:   now.Add(caPolicy().CRLValidity),
+	}
+	cert, err := s.certRepo.FindBySerialNumber(result.SerialNumber)
+	switch {
+	case err == nil && cert.IsRevoked():
+		revokedAt := cert.RevokedAt.UTC().Truncate(time.Second)
+		result.Status = crypto.CertStatusRevoked
+		result.RevokedAt = &revokedAt
+		result.RevocationReason = cert.RevocationReason
+	case err == nil:
+		result.Status = crypto.CertStatusGood
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, response.CodeDBError
+	}
+
+	responder, err := currentResponder(ca, now, caPolicy().CRLValidity)
+	if err != nil {
+		log.Printf("Failed to issue status responder certificate: %v", err)
+		return nil, response.CodeCryptoError
+	}
+	sig, err := responder.Sign(crypto.CertStatusMessage(result.SerialNumber, result.Status,
+		result.RevokedAt, result.RevocationReason, result.ProducedAt, result.NextUpdate))
+	if err != nil {
+		return nil, response.CodeCryptoError
+	}
+	result.Signature = crypto.EncodeToBase64(sig)
+	result.ResponderCertificate = string(responder.CertificatePEM())
+	return result, response.CodeSuccess
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	stdcrypto "crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/smx509"
+
+	"github.com/sm2-cosign/backend/internal/config"
+	"github.com/sm2-cosign/backend/internal/crypto"
+	"github.com/sm2-cosign/backend/pkg/client"
+	"github.com/sm2-cosign/backend/pkg/response"
+)
+
+// coopSigner 以协同签名服务签署证书请求
+type coopSigner struct {
+	t      *testing.T
+	userID string
+	ks     *client.KeyShare
+}
+
+func (s *coopSigner) Public() stdcrypto.PublicKey {
+	return s.ks.PublicKey()
+}
+
+func (s *coopSigner) Sign(_ io.Reader, digest []byte, opts stdcrypto.SignerOpts) ([]byte, error) {
+	e := digest
+	if _, ok := opts.(*sm2.SM2SignerOption); ok {
+		var err error
+		if e, err = s.ks.Digest(digest, nil); err != nil {
+			return nil, err
+		}
+	}
+	session, err := s.ks.NewSignSession(e)
+	if err != nil {
+		return nil, err
+	}
+	resp, code := NewCosignService().Sign(&SignRequest{
+		UserID: s.userID,
+		Q1:     crypto.EncodeToBase64(session.Q1()),
+		E:      crypto.EncodeToBase64(e),
+	}, "")
+	if code != response.CodeSuccess {
+		s.t.Fatalf("sign: code %d", code)
+	}
+	var parts [3][]byte
+	for i, v := range []string{resp.R, resp.S2, resp.S3} {
+		if parts[i], err = crypto.DecodeFromBase64(v); err != nil {
+			return nil, err
+		}
+	}
+	return session.FinishASN1(parts[0], parts[1], parts[2])
+}
+
+// initTestCA 在临时目录中生成 CA，测试结束时按原配置重新加载
+func initTestCA(t *testing.T, policy config.CAConfig) {
+	t.Helper()
+	t.Cleanup(func() {
+		if _, err := InitCA(); err != nil {
+			t.Error(err)
+		}
+	})
+	saveConfig(t)
+	config.AppConfig.Auth.MasterKey = "000102030405060708090a0b0c0d0e0f"
+	policy.KeyFile = filepath.Join(t.TempDir(), "ca.json")
+	config.AppConfig.CA = policy
+	if _, err := InitCA(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIssueSubject(t *testing.T) {
+	userID, ks := newTestUser(t)
+	user, code := NewUserService().GetUserInfo(userID)
+	if code != response.CodeSuccess {
+		t.Fatalf("user info: code %d", code)
+	}
+
+	// CSR 中的主题字段均不写入证书，O/OU 只取自配置
+	csr, err := smx509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{
+		CommonName:         "mallory",
+		Organization:       []string{"Forged Org"},
+		OrganizationalUnit: []string{"Forged Unit"},
+		Country:            []string{"XX"},
+		SerialNumber:       "42",
+	}}, &coopSigner{t: t, userID: userID, ks: ks})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &CertRequest{UserID: userID, CSR: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}))}
+
+	tests := []struct {
+		name         string
+		organization []string
+		unit         []string
+	}{
+		{"not configured", nil, nil},
+		{"configured", []string{"Example"}, []string{"Signing"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initTestCA(t, config.CAConfig{
+				CommonName:         "Test Root CA",
+				RootValidity:       time.Hour,
+				CertValidity:       time.Hour,
+				Organization:       tt.organization,
+				OrganizationalUnit: tt.unit,
+			})
+			record, code := NewCertService().Issue(req, "")
+			if code != response.CodeSuccess {
+				t.Fatalf("issue: code %d", code)
+			}
+			block, _ := pem.Decode([]byte(record.Certificate))
+			cert, err := smx509.ParseCertificate(block.Bytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := cert.Subject
+			if got.CommonName != user.Username || !slices.Equal(got.Organization, tt.organization) ||
+				!slices.Equal(got.OrganizationalUnit, tt.unit) || len(got.Country) != 0 || got.SerialNumber != "" {
+				t.Errorf("unexpected subject %s", got)
+			}
+		})
+	}
+}
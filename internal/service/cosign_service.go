@@ -1,7 +1,17 @@
 package service
 
 import (
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/sm2-cosign/backend/internal/config"
 	"github.com/sm2-cosign/backend/internal/crypto"
+	"github.com/sm2-cosign/backend/internal/keystore"
 	"github.com/sm2-cosign/backend/internal/model"
 	"github.com/sm2-cosign/backend/internal/repository"
 	"github.com/sm2-cosign/backend/pkg/response"
@@ -10,26 +20,67 @@ import (
 
 // CosignService 协同签名服务
 type CosignService struct {
-	keyRepo   *repository.KeyRepository
-	auditRepo *repository.AuditLogRepository
+	keyRepo   repository.KeyRepository
+	auditRepo repository.AuditLogRepository
+	uow       repository.UnitOfWork
+	nonceMode crypto.NonceMode
+
+	batchMaxSize int
+	batchWorkers int
 }
 
 // NewCosignService 创建协同签名服务实例
 func NewCosignService() *CosignService {
+	initCaches()
 	return &CosignService{
 		keyRepo:   repository.NewKeyRepository(),
 		auditRepo: repository.NewAuditLogRepository(),
+		uow:       repository.NewUnitOfWork(),
+		nonceMode: nonceMode(),
+
+		batchMaxSize: batchMaxSize(),
+		batchWorkers: batchWorkers(),
+	}
+}
+
+// nonceMode 读取配置的签名随机数生成方式，配置无效时使用默认值（启动时已校验）
+func nonceMode() crypto.NonceMode {
+	if config.AppConfig == nil {
+		return crypto.DefaultNonceMode
+	}
+	mode, err := crypto.ParseNonceMode(config.AppConfig.Cosign.NonceMode)
+	if err != nil {
+		return crypto.DefaultNonceMode
+	}
+	return mode
+}
+
+// batchMaxSize 批量签名单次最多条目数
+func batchMaxSize() int {
+	if config.AppConfig == nil || config.AppConfig.Cosign.BatchMaxSize <= 0 {
+		return 1000
+	}
+	return config.AppConfig.Cosign.BatchMaxSize
+}
+
+// batchWorkers 批量签名并发数，未配置时使用 CPU 核数
+func batchWorkers() int {
+	if config.AppConfig == nil || config.AppConfig.Cosign.BatchWorkers <= 0 {
+		return runtime.NumCPU()
 	}
+	return config.AppConfig.Cosign.BatchWorkers
 }
 
 // KeyInitRequest 密钥初始化请求
 type KeyInitRequest struct {
-	UserID string `json:"userId" validate:"required"`
-	P1     string `json:"p1" validate:"required"`
+	UserID  string `json:"userId" validate:"required"`
+	P1      string `json:"p1" validate:"required"`
+	P1Proof string `json:"p1Proof,omitempty"`
 }
 
 type KeyInitResponse struct {
 	P2        string `json:"p2"`
+	P2Proof   string `json:"p2Proof"`
 	PublicKey string `json:"publicKey"`
 }
 
@@ -41,55 +92,256 @@ func (s *CosignService) KeyInit(req *KeyInitRequest, ipAddress string) (*KeyInit
 		return nil, response.CodeInvalidParam
 	}
 
+	// 校验客户端对 d1 的知识证明
+	proofContext := []byte(req.UserID)
+	if code := verifyProof(p1, req.P1Proof, crypto.ProofLabelP1, proofContext); code != response.CodeSuccess {
+		return nil, code
+	}
+
 	// 生成协同密钥对
-	keyResult, err := crypto.CoopKeyGenInit(p1)
-	if err != nil {
-		return nil, response.CodeCryptoError
+	store, generated, code := generateKey(p1, proofContext)
+	if code != response.CodeSuccess {
+		return nil, code
+	}
+	publicKey := crypto.EncodeToBase64(generated.Pa)
+
+	auditLog := &model.AuditLog{
+		ID:        utils.GenerateUUID(),
+		UserID:    req.UserID,
+		Action:    model.ActionKeyGen,
+		IPAddress: ipAddress,
 	}
 
-	// 更新或创建密钥记录
-	existingKey, _ := s.keyRepo.FindByUserID(req.UserID)
-	if existingKey != nil {
-		existingKey.D2 = crypto.EncodeToBase64(keyResult.D2)
-		existingKey.D2Inv = crypto.EncodeToBase64(keyResult.D2Inv)
-		existingKey.PublicKey = crypto.EncodeToBase64(keyResult.Pa)
-		if err := s.keyRepo.Update(existingKey); err != nil {
-			return nil, response.CodeDBError
+	// 新密钥按 key_policy.validity 重新计算有效期
+	notBefore, notAfter := keyValidity()
+
+	// 已有密钥归档保留（分量不删除，经批准后仍可解密旧数据），新密钥、用户公钥与审计日志在同一事务内写入
+	var stale *model.Key
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		user, err := repos.Users.FindByID(req.UserID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return codeError(response.CodeUserNotFound)
 		}
-	} else {
+		if err != nil {
+			return err
+		}
+		user.PublicKey = publicKey
+		if err := repos.Users.Update(user); err != nil {
+			return err
+		}
+
+		existingKey, err := repos.Keys.FindByUserID(req.UserID)
+		switch {
+		case err == nil:
+			// 旧公钥的证书被新密钥取代
+			if stale, err = archiveKeyTx(repos, existingKey, time.Now().UTC(), model.RevocationSuperseded, ipAddress); err != nil {
+				return err
+			}
+		case !errors.Is(err, sql.ErrNoRows):
+			return err
+		}
+
 		key := &model.Key{
 			ID:        utils.GenerateUUID(),
 			UserID:    req.UserID,
-			D2:        crypto.EncodeToBase64(keyResult.D2),
-			D2Inv:     crypto.EncodeToBase64(keyResult.D2Inv),
-			PublicKey: crypto.EncodeToBase64(keyResult.Pa),
+			D2Inv:     generated.Ref,
+			Store:     store.Name(),
+			PublicKey: publicKey,
 			Status:    model.KeyStatusEnabled,
+			NotBefore: notBefore,
+			NotAfter:  notAfter,
 		}
-		if err := s.keyRepo.Create(key); err != nil {
-			return nil, response.CodeDBError
+		if err := repos.Keys.Create(key); err != nil {
+			return err
 		}
+		return repos.AuditLogs.Create(auditLog)
+	})
+	// 旧私钥分量的缓存与包含旧证书状态的 CRL 立即失效
+	keyShareCache.remove(req.UserID)
+	invalidateCRL()
+	if err != nil {
+		discardShare(store, generated)
+		return nil, txCode(err)
+	}
+	if stale != nil {
+		deleteShare(stale)
+	}
+
+	return &KeyInitResponse{
+		P2:        crypto.EncodeToBase64(generated.P2),
+		P2Proof:   crypto.EncodeToBase64(generated.P2Proof),
+		PublicKey: publicKey,
+	}, response.CodeSuccess
+}
+
+// KeyRefreshRequest 私钥分量刷新请求（准备阶段）
+type KeyRefreshRequest struct {
+	UserID string `json:"userId" validate:"required"`
+}
+
+// KeyRefreshResponse 私钥分量刷新准备结果
+// 客户端令 d1' = d1*Delta 并校验 d1'*P2 - G 等于协同公钥，再以 RefreshID 提交；提交成功前须同时保留 d1 与 d1'
+type KeyRefreshResponse struct {
+	RefreshID string `json:"refreshId"`
+	Delta     string `json:"delta"`
+	P2        string `json:"p2"`
+}
+
+// KeyRefreshCommitRequest 私钥分量刷新提交请求
+type KeyRefreshCommitRequest struct {
+	UserID    string `json:"userId" validate:"required"`
+	RefreshID string `json:"refreshId" validate:"required"`
+}
+
+// KeyRefreshCommitResponse 私钥分量刷新提交结果，协同公钥不变
+type KeyRefreshCommitResponse struct {
+	PublicKey string `json:"publicKey"`
+}
+
+// refreshID 由分量引用计算刷新标识，提交后仍可据此识别重复提交
+func refreshID(ref string) string {
+	return hex.EncodeToString(crypto.SM3Hash([]byte("key-refresh:" + ref))[:16])
+}
+
+// KeyRefresh 私钥分量刷新准备阶段
+// 服务端生成刷新因子 δ 并派生 d2Inv' = d2Inv*δ^(-1) 作为待提交分量，当前分量保持可用直至提交；
+// 再次准备时替换此前未提交的刷新
+func (s *CosignService) KeyRefresh(req *KeyRefreshRequest, ipAddress string) (*KeyRefreshResponse, response.Code) {
+	key, err := s.keyRepo.FindByUserID(req.UserID)
+	if err != nil {
+		return nil, response.CodeKeyNotFound
+	}
+	// 刷新不改变有效期，已过期的密钥应重新生成
+	if code := checkValidity(key.NotBefore, key.NotAfter, useSign); code != response.CodeSuccess {
+		return nil, code
+	}
+	store, err := keystore.Get(key.Store)
+	if err != nil {
+		return nil, response.CodeCryptoError
+	}
+
+	delta, err := crypto.GenerateRefreshDelta()
+	if err != nil {
+		return nil, response.CodeCryptoError
+	}
+	defer delta.Wipe()
+	generated, err := store.Refresh(key, delta)
+	if errors.Is(err, keystore.ErrKeyNotFound) {
+		return nil, response.CodeKeyNotFound
+	}
+	if err != nil {
+		return nil, cryptoCode(err)
 	}
 
-	// 记录审计日志
 	auditLog := &model.AuditLog{
 		ID:        utils.GenerateUUID(),
 		UserID:    req.UserID,
-		Action:    model.ActionKeyGen,
+		Action:    model.ActionKeyRefresh,
+		Detail:    `{"phase":"prepare"}`,
 		IPAddress: ipAddress,
 	}
-	s.auditRepo.Create(auditLog)
 
-	return &KeyInitResponse{
-		P2:        crypto.EncodeToBase64(keyResult.P2),
-		PublicKey: crypto.EncodeToBase64(keyResult.Pa),
+	// 待提交分量与审计日志在同一事务内写入
+	var stale *model.Key
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		current, err := repos.Keys.FindByID(key.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return codeError(response.CodeKeyNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		// 读取后密钥已被重新生成或刷新，派生的分量已过时
+		if current.D2 != key.D2 || current.D2Inv != key.D2Inv || current.Store != key.Store {
+			return codeError(response.CodeRefreshConflict)
+		}
+		if current.PendingD2Inv != "" {
+			stale = pendingKey(current)
+		}
+		current.PendingD2Inv = generated.Ref
+		if err := repos.Keys.Update(current); err != nil {
+			return err
+		}
+		return repos.AuditLogs.Create(auditLog)
+	})
+	if err != nil {
+		discardShare(store, generated)
+		return nil, txCode(err)
+	}
+	if stale != nil {
+		deleteShare(stale)
+	}
+
+	return &KeyRefreshResponse{
+		RefreshID: refreshID(generated.Ref),
+		Delta:     crypto.EncodeToBase64(delta),
+		P2:        crypto.EncodeToBase64(generated.P2),
 	}, response.CodeSuccess
 }
 
+// KeyRefreshCommit 私钥分量刷新提交阶段：以待提交分量替换当前分量并删除旧分量
+// 刷新已提交时重复提交同一刷新标识返回成功，便于客户端在未收到响应时重试
+func (s *CosignService) KeyRefreshCommit(req *KeyRefreshCommitRequest, ipAddress string) (*KeyRefreshCommitResponse, response.Code) {
+	if req.RefreshID == "" {
+		return nil, response.CodeInvalidParam
+	}
+
+	auditLog := &model.AuditLog{
+		ID:        utils.GenerateUUID(),
+		UserID:    req.UserID,
+		Action:    model.ActionKeyRefresh,
+		Detail:    `{"phase":"commit"}`,
+		IPAddress: ipAddress,
+	}
+
+	var replaced *model.Key
+	var publicKey string
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		key, err := repos.Keys.FindByUserID(req.UserID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return codeError(response.CodeKeyNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		publicKey = key.PublicKey
+
+		switch {
+		case key.PendingD2Inv != "" && refreshID(key.PendingD2Inv) == req.RefreshID:
+			old := *key
+			old.PendingD2Inv = ""
+			replaced = &old
+			key.D2, key.D2Inv, key.PendingD2Inv = "", key.PendingD2Inv, ""
+			// 此前导出的备份中的分量随刷新失效，导入时按代次拒绝
+			key.ShareGeneration++
+			if err := repos.Keys.Update(key); err != nil {
+				return err
+			}
+			return repos.AuditLogs.Create(auditLog)
+		case key.D2Inv != "" && refreshID(key.D2Inv) == req.RefreshID:
+			return nil
+		default:
+			return codeError(response.CodeRefreshConflict)
+		}
+	})
+	// 旧私钥分量的缓存立即失效
+	keyShareCache.remove(req.UserID)
+	if err != nil {
+		return nil, txCode(err)
+	}
+	if replaced != nil {
+		deleteShare(replaced)
+	}
+
+	return &KeyRefreshCommitResponse{PublicKey: publicKey}, response.CodeSuccess
+}
+
 // SignRequest 签名请求
 type SignRequest struct {
-	UserID string `json:"userId" validate:"required"`
-	Q1     string `json:"q1" validate:"required"`
-	E      string `json:"e" validate:"required"`
+	UserID  string `json:"userId" validate:"required"`
+	Q1      string `json:"q1" validate:"required"`
+	E       string `json:"e" validate:"required"`
+	Q1Proof string `json:"q1Proof,omitempty"`
 }
 
 type SignResponse struct {
@@ -100,43 +352,55 @@ type SignResponse struct {
 
 // Sign 协同签名
 func (s *CosignService) Sign(req *SignRequest, ipAddress string) (*SignResponse, response.Code) {
-	// 获取密钥
-	key, err := s.keyRepo.FindByUserID(req.UserID)
-	if err != nil {
-		return nil, response.CodeKeyNotFound
+	share, code := s.openShare(req.UserID, useSign)
+	if code != response.CodeSuccess {
+		return nil, code
+	}
+	defer share.Close()
+
+	result, code := s.signOne(req.UserID, share, req.Q1, req.E, req.Q1Proof)
+	if code != response.CodeSuccess {
+		return nil, code
 	}
 
+	// 记录审计日志，写入失败时不返回签名结果
+	auditLog := &model.AuditLog{
+		ID:        utils.GenerateUUID(),
+		UserID:    req.UserID,
+		Action:    model.ActionSign,
+		IPAddress: ipAddress,
+	}
+	if err := s.auditRepo.Create(auditLog); err != nil {
+		return nil, response.CodeDBError
+	}
+
+	return result, response.CodeSuccess
+}
+
+// signOne 校验并执行单次协同签名，不记录审计日志
+func (s *CosignService) signOne(userID string, share keystore.Share, q1B64, eB64, proofB64 string) (*SignResponse, response.Code) {
 	// 解码参数
-	q1, err := crypto.DecodeFromBase64(req.Q1)
+	q1, err := crypto.DecodeFromBase64(q1B64)
 	if err != nil || len(q1) != 64 {
 		return nil, response.CodeInvalidParam
 	}
 
-	e, err := crypto.DecodeFromBase64(req.E)
+	e, err := crypto.DecodeFromBase64(eB64)
 	if err != nil || len(e) != 32 {
 		return nil, response.CodeInvalidParam
 	}
 
-	// 解码 D2Inv
-	d2Inv, err := crypto.DecodeFromBase64(key.D2Inv)
-	if err != nil {
-		return nil, response.CodeCryptoError
+	// 校验客户端对 k1 的知识证明，上下文绑定用户与消息哈希
+	proofContext := append([]byte(userID), e...)
+	if code := verifyProof(q1, proofB64, crypto.ProofLabelQ1, proofContext); code != response.CodeSuccess {
+		return nil, code
 	}
 
 	// 执行协同签名
-	result, err := crypto.CoopSign(d2Inv, q1, e)
+	result, err := share.Sign(q1, e, s.nonceMode)
 	if err != nil {
-		return nil, response.CodeCryptoError
-	}
-
-	// 记录审计日志
-	auditLog := &model.AuditLog{
-		ID:        utils.GenerateUUID(),
-		UserID:    req.UserID,
-		Action:    model.ActionSign,
-		IPAddress: ipAddress,
+		return nil, cryptoCode(err)
 	}
-	s.auditRepo.Create(auditLog)
 
 	return &SignResponse{
 		R:  crypto.EncodeToBase64(result.R),
@@ -145,10 +409,111 @@ func (s *CosignService) Sign(req *SignRequest, ipAddress string) (*SignResponse,
 	}, response.CodeSuccess
 }
 
+// SignBatchItem 批量签名中的单项
+type SignBatchItem struct {
+	Q1      string `json:"q1"`
+	E       string `json:"e"`
+	Q1Proof string `json:"q1Proof,omitempty"`
+}
+
+// SignBatchRequest 批量签名请求
+type SignBatchRequest struct {
+	UserID string          `json:"userId" validate:"required"`
+	Items  []SignBatchItem `json:"items" validate:"required"`
+}
+
+// SignBatchResult 批量签名单项结果，Code 非 0 时签名分量为空
+type SignBatchResult struct {
+	Code    response.Code `json:"code"`
+	Message string        `json:"message,omitempty"`
+	R       string        `json:"r,omitempty"`
+	S2      string        `json:"s2,omitempty"`
+	S3      string        `json:"s3,omitempty"`
+}
+
+// SignBatchResponse 批量签名响应，Items 与请求顺序一致
+type SignBatchResponse struct {
+	Items     []SignBatchResult `json:"items"`
+	Succeeded int               `json:"succeeded"`
+}
+
+// SignBatch 批量协同签名
+// 同一密钥下的多个签名请求由有界工作池并发处理，成功项的审计日志在同一事务内写入
+func (s *CosignService) SignBatch(req *SignBatchRequest, ipAddress string) (*SignBatchResponse, response.Code) {
+	if len(req.Items) == 0 || len(req.Items) > s.batchMaxSize {
+		return nil, response.CodeInvalidParam
+	}
+
+	share, code := s.openShare(req.UserID, useSign)
+	if code != response.CodeSuccess {
+		return nil, code
+	}
+	defer share.Close()
+
+	results := make([]SignBatchResult, len(req.Items))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(s.batchWorkers, len(req.Items)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				item := &req.Items[i]
+				result, code := s.signOne(req.UserID, share, item.Q1, item.E, item.Q1Proof)
+				if code != response.CodeSuccess {
+					results[i] = SignBatchResult{Code: code, Message: response.GetMessage(code)}
+					continue
+				}
+				results[i] = SignBatchResult{R: result.R, S2: result.S2, S3: result.S3}
+			}
+		}()
+	}
+	for i := range req.Items {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	resp := &SignBatchResponse{Items: results}
+	for _, result := range results {
+		if result.Code == response.CodeSuccess {
+			resp.Succeeded++
+		}
+	}
+
+	// 审计日志写入失败时不返回签名结果
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		for i := 0; i < resp.Succeeded; i++ {
+			auditLog := &model.AuditLog{
+				ID:        utils.GenerateUUID(),
+				UserID:    req.UserID,
+				Action:    model.ActionSign,
+				IPAddress: ipAddress,
+			}
+			if err := repos.AuditLogs.Create(auditLog); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, txCode(err)
+	}
+
+	return resp, response.CodeSuccess
+}
+
 // DecryptRequest 解密请求
+// T1 与 Ciphertext 二选一：提交 T1 = d1*C1 时返回 d2Inv*T1；
+// 提交完整密文时服务端仅解析 C1 并返回 d2Inv*C1，C2 不参与运算，由客户端完成 KDF 与 C3 校验
 type DecryptRequest struct {
-	UserID string `json:"userId" validate:"required"`
-	T1     string `json:"t1" validate:"required"`
+	UserID     string `json:"userId" validate:"required"`
+	T1         string `json:"t1,omitempty"`
+	Ciphertext string `json:"ciphertext,omitempty"`
+	// Format 密文格式: c1c3c2 / c1c2c3 / asn1，为空时按首字节识别 c1c3c2 与 asn1
+	Format string `json:"format,omitempty"`
+	// KeyID 指定解密使用的密钥，为空时使用当前密钥；归档密钥须经管理员批准
+	KeyID string `json:"keyId,omitempty"`
 }
 
 type DecryptResponse struct {
@@ -158,39 +523,159 @@ type DecryptResponse struct {
 // Decrypt 协同解密
 func (s *CosignService) Decrypt(req *DecryptRequest, ipAddress string) (*DecryptResponse, response.Code) {
 	// 获取密钥
-	key, err := s.keyRepo.FindByUserID(req.UserID)
-	if err != nil {
-		return nil, response.CodeKeyNotFound
+	share, code := s.openDecryptShare(req.UserID, req.KeyID)
+	if code != response.CodeSuccess {
+		return nil, code
+	}
+	defer share.Close()
+
+	// T1 与完整密文必须且只能提交一个
+	if (req.T1 == "") == (req.Ciphertext == "") {
+		return nil, response.CodeInvalidParam
+	}
+
+	// 执行协同解密
+	var t2 []byte
+	if req.Ciphertext != "" {
+		format, err := crypto.ParseCiphertextFormat(req.Format)
+		if err != nil {
+			return nil, response.CodeInvalidParam
+		}
+		ciphertext, err := crypto.DecodeFromBase64(req.Ciphertext)
+		if err != nil {
+			return nil, response.CodeInvalidParam
+		}
+		ct, err := crypto.ParseCiphertext(ciphertext, format)
+		if err != nil {
+			return nil, cryptoCode(err)
+		}
+		t2, err = share.Decrypt(ct.C1)
+		if err != nil {
+			return nil, cryptoCode(err)
+		}
+	} else {
+		t1, err := crypto.DecodeFromBase64(req.T1)
+		if err != nil || len(t1) != 64 {
+			return nil, response.CodeInvalidParam
+		}
+		t2, err = share.Decrypt(t1)
+		if err != nil {
+			return nil, cryptoCode(err)
+		}
 	}
 
+	// 记录审计日志，写入失败时不返回解密结果
+	auditLog := &model.AuditLog{
+		ID:        utils.GenerateUUID(),
+		UserID:    req.UserID,
+		Action:    model.ActionDecrypt,
+		IPAddress: ipAddress,
+	}
+	if req.KeyID != "" {
+		detail, _ := json.Marshal(map[string]string{"keyId": req.KeyID})
+		auditLog.Detail = string(detail)
+	}
+	if err := s.auditRepo.Create(auditLog); err != nil {
+		return nil, response.CodeDBError
+	}
+
+	return &DecryptResponse{
+		T2: crypto.EncodeToBase64(t2),
+	}, response.CodeSuccess
+}
+
+// KeyExchangeInitRequest 协同密钥交换初始化请求
+type KeyExchangeInitRequest struct {
+	UserID string `json:"userId" validate:"required"`
+	K1     string `json:"k1" validate:"required"`
+}
+
+// KeyExchangeInitResponse 协同密钥交换初始化响应
+type KeyExchangeInitResponse struct {
+	R string `json:"r"`
+}
+
+// KeyExchangeInit 协同密钥交换第一步：由客户端 K1 = k1*G 生成本方临时公钥 R = d2Inv*K1
+// 该运算与协同解密相同，同样记录审计日志，写入失败时不返回结果
+func (s *CosignService) KeyExchangeInit(req *KeyExchangeInitRequest, ipAddress string) (*KeyExchangeInitResponse, response.Code) {
+	// 获取密钥
+	share, code := s.openShare(req.UserID, useSign)
+	if code != response.CodeSuccess {
+		return nil, code
+	}
+	defer share.Close()
+
 	// 解码参数
-	t1, err := crypto.DecodeFromBase64(req.T1)
-	if err != nil || len(t1) != 64 {
+	k1, err := crypto.DecodeFromBase64(req.K1)
+	if err != nil || len(k1) != 64 {
 		return nil, response.CodeInvalidParam
 	}
 
-	// 解码 D2Inv
-	d2Inv, err := crypto.DecodeFromBase64(key.D2Inv)
+	r, err := share.KeyExchangeInit(k1)
 	if err != nil {
-		return nil, response.CodeCryptoError
+		return nil, cryptoCode(err)
 	}
 
-	// 执行协同解密
-	t2, err := crypto.CoopDecrypt(d2Inv, t1)
+	auditLog := &model.AuditLog{
+		ID:        utils.GenerateUUID(),
+		UserID:    req.UserID,
+		Action:    model.ActionKeyExchange,
+		Detail:    `{"phase":"init"}`,
+		IPAddress: ipAddress,
+	}
+	if err := s.auditRepo.Create(auditLog); err != nil {
+		return nil, response.CodeDBError
+	}
+
+	return &KeyExchangeInitResponse{
+		R: crypto.EncodeToBase64(r),
+	}, response.CodeSuccess
+}
+
+// KeyExchangeRequest 协同密钥交换请求
+type KeyExchangeRequest struct {
+	UserID string `json:"userId" validate:"required"`
+	W      string `json:"w" validate:"required"`
+}
+
+// KeyExchangeResponse 协同密钥交换响应
+type KeyExchangeResponse struct {
+	T2 string `json:"t2"`
+}
+
+// KeyExchange 协同密钥交换第二步：计算 T2 = d2Inv*W，共享点由客户端计算
+func (s *CosignService) KeyExchange(req *KeyExchangeRequest, ipAddress string) (*KeyExchangeResponse, response.Code) {
+	// 获取密钥
+	share, code := s.openShare(req.UserID, useSign)
+	if code != response.CodeSuccess {
+		return nil, code
+	}
+	defer share.Close()
+
+	// 解码参数
+	w, err := crypto.DecodeFromBase64(req.W)
+	if err != nil || len(w) != 64 {
+		return nil, response.CodeInvalidParam
+	}
+
+	t2, err := share.KeyExchange(w)
 	if err != nil {
-		return nil, response.CodeCryptoError
+		return nil, cryptoCode(err)
 	}
 
-	// 记录审计日志
+	// 记录审计日志，写入失败时不返回 T2
 	auditLog := &model.AuditLog{
 		ID:        utils.GenerateUUID(),
 		UserID:    req.UserID,
-		Action:    model.ActionDecrypt,
+		Action:    model.ActionKeyExchange,
+		Detail:    `{"phase":"compute"}`,
 		IPAddress: ipAddress,
 	}
-	s.auditRepo.Create(auditLog)
+	if err := s.auditRepo.Create(auditLog); err != nil {
+		return nil, response.CodeDBError
+	}
 
-	return &DecryptResponse{
+	return &KeyExchangeResponse{
 		T2: crypto.EncodeToBase64(t2),
 	}, response.CodeSuccess
 }
@@ -204,12 +689,29 @@ func (s *CosignService) GetKeyByUserID(userID string) (*model.Key, response.Code
 	return key, response.CodeSuccess
 }
 
-// DeleteKey 删除密钥
-func (s *CosignService) DeleteKey(keyID string) response.Code {
-	if err := s.keyRepo.Delete(keyID); err != nil {
-		return response.CodeDBError
+// KeyInfo 用户密钥信息及有效期状态
+type KeyInfo struct {
+	model.Key
+	Expired bool `json:"expired"` // 已过期
+	Rotate  bool `json:"rotate"`  // 当前密钥距到期不足 key_policy.rotate_before 或已过期，客户端应调用 /api/key/init 重新生成
+}
+
+// UserKeys 获取用户的密钥列表（含已归档的密钥），用户尚未生成密钥时返回空列表
+func (s *CosignService) UserKeys(userID string) ([]KeyInfo, response.Code) {
+	keys, err := s.keyRepo.ListByUserID(userID)
+	if err != nil {
+		return nil, response.CodeDBError
+	}
+	now := time.Now()
+	infos := make([]KeyInfo, 0, len(keys))
+	for _, key := range keys {
+		infos = append(infos, KeyInfo{
+			Key:     key,
+			Expired: key.Expired(now),
+			Rotate:  !key.IsArchived() && key.RotateDue(now, keyPolicy().RotateBefore),
+		})
 	}
-	return response.CodeSuccess
+	return infos, response.CodeSuccess
 }
 
 // ListKeys 获取密钥列表
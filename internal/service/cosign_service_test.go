@@ -0,0 +1,87 @@
+package service
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sm2-cosign/backend/internal/crypto"
+	"github.com/sm2-cosign/backend/internal/model"
+	"github.com/sm2-cosign/backend/pkg/response"
+)
+
+func TestKeyRefreshCommit(t *testing.T) {
+	s := NewCosignService()
+	userID, ks := newTestUser(t)
+	old := findKey(t, userID)
+
+	// 再次准备时此前未提交的刷新失效
+	first, code := s.KeyRefresh(&KeyRefreshRequest{UserID: userID}, "")
+	if code != response.CodeSuccess {
+		t.Fatalf("prepare: code %d", code)
+	}
+	refresh, code := s.KeyRefresh(&KeyRefreshRequest{UserID: userID}, "")
+	if code != response.CodeSuccess {
+		t.Fatalf("prepare again: code %d", code)
+	}
+	if _, code := s.KeyRefreshCommit(&KeyRefreshCommitRequest{UserID: userID, RefreshID: first.RefreshID}, ""); code != response.CodeRefreshConflict {
+		t.Fatalf("commit superseded refresh: got code %d, want %d", code, response.CodeRefreshConflict)
+	}
+	if key := findKey(t, userID); key.D2Inv != old.D2Inv || key.PendingD2Inv == "" || key.ShareGeneration != 0 {
+		t.Fatalf("key record before commit: pending %q, generation %d", key.PendingD2Inv, key.ShareGeneration)
+	}
+
+	commit := &KeyRefreshCommitRequest{UserID: userID, RefreshID: refresh.RefreshID}
+	resp, code := s.KeyRefreshCommit(commit, "")
+	if code != response.CodeSuccess {
+		t.Fatalf("commit: code %d", code)
+	}
+	if resp.PublicKey != old.PublicKey {
+		t.Fatal("refresh changed the public key")
+	}
+	// 重复提交同一刷新返回成功，代次不再递增
+	if _, code := s.KeyRefreshCommit(commit, ""); code != response.CodeSuccess {
+		t.Fatalf("retry commit: code %d", code)
+	}
+	key := findKey(t, userID)
+	if key.D2Inv == old.D2Inv || key.PendingD2Inv != "" || key.ShareGeneration != 1 {
+		t.Fatalf("key record after commit: d2_inv unchanged = %v, pending = %q, generation %d",
+			key.D2Inv == old.D2Inv, key.PendingD2Inv, key.ShareGeneration)
+	}
+
+	// 客户端以 Delta 与 P2 更新分量后协同公钥不变
+	delta, err := crypto.DecodeFromBase64(refresh.Delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, err := crypto.DecodeFromBase64(refresh.P2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshed, err := ks.Refresh(delta, p2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(refreshed.PublicKeyBytes(), ks.PublicKeyBytes()) {
+		t.Fatal("refreshed client share does not match the public key")
+	}
+
+	// 两次准备与一次提交各记录一条审计日志
+	if got := auditCount(t, model.ActionKeyRefresh, userID); got != 3 {
+		t.Errorf("key refresh audit entries: got %d, want 3", got)
+	}
+
+	// 未准备的刷新标识与缺少标识的请求被拒绝
+	for _, tt := range []struct {
+		name string
+		req  *KeyRefreshCommitRequest
+		want response.Code
+	}{
+		{"unknown refresh", &KeyRefreshCommitRequest{UserID: userID, RefreshID: first.RefreshID}, response.CodeRefreshConflict},
+		{"missing refresh id", &KeyRefreshCommitRequest{UserID: userID}, response.CodeInvalidParam},
+		{"unknown user", &KeyRefreshCommitRequest{UserID: "no-such-user", RefreshID: refresh.RefreshID}, response.CodeKeyNotFound},
+	} {
+		if _, code := s.KeyRefreshCommit(tt.req, ""); code != tt.want {
+			t.Errorf("%s: got code %d, want %d", tt.name, code, tt.want)
+		}
+	}
+}
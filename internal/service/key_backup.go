@@ -0,0 +1,423 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/emmansun/gmsm/sm2"
+
+	"github.com/sm2-cosign/backend/internal/config"
+	"github.com/sm2-cosign/backend/internal/crypto"
+	"github.com/sm2-cosign/backend/internal/keystore"
+	"github.com/sm2-cosign/backend/internal/model"
+	"github.com/sm2-cosign/backend/internal/repository"
+	"github.com/sm2-cosign/backend/pkg/response"
+	"github.com/sm2-cosign/backend/pkg/utils"
+)
+
+// keyBackupVersion 备份包格式版本
+const keyBackupVersion = 1
+
+// 导入结果状态
+const (
+	KeyImportRestored = "restored" // 新建密钥记录（用户不存在时一并恢复）
+	KeyImportReplaced = "replaced" // 覆盖同一密钥记录的分量 (-force)
+	KeyImportSkipped  = "skipped"
+)
+
+var ErrBackupMismatch = errors.New("backup does not match the recovery key")
+
+// KeyBackup 私钥分量备份包
+// 密钥记录、所属用户与 d2Inv 以恢复公钥封装在 Data 中，其余字段为明文清单，作为附加数据与密文绑定
+type KeyBackup struct {
+	Version     int       `json:"version"`
+	ID          string    `json:"id"`
+	RecoveryKey string    `json:"recoveryKey"` // 恢复公钥标识
+	CreatedAt   time.Time `json:"createdAt"`
+	KeyIDs      []string  `json:"keyIds"`
+	WrappedKey  string    `json:"wrappedKey"`
+	Nonce       string    `json:"nonce"`
+	Data        string    `json:"data"`
+}
+
+// aad 备份包明文清单的规范编码
+func (b *KeyBackup) aad() []byte {
+	return []byte(fmt.Sprintf("sm2-cosign key backup v%d\n%s\n%s\n%s\n%s",
+		b.Version, b.ID, b.RecoveryKey, b.CreatedAt.UTC().Format(time.RFC3339Nano), strings.Join(b.KeyIDs, ",")))
+}
+
+// keyBackupEntry 备份包中的一条密钥记录
+type keyBackupEntry struct {
+	UserID          string     `json:"userId"`
+	Username        string     `json:"username"`
+	PasswordHash    string     `json:"passwordHash"`
+	UserStatus      int        `json:"userStatus"`
+	UserArchived    *time.Time `json:"userArchivedAt,omitempty"`
+	KeyID           string     `json:"keyId"`
+	PublicKey       string     `json:"publicKey"`
+	HMACKey         string     `json:"hmacKey,omitempty"`
+	KeyStatus       int        `json:"keyStatus"`
+	NotBefore       *time.Time `json:"notBefore,omitempty"`
+	NotAfter        *time.Time `json:"notAfter,omitempty"`
+	KeyArchived     *time.Time `json:"keyArchivedAt,omitempty"`
+	D2Inv           string     `json:"d2Inv"`
+	ShareGeneration int        `json:"shareGeneration"` // 导出时的分量代次，密钥此后刷新过则备份中的分量已失效
+}
+
+// BackupService 私钥分量备份与恢复服务
+type BackupService struct {
+	keyRepo  repository.KeyRepository
+	userRepo repository.UserRepository
+	uow      repository.UnitOfWork
+}
+
+// NewBackupService 创建备份服务实例
+func NewBackupService() *BackupService {
+	initCaches()
+	return &BackupService{
+		keyRepo:  repository.NewKeyRepository(),
+		userRepo: repository.NewUserRepository(),
+		uow:      repository.NewUnitOfWork(),
+	}
+}
+
+// LoadRecoveryPublicKey 读取 backup.recovery_key_file 配置的恢复公钥，未配置时返回 nil
+func LoadRecoveryPublicKey() (*ecdsa.PublicKey, error) {
+	if config.AppConfig == nil || config.AppConfig.Backup.RecoveryKeyFile == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(config.AppConfig.Backup.RecoveryKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.ParseRecoveryPublicKey(data)
+}
+
+// KeyExportRequest 私钥分量导出请求
+type KeyExportRequest struct {
+	KeyIDs []string `json:"keyIds" validate:"required"`
+}
+
+// ExportKeys 以恢复公钥封装选定密钥的服务端私钥分量，每个密钥记录一条导出审计日志
+// HSM 后端的分量不能离开提供者，包含此类密钥时整个请求失败
+func (s *BackupService) ExportKeys(req *KeyExportRequest, ipAddress string) (*KeyBackup, response.Code) {
+	pub, err := LoadRecoveryPublicKey()
+	if err != nil {
+		log.Printf("Failed to load recovery public key: %v", err)
+		return nil, response.CodeInternalError
+	}
+	if pub == nil {
+		return nil, response.CodeUnavailable
+	}
+
+	keyIDs := make([]string, 0, len(req.KeyIDs))
+	seen := make(map[string]bool, len(req.KeyIDs))
+	for _, id := range req.KeyIDs {
+		if id == "" {
+			return nil, response.CodeInvalidParam
+		}
+		if !seen[id] {
+			seen[id] = true
+			keyIDs = append(keyIDs, id)
+		}
+	}
+	if len(keyIDs) == 0 {
+		return nil, response.CodeInvalidParam
+	}
+
+	entries := make([]keyBackupEntry, 0, len(keyIDs))
+	userIDs := make([]string, 0, len(keyIDs))
+	for _, id := range keyIDs {
+		entry, code := s.exportEntry(id)
+		if code != response.CodeSuccess {
+			return nil, code
+		}
+		entries = append(entries, *entry)
+		userIDs = append(userIDs, entry.UserID)
+	}
+	plaintext, err := json.Marshal(entries)
+	if err != nil {
+		return nil, response.CodeInternalError
+	}
+	defer clear(plaintext)
+
+	backup := &KeyBackup{
+		Version:     keyBackupVersion,
+		ID:          utils.GenerateUUID(),
+		RecoveryKey: crypto.RecoveryKeyID(pub),
+		CreatedAt:   time.Now().UTC(),
+		KeyIDs:      keyIDs,
+	}
+	env, err := crypto.SealRecovery(pub, plaintext, backup.aad())
+	if err != nil {
+		return nil, response.CodeCryptoError
+	}
+	backup.WrappedKey = crypto.EncodeToBase64(env.WrappedKey)
+	backup.Nonce = crypto.EncodeToBase64(env.Nonce)
+	backup.Data = crypto.EncodeToBase64(env.Data)
+
+	// 审计日志写入失败时不返回备份包
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		for i, id := range keyIDs {
+			if err := repos.AuditLogs.Create(&model.AuditLog{
+				ID:        utils.GenerateUUID(),
+				UserID:    userIDs[i],
+				Action:    model.ActionKeyExport,
+				Detail:    backupDetail(backup.ID, id),
+				IPAddress: ipAddress,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, response.CodeDBError
+	}
+	return backup, response.CodeSuccess
+}
+
+// exportEntry 读取密钥记录、所属用户与 d2Inv
+func (s *BackupService) exportEntry(keyID string) (*keyBackupEntry, response.Code) {
+	key, err := s.keyRepo.FindByID(keyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, response.CodeKeyNotFound
+	}
+	if err != nil {
+		return nil, response.CodeDBError
+	}
+	user, err := s.userRepo.FindByID(key.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, response.CodeUserNotFound
+	}
+	if err != nil {
+		return nil, response.CodeDBError
+	}
+	store, err := keystore.Get(key.Store)
+	if err != nil {
+		log.Printf("Key %s: %v", key.ID, err)
+		return nil, response.CodeCryptoError
+	}
+	d2Inv, err := store.Export(key)
+	switch {
+	case errors.Is(err, keystore.ErrNotExportable):
+		return nil, response.CodeNotExportable
+	case errors.Is(err, keystore.ErrKeyNotFound):
+		return nil, response.CodeKeyNotFound
+	case err != nil:
+		log.Printf("Key %s: failed to export key share: %v", key.ID, err)
+		return nil, response.CodeCryptoError
+	}
+	defer d2Inv.Wipe()
+
+	return &keyBackupEntry{
+		UserID:          user.ID,
+		Username:        user.Username,
+		PasswordHash:    user.PasswordHash,
+		UserStatus:      user.Status,
+		UserArchived:    user.ArchivedAt,
+		KeyID:           key.ID,
+		PublicKey:       key.PublicKey,
+		HMACKey:         key.HMACKey,
+		KeyStatus:       key.Status,
+		NotBefore:       key.NotBefore,
+		NotAfter:        key.NotAfter,
+		KeyArchived:     key.ArchivedAt,
+		D2Inv:           crypto.EncodeToBase64(d2Inv),
+		ShareGeneration: key.ShareGeneration,
+	}, response.CodeSuccess
+}
+
+// KeyImportItem 单个密钥的导入结果
+type KeyImportItem struct {
+	KeyID  string
+	UserID string
+	Status string
+	Reason string // 跳过原因
+}
+
+// ImportKeys 以恢复私钥解封备份包，将分量写入当前存储后端并恢复密钥记录，每个恢复的密钥记录一条导入审计日志
+// 密钥记录已存在时跳过，force 为 true 时覆盖同一密钥记录的分量；用户已换用其他密钥，
+// 或密钥在导出后刷新过分量（备份中的分量代次低于当前记录）时始终跳过
+func (s *BackupService) ImportKeys(backup *KeyBackup, priv *sm2.PrivateKey, force bool) ([]KeyImportItem, error) {
+	if backup.Version != keyBackupVersion {
+		return nil, fmt.Errorf("unsupported backup version %d", backup.Version)
+	}
+	if backup.RecoveryKey != crypto.RecoveryKeyID(&priv.PublicKey) {
+		return nil, fmt.Errorf("%w: backup was sealed for recovery key %s", ErrBackupMismatch, backup.RecoveryKey)
+	}
+	var env crypto.RecoveryEnvelope
+	var err error
+	if env.WrappedKey, err = crypto.DecodeFromBase64(backup.WrappedKey); err != nil {
+		return nil, fmt.Errorf("invalid wrapped key: %w", err)
+	}
+	if env.Nonce, err = crypto.DecodeFromBase64(backup.Nonce); err != nil {
+		return nil, fmt.Errorf("invalid nonce: %w", err)
+	}
+	if env.Data, err = crypto.DecodeFromBase64(backup.Data); err != nil {
+		return nil, fmt.Errorf("invalid data: %w", err)
+	}
+	plaintext, err := crypto.OpenRecovery(priv, &env, backup.aad())
+	if err != nil {
+		return nil, err
+	}
+	defer plaintext.Wipe()
+
+	var entries []keyBackupEntry
+	if err := json.Unmarshal(plaintext, &entries); err != nil {
+		return nil, fmt.Errorf("invalid backup payload: %w", err)
+	}
+	if len(entries) != len(backup.KeyIDs) {
+		return nil, fmt.Errorf("%w: payload holds %d keys, manifest lists %d", ErrBackupMismatch, len(entries), len(backup.KeyIDs))
+	}
+	for i := range entries {
+		if entries[i].KeyID != backup.KeyIDs[i] {
+			return nil, fmt.Errorf("%w: payload key %s not in manifest", ErrBackupMismatch, entries[i].KeyID)
+		}
+	}
+
+	items := make([]KeyImportItem, 0, len(entries))
+	for i := range entries {
+		item, err := s.importEntry(backup.ID, &entries[i], force)
+		if err != nil {
+			return items, fmt.Errorf("key %s: %w", entries[i].KeyID, err)
+		}
+		items = append(items, *item)
+	}
+	return items, nil
+}
+
+// importEntry 恢复一条密钥记录
+func (s *BackupService) importEntry(backupID string, entry *keyBackupEntry, force bool) (*KeyImportItem, error) {
+	item := &KeyImportItem{KeyID: entry.KeyID, UserID: entry.UserID}
+
+	d2Inv, err := crypto.DecodeSecretBase64(entry.D2Inv)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key share: %w", err)
+	}
+	defer d2Inv.Wipe()
+	store := keystore.Primary()
+	ref, err := store.Import(d2Inv)
+	if err != nil {
+		return nil, err
+	}
+	imported := &keystore.Generated{Ref: ref}
+
+	var replaced *model.Key
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		user, err := repos.Users.FindByID(entry.UserID)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			exists, err := repos.Users.ExistsByUsername(entry.Username)
+			if err != nil {
+				return err
+			}
+			if exists {
+				item.Status, item.Reason = KeyImportSkipped, "username taken by another user"
+				return nil
+			}
+			if err := repos.Users.Create(&model.User{
+				ID:           entry.UserID,
+				Username:     entry.Username,
+				PasswordHash: entry.PasswordHash,
+				PublicKey:    entry.PublicKey,
+				Status:       entry.UserStatus,
+				ArchivedAt:   entry.UserArchived,
+			}); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case user.Username != entry.Username:
+			item.Status, item.Reason = KeyImportSkipped, "user id belongs to another username"
+			return nil
+		}
+
+		key, err := repos.Keys.FindByID(entry.KeyID)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			// 用户已有其他未归档密钥时不恢复未归档的旧密钥，归档密钥可与当前密钥并存
+			if entry.KeyStatus != model.KeyStatusArchived {
+				_, err := repos.Keys.FindByUserID(entry.UserID)
+				if err == nil {
+					item.Status, item.Reason = KeyImportSkipped, "user has a newer key"
+					return nil
+				}
+				if !errors.Is(err, sql.ErrNoRows) {
+					return err
+				}
+			}
+			if err := repos.Keys.Create(&model.Key{
+				ID:              entry.KeyID,
+				UserID:          entry.UserID,
+				D2Inv:           ref,
+				Store:           store.Name(),
+				PublicKey:       entry.PublicKey,
+				HMACKey:         entry.HMACKey,
+				Status:          entry.KeyStatus,
+				NotBefore:       entry.NotBefore,
+				NotAfter:        entry.NotAfter,
+				ArchivedAt:      entry.KeyArchived,
+				ShareGeneration: entry.ShareGeneration,
+			}); err != nil {
+				return err
+			}
+			item.Status = KeyImportRestored
+		case err != nil:
+			return err
+		case key.UserID != entry.UserID:
+			item.Status, item.Reason = KeyImportSkipped, "key id belongs to another user"
+			return nil
+		case key.PublicKey != entry.PublicKey:
+			item.Status, item.Reason = KeyImportSkipped, "public key differs from the backup"
+			return nil
+		case entry.ShareGeneration < key.ShareGeneration:
+			// 刷新后客户端分量已随之更新，旧分量无法再与其组合
+			item.Status, item.Reason = KeyImportSkipped, "key share refreshed after the backup"
+			return nil
+		case !force:
+			item.Status, item.Reason = KeyImportSkipped, "key exists"
+			return nil
+		default:
+			old := *key
+			replaced = &old
+			key.D2, key.D2Inv, key.PendingD2Inv, key.Store = "", ref, "", store.Name()
+			key.ShareGeneration = entry.ShareGeneration
+			if err := repos.Keys.Update(key); err != nil {
+				return err
+			}
+			item.Status = KeyImportReplaced
+		}
+
+		return repos.AuditLogs.Create(&model.AuditLog{
+			ID:     utils.GenerateUUID(),
+			UserID: entry.UserID,
+			Action: model.ActionKeyImport,
+			Detail: backupDetail(backupID, entry.KeyID),
+		})
+	})
+	if err != nil || item.Status == KeyImportSkipped {
+		discardShare(store, imported)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if replaced != nil {
+		keyShareCache.remove(entry.UserID)
+		deleteShare(replaced)
+	}
+	return item, nil
+}
+
+// backupDetail 备份审计日志详情
+func backupDetail(backupID, keyID string) string {
+	detail, _ := json.Marshal(map[string]string{"backupId": backupID, "keyId": keyID})
+	return string(detail)
+}
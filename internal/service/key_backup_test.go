@@ -0,0 +1,123 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/smx509"
+
+	"github.com/sm2-cosign/backend/internal/config"
+	"github.com/sm2-cosign/backend/internal/crypto"
+	"github.com/sm2-cosign/backend/internal/model"
+	"github.com/sm2-cosign/backend/pkg/response"
+)
+
+// exportBackup 以临时恢复密钥导出选定密钥，返回备份包与恢复私钥
+func exportBackup(t *testing.T, keyIDs ...string) (*KeyBackup, *sm2.PrivateKey) {
+	t.Helper()
+	priv, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := smx509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "recovery.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	saveConfig(t)
+	config.AppConfig.Backup.RecoveryKeyFile = path
+	backup, code := NewBackupService().ExportKeys(&KeyExportRequest{KeyIDs: keyIDs}, "")
+	if code != response.CodeSuccess {
+		t.Fatalf("export: code %d", code)
+	}
+	return backup, priv
+}
+
+func TestImportKeysManifest(t *testing.T) {
+	userID, _ := newTestUser(t)
+	backup, priv := exportBackup(t, findKey(t, userID).ID)
+	s := NewBackupService()
+
+	other, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ImportKeys(backup, other, false); !errors.Is(err, ErrBackupMismatch) {
+		t.Errorf("import with other key: got %v, want %v", err, ErrBackupMismatch)
+	}
+	tampered := *backup
+	tampered.KeyIDs = []string{"other-key"}
+	if _, err := s.ImportKeys(&tampered, priv, false); !errors.Is(err, crypto.ErrRecoveryDecrypt) {
+		t.Errorf("import with tampered manifest: got %v, want %v", err, crypto.ErrRecoveryDecrypt)
+	}
+	unsupported := *backup
+	unsupported.Version = keyBackupVersion + 1
+	if _, err := s.ImportKeys(&unsupported, priv, false); err == nil {
+		t.Error("import of an unsupported version succeeded")
+	}
+}
+
+func TestImportKeysShareGeneration(t *testing.T) {
+	cosign := NewCosignService()
+	s := NewBackupService()
+	userID, _ := newTestUser(t)
+	key := findKey(t, userID)
+	backup, priv := exportBackup(t, key.ID)
+
+	// importStatus 导入备份并返回唯一条目的状态与原因
+	importStatus := func(t *testing.T, force bool) (string, string) {
+		t.Helper()
+		items, err := s.ImportKeys(backup, priv, force)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != 1 {
+			t.Fatalf("got %d items, want 1", len(items))
+		}
+		return items[0].Status, items[0].Reason
+	}
+
+	// 密钥记录已存在时跳过，-force 覆盖同一代次的分量
+	if status, _ := importStatus(t, false); status != KeyImportSkipped {
+		t.Errorf("import of an existing key: got status %s, want %s", status, KeyImportSkipped)
+	}
+	if status, reason := importStatus(t, true); status != KeyImportReplaced {
+		t.Errorf("forced import: got status %s (%s), want %s", status, reason, KeyImportReplaced)
+	}
+	if got := auditCount(t, model.ActionKeyImport, userID); got != 1 {
+		t.Errorf("key import audit entries: got %d, want 1", got)
+	}
+
+	// 刷新后备份中的旧分量失效，-force 也不覆盖
+	refresh, code := cosign.KeyRefresh(&KeyRefreshRequest{UserID: userID}, "")
+	if code != response.CodeSuccess {
+		t.Fatalf("prepare refresh: code %d", code)
+	}
+	if _, code := cosign.KeyRefreshCommit(&KeyRefreshCommitRequest{UserID: userID, RefreshID: refresh.RefreshID}, ""); code != response.CodeSuccess {
+		t.Fatalf("commit refresh: code %d", code)
+	}
+	refreshed := findKey(t, userID)
+	if status, reason := importStatus(t, true); status != KeyImportSkipped || reason != "key share refreshed after the backup" {
+		t.Errorf("forced import of a refreshed key: got status %s (%s), want %s", status, reason, KeyImportSkipped)
+	}
+	if key := findKey(t, userID); key.D2Inv != refreshed.D2Inv || key.ShareGeneration != 1 {
+		t.Errorf("stale backup changed the refreshed key: generation %d", key.ShareGeneration)
+	}
+
+	// 刷新后重新导出的备份可以覆盖
+	backup, priv = exportBackup(t, key.ID)
+	if status, reason := importStatus(t, true); status != KeyImportReplaced {
+		t.Errorf("forced import of a new backup: got status %s (%s), want %s", status, reason, KeyImportReplaced)
+	}
+	if key := findKey(t, userID); key.ShareGeneration != 1 {
+		t.Errorf("generation after import: got %d, want 1", key.ShareGeneration)
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sm2-cosign/backend/internal/config"
+	"github.com/sm2-cosign/backend/internal/model"
+	"github.com/sm2-cosign/backend/internal/repository"
+	"github.com/sm2-cosign/backend/pkg/response"
+	"github.com/sm2-cosign/backend/pkg/utils"
+)
+
+// 密钥有效期默认参数
+const (
+	defaultRotateBefore = 30 * 24 * time.Hour
+	// expiryCheckBatch 后台任务每批标记的密钥数
+	expiryCheckBatch = 100
+)
+
+// keyUse 私钥分量用途，决定过期后是否仍可使用
+type keyUse int
+
+const (
+	useSign    keyUse = iota // 签名、密钥交换与分量刷新
+	useDecrypt               // 解密，key_policy.decrypt_after_expiry 开启时过期后仍可使用
+)
+
+// keyPolicy 返回密钥有效期策略
+func keyPolicy() config.KeyPolicyConfig {
+	policy := config.KeyPolicyConfig{RotateBefore: defaultRotateBefore}
+	if config.AppConfig != nil {
+		policy = config.AppConfig.KeyPolicy
+	}
+	return policy
+}
+
+// keyValidity 计算新密钥的有效期，key_policy.validity 未配置时不限制
+func keyValidity() (notBefore, notAfter *time.Time) {
+	validity := keyPolicy().Validity
+	if validity <= 0 {
+		return nil, nil
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	end := now.Add(validity)
+	return &now, &end
+}
+
+// checkValidity 检查密钥当前能否用于 use
+func checkValidity(notBefore, notAfter *time.Time, use keyUse) response.Code {
+	key := model.Key{NotBefore: notBefore, NotAfter: notAfter}
+	now := time.Now()
+	if key.NotYetValid(now) {
+		return response.CodeKeyNotYetValid
+	}
+	if key.Expired(now) && !(use == useDecrypt && keyPolicy().DecryptAfterExpiry) {
+		return response.CodeKeyExpired
+	}
+	return response.CodeSuccess
+}
+
+// CountExpiringKeys 统计在 key_policy.rotate_before 内到期（含已过期）的密钥数
+func CountExpiringKeys() (int64, error) {
+	return repository.NewKeyRepository().CountExpiring(time.Now().Add(keyPolicy().RotateBefore))
+}
+
+// KeyExpiryMonitor 后台任务：定期标记即将过期的密钥，记录审计日志提醒轮换
+type KeyExpiryMonitor struct {
+	keyRepo repository.KeyRepository
+	uow     repository.UnitOfWork
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewKeyExpiryMonitor 创建密钥到期检查任务
+func NewKeyExpiryMonitor() *KeyExpiryMonitor {
+	return &KeyExpiryMonitor{
+		keyRepo: repository.NewKeyRepository(),
+		uow:     repository.NewUnitOfWork(),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start 按 key_policy.check_interval 在后台检查，间隔不大于 0 时不启动
+func (m *KeyExpiryMonitor) Start() {
+	interval := keyPolicy().CheckInterval
+	if interval <= 0 {
+		close(m.done)
+		return
+	}
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, err := m.Check(time.Now()); err != nil {
+				log.Printf("Key expiry check failed: %v", err)
+			}
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Close 停止后台任务并等待退出
+func (m *KeyExpiryMonitor) Close() {
+	m.stopOnce.Do(func() { close(m.stop) })
+	<-m.done
+}
+
+// Check 标记在 now + rotate_before 之前到期且尚未标记的密钥，返回本次标记的数量
+// 多实例同时检查时每个密钥只由一个实例标记并记录审计日志
+func (m *KeyExpiryMonitor) Check(now time.Time) (int, error) {
+	deadline := now.Add(keyPolicy().RotateBefore)
+	flagged := 0
+	for {
+		keys, err := m.keyRepo.ListExpiring(deadline, expiryCheckBatch)
+		if err != nil {
+			return flagged, err
+		}
+		for i := range keys {
+			key := &keys[i]
+			marked := false
+			err := m.uow.Do(func(repos *repository.Repositories) error {
+				var err error
+				if marked, err = repos.Keys.MarkExpiryWarned(key.ID, now); err != nil || !marked {
+					return err
+				}
+				detail, _ := json.Marshal(map[string]interface{}{"keyId": key.ID, "notAfter": key.NotAfter})
+				return repos.AuditLogs.Create(&model.AuditLog{
+					ID:     utils.GenerateUUID(),
+					UserID: key.UserID,
+					Action: model.ActionKeyExpiring,
+					Detail: string(detail),
+				})
+			})
+			if err != nil {
+				return flagged, err
+			}
+			if marked {
+				flagged++
+				log.Printf("Key %s of user %s expires at %s, rotation required",
+					key.ID, key.UserID, key.NotAfter.Format(time.RFC3339))
+			}
+		}
+		if len(keys) < expiryCheckBatch {
+			return flagged, nil
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sm2-cosign/backend/internal/config"
+	"github.com/sm2-cosign/backend/internal/model"
+	"github.com/sm2-cosign/backend/internal/repository"
+	"github.com/sm2-cosign/backend/pkg/response"
+)
+
+func TestCheckValidity(t *testing.T) {
+	saveConfig(t)
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+	tests := []struct {
+		name                string
+		notBefore, notAfter *time.Time
+		use                 keyUse
+		decryptAfterExpiry  bool
+		want                response.Code
+	}{
+		{"unlimited", nil, nil, useSign, false, response.CodeSuccess},
+		{"valid", &past, &future, useSign, false, response.CodeSuccess},
+		{"not yet valid", &future, nil, useDecrypt, true, response.CodeKeyNotYetValid},
+		{"expired sign", nil, &past, useSign, true, response.CodeKeyExpired},
+		{"expired decrypt", nil, &past, useDecrypt, false, response.CodeKeyExpired},
+		{"expired decrypt allowed", nil, &past, useDecrypt, true, response.CodeSuccess},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.AppConfig.KeyPolicy.DecryptAfterExpiry = tt.decryptAfterExpiry
+			if got := checkValidity(tt.notBefore, tt.notAfter, tt.use); got != tt.want {
+				t.Errorf("got code %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyValidityPolicy(t *testing.T) {
+	saveConfig(t)
+	config.AppConfig.KeyPolicy.Validity = 0
+	if notBefore, notAfter := keyValidity(); notBefore != nil || notAfter != nil {
+		t.Errorf("unlimited policy: not_before %v, not_after %v", notBefore, notAfter)
+	}
+	config.AppConfig.KeyPolicy.Validity = 30 * 24 * time.Hour
+	notBefore, notAfter := keyValidity()
+	if notBefore == nil || notAfter == nil || notAfter.Sub(*notBefore) != config.AppConfig.KeyPolicy.Validity {
+		t.Errorf("validity: not_before %v, not_after %v", notBefore, notAfter)
+	}
+}
+
+func TestKeyExpiryMonitor(t *testing.T) {
+	saveConfig(t)
+	config.AppConfig.KeyPolicy = config.KeyPolicyConfig{RotateBefore: 24 * time.Hour}
+	keyRepo := repository.NewKeyRepository()
+
+	// setValidity 直接修改密钥记录的有效期
+	setValidity := func(t *testing.T, userID string, notBefore, notAfter *time.Time) {
+		t.Helper()
+		key := findKey(t, userID)
+		key.NotBefore, key.NotAfter = notBefore, notAfter
+		if err := keyRepo.Update(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	past, soon, later := now.Add(-time.Minute), now.Add(time.Hour), now.Add(48*time.Hour)
+
+	expired, _ := newTestUser(t)
+	setValidity(t, expired, nil, &past)
+	expiring, _ := newTestUser(t)
+	setValidity(t, expiring, &past, &soon)
+	valid, _ := newTestUser(t)
+	setValidity(t, valid, &soon, &later)
+	unlimited, _ := newTestUser(t)
+
+	// 每个密钥只标记一次
+	monitor := NewKeyExpiryMonitor()
+	flagged, err := monitor.Check(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flagged != 2 {
+		t.Errorf("first check flagged %d keys, want 2", flagged)
+	}
+	if flagged, err := monitor.Check(now); err != nil || flagged != 0 {
+		t.Errorf("second check: flagged %d, err %v", flagged, err)
+	}
+	for _, tt := range []struct {
+		userID string
+		want   int64
+	}{
+		{expired, 1},
+		{expiring, 1},
+		{valid, 0},
+		{unlimited, 0},
+	} {
+		if n := auditCount(t, model.ActionKeyExpiring, tt.userID); n != tt.want {
+			t.Errorf("user %s: %d key_expiring audit entries, want %d", tt.userID, n, tt.want)
+		}
+		if warned := findKey(t, tt.userID).ExpiryWarnedAt != nil; warned != (tt.want == 1) {
+			t.Errorf("user %s: marked %v", tt.userID, warned)
+		}
+	}
+
+	// 重新生成密钥按 key_policy.validity 设置新的有效期并清除标记
+	config.AppConfig.KeyPolicy.Validity = 30 * 24 * time.Hour
+	regenerate(t, expired)
+	stored := findKey(t, expired)
+	if stored.ExpiryWarnedAt != nil || stored.NotBefore == nil || stored.NotAfter == nil ||
+		stored.NotAfter.Sub(*stored.NotBefore) != config.AppConfig.KeyPolicy.Validity {
+		t.Errorf("rotated key validity: not_before %v, not_after %v, warned %v", stored.NotBefore, stored.NotAfter, stored.ExpiryWarnedAt)
+	}
+	if flagged, err := monitor.Check(now); err != nil || flagged != 0 {
+		t.Errorf("check after rotation: flagged %d, err %v", flagged, err)
+	}
+}
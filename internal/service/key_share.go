@@ -0,0 +1,147 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/sm2-cosign/backend/internal/keystore"
+	"github.com/sm2-cosign/backend/internal/model"
+	"github.com/sm2-cosign/backend/internal/repository"
+	"github.com/sm2-cosign/backend/pkg/response"
+)
+
+// CheckKeyStores 检查密钥记录引用的存储后端均已注册，启动时调用，避免切换后端后已有密钥无法使用
+func CheckKeyStores() error {
+	names, err := repository.NewKeyRepository().ListStores()
+	if err != nil {
+		return err
+	}
+	return keystore.CheckRegistered(names)
+}
+
+// generateKey 由当前存储后端生成服务端私钥分量及 P2 知识证明
+// 分量此时已写入后端，密钥记录未能保存时须调用 discardShare 清理
+func generateKey(p1, p1Context []byte) (keystore.KeyStore, *keystore.Generated, response.Code) {
+	store := keystore.Primary()
+	generated, err := store.Generate(p1, p2ProofContext(p1Context, p1))
+	if err != nil {
+		return nil, nil, cryptoCode(err)
+	}
+	return store, generated, response.CodeSuccess
+}
+
+// openShare 打开用户的服务端私钥分量，优先使用缓存，调用方用完后须调用 Close
+// 只有进程内的分量 (db / file 后端) 会被缓存，HSM 后端每次按标签引用
+// 密钥不在有效期内时按 use 拒绝，缓存命中时同样检查
+// 缓存命中时仍查询当前密钥的ID与分量代次，与缓存不一致（多实例部署时其他实例已重新生成、刷新或归档密钥）则重新读取
+func (s *CosignService) openShare(userID string, use keyUse) (keystore.Share, response.Code) {
+	if cached, ok := keyShareCache.get(userID); ok {
+		keyID, generation, err := s.keyRepo.FindShareGeneration(userID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, response.CodeDBError
+		}
+		if err == nil && keyID == cached.keyID && generation == cached.generation {
+			if code := checkValidity(cached.notBefore, cached.notAfter, use); code != response.CodeSuccess {
+				return nil, code
+			}
+			if d2Inv, ok := cached.copyD2Inv(); ok {
+				return keystore.NewSoftShare(d2Inv), response.CodeSuccess
+			}
+		} else {
+			keyShareCache.remove(userID)
+		}
+	}
+
+	gen := keyShareCache.generation()
+	key, err := s.keyRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, response.CodeKeyNotFound
+	}
+	if code := checkValidity(key.NotBefore, key.NotAfter, use); code != response.CodeSuccess {
+		return nil, code
+	}
+	share, code := openKeyShare(key)
+	if code != response.CodeSuccess {
+		return nil, code
+	}
+	if soft, ok := share.(*keystore.SoftShare); ok {
+		keyShareCache.add(userID, &cachedKeyShare{
+			keyID:      key.ID,
+			generation: key.ShareGeneration,
+			notBefore:  key.NotBefore,
+			notAfter:   key.NotAfter,
+			d2Inv:      soft.D2Inv(),
+		}, gen)
+	}
+	return share, response.CodeSuccess
+}
+
+// openDecryptShare 打开解密使用的私钥分量，keyID 为空或为当前密钥时同 openShare
+// 归档密钥只在管理员批准的期限内可用，不检查有效期，也不缓存
+func (s *CosignService) openDecryptShare(userID, keyID string) (keystore.Share, response.Code) {
+	if keyID == "" {
+		return s.openShare(userID, useDecrypt)
+	}
+	key, err := s.keyRepo.FindByID(keyID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && key.UserID != userID) {
+		return nil, response.CodeKeyNotFound
+	}
+	if err != nil {
+		return nil, response.CodeDBError
+	}
+	if !key.IsArchived() {
+		return s.openShare(userID, useDecrypt)
+	}
+	if !key.DecryptApproved(time.Now()) {
+		return nil, response.CodeKeyArchived
+	}
+	return openKeyShare(key)
+}
+
+// openKeyShare 由密钥记录所在的存储后端打开私钥分量
+func openKeyShare(key *model.Key) (keystore.Share, response.Code) {
+	store, err := keystore.Get(key.Store)
+	if err != nil {
+		log.Printf("Key %s: %v", key.ID, err)
+		return nil, response.CodeCryptoError
+	}
+	share, err := store.Open(key)
+	if errors.Is(err, keystore.ErrKeyNotFound) {
+		return nil, response.CodeKeyNotFound
+	}
+	if err != nil {
+		log.Printf("Key %s: failed to open key share: %v", key.ID, err)
+		return nil, response.CodeCryptoError
+	}
+	return share, response.CodeSuccess
+}
+
+// discardShare 删除未能写入密钥记录的新分量
+func discardShare(store keystore.KeyStore, generated *keystore.Generated) {
+	if err := store.Delete(&model.Key{D2Inv: generated.Ref, Store: store.Name()}); err != nil {
+		log.Printf("Failed to delete unused key share from %q store: %v", store.Name(), err)
+	}
+}
+
+// deleteShare 删除密钥记录引用的私钥分量，失败时只记录日志（密钥记录已删除或替换，分量不再被引用）
+func deleteShare(key *model.Key) {
+	store, err := keystore.Get(key.Store)
+	if err == nil {
+		err = store.Delete(key)
+	}
+	if err == nil && key.PendingD2Inv != "" {
+		err = store.Delete(pendingKey(key))
+	}
+	if err != nil {
+		log.Printf("Key %s: failed to delete key share from %q store: %v", key.ID, key.Store, err)
+	}
+}
+
+// pendingKey 返回以待提交分量为 d2Inv 的密钥记录副本
+func pendingKey(key *model.Key) *model.Key {
+	pending := *key
+	pending.D2, pending.D2Inv, pending.PendingD2Inv = "", key.PendingD2Inv, ""
+	return &pending
+}
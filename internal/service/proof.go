@@ -0,0 +1,44 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/sm2-cosign/backend/internal/config"
+	"github.com/sm2-cosign/backend/internal/crypto"
+	"github.com/sm2-cosign/backend/pkg/response"
+)
+
+// 知识证明上下文：
+//   P1 (注册): 用户名；P1 (密钥初始化): 用户ID；Q1: 用户ID || e
+//   P2: P1 的上下文 || P1，客户端据此确认 P2 是针对本次请求生成的
+
+// proofRequired 是否强制要求客户端提交知识证明
+func proofRequired() bool {
+	return config.AppConfig != nil && config.AppConfig.Cosign.RequireProof
+}
+
+// verifyProof 校验客户端对 point 的知识证明，未提交证明时按配置决定是否拒绝
+func verifyProof(point []byte, proof, label string, context []byte) response.Code {
+	if proof == "" {
+		if proofRequired() {
+			return response.CodeInvalidProof
+		}
+		return response.CodeSuccess
+	}
+	proofBytes, err := crypto.DecodeFromBase64(proof)
+	if err != nil {
+		return response.CodeInvalidParam
+	}
+	if err := crypto.VerifyKnowledge(point, proofBytes, label, context); err != nil {
+		if errors.Is(err, crypto.ErrInvalidPoint) {
+			return response.CodeInvalidParam
+		}
+		return response.CodeInvalidProof
+	}
+	return response.CodeSuccess
+}
+
+// p2ProofContext 服务端 P2 知识证明的上下文：P1 的上下文 || P1
+func p2ProofContext(p1Context, p1 []byte) []byte {
+	return append(append([]byte(nil), p1Context...), p1...)
+}
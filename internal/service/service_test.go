@@ -0,0 +1,129 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sm2-cosign/backend/internal/config"
+	"github.com/sm2-cosign/backend/internal/crypto"
+	"github.com/sm2-cosign/backend/internal/model"
+	"github.com/sm2-cosign/backend/internal/repository"
+	"github.com/sm2-cosign/backend/pkg/client"
+	"github.com/sm2-cosign/backend/pkg/response"
+	"github.com/sm2-cosign/backend/pkg/utils"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+// runTests 使用内存 SQLite 数据库运行服务层测试
+func runTests(m *testing.M) int {
+	config.AppConfig = &config.Config{
+		Database: config.DatabaseConfig{
+			Driver:      repository.DriverSQLite,
+			Path:        repository.MemoryPath,
+			BusyTimeout: 5 * time.Second,
+			JournalMode: "MEMORY",
+			Synchronous: "OFF",
+		},
+		Auth:   config.AuthConfig{TokenExpire: time.Hour},
+		Cosign: config.CosignConfig{NonceMode: string(crypto.DefaultNonceMode)},
+		Cache: config.CacheConfig{
+			SessionSize: 100,
+			SessionTTL:  time.Minute,
+			KeySize:     100,
+			KeyTTL:      time.Minute,
+		},
+	}
+	if err := repository.InitDB(config.AppConfig.Database); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer repository.CloseDB()
+	if _, err := repository.MigrateUp(0); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return m.Run()
+}
+
+// saveConfig 保存当前配置，测试结束时恢复
+func saveConfig(t *testing.T) {
+	t.Helper()
+	saved := *config.AppConfig
+	t.Cleanup(func() { *config.AppConfig = saved })
+}
+
+// newTestUser 注册用户并完成客户端密钥协商，返回用户ID与客户端分量
+func newTestUser(t *testing.T) (string, *client.KeyShare) {
+	t.Helper()
+	ks, err := client.GenerateKeyShare()
+	if err != nil {
+		t.Fatal(err)
+	}
+	username := "u" + utils.GenerateUUID()[:16]
+	resp, code := NewUserService().Register(&RegisterRequest{
+		Username: username,
+		Password: "password-123",
+		P1:       crypto.EncodeToBase64(ks.P1()),
+	}, "")
+	if code != response.CodeSuccess {
+		t.Fatalf("register: code %d", code)
+	}
+	completeKeyShare(t, ks, resp.P2, resp.PublicKey, resp.P2Proof, username)
+	return resp.UserID, ks
+}
+
+// regenerate 重新生成用户的密钥，返回新的客户端分量
+func regenerate(t *testing.T, userID string) *client.KeyShare {
+	t.Helper()
+	ks, err := client.GenerateKeyShare()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, code := NewCosignService().KeyInit(&KeyInitRequest{UserID: userID, P1: crypto.EncodeToBase64(ks.P1())}, "")
+	if code != response.CodeSuccess {
+		t.Fatalf("key init: code %d", code)
+	}
+	completeKeyShare(t, ks, resp.P2, resp.PublicKey, resp.P2Proof, userID)
+	return ks
+}
+
+// completeKeyShare 以服务端返回的 P2、协同公钥与知识证明完成客户端分量
+func completeKeyShare(t *testing.T, ks *client.KeyShare, p2, publicKey, p2Proof, context string) {
+	t.Helper()
+	var parts [3][]byte
+	for i, s := range []string{p2, publicKey, p2Proof} {
+		b, err := crypto.DecodeFromBase64(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[i] = b
+	}
+	if err := ks.Complete(parts[0], parts[1], parts[2], []byte(context)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// findKey 查询用户当前的密钥记录
+func findKey(t *testing.T, userID string) *model.Key {
+	t.Helper()
+	key, err := repository.NewKeyRepository().FindByUserID(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// auditCount 统计用户指定操作的审计日志条数
+func auditCount(t *testing.T, action, userID string) int64 {
+	t.Helper()
+	_, total, err := repository.NewAuditLogRepository().List(1, 1, action, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return total
+}
@@ -1,12 +1,14 @@
 package service
 
 import (
+	"database/sql"
 	"encoding/hex"
 	"errors"
 	"time"
 
 	"github.com/sm2-cosign/backend/internal/config"
 	"github.com/sm2-cosign/backend/internal/crypto"
+	"github.com/sm2-cosign/backend/internal/metrics"
 	"github.com/sm2-cosign/backend/internal/model"
 	"github.com/sm2-cosign/backend/internal/repository"
 	"github.com/sm2-cosign/backend/pkg/response"
@@ -15,19 +17,22 @@ import (
 
 // UserService 用户服务
 type UserService struct {
-	userRepo    *repository.UserRepository
-	keyRepo     *repository.KeyRepository
-	sessionRepo *repository.SessionRepository
-	auditRepo   *repository.AuditLogRepository
+	userRepo    repository.UserRepository
+	keyRepo     repository.KeyRepository
+	sessionRepo repository.SessionRepository
+	auditRepo   repository.AuditLogRepository
+	uow         repository.UnitOfWork
 }
 
 // NewUserService 创建用户服务实例
 func NewUserService() *UserService {
+	initCaches()
 	return &UserService{
 		userRepo:    repository.NewUserRepository(),
 		keyRepo:     repository.NewKeyRepository(),
 		sessionRepo: repository.NewSessionRepository(),
 		auditRepo:   repository.NewAuditLogRepository(),
+		uow:         repository.NewUnitOfWork(),
 	}
 }
 
@@ -36,17 +41,20 @@ type RegisterRequest struct {
 	Username string `json:"username" validate:"required,min=3,max=32"`
 	Password string `json:"password" validate:"required,min=6,max=64"`
 	P1       string `json:"p1" validate:"required"`
+	P1Proof  string `json:"p1Proof,omitempty"`
 }
 
 type RegisterResponse struct {
 	UserID    string `json:"userId"`
 	PublicKey string `json:"publicKey"`
 	P2        string `json:"p2"`
+	P2Proof   string `json:"p2Proof"`
 }
 
 // Register 用户注册
+// 用户、密钥与审计日志在同一事务内写入，任一步失败时全部回滚
 func (s *UserService) Register(req *RegisterRequest, ipAddress string) (*RegisterResponse, response.Code) {
-	// 检查用户名是否存在
+	// 检查用户名是否存在（事务内会再次检查）
 	exists, err := s.userRepo.ExistsByUsername(req.Username)
 	if err != nil {
 		return nil, response.CodeDBError
@@ -61,11 +69,18 @@ func (s *UserService) Register(req *RegisterRequest, ipAddress string) (*Registe
 		return nil, response.CodeInvalidParam
 	}
 
+	// 校验客户端对 d1 的知识证明，注册时用户ID尚未分配，上下文使用用户名
+	proofContext := []byte(req.Username)
+	if code := verifyProof(p1, req.P1Proof, crypto.ProofLabelP1, proofContext); code != response.CodeSuccess {
+		return nil, code
+	}
+
 	// 生成协同密钥对
-	keyResult, err := crypto.CoopKeyGenInit(p1)
-	if err != nil {
-		return nil, response.CodeCryptoError
+	store, generated, code := generateKey(p1, proofContext)
+	if code != response.CodeSuccess {
+		return nil, code
 	}
+	publicKey := crypto.EncodeToBase64(generated.Pa)
 
 	// 生成用户ID
 	userID := utils.GenerateUUID()
@@ -73,36 +88,27 @@ func (s *UserService) Register(req *RegisterRequest, ipAddress string) (*Registe
 	// 生成密码哈希
 	salt, err := utils.GenerateSalt()
 	if err != nil {
+		discardShare(store, generated)
 		return nil, response.CodeInternalError
 	}
 	passwordHash := crypto.SM3HashWithPassword([]byte(req.Password), salt)
 
-	// 创建用户
 	user := &model.User{
 		ID:           userID,
 		Username:     req.Username,
 		PasswordHash: hex.EncodeToString(salt) + hex.EncodeToString(passwordHash),
-		PublicKey:    crypto.EncodeToBase64(keyResult.Pa),
+		PublicKey:    publicKey,
 		Status:       model.UserStatusEnabled,
 	}
-	if err := s.userRepo.Create(user); err != nil {
-		return nil, response.CodeDBError
-	}
-
-	// 创建密钥记录
 	key := &model.Key{
 		ID:        utils.GenerateUUID(),
 		UserID:    userID,
-		D2:        crypto.EncodeToBase64(keyResult.D2),
-		D2Inv:     crypto.EncodeToBase64(keyResult.D2Inv),
-		PublicKey: crypto.EncodeToBase64(keyResult.Pa),
+		D2Inv:     generated.Ref,
+		Store:     store.Name(),
+		PublicKey: publicKey,
 		Status:    model.KeyStatusEnabled,
 	}
-	if err := s.keyRepo.Create(key); err != nil {
-		return nil, response.CodeDBError
-	}
-
-	// 记录审计日志
+	key.NotBefore, key.NotAfter = keyValidity()
 	auditLog := &model.AuditLog{
 		ID:        utils.GenerateUUID(),
 		UserID:    userID,
@@ -110,12 +116,34 @@ func (s *UserService) Register(req *RegisterRequest, ipAddress string) (*Registe
 		Detail:    `{"username":"` + req.Username + `"}`,
 		IPAddress: ipAddress,
 	}
-	s.auditRepo.Create(auditLog)
+
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		// 并发注册同名用户时以事务内的检查为准
+		exists, err := repos.Users.ExistsByUsername(req.Username)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return codeError(response.CodeUserExists)
+		}
+		if err := repos.Users.Create(user); err != nil {
+			return err
+		}
+		if err := repos.Keys.Create(key); err != nil {
+			return err
+		}
+		return repos.AuditLogs.Create(auditLog)
+	})
+	if err != nil {
+		discardShare(store, generated)
+		return nil, txCode(err)
+	}
 
 	return &RegisterResponse{
 		UserID:    userID,
-		PublicKey: crypto.EncodeToBase64(keyResult.Pa),
-		P2:        crypto.EncodeToBase64(keyResult.P2),
+		PublicKey: publicKey,
+		P2:        crypto.EncodeToBase64(generated.P2),
+		P2Proof:   crypto.EncodeToBase64(generated.P2Proof),
 	}, response.CodeSuccess
 }
 
@@ -136,23 +164,27 @@ func (s *UserService) Login(req *LoginRequest, ipAddress string) (*LoginResponse
 	// 查找用户
 	user, err := s.userRepo.FindByUsername(req.Username)
 	if err != nil {
+		metrics.LoginFailures.WithLabelValues("user_not_found").Inc()
 		return nil, response.CodeUserNotFound
 	}
 
 	// 验证密码
 	storedHash := user.PasswordHash
 	if len(storedHash) < 64 {
+		metrics.LoginFailures.WithLabelValues("password_error").Inc()
 		return nil, response.CodePasswordError
 	}
 	salt, _ := hex.DecodeString(storedHash[:32])
 	expectedHash := storedHash[32:]
 	actualHash := hex.EncodeToString(crypto.SM3HashWithPassword([]byte(req.Password), salt))
 	if actualHash != expectedHash {
+		metrics.LoginFailures.WithLabelValues("password_error").Inc()
 		return nil, response.CodePasswordError
 	}
 
 	// 检查用户状态
 	if !user.IsEnabled() {
+		metrics.LoginFailures.WithLabelValues("user_disabled").Inc()
 		return nil, response.CodeUserDisabled
 	}
 
@@ -171,18 +203,22 @@ func (s *UserService) Login(req *LoginRequest, ipAddress string) (*LoginResponse
 		UserID:    user.ID,
 		ExpiresAt: expiresAt,
 	}
-	if err := s.sessionRepo.Create(session); err != nil {
-		return nil, response.CodeDBError
-	}
-
-	// 记录审计日志
 	auditLog := &model.AuditLog{
 		ID:        utils.GenerateUUID(),
 		UserID:    user.ID,
 		Action:    model.ActionLogin,
 		IPAddress: ipAddress,
 	}
-	s.auditRepo.Create(auditLog)
+	// 会话与审计日志在同一事务内写入
+	err = s.uow.Do(func(repos *repository.Repositories) error {
+		if err := repos.Sessions.Create(session); err != nil {
+			return err
+		}
+		return repos.AuditLogs.Create(auditLog)
+	})
+	if err != nil {
+		return nil, txCode(err)
+	}
 
 	return &LoginResponse{
 		Token:     token,
@@ -194,7 +230,9 @@ func (s *UserService) Login(req *LoginRequest, ipAddress string) (*LoginResponse
 // Logout 用户登出
 func (s *UserService) Logout(token string) response.Code {
 	// 删除会话
-	if err := s.sessionRepo.Delete(token); err != nil {
+	err := s.sessionRepo.Delete(token)
+	sessionCache.remove(token)
+	if err != nil {
 		return response.CodeDBError
 	}
 	return response.CodeSuccess
@@ -209,32 +247,67 @@ func (s *UserService) GetUserInfo(userID string) (*model.User, response.Code) {
 	return user, response.CodeSuccess
 }
 
-// ValidateSession 验证会话
+// ValidateSession 验证会话，优先使用缓存
 func (s *UserService) ValidateSession(token string) (*model.Session, response.Code) {
-	session, err := s.sessionRepo.FindByID(token)
-	if err != nil {
-		return nil, response.CodeTokenInvalid
+	session, ok := sessionCache.get(token)
+	if !ok {
+		gen := sessionCache.generation()
+		var err error
+		if session, err = s.sessionRepo.FindByID(token); err != nil {
+			return nil, response.CodeTokenInvalid
+		}
+		sessionCache.add(token, session, gen)
 	}
 	if session.IsExpired() {
 		s.sessionRepo.Delete(token)
+		sessionCache.remove(token)
 		return nil, response.CodeTokenExpired
 	}
 	return session, response.CodeSuccess
 }
 
-// DeleteUser 删除用户
-func (s *UserService) DeleteUser(userID string) response.Code {
-	// 删除用户（级联删除密钥和会话）
-	if err := s.userRepo.Delete(userID); err != nil {
-		return response.CodeDBError
+// IsAdmin 检查用户是否为 admin.username 配置的管理员且处于启用状态
+func (s *UserService) IsAdmin(userID string) bool {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return false
 	}
-	return response.CodeSuccess
+	username, _ := getAdminCredentials()
+	return user.Username == username && user.IsEnabled()
 }
 
-// UpdateUserStatus 更新用户状态
+// UpdateUserStatus 更新用户状态（启用/禁用），已归档的用户须通过恢复接口启用
 func (s *UserService) UpdateUserStatus(userID string, status int) response.Code {
-	if err := s.userRepo.UpdateStatus(userID, status); err != nil {
-		return response.CodeDBError
+	if status != model.UserStatusEnabled && status != model.UserStatusDisabled {
+		return response.CodeInvalidParam
+	}
+	// 禁用时删除用户的会话并暂停其证书，重新启用时解除暂停，用户须重新登录
+	err := s.uow.Do(func(repos *repository.Repositories) error {
+		user, err := repos.Users.FindByID(userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return codeError(response.CodeUserNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		if user.IsArchived() {
+			return codeError(response.CodeInvalidParam)
+		}
+		if err := repos.Users.UpdateStatus(userID, status); err != nil {
+			return err
+		}
+		if status == model.UserStatusDisabled {
+			if err := repos.Sessions.DeleteByUserID(userID); err != nil {
+				return err
+			}
+			return repos.Certs.HoldByUserID(userID, time.Now())
+		}
+		return repos.Certs.ReleaseHoldByUserID(userID)
+	})
+	invalidateUser(userID)
+	invalidateCRL()
+	if err != nil {
+		return txCode(err)
 	}
 	return response.CodeSuccess
 }
@@ -254,3 +327,36 @@ var (
 	ErrInvalidE  = errors.New("invalid E format")
 	ErrInvalidT1 = errors.New("invalid T1 format")
 )
+
+// codeError 携带业务错误码的事务中止原因，事务回滚后按原错误码返回
+type codeError response.Code
+
+func (e codeError) Error() string {
+	return response.GetMessage(response.Code(e))
+}
+
+// txCode 将事务返回的错误转换为错误码，非业务错误统一视为数据库错误
+func txCode(err error) response.Code {
+	var ce codeError
+	if errors.As(err, &ce) {
+		return response.Code(ce)
+	}
+	return response.CodeDBError
+}
+
+// cryptoCode 将协同运算返回的错误转换为错误码，客户端提交的点不合法时视为参数错误
+func cryptoCode(err error) response.Code {
+	switch {
+	case errors.Is(err, crypto.ErrInvalidP1),
+		errors.Is(err, crypto.ErrInvalidQ1),
+		errors.Is(err, crypto.ErrInvalidE),
+		errors.Is(err, crypto.ErrInvalidT1),
+		errors.Is(err, crypto.ErrInvalidCiphertext),
+		errors.Is(err, crypto.ErrInvalidCiphertextFormat),
+		errors.Is(err, crypto.ErrInvalidK1),
+		errors.Is(err, crypto.ErrInvalidW):
+		return response.CodeInvalidParam
+	default:
+		return response.CodeCryptoError
+	}
+}
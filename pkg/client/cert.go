@@ -0,0 +1,219 @@
+package client
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/smx509"
+	cosigncrypto "github.com/sm2-cosign/backend/internal/crypto"
+)
+
+var (
+	ErrInvalidCertificate = errors.New("invalid certificate returned by server")
+	ErrInvalidCRL         = errors.New("invalid CRL returned by server")
+	ErrInvalidCertStatus  = errors.New("invalid certificate status returned by server")
+)
+
+// Signer 以协同签名实现 crypto.Signer，可用于 smx509.CreateCertificateRequest 等需要签名者的接口
+type Signer struct {
+	client *Client
+	ctx    context.Context
+	ks     *KeyShare
+	// UID 签名使用的用户标识，为空时使用默认用户标识
+	UID []byte
+}
+
+// Signer 返回以 ks 协同签名的 crypto.Signer，签名请求使用 ctx
+func (c *Client) Signer(ctx context.Context, ks *KeyShare) *Signer {
+	return &Signer{client: c, ctx: ctx, ks: ks}
+}
+
+// Public 返回协同公钥 Pa
+func (s *Signer) Public() crypto.PublicKey {
+	return s.ks.PublicKey()
+}
+
+// Sign 协同签名，返回 ASN.1 DER 编码的签名
+// opts 为 *sm2.SM2SignerOption 时 digest 为原始消息，按 s.UID 计算摘要；否则 digest 为摘要 e = SM3(ZA || M)
+func (s *Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	e := digest
+	if _, ok := opts.(*sm2.SM2SignerOption); ok {
+		var err error
+		if e, err = s.ks.Digest(digest, s.UID); err != nil {
+			return nil, err
+		}
+	}
+	return s.client.SignDigest(s.ctx, s.ks, e)
+}
+
+// CreateCSR 生成以协同签名签署的 SM2 证书请求，返回 DER 编码
+func (c *Client) CreateCSR(ctx context.Context, ks *KeyShare, subject pkix.Name) ([]byte, error) {
+	return smx509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: subject}, c.Signer(ctx, ks))
+}
+
+// Certificate 内置 CA 签发的证书
+type Certificate struct {
+	ID           string    `json:"id"`
+	KeyID        string    `json:"keyId"`
+	SerialNumber string    `json:"serialNumber"` // 证书序列号 (hex)
+	Subject      string    `json:"subject"`
+	NotBefore    time.Time `json:"notBefore"`
+	NotAfter     time.Time `json:"notAfter"`
+	Certificate  string    `json:"certificate"` // PEM 编码的证书
+	CreatedAt    time.Time `json:"createdAt"`
+	// RevokedAt 吊销时间，未吊销时为 nil
+	RevokedAt        *time.Time `json:"revokedAt,omitempty"`
+	RevocationReason int        `json:"revocationReason,omitempty"` // CRL 吊销原因码
+}
+
+// Parse 解析证书
+func (c *Certificate) Parse() (*smx509.Certificate, error) {
+	return parseCertificatePEM(c.Certificate)
+}
+
+// RequestCertificate 以协同签名签署证书请求并向服务端申请证书
+// 证书主题由服务端决定（CommonName 为用户名，O/OU 取自服务端配置），subject 仅写入请求，不出现在证书中
+func (c *Client) RequestCertificate(ctx context.Context, ks *KeyShare, subject pkix.Name) (*Certificate, error) {
+	csr, err := c.CreateCSR(ctx, ks, subject)
+	if err != nil {
+		return nil, err
+	}
+	var cert Certificate
+	if err := c.post(ctx, "/api/cert", map[string]string{"csr": encode(csr)}, &cert); err != nil {
+		return nil, err
+	}
+	if _, err := cert.Parse(); err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// Certificates 获取当前用户已签发的证书，按签发时间倒序
+func (c *Client) Certificates(ctx context.Context) ([]Certificate, error) {
+	var certs []Certificate
+	if err := c.get(ctx, "/api/cert", &certs); err != nil {
+		return nil, err
+	}
+	return certs, nil
+}
+
+// CACertificate 获取内置 CA 的根证书
+func (c *Client) CACertificate(ctx context.Context) (*smx509.Certificate, error) {
+	var resp struct {
+		Certificate string `json:"certificate"`
+	}
+	if err := c.get(ctx, "/api/cert/ca", &resp); err != nil {
+		return nil, err
+	}
+	return parseCertificatePEM(resp.Certificate)
+}
+
+// CRL 获取内置 CA 的证书吊销列表并以根证书 root 验证其签名
+func (c *Client) CRL(ctx context.Context, root *smx509.Certificate) (*smx509.RevocationList, error) {
+	der, err := c.getRaw(ctx, "/api/cert/crl")
+	if err != nil {
+		return nil, err
+	}
+	crl, err := smx509.ParseRevocationList(der)
+	if err != nil {
+		return nil, ErrInvalidCRL
+	}
+	if err := crl.CheckSignatureFrom(root); err != nil {
+		return nil, ErrInvalidCRL
+	}
+	return crl, nil
+}
+
+// CertStatus 证书状态查询结果
+type CertStatus struct {
+	SerialNumber     string     `json:"serialNumber"`
+	Status           string     `json:"status"` // good / revoked / unknown
+	RevokedAt        *time.Time `json:"revokedAt,omitempty"`
+	RevocationReason int        `json:"revocationReason,omitempty"`
+	ProducedAt       time.Time  `json:"producedAt"`
+	NextUpdate       time.Time  `json:"nextUpdate"`
+	Signature        string     `json:"signature"`
+	// ResponderCertificate PEM 编码的状态响应者证书，由根证书签发
+	ResponderCertificate string `json:"responderCertificate"`
+}
+
+// CertificateStatus 按序列号查询证书状态，校验响应者证书由根证书 root 签发且具有 OCSPSigning 用途后，以其公钥验证结果签名
+func (c *Client) CertificateStatus(ctx context.Context, serial *big.Int, root *smx509.Certificate) (*CertStatus, error) {
+	var status CertStatus
+	if err := c.get(ctx, "/api/cert/status/"+serial.Text(16), &status); err != nil {
+		return nil, err
+	}
+	responder, err := parseCertificatePEM(status.ResponderCertificate)
+	if err != nil || cosigncrypto.VerifyResponder(responder, root, status.ProducedAt) != nil {
+		return nil, ErrInvalidCertStatus
+	}
+	pub, ok := responder.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, ErrInvalidCertStatus
+	}
+	sig, err := decode("signature", status.Signature)
+	if err != nil {
+		return nil, err
+	}
+	msg := cosigncrypto.CertStatusMessage(status.SerialNumber, status.Status, status.RevokedAt,
+		status.RevocationReason, status.ProducedAt, status.NextUpdate)
+	if status.SerialNumber != serial.Text(16) || !sm2.VerifyASN1WithSM2(pub, nil, msg, sig) {
+		return nil, ErrInvalidCertStatus
+	}
+	return &status, nil
+}
+
+// getRaw 获取非 JSON 的响应内容，服务端返回 JSON 时按业务错误处理
+func (c *Client) getRaw(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "application/json" {
+		var result apiResponse
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return nil, fmt.Errorf("cosign: %s %s: HTTP %d: %w", req.Method, path, resp.StatusCode, err)
+		}
+		return nil, &APIError{Code: result.Code, Message: result.Message}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cosign: %s %s: HTTP %d", req.Method, path, resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func parseCertificatePEM(s string) (*smx509.Certificate, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, ErrInvalidCertificate
+	}
+	cert, err := smx509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, ErrInvalidCertificate
+	}
+	return cert, nil
+}
@@ -0,0 +1,510 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIError 服务端返回的业务错误
+type APIError struct {
+	Code    int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("cosign: %d %s", e.Code, e.Message)
+}
+
+// Client 协同签名服务 HTTP 客户端
+// 登录成功后 Token 与用户ID保存在客户端中，后续请求自动携带
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	// Proofs 为 true 时请求附带 P1 / Q1 知识证明
+	Proofs bool
+
+	token  string
+	userID string
+}
+
+// New 创建客户端，baseURL 形如 http://127.0.0.1:9002
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		Proofs:     true,
+	}
+}
+
+// Token 当前访问令牌
+func (c *Client) Token() string {
+	return c.token
+}
+
+// UserID 当前登录用户ID
+func (c *Client) UserID() string {
+	return c.userID
+}
+
+// SetToken 设置已有的访问令牌与用户ID
+func (c *Client) SetToken(token, userID string) {
+	c.token, c.userID = token, userID
+}
+
+type apiResponse struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+func (c *Client) post(ctx context.Context, path string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req, path, out)
+}
+
+func (c *Client) get(ctx context.Context, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	return c.do(req, path, out)
+}
+
+func (c *Client) do(req *http.Request, path string, out interface{}) error {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("cosign: %s %s: HTTP %d: %w", req.Method, path, resp.StatusCode, err)
+	}
+	if result.Code != 0 {
+		return &APIError{Code: result.Code, Message: result.Message}
+	}
+	if out == nil || len(result.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(result.Data, out)
+}
+
+func encode(b []byte) string {
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func decode(field, s string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("cosign: invalid %s: %w", field, err)
+	}
+	return b, nil
+}
+
+type keyGenResponse struct {
+	UserID    string `json:"userId"`
+	PublicKey string `json:"publicKey"`
+	P2        string `json:"p2"`
+	P2Proof   string `json:"p2Proof"`
+}
+
+// completeKeyGen 校验服务端返回的 P2 / Pa 并完成客户端密钥
+func completeKeyGen(ks *KeyShare, resp *keyGenResponse, p1Context []byte) error {
+	p2, err := decode("p2", resp.P2)
+	if err != nil {
+		return err
+	}
+	pa, err := decode("publicKey", resp.PublicKey)
+	if err != nil {
+		return err
+	}
+	p2Proof, err := decode("p2Proof", resp.P2Proof)
+	if err != nil {
+		return err
+	}
+	return ks.Complete(p2, pa, p2Proof, p1Context)
+}
+
+// Register 注册用户并完成协同密钥生成，返回用户ID与客户端密钥分量
+func (c *Client) Register(ctx context.Context, username, password string) (string, *KeyShare, error) {
+	ks, err := GenerateKeyShare()
+	if err != nil {
+		return "", nil, err
+	}
+	req := map[string]string{
+		"username": username,
+		"password": password,
+		"p1":       encode(ks.P1()),
+	}
+	if c.Proofs {
+		proof, err := ks.ProveP1([]byte(username))
+		if err != nil {
+			return "", nil, err
+		}
+		req["p1Proof"] = encode(proof)
+	}
+
+	var resp keyGenResponse
+	if err := c.post(ctx, "/api/register", req, &resp); err != nil {
+		return "", nil, err
+	}
+	if err := completeKeyGen(ks, &resp, []byte(username)); err != nil {
+		return "", nil, err
+	}
+	return resp.UserID, ks, nil
+}
+
+// Login 登录并保存访问令牌
+func (c *Client) Login(ctx context.Context, username, password string) error {
+	var resp struct {
+		Token  string `json:"token"`
+		UserID string `json:"userId"`
+	}
+	req := map[string]string{"username": username, "password": password}
+	if err := c.post(ctx, "/api/login", req, &resp); err != nil {
+		return err
+	}
+	c.SetToken(resp.Token, resp.UserID)
+	return nil
+}
+
+// Logout 登出并清除访问令牌
+func (c *Client) Logout(ctx context.Context) error {
+	err := c.post(ctx, "/api/logout", struct{}{}, nil)
+	c.SetToken("", "")
+	return err
+}
+
+// KeyInfo 服务端记录的密钥信息
+type KeyInfo struct {
+	ID           string     `json:"id"`
+	PublicKey    string     `json:"publicKey"`
+	Status       int        `json:"status"`                 // 1=启用，0=禁用，2=已归档
+	NotBefore    *time.Time `json:"notBefore,omitempty"`    // 生效时间，为空表示不限制
+	NotAfter     *time.Time `json:"notAfter,omitempty"`     // 到期时间，为空表示不限制
+	ArchivedAt   *time.Time `json:"archivedAt,omitempty"`   // 归档时间
+	DecryptUntil *time.Time `json:"decryptUntil,omitempty"` // 管理员批准归档密钥解密的截止时间
+	CreatedAt    time.Time  `json:"createdAt"`
+	Expired      bool       `json:"expired"`
+	// Rotate 为 true 时当前密钥即将或已经过期，应调用 KeyInit 重新生成
+	Rotate bool `json:"rotate"`
+}
+
+// Keys 获取当前用户的密钥（含已归档的密钥），尚未生成密钥时返回空列表
+func (c *Client) Keys(ctx context.Context) ([]KeyInfo, error) {
+	var keys []KeyInfo
+	if err := c.get(ctx, "/api/keys", &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// KeyInit 重新生成协同密钥，返回新的客户端密钥分量
+func (c *Client) KeyInit(ctx context.Context) (*KeyShare, error) {
+	ks, err := GenerateKeyShare()
+	if err != nil {
+		return nil, err
+	}
+	req := map[string]string{"p1": encode(ks.P1())}
+	if c.Proofs {
+		proof, err := ks.ProveP1([]byte(c.userID))
+		if err != nil {
+			return nil, err
+		}
+		req["p1Proof"] = encode(proof)
+	}
+
+	var resp keyGenResponse
+	if err := c.post(ctx, "/api/key/init", req, &resp); err != nil {
+		return nil, err
+	}
+	if err := completeKeyGen(ks, &resp, []byte(c.userID)); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// KeyRefresh 准备阶段返回的待提交刷新
+type KeyRefresh struct {
+	ID    string    // 刷新标识
+	Share *KeyShare // 刷新后的客户端分量，提交成功后替换原分量
+}
+
+// PrepareKeyRefresh 发起私钥分量刷新并派生新的客户端分量，协同公钥不变
+func (c *Client) PrepareKeyRefresh(ctx context.Context, ks *KeyShare) (*KeyRefresh, error) {
+	var resp struct {
+		RefreshID string `json:"refreshId"`
+		Delta     string `json:"delta"`
+		P2        string `json:"p2"`
+	}
+	if err := c.post(ctx, "/api/key/refresh", struct{}{}, &resp); err != nil {
+		return nil, err
+	}
+	delta, err := decode("delta", resp.Delta)
+	if err != nil {
+		return nil, err
+	}
+	p2, err := decode("p2", resp.P2)
+	if err != nil {
+		return nil, err
+	}
+	refreshed, err := ks.Refresh(delta, p2)
+	clear(delta)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyRefresh{ID: resp.RefreshID, Share: refreshed}, nil
+}
+
+// CommitKeyRefresh 提交私钥分量刷新，可安全重试；返回错误且无法确认是否已提交时须保留两个分量并重试
+func (c *Client) CommitKeyRefresh(ctx context.Context, refresh *KeyRefresh) error {
+	return c.post(ctx, "/api/key/refresh/commit", map[string]string{"refreshId": refresh.ID}, nil)
+}
+
+// KeyRefresh 完成一次私钥分量刷新，返回刷新后的客户端分量
+func (c *Client) KeyRefresh(ctx context.Context, ks *KeyShare) (*KeyShare, error) {
+	refresh, err := c.PrepareKeyRefresh(ctx, ks)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.CommitKeyRefresh(ctx, refresh); err != nil {
+		return nil, err
+	}
+	return refresh.Share, nil
+}
+
+// SignDigest 对摘要 e = SM3(ZA || M) 进行协同签名，返回 ASN.1 DER 编码的标准 SM2 签名
+func (c *Client) SignDigest(ctx context.Context, ks *KeyShare, e []byte) ([]byte, error) {
+	session, err := ks.NewSignSession(e)
+	if err != nil {
+		return nil, err
+	}
+	req := map[string]string{
+		"q1": encode(session.Q1()),
+		"e":  encode(e),
+	}
+	if c.Proofs {
+		proof, err := session.ProveQ1(c.userID)
+		if err != nil {
+			return nil, err
+		}
+		req["q1Proof"] = encode(proof)
+	}
+
+	var resp struct {
+		R  string `json:"r"`
+		S2 string `json:"s2"`
+		S3 string `json:"s3"`
+	}
+	if err := c.post(ctx, "/api/sign", req, &resp); err != nil {
+		return nil, err
+	}
+	r, err := decode("r", resp.R)
+	if err != nil {
+		return nil, err
+	}
+	s2, err := decode("s2", resp.S2)
+	if err != nil {
+		return nil, err
+	}
+	s3, err := decode("s3", resp.S3)
+	if err != nil {
+		return nil, err
+	}
+	return session.FinishASN1(r, s2, s3)
+}
+
+// BatchSignature 批量签名中单项的结果
+type BatchSignature struct {
+	// Signature ASN.1 DER 编码的 SM2 签名，失败时为空
+	Signature []byte
+	// Err 服务端拒绝时为 *APIError，签名合成失败时为 ErrInvalidSignature
+	Err error
+}
+
+// SignDigests 批量协同签名多个摘要，结果与 digests 顺序一致
+// 返回的 error 仅表示整个请求失败，单项失败记录在对应的 BatchSignature.Err 中
+func (c *Client) SignDigests(ctx context.Context, ks *KeyShare, digests [][]byte) ([]BatchSignature, error) {
+	sessions := make([]*SignSession, len(digests))
+	items := make([]map[string]string, len(digests))
+	for i, e := range digests {
+		session, err := ks.NewSignSession(e)
+		if err != nil {
+			return nil, err
+		}
+		sessions[i] = session
+		items[i] = map[string]string{
+			"q1": encode(session.Q1()),
+			"e":  encode(e),
+		}
+		if c.Proofs {
+			proof, err := session.ProveQ1(c.userID)
+			if err != nil {
+				return nil, err
+			}
+			items[i]["q1Proof"] = encode(proof)
+		}
+	}
+
+	var resp struct {
+		Items []struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+			R       string `json:"r"`
+			S2      string `json:"s2"`
+			S3      string `json:"s3"`
+		} `json:"items"`
+	}
+	if err := c.post(ctx, "/api/sign/batch", map[string]interface{}{"items": items}, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Items) != len(digests) {
+		return nil, fmt.Errorf("cosign: batch returned %d items, want %d", len(resp.Items), len(digests))
+	}
+
+	out := make([]BatchSignature, len(digests))
+	for i, item := range resp.Items {
+		if item.Code != 0 {
+			out[i].Err = &APIError{Code: item.Code, Message: item.Message}
+			continue
+		}
+		out[i].Signature, out[i].Err = finishBatchItem(sessions[i], item.R, item.S2, item.S3)
+	}
+	return out, nil
+}
+
+func finishBatchItem(session *SignSession, rB64, s2B64, s3B64 string) ([]byte, error) {
+	r, err := decode("r", rB64)
+	if err != nil {
+		return nil, err
+	}
+	s2, err := decode("s2", s2B64)
+	if err != nil {
+		return nil, err
+	}
+	s3, err := decode("s3", s3B64)
+	if err != nil {
+		return nil, err
+	}
+	return session.FinishASN1(r, s2, s3)
+}
+
+// Sign 使用默认用户标识对消息进行协同签名，结果可用 sm2.VerifyASN1WithSM2 验证
+func (c *Client) Sign(ctx context.Context, ks *KeyShare, msg []byte) ([]byte, error) {
+	e, err := ks.Digest(msg, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.SignDigest(ctx, ks, e)
+}
+
+// Decrypt 协同解密 SM2 密文 (C1C3C2 或 ASN.1 编码)，返回明文
+func (c *Client) Decrypt(ctx context.Context, ks *KeyShare, ciphertext []byte) ([]byte, error) {
+	return c.DecryptWithKey(ctx, "", ks, ciphertext)
+}
+
+// DecryptWithKey 以指定密钥协同解密，ks 为该密钥的客户端分量；keyID 为空时使用当前密钥
+// 归档密钥须经管理员批准后在期限内使用
+func (c *Client) DecryptWithKey(ctx context.Context, keyID string, ks *KeyShare, ciphertext []byte) ([]byte, error) {
+	session, err := ks.NewDecryptSession(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		T2 string `json:"t2"`
+	}
+	req := map[string]string{"t1": encode(session.T1())}
+	if keyID != "" {
+		req["keyId"] = keyID
+	}
+	if err := c.post(ctx, "/api/decrypt", req, &resp); err != nil {
+		return nil, err
+	}
+	t2, err := decode("t2", resp.T2)
+	if err != nil {
+		return nil, err
+	}
+	return session.Finish(t2)
+}
+
+// DecryptCiphertext 提交完整密文进行协同解密，服务端仅使用 C1，返回明文
+func (c *Client) DecryptCiphertext(ctx context.Context, ks *KeyShare, ciphertext []byte, format CiphertextFormat) ([]byte, error) {
+	session, err := ks.NewDecryptSessionFormat(ciphertext, format)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		T2 string `json:"t2"`
+	}
+	req := map[string]string{"ciphertext": encode(ciphertext)}
+	if format != CiphertextAuto {
+		req["format"] = string(format)
+	}
+	if err := c.post(ctx, "/api/decrypt", req, &resp); err != nil {
+		return nil, err
+	}
+	t2, err := decode("t2", resp.T2)
+	if err != nil {
+		return nil, err
+	}
+	return session.FinishCiphertext(t2)
+}
+
+// KeyExchangeInit 由服务端生成本方临时公钥，返回的 R 需发送给对端
+func (c *Client) KeyExchangeInit(ctx context.Context, session *KeyExchangeSession) ([]byte, error) {
+	var resp struct {
+		R string `json:"r"`
+	}
+	req := map[string]string{"k1": encode(session.K1())}
+	if err := c.post(ctx, "/api/keyexchange/init", req, &resp); err != nil {
+		return nil, err
+	}
+	r, err := decode("r", resp.R)
+	if err != nil {
+		return nil, err
+	}
+	if err := session.SetEphemeral(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// KeyExchange 收到对端公钥、身份与临时公钥后完成协同计算
+// 之后可通过 session.Key / Confirmation / VerifyConfirmation 获取共享密钥与确认值
+func (c *Client) KeyExchange(ctx context.Context, session *KeyExchangeSession, peerPub *ecdsa.PublicKey, peerUID, peerR []byte) error {
+	w, err := session.Prepare(peerPub, peerUID, peerR)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		T2 string `json:"t2"`
+	}
+	if err := c.post(ctx, "/api/keyexchange/compute", map[string]string{"w": encode(w)}, &resp); err != nil {
+		return err
+	}
+	t2, err := decode("t2", resp.T2)
+	if err != nil {
+		return err
+	}
+	return session.Finish(t2)
+}
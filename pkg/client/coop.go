@@ -0,0 +1,398 @@
+// Package client 协同签名客户端参考实现
+// 包含客户端 (d1 一方) 的全部协同运算，以及调用服务端接口的 HTTP 客户端
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+
+	"filippo.io/bigmod"
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/sm3"
+
+	"github.com/sm2-cosign/backend/internal/crypto"
+)
+
+// DefaultUID SM2 默认用户标识
+var DefaultUID = []byte("1234567812345678")
+
+var (
+	ErrInvalidPoint      = errors.New("client: invalid curve point")
+	ErrInvalidScalar     = errors.New("client: invalid scalar")
+	ErrPublicKeyMismatch = errors.New("client: server public key does not match P2")
+	ErrInvalidSignature  = errors.New("client: combined signature is invalid")
+	ErrDecryption        = errors.New("client: decryption failed")
+)
+
+var (
+	curve        = sm2.P256()
+	orderModulus *bigmod.Modulus
+	orderMinus2  []byte
+)
+
+func init() {
+	n := curve.Params().N
+	m, err := bigmod.NewModulus(n.FillBytes(make([]byte, crypto.ScalarSize)))
+	if err != nil {
+		panic("client: invalid curve order: " + err.Error())
+	}
+	orderModulus = m
+	orderMinus2 = new(big.Int).Sub(n, big.NewInt(2)).FillBytes(make([]byte, crypto.ScalarSize))
+}
+
+// randomScalar 生成 [1, n-1] 内的随机标量
+func randomScalar() (*bigmod.Nat, error) {
+	b := make([]byte, crypto.ScalarSize)
+	for {
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		k, err := bigmod.NewNat().SetBytes(b, orderModulus)
+		if err == nil && k.IsZero() == 0 {
+			return k, nil
+		}
+	}
+}
+
+// parseScalar 解析 32 字节标量，允许为 0 (r/s2/s3 等公开值)
+func parseScalar(b []byte) (*bigmod.Nat, error) {
+	if len(b) != crypto.ScalarSize {
+		return nil, ErrInvalidScalar
+	}
+	k, err := bigmod.NewNat().SetBytes(b, orderModulus)
+	if err != nil {
+		return nil, ErrInvalidScalar
+	}
+	return k, nil
+}
+
+func scalarBytes(k *bigmod.Nat) []byte {
+	return k.Bytes(orderModulus)
+}
+
+func parsePoint(b []byte) (x, y *big.Int, err error) {
+	if len(b) != crypto.PointSize {
+		return nil, nil, ErrInvalidPoint
+	}
+	x = new(big.Int).SetBytes(b[:crypto.ScalarSize])
+	y = new(big.Int).SetBytes(b[crypto.ScalarSize:])
+	if !curve.IsOnCurve(x, y) {
+		return nil, nil, ErrInvalidPoint
+	}
+	return x, y, nil
+}
+
+func pointBytes(x, y *big.Int) []byte {
+	out := make([]byte, crypto.PointSize)
+	x.FillBytes(out[:crypto.ScalarSize])
+	y.FillBytes(out[crypto.ScalarSize:])
+	return out
+}
+
+// KeyShare 客户端私钥分量 d1 及协同公钥
+type KeyShare struct {
+	d1 *bigmod.Nat
+	p1 []byte
+	pa *ecdsa.PublicKey
+}
+
+// GenerateKeyShare 生成客户端私钥分量 d1，P1 = d1*G
+func GenerateKeyShare() (*KeyShare, error) {
+	d1, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+	return newKeyShare(d1), nil
+}
+
+// LoadKeyShare 从保存的 d1 与协同公钥 Pa 恢复密钥分量，pa 为空表示尚未完成密钥生成
+func LoadKeyShare(d1, pa []byte) (*KeyShare, error) {
+	d, err := parseScalar(d1)
+	if err != nil || d.IsZero() == 1 {
+		return nil, ErrInvalidScalar
+	}
+	ks := newKeyShare(d)
+	if len(pa) > 0 {
+		x, y, err := parsePoint(pa)
+		if err != nil {
+			return nil, err
+		}
+		ks.pa = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	}
+	return ks, nil
+}
+
+func newKeyShare(d1 *bigmod.Nat) *KeyShare {
+	x, y := curve.ScalarBaseMult(scalarBytes(d1))
+	return &KeyShare{d1: d1, p1: pointBytes(x, y)}
+}
+
+// D1 返回 32 字节私钥分量，调用方负责安全保存
+func (k *KeyShare) D1() []byte {
+	return scalarBytes(k.d1)
+}
+
+// P1 返回 64 字节公钥分量 P1 = d1*G
+func (k *KeyShare) P1() []byte {
+	return append([]byte(nil), k.p1...)
+}
+
+// PublicKey 返回协同公钥 Pa，密钥生成完成前为 nil
+func (k *KeyShare) PublicKey() *ecdsa.PublicKey {
+	return k.pa
+}
+
+// PublicKeyBytes 返回 64 字节协同公钥 Pa，密钥生成完成前为 nil
+func (k *KeyShare) PublicKeyBytes() []byte {
+	if k.pa == nil {
+		return nil
+	}
+	return pointBytes(k.pa.X, k.pa.Y)
+}
+
+// ProveP1 生成 d1 的知识证明，context 为注册时的用户名或密钥初始化时的用户ID
+func (k *KeyShare) ProveP1(context []byte) ([]byte, error) {
+	return crypto.ProveKnowledge(k.D1(), k.p1, crypto.ProofLabelP1, context)
+}
+
+// Complete 校验服务端返回的 P2 / Pa 并保存协同公钥
+// Pa 必须等于 d1*P2 - G；p2Proof 非空时同时校验服务端对 d2Inv 的知识证明
+func (k *KeyShare) Complete(p2, pa, p2Proof, p1Context []byte) error {
+	p2X, p2Y, err := parsePoint(p2)
+	if err != nil {
+		return err
+	}
+	paX, paY, err := parsePoint(pa)
+	if err != nil {
+		return err
+	}
+	if len(p2Proof) > 0 {
+		context := append(append([]byte(nil), p1Context...), k.p1...)
+		if err := crypto.VerifyKnowledge(p2, p2Proof, crypto.ProofLabelP2, context); err != nil {
+			return err
+		}
+	}
+
+	// d1*P2 - G = (d1*d2Inv - 1)*G
+	x, y := curve.ScalarMult(p2X, p2Y, scalarBytes(k.d1))
+	params := curve.Params()
+	x, y = curve.Add(x, y, params.Gx, new(big.Int).Sub(params.P, params.Gy))
+	if x.Cmp(paX) != 0 || y.Cmp(paY) != 0 {
+		return ErrPublicKeyMismatch
+	}
+	k.pa = &ecdsa.PublicKey{Curve: curve, X: paX, Y: paY}
+	return nil
+}
+
+// Refresh 以服务端返回的刷新因子 δ 派生新的客户端分量 d1' = d1*δ
+// 校验 d1'*P2' - G 仍等于协同公钥后返回新分量，原分量保持不变；服务端确认提交前两者都须保留
+func (k *KeyShare) Refresh(delta, p2 []byte) (*KeyShare, error) {
+	if k.pa == nil {
+		return nil, ErrPublicKeyMismatch
+	}
+	d, err := parseScalar(delta)
+	if err != nil || d.IsZero() == 1 {
+		return nil, ErrInvalidScalar
+	}
+	refreshed := newKeyShare(d.Mul(k.d1, orderModulus))
+	if err := refreshed.Complete(p2, k.PublicKeyBytes(), nil, nil); err != nil {
+		return nil, err
+	}
+	return refreshed, nil
+}
+
+// Digest 计算待签名摘要 e = SM3(ZA || msg)，uid 为空时使用默认用户标识
+func (k *KeyShare) Digest(msg, uid []byte) ([]byte, error) {
+	if k.pa == nil {
+		return nil, ErrPublicKeyMismatch
+	}
+	if len(uid) == 0 {
+		uid = DefaultUID
+	}
+	return sm2.CalculateSM2Hash(k.pa, msg, uid)
+}
+
+// SignSession 单次协同签名的客户端状态，不可复用
+type SignSession struct {
+	key *KeyShare
+	e   []byte
+	k1  *bigmod.Nat
+	q1  []byte
+}
+
+// NewSignSession 为摘要 e 生成随机数 k1，Q1 = k1*G
+func (k *KeyShare) NewSignSession(e []byte) (*SignSession, error) {
+	if len(e) != sm3.Size {
+		return nil, ErrInvalidScalar
+	}
+	k1, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+	x, y := curve.ScalarBaseMult(scalarBytes(k1))
+	return &SignSession{key: k, e: append([]byte(nil), e...), k1: k1, q1: pointBytes(x, y)}, nil
+}
+
+// E 返回待签名摘要
+func (s *SignSession) E() []byte {
+	return s.e
+}
+
+// Q1 返回 64 字节盲化点 Q1
+func (s *SignSession) Q1() []byte {
+	return s.q1
+}
+
+// ProveQ1 生成 k1 的知识证明，上下文为 用户ID || e
+func (s *SignSession) ProveQ1(userID string) ([]byte, error) {
+	context := append([]byte(userID), s.e...)
+	return crypto.ProveKnowledge(scalarBytes(s.k1), s.q1, crypto.ProofLabelQ1, context)
+}
+
+// Finish 由服务端返回的 r, s2, s3 合成标准 SM2 签名 (r, s)
+// s = d1^(-1) * (k1*s2 + s3) - r mod n，合成后使用协同公钥验签
+func (s *SignSession) Finish(r, s2, s3 []byte) (*big.Int, *big.Int, error) {
+	rNat, err := parseScalar(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	s2Nat, err := parseScalar(s2)
+	if err != nil {
+		return nil, nil, err
+	}
+	s3Nat, err := parseScalar(s3)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	d1Inv := bigmod.NewNat().Exp(s.key.d1, orderMinus2, orderModulus)
+	sig := bigmod.NewNat().ExpandFor(orderModulus)
+	sig.Add(s.k1, orderModulus).Mul(s2Nat, orderModulus).Add(s3Nat, orderModulus)
+	sig.Mul(d1Inv, orderModulus).Sub(rNat, orderModulus)
+
+	rInt := new(big.Int).SetBytes(r)
+	sInt := new(big.Int).SetBytes(scalarBytes(sig))
+	if s.key.pa == nil || !sm2.Verify(s.key.pa, s.e, rInt, sInt) {
+		return nil, nil, ErrInvalidSignature
+	}
+	return rInt, sInt, nil
+}
+
+// FinishASN1 同 Finish，返回 ASN.1 DER 编码的签名
+func (s *SignSession) FinishASN1(r, s2, s3 []byte) ([]byte, error) {
+	rInt, sInt, err := s.Finish(r, s2, s3)
+	if err != nil {
+		return nil, err
+	}
+	return encodeSignature(rInt, sInt)
+}
+
+// CiphertextFormat SM2 密文编码格式
+type CiphertextFormat = crypto.CiphertextFormat
+
+// 支持的密文格式，CiphertextAuto 按首字节识别 C1C3C2 与 ASN.1
+const (
+	CiphertextAuto   = crypto.CiphertextAuto
+	CiphertextC1C3C2 = crypto.CiphertextC1C3C2
+	CiphertextC1C2C3 = crypto.CiphertextC1C2C3
+	CiphertextASN1   = crypto.CiphertextASN1
+)
+
+// DecryptSession 单次协同解密的客户端状态
+// 两种方式任选其一：
+//   - 提交 T1() 给服务端，以返回的 d2Inv*T1 调用 Finish
+//   - 提交完整密文给服务端，以返回的 d2Inv*C1 调用 FinishCiphertext
+type DecryptSession struct {
+	key        *KeyShare
+	ciphertext []byte
+	format     CiphertextFormat
+	ct         *crypto.Ciphertext
+}
+
+// NewDecryptSession 解析 C1C3C2 拼接 (C1 为 04 前缀的未压缩点) 或 ASN.1 编码的 SM2 密文
+func (k *KeyShare) NewDecryptSession(ciphertext []byte) (*DecryptSession, error) {
+	return k.NewDecryptSessionFormat(ciphertext, CiphertextAuto)
+}
+
+// NewDecryptSessionFormat 按指定格式解析 SM2 密文
+func (k *KeyShare) NewDecryptSessionFormat(ciphertext []byte, format CiphertextFormat) (*DecryptSession, error) {
+	ct, err := crypto.ParseCiphertext(ciphertext, format)
+	if err != nil {
+		return nil, ErrDecryption
+	}
+	return &DecryptSession{key: k, ciphertext: ciphertext, format: format, ct: ct}, nil
+}
+
+// Ciphertext 返回原始密文与格式，用于提交完整密文
+func (s *DecryptSession) Ciphertext() ([]byte, CiphertextFormat) {
+	return s.ciphertext, s.format
+}
+
+// T1 返回 64 字节 T1 = d1*C1
+func (s *DecryptSession) T1() []byte {
+	c1X, c1Y, _ := parsePoint(s.ct.C1)
+	return pointBytes(curve.ScalarMult(c1X, c1Y, scalarBytes(s.key.d1)))
+}
+
+// Finish 由服务端返回的 T2 = d2Inv*T1 恢复明文
+// (x2, y2) = T2 - C1 = d*C1，t = KDF(x2 || y2, len(C2))，M = C2 xor t，并校验 C3 = SM3(x2 || M || y2)
+func (s *DecryptSession) Finish(t2 []byte) ([]byte, error) {
+	t2X, t2Y, err := parsePoint(t2)
+	if err != nil {
+		return nil, ErrDecryption
+	}
+	return s.recover(t2X, t2Y)
+}
+
+// FinishCiphertext 由提交完整密文时服务端返回的 T2 = d2Inv*C1 恢复明文
+// d1*T2 - C1 = d*C1，其余步骤同 Finish
+func (s *DecryptSession) FinishCiphertext(t2 []byte) ([]byte, error) {
+	t2X, t2Y, err := parsePoint(t2)
+	if err != nil {
+		return nil, ErrDecryption
+	}
+	x, y := curve.ScalarMult(t2X, t2Y, scalarBytes(s.key.d1))
+	return s.recover(x, y)
+}
+
+// recover 由 (x, y) = d1*d2Inv*C1 计算 d*C1 = (x, y) - C1 并完成 KDF 与 C3 校验
+func (s *DecryptSession) recover(x, y *big.Int) ([]byte, error) {
+	c1X, c1Y, _ := parsePoint(s.ct.C1)
+	x2, y2 := curve.Add(x, y, c1X, new(big.Int).Sub(curve.Params().P, c1Y))
+	if x2.Sign() == 0 && y2.Sign() == 0 {
+		return nil, ErrDecryption
+	}
+	shared := pointBytes(x2, y2)
+
+	msg := sm3.Kdf(shared, len(s.ct.C2))
+	if allZero(msg) {
+		return nil, ErrDecryption
+	}
+	subtle.XORBytes(msg, s.ct.C2, msg)
+
+	h := sm3.New()
+	h.Write(shared[:crypto.ScalarSize])
+	h.Write(msg)
+	h.Write(shared[crypto.ScalarSize:])
+	if subtle.ConstantTimeCompare(h.Sum(nil), s.ct.C3) != 1 {
+		return nil, ErrDecryption
+	}
+	return msg, nil
+}
+
+func allZero(b []byte) bool {
+	var acc byte
+	for _, v := range b {
+		acc |= v
+	}
+	return acc == 0
+}
+
+// encodeSignature 将 (r, s) 编码为 ASN.1 DER SEQUENCE { r INTEGER, s INTEGER }
+func encodeSignature(r, s *big.Int) ([]byte, error) {
+	return asn1.Marshal(struct{ R, S *big.Int }{r, s})
+}
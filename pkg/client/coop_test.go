@@ -0,0 +1,245 @@
+package client
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/emmansun/gmsm/sm2"
+
+	"github.com/sm2-cosign/backend/internal/crypto"
+)
+
+// newTestKey 以服务端协同运算完成一次密钥生成，返回客户端密钥分量与服务端 d2Inv
+func newTestKey(t *testing.T) (*KeyShare, []byte) {
+	t.Helper()
+	ks, err := GenerateKeyShare()
+	if err != nil {
+		t.Fatal(err)
+	}
+	context := []byte("alice")
+	proof, err := ks.ProveP1(context)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crypto.VerifyKnowledge(ks.P1(), proof, crypto.ProofLabelP1, context); err != nil {
+		t.Fatalf("server rejected P1 proof: %v", err)
+	}
+
+	keyResult, err := crypto.CoopKeyGenInit(ks.P1())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2Proof, err := crypto.ProveKnowledge(keyResult.D2Inv, keyResult.P2, crypto.ProofLabelP2, append(context, ks.P1()...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Complete(keyResult.P2, keyResult.Pa, p2Proof, context); err != nil {
+		t.Fatal(err)
+	}
+	return ks, keyResult.D2Inv
+}
+
+func TestKeyShareComplete(t *testing.T) {
+	ks, _ := newTestKey(t)
+	if !bytes.Equal(ks.PublicKeyBytes(), pointBytes(ks.PublicKey().X, ks.PublicKey().Y)) {
+		t.Fatal("public key encoding mismatch")
+	}
+
+	// Pa 与 P2 不匹配时拒绝
+	other, err := crypto.CoopKeyGenInit(ks.P1())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh, _ := LoadKeyShare(ks.D1(), nil)
+	if err := fresh.Complete(other.P2, ks.PublicKeyBytes(), nil, nil); !errors.Is(err, ErrPublicKeyMismatch) {
+		t.Errorf("got %v, want %v", err, ErrPublicKeyMismatch)
+	}
+	// P2 证明上下文不匹配时拒绝
+	proof, _ := crypto.ProveKnowledge(other.D2Inv, other.P2, crypto.ProofLabelP2, []byte("mallory"))
+	if err := fresh.Complete(other.P2, other.Pa, proof, []byte("alice")); !errors.Is(err, crypto.ErrInvalidProof) {
+		t.Errorf("got %v, want %v", err, crypto.ErrInvalidProof)
+	}
+}
+
+func TestKeyShareRefresh(t *testing.T) {
+	ks, d2Inv := newTestKey(t)
+	delta, err := crypto.GenerateRefreshDelta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshed, err := crypto.CoopRefresh(d2Inv, delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := ks.Refresh(delta, refreshed.P2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(next.PublicKeyBytes(), ks.PublicKeyBytes()) {
+		t.Fatal("refresh changed the public key")
+	}
+	if bytes.Equal(next.D1(), ks.D1()) {
+		t.Fatal("refresh did not change d1")
+	}
+
+	// 新分量与刷新后的服务端分量配合签名
+	msg := []byte("refreshed share")
+	e, _ := next.Digest(msg, nil)
+	session, _ := next.NewSignSession(e)
+	result, err := crypto.CoopSign(refreshed.D2Inv, session.Q1(), e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := session.FinishASN1(result.R, result.S2, result.S3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sm2.VerifyASN1WithSM2(ks.PublicKey(), nil, msg, sig) {
+		t.Fatal("signature with refreshed shares does not verify")
+	}
+
+	// 新旧分量不能混用
+	session, _ = ks.NewSignSession(e)
+	result, _ = crypto.CoopSign(refreshed.D2Inv, session.Q1(), e)
+	if _, _, err := session.Finish(result.R, result.S2, result.S3); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("old d1 with refreshed d2Inv: got %v, want %v", err, ErrInvalidSignature)
+	}
+
+	// P2' 与 δ 不匹配时拒绝
+	other, _ := crypto.GenerateRefreshDelta()
+	if _, err := ks.Refresh(other, refreshed.P2); !errors.Is(err, ErrPublicKeyMismatch) {
+		t.Errorf("mismatched delta: got %v, want %v", err, ErrPublicKeyMismatch)
+	}
+	if _, err := ks.Refresh(make([]byte, crypto.ScalarSize), refreshed.P2); !errors.Is(err, ErrInvalidScalar) {
+		t.Errorf("zero delta: got %v, want %v", err, ErrInvalidScalar)
+	}
+}
+
+func TestCooperativeSign(t *testing.T) {
+	ks, d2Inv := newTestKey(t)
+	msg := []byte("message digest")
+
+	for i := 0; i < 16; i++ {
+		e, err := ks.Digest(msg, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		session, err := ks.NewSignSession(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proof, err := session.ProveQ1("user-id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := crypto.VerifyKnowledge(session.Q1(), proof, crypto.ProofLabelQ1, append([]byte("user-id"), e...)); err != nil {
+			t.Fatalf("server rejected Q1 proof: %v", err)
+		}
+
+		result, err := crypto.CoopSign(d2Inv, session.Q1(), e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig, err := session.FinishASN1(result.R, result.S2, result.S3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sm2.VerifyASN1WithSM2(ks.PublicKey(), nil, msg, sig) {
+			t.Fatal("standard SM2 verification failed")
+		}
+	}
+
+	// 篡改服务端分量后合成失败
+	e, _ := ks.Digest(msg, nil)
+	session, _ := ks.NewSignSession(e)
+	result, err := crypto.CoopSign(d2Inv, session.Q1(), e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result.S3[31] ^= 1
+	if _, _, err := session.Finish(result.R, result.S2, result.S3); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("got %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestCooperativeDecrypt(t *testing.T) {
+	ks, d2Inv := newTestKey(t)
+	plaintext := []byte("cooperative decryption plaintext")
+
+	plain, err := sm2.Encrypt(rand.Reader, ks.PublicKey(), plaintext, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	asn1, err := sm2.EncryptASN1(rand.Reader, ks.PublicKey(), plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, ciphertext := range map[string][]byte{"c1c3c2": plain, "asn1": asn1} {
+		t.Run(name, func(t *testing.T) {
+			session, err := ks.NewDecryptSession(ciphertext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t2, err := crypto.CoopDecrypt(d2Inv, session.T1())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := session.Finish(t2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Fatalf("plaintext = %q, want %q", got, plaintext)
+			}
+		})
+	}
+
+	// 提交完整密文：服务端计算 T2 = d2Inv*C1
+	c1c2c3, err := sm2.Encrypt(rand.Reader, ks.PublicKey(), plaintext, sm2.NewPlainEncrypterOpts(sm2.MarshalUncompressed, sm2.C1C2C3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for format, ciphertext := range map[CiphertextFormat][]byte{
+		CiphertextC1C3C2: plain,
+		CiphertextC1C2C3: c1c2c3,
+		CiphertextASN1:   asn1,
+	} {
+		t.Run("ciphertext-"+string(format), func(t *testing.T) {
+			session, err := ks.NewDecryptSessionFormat(ciphertext, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t2, err := crypto.CoopDecryptCiphertext(d2Inv, ciphertext, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := session.FinishCiphertext(t2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Fatalf("plaintext = %q, want %q", got, plaintext)
+			}
+			// 两种模式的 T2 不能混用
+			if _, err := session.Finish(t2); !errors.Is(err, ErrDecryption) {
+				t.Errorf("got %v, want %v", err, ErrDecryption)
+			}
+		})
+	}
+
+	// C3 校验失败
+	tampered := append([]byte(nil), plain...)
+	tampered[len(tampered)-1] ^= 1
+	session, err := ks.NewDecryptSession(tampered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t2, _ := crypto.CoopDecrypt(d2Inv, session.T1())
+	if _, err := session.Finish(t2); !errors.Is(err, ErrDecryption) {
+		t.Errorf("got %v, want %v", err, ErrDecryption)
+	}
+}
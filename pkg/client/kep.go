@@ -0,0 +1,187 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/subtle"
+	"errors"
+	"math/big"
+
+	"filippo.io/bigmod"
+	"github.com/emmansun/gmsm/sm2"
+
+	"github.com/sm2-cosign/backend/internal/crypto"
+)
+
+var (
+	ErrKeyExchange     = errors.New("client: key exchange failed")
+	ErrKeyConfirmation = errors.New("client: key exchange confirmation mismatch")
+)
+
+// KeyExchangeSession 单次协同 SM2 密钥交换的客户端状态，不可复用
+// 流程：K1() 提交服务端 → SetEphemeral 保存返回的 R 并发给对端 →
+// 收到对端公钥与临时公钥后 Prepare 得到 W → 提交服务端 → Finish
+type KeyExchangeSession struct {
+	key       *KeyShare
+	z         []byte
+	initiator bool
+	k1        *bigmod.Nat
+	k1Point   []byte
+
+	r      []byte // 本方临时公钥 R = d2Inv*K1
+	peerZ  []byte
+	peerR  []byte
+	uX, uY *big.Int // U = P_B + x̄_B*R_B
+	v      []byte   // 共享点 V
+}
+
+// NewKeyExchangeSession 以本方身份 uid 创建密钥交换会话，uid 为空时使用默认用户标识
+// initiator 为 true 表示本方为发起方 (A)，决定 Z 与临时公钥在 KDF 与确认值中的顺序
+func (k *KeyShare) NewKeyExchangeSession(uid []byte, initiator bool) (*KeyExchangeSession, error) {
+	if k.pa == nil {
+		return nil, ErrPublicKeyMismatch
+	}
+	if len(uid) == 0 {
+		uid = DefaultUID
+	}
+	z, err := sm2.CalculateZA(k.pa, uid)
+	if err != nil {
+		return nil, err
+	}
+	k1, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+	x, y := curve.ScalarBaseMult(scalarBytes(k1))
+	return &KeyExchangeSession{key: k, z: z, initiator: initiator, k1: k1, k1Point: pointBytes(x, y)}, nil
+}
+
+// K1 返回 64 字节 K1 = k1*G
+func (s *KeyExchangeSession) K1() []byte {
+	return s.k1Point
+}
+
+// SetEphemeral 保存服务端返回的本方临时公钥 R
+func (s *KeyExchangeSession) SetEphemeral(r []byte) error {
+	if _, _, err := parsePoint(r); err != nil {
+		return err
+	}
+	s.r = append([]byte(nil), r...)
+	return nil
+}
+
+// Ephemeral 返回 64 字节本方临时公钥 R，发送给对端
+func (s *KeyExchangeSession) Ephemeral() []byte {
+	return s.r
+}
+
+// Prepare 由对端公钥、身份与临时公钥计算 W = (d1 + x̄*k1)*U
+func (s *KeyExchangeSession) Prepare(peerPub *ecdsa.PublicKey, peerUID, peerR []byte) ([]byte, error) {
+	if s.r == nil {
+		return nil, ErrKeyExchange
+	}
+	if peerPub == nil || !curve.IsOnCurve(peerPub.X, peerPub.Y) {
+		return nil, ErrInvalidPoint
+	}
+	rX, rY, err := parsePoint(peerR)
+	if err != nil {
+		return nil, err
+	}
+	if len(peerUID) == 0 {
+		peerUID = DefaultUID
+	}
+	peerZ, err := sm2.CalculateZA(peerPub, peerUID)
+	if err != nil {
+		return nil, err
+	}
+
+	// U = P_B + x̄_B*R_B 为公开值
+	uX, uY := curve.ScalarMult(rX, rY, crypto.KeyExchangeReduce(rX).Bytes())
+	uX, uY = curve.Add(peerPub.X, peerPub.Y, uX, uY)
+	if uX.Sign() == 0 && uY.Sign() == 0 {
+		return nil, ErrKeyExchange
+	}
+
+	// coef = d1 + x̄*k1 mod n
+	xBar := crypto.KeyExchangeReduce(new(big.Int).SetBytes(s.r[:crypto.ScalarSize]))
+	coef, err := parseScalar(xBar.FillBytes(make([]byte, crypto.ScalarSize)))
+	if err != nil {
+		return nil, err
+	}
+	coef.Mul(s.k1, orderModulus).Add(s.key.d1, orderModulus)
+	if coef.IsZero() == 1 {
+		return nil, ErrKeyExchange
+	}
+
+	wX, wY := curve.ScalarMult(uX, uY, scalarBytes(coef))
+	s.peerZ = peerZ
+	s.peerR = append([]byte(nil), peerR...)
+	s.uX, s.uY = uX, uY
+	return pointBytes(wX, wY), nil
+}
+
+// Finish 由服务端返回的 T2 = d2Inv*W 计算共享点 V = T2 - U
+func (s *KeyExchangeSession) Finish(t2 []byte) error {
+	if s.uX == nil {
+		return ErrKeyExchange
+	}
+	tX, tY, err := parsePoint(t2)
+	if err != nil {
+		return ErrKeyExchange
+	}
+	vX, vY := curve.Add(tX, tY, s.uX, new(big.Int).Sub(curve.Params().P, s.uY))
+	if vX.Sign() == 0 && vY.Sign() == 0 {
+		return ErrKeyExchange
+	}
+	s.v = pointBytes(vX, vY)
+	return nil
+}
+
+// order 按发起方在前的顺序返回 Z 与临时公钥
+func (s *KeyExchangeSession) order() (zA, zB, rA, rB []byte) {
+	if s.initiator {
+		return s.z, s.peerZ, s.r, s.peerR
+	}
+	return s.peerZ, s.z, s.peerR, s.r
+}
+
+// Key 派生 keyLen 字节共享密钥，须在 Finish 之后调用
+func (s *KeyExchangeSession) Key(keyLen int) ([]byte, error) {
+	if s.v == nil {
+		return nil, ErrKeyExchange
+	}
+	zA, zB, _, _ := s.order()
+	return crypto.KeyExchangeKey(s.v, zA, zB, keyLen), nil
+}
+
+// Confirmation 返回本方确认值 (发起方 S_A，响应方 S_B)，发送给对端
+func (s *KeyExchangeSession) Confirmation() ([]byte, error) {
+	prefix := crypto.KeyExchangeConfirmResponder
+	if s.initiator {
+		prefix = crypto.KeyExchangeConfirmInitiator
+	}
+	return s.confirmation(prefix)
+}
+
+// VerifyConfirmation 校验对端确认值
+func (s *KeyExchangeSession) VerifyConfirmation(peer []byte) error {
+	prefix := crypto.KeyExchangeConfirmInitiator
+	if s.initiator {
+		prefix = crypto.KeyExchangeConfirmResponder
+	}
+	want, err := s.confirmation(prefix)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(want, peer) != 1 {
+		return ErrKeyConfirmation
+	}
+	return nil
+}
+
+func (s *KeyExchangeSession) confirmation(prefix byte) ([]byte, error) {
+	if s.v == nil {
+		return nil, ErrKeyExchange
+	}
+	zA, zB, rA, rB := s.order()
+	return crypto.KeyExchangeConfirmation(prefix, s.v, zA, zB, rA, rB), nil
+}
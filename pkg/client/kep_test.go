@@ -0,0 +1,157 @@
+package client
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/emmansun/gmsm/sm2"
+
+	"github.com/sm2-cosign/backend/internal/crypto"
+)
+
+func toPublicKey(t *testing.T, p []byte) *ecdsa.PublicKey {
+	t.Helper()
+	pub, err := sm2.NewPublicKey(append([]byte{0x04}, p...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub
+}
+
+// exchange 以服务端协同运算完成本方的密钥交换计算
+func exchange(t *testing.T, session *KeyExchangeSession, d2Inv []byte, peerPub *ecdsa.PublicKey, peerUID, peerR []byte) {
+	t.Helper()
+	w, err := session.Prepare(peerPub, peerUID, peerR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t2, err := crypto.CoopKeyExchange(d2Inv, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Finish(t2); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func initSession(t *testing.T, ks *KeyShare, d2Inv, uid []byte, initiator bool) *KeyExchangeSession {
+	t.Helper()
+	session, err := ks.NewKeyExchangeSession(uid, initiator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := crypto.CoopKeyExchangeInit(d2Inv, session.K1())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.SetEphemeral(r); err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+func TestKeyExchange(t *testing.T) {
+	const keyLen = 32
+	ks, d2Inv := newTestKey(t)
+	uid, peerUID := []byte("alice"), []byte("bob")
+	peerKey, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("initiator", func(t *testing.T) {
+		peer, err := sm2.NewKeyExchange(peerKey, ks.PublicKey(), peerUID, uid, keyLen, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		session := initSession(t, ks, d2Inv, uid, true)
+		rB, sB, err := peer.RepondKeyExchange(rand.Reader, toPublicKey(t, session.Ephemeral()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		exchange(t, session, d2Inv, &peerKey.PublicKey, peerUID, pointBytes(rB.X, rB.Y))
+
+		if err := session.VerifyConfirmation(sB); err != nil {
+			t.Fatal(err)
+		}
+		sA, _ := session.Confirmation()
+		peerShared, err := peer.ConfirmInitiator(sA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, _ := session.Key(keyLen)
+		if !bytes.Equal(key, peerShared) {
+			t.Fatalf("shared key mismatch: %x != %x", key, peerShared)
+		}
+	})
+
+	t.Run("responder", func(t *testing.T) {
+		peer, err := sm2.NewKeyExchange(peerKey, ks.PublicKey(), peerUID, uid, keyLen, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rA, err := peer.InitKeyExchange(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		session := initSession(t, ks, d2Inv, uid, false)
+		exchange(t, session, d2Inv, &peerKey.PublicKey, peerUID, pointBytes(rA.X, rA.Y))
+
+		sB, _ := session.Confirmation()
+		peerShared, sA, err := peer.ConfirmResponder(toPublicKey(t, session.Ephemeral()), sB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := session.VerifyConfirmation(sA); err != nil {
+			t.Fatal(err)
+		}
+		key, _ := session.Key(keyLen)
+		if !bytes.Equal(key, peerShared) {
+			t.Fatalf("shared key mismatch: %x != %x", key, peerShared)
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		peer, err := sm2.NewKeyExchange(peerKey, ks.PublicKey(), peerUID, uid, keyLen, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		session := initSession(t, ks, d2Inv, uid, true)
+		rB, sB, err := peer.RepondKeyExchange(rand.Reader, toPublicKey(t, session.Ephemeral()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := session.Prepare(&peerKey.PublicKey, peerUID, pointBytes(rB.X, rB.Y))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 服务端使用错误的 d2Inv
+		_, other := newTestKey(t)
+		t2, err := crypto.CoopKeyExchange(other, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := session.Finish(t2); err != nil {
+			t.Fatal(err)
+		}
+		if err := session.VerifyConfirmation(sB); !errors.Is(err, ErrKeyConfirmation) {
+			t.Errorf("got %v, want %v", err, ErrKeyConfirmation)
+		}
+	})
+
+	t.Run("order", func(t *testing.T) {
+		session, err := ks.NewKeyExchangeSession(uid, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := session.Prepare(&peerKey.PublicKey, peerUID, session.K1()); !errors.Is(err, ErrKeyExchange) {
+			t.Errorf("Prepare before SetEphemeral: got %v, want %v", err, ErrKeyExchange)
+		}
+		if _, err := session.Key(keyLen); !errors.Is(err, ErrKeyExchange) {
+			t.Errorf("Key before Finish: got %v, want %v", err, ErrKeyExchange)
+		}
+	})
+}
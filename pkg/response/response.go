@@ -25,6 +25,15 @@ const (
 	CodeInternalError   Code = 10011
 	CodeUnauthorized    Code = 10012
 	CodeForbidden       Code = 10013
+	CodeUnavailable     Code = 10014
+	CodeInvalidProof    Code = 10015
+	CodeRefreshConflict Code = 10016
+	CodeNotExportable   Code = 10017
+	CodeKeyExpired      Code = 10018
+	CodeKeyNotYetValid  Code = 10019
+	CodeKeyArchived     Code = 10020
+	CodePurgeNotAllowed Code = 10021
+	CodeInvalidCSR      Code = 10022
 )
 
 // 错误码消息映射
@@ -43,6 +52,15 @@ var codeMessages = map[Code]string{
 	CodeInternalError:   "内部错误",
 	CodeUnauthorized:    "未授权",
 	CodeForbidden:       "禁止访问",
+	CodeUnavailable:     "服务不可用",
+	CodeInvalidProof:    "零知识证明验证失败",
+	CodeRefreshConflict: "密钥刷新已失效",
+	CodeNotExportable:   "私钥分量不可导出",
+	CodeKeyExpired:      "密钥已过期",
+	CodeKeyNotYetValid:  "密钥尚未生效",
+	CodeKeyArchived:     "密钥已归档",
+	CodePurgeNotAllowed: "未归档或未超过清除宽限期",
+	CodeInvalidCSR:      "证书请求无效或与当前密钥不匹配",
 }
 
 // Response 统一响应结构
@@ -94,6 +112,15 @@ func ErrorWithData(c *fiber.Ctx, code Code, data interface{}) error {
 	})
 }
 
+// ErrorWithStatus 指定 HTTP 状态码的错误响应（用于探针等依赖状态码的场景）
+func ErrorWithStatus(c *fiber.Ctx, status int, code Code, data interface{}) error {
+	return c.Status(status).JSON(Response{
+		Code:    code,
+		Message: GetMessage(code),
+		Data:    data,
+	})
+}
+
 // GetMessage 获取错误码对应的消息
 func GetMessage(code Code) string {
 	if msg, ok := codeMessages[code]; ok {